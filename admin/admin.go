@@ -0,0 +1,337 @@
+// Package admin serves a small admin page for a cache.Cache, in the spirit of /debug/pprof,
+// showing its size, hit rate and largest keys, with controls to delete a key or flush it.
+//
+// Routes, relative to wherever the handler is mounted:
+//
+//	GET  /           the admin page
+//	GET  /dashboard  charts of the cache's usage over time and a searchable key table
+//	GET  /history    the samples behind the dashboard's charts as JSON
+//	GET  /keys       metadata of keys containing ?q=, up to ?n= of them (default 100), as JSON
+//	GET  /top        the largest keys as JSON, ?n= of them (default 20), or the most read with ?by=hits
+//	GET  /snapshot   downloads cache.Snapshot as JSON, metadata only, no values
+//	POST /delete     deletes the key in the "key" form value, or every key starting with "prefix"
+//	POST /flush      clears the cache
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultPath is where Register mounts the handler.
+const DefaultPath = "/cachez/"
+
+// DefaultTopKeys is how many keys are listed when no ?n= is given.
+const DefaultTopKeys = 20
+
+// Handler serves the admin page for a cache.
+type Handler struct {
+	cache   *cache.Cache
+	mux     *http.ServeMux
+	history *history
+
+	authorize func(r *http.Request) bool
+	challenge string // WWW-Authenticate header sent with 401s
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithAuth only allows requests for which authorize returns true. Other requests get a 401.
+//
+// Without it the admin handler is open to anyone who can reach it, including its delete
+// and flush endpoints, so only leave it off behind something that authenticates already.
+func WithAuth(authorize func(r *http.Request) bool) Option {
+	return func(h *Handler) {
+		h.authorize = authorize
+		h.challenge = "Bearer"
+	}
+}
+
+// WithBearerToken only allows requests with an "Authorization: Bearer <token>" header, e.g. from cachectl.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(r *http.Request) bool {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	})
+}
+
+// WithBasicAuth only allows requests with the given HTTP basic auth credentials,
+// which browsers prompt for.
+func WithBasicAuth(username, password string) Option {
+	return func(h *Handler) {
+		h.authorize = func(r *http.Request) bool {
+			u, p, ok := r.BasicAuth()
+			userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+			return ok && userOK && passOK
+		}
+		h.challenge = `Basic realm="cachez", charset="UTF-8"`
+	}
+}
+
+// WithHistory sets how often the dashboard samples the cache's stats and how many samples
+// it keeps, by default every 10 seconds for an hour.
+func WithHistory(interval time.Duration, samples int) Option {
+	return func(h *Handler) {
+		if interval > 0 {
+			h.history.interval = interval
+		}
+		if samples > 0 {
+			h.history.max = samples
+		}
+	}
+}
+
+// NewHandler creates a Handler for c. Mount it under a prefix with http.StripPrefix,
+// or use Register.
+//
+// The delete and flush endpoints reject cross-origin browser requests, so another site
+// can't trigger them from an operator's browser.
+//
+// The handler samples c's stats in the background for the dashboard until it's closed.
+func NewHandler(c *cache.Cache, opts ...Option) *Handler {
+	h := &Handler{
+		cache:   c,
+		mux:     http.NewServeMux(),
+		history: newHistory(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	csrf := http.NewCrossOriginProtection()
+
+	h.mux.HandleFunc("GET /{$}", h.index)
+	h.mux.HandleFunc("GET /dashboard", h.dashboard)
+	h.mux.HandleFunc("GET /history", h.historyJSON)
+	h.mux.HandleFunc("GET /keys", h.keys)
+	h.mux.HandleFunc("GET /top", h.top)
+	h.mux.HandleFunc("GET /snapshot", h.snapshot)
+	h.mux.Handle("POST /delete", csrf.Handler(http.HandlerFunc(h.delete)))
+	h.mux.Handle("POST /flush", csrf.Handler(http.HandlerFunc(h.flush)))
+
+	go h.history.run(c)
+
+	return h
+}
+
+// Register mounts a Handler for c on mux at DefaultPath, and returns it so it can be closed.
+func Register(mux *http.ServeMux, c *cache.Cache, opts ...Option) *Handler {
+	h := NewHandler(c, opts...)
+	mux.Handle(DefaultPath, http.StripPrefix(DefaultPath[:len(DefaultPath)-1], h))
+	return h
+}
+
+// Close stops sampling stats for the dashboard.
+func (h *Handler) Close() error {
+	h.history.stop()
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize != nil && !h.authorize(r) {
+		w.Header().Set("WWW-Authenticate", h.challenge)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// KeySize is a key and the estimated size of its value, as listed by /top.
+type KeySize struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// KeyHits is a key and how many times it's been read, as listed by /top?by=hits.
+type KeyHits struct {
+	Key  string `json:"key"`
+	Hits int64  `json:"hits"`
+}
+
+// topKeys returns the n largest keys, largest first, and the n most read, most read first.
+func (h *Handler) topKeys(n int) ([]KeySize, []KeyHits) {
+	top := h.cache.TopKeys(n)
+
+	largest := make([]KeySize, len(top.Largest))
+	for i, m := range top.Largest {
+		largest[i] = KeySize{Key: m.Key, Size: int64(len(m.Key)) + m.Size}
+	}
+	hottest := make([]KeyHits, len(top.Hottest))
+	for i, m := range top.Hottest {
+		hottest[i] = KeyHits{Key: m.Key, Hits: m.Hits}
+	}
+	return largest, hottest
+}
+
+func topKeysParam(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		return DefaultTopKeys
+	}
+	return n
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Stats    cache.Stats
+		HitRate  string
+		TopKeys  []KeySize
+		Hottest  []KeyHits
+		Rendered time.Time
+	}{
+		Stats:    h.cache.Stats(),
+		Rendered: time.Now(),
+	}
+	data.TopKeys, data.Hottest = h.topKeys(topKeysParam(r))
+	data.HitRate = strconv.FormatFloat(data.Stats.HitRate()*100, 'f', 1, 64) + "%"
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, data); err != nil {
+		log.Printf("admin: rendering page: %v", err)
+	}
+}
+
+func (h *Handler) top(w http.ResponseWriter, r *http.Request) {
+	largest, hottest := h.topKeys(topKeysParam(r))
+	if r.URL.Query().Get("by") == "hits" {
+		writeJSON(w, hottest)
+		return
+	}
+	writeJSON(w, largest)
+}
+
+func (h *Handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	filename := "cache-snapshot-" + time.Now().UTC().Format("20060102T150405Z") + ".json"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := h.cache.WriteSnapshot(w); err != nil {
+		log.Printf("admin: writing snapshot: %v", err)
+	}
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	key, prefix := r.FormValue("key"), r.FormValue("prefix")
+
+	switch {
+	case key != "":
+		h.cache.Delete(key)
+		log.Printf("admin: deleted %q", key)
+	case prefix != "":
+		n := h.cache.DeletePrefix(prefix)
+		log.Printf("admin: deleted %d keys with prefix %q", n, prefix)
+	default:
+		http.Error(w, "missing key or prefix", http.StatusBadRequest)
+		return
+	}
+
+	backToIndex(w)
+}
+
+func (h *Handler) flush(w http.ResponseWriter, r *http.Request) {
+	h.cache.Clear()
+	backToIndex(w)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("admin: encoding response: %v", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// backToIndex redirects a form post back to the admin page. The location is relative
+// since the handler doesn't know the prefix it's mounted under.
+func backToIndex(w http.ResponseWriter) {
+	w.Header().Set("Location", "./")
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cachez</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 2px 12px 2px 0; }
+td.num { text-align: right; font-family: monospace; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>cachez</h1>
+<p>Rendered {{.Rendered.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Stats</h2>
+<table>
+<tr><th>Items</th><td class="num">{{.Stats.Items}}</td></tr>
+<tr><th>Size</th><td class="num">{{.Stats.Size}} / {{.Stats.MaxSize}} bytes</td></tr>
+<tr><th>Hits</th><td class="num">{{.Stats.Hits}}</td></tr>
+<tr><th>Misses</th><td class="num">{{.Stats.Misses}}</td></tr>
+<tr><th>Hit rate</th><td class="num">{{.HitRate}}</td></tr>
+<tr><th>Clears</th><td class="num">{{.Stats.Clears}}</td></tr>
+</table>
+
+{{if .Stats.Groups}}
+<h2>Groups</h2>
+<table>
+<tr><th>Group</th><th>Items</th><th>Size</th><th>Hits</th><th>Misses</th></tr>
+{{range $name, $g := .Stats.Groups}}
+<tr><td>{{$name}}</td><td class="num">{{$g.Items}}</td><td class="num">{{$g.Size}}</td><td class="num">{{$g.Hits}}</td><td class="num">{{$g.Misses}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+<h2>Largest keys</h2>
+<table>
+<tr><th>Key</th><th>Size</th><th></th></tr>
+{{range .TopKeys}}
+<tr>
+<td>{{.Key}}</td><td class="num">{{.Size}}</td>
+<td><form method="post" action="delete"><input type="hidden" name="key" value="{{.Key}}"><button>Delete</button></form></td>
+</tr>
+{{else}}
+<tr><td colspan="3">The cache is empty.</td></tr>
+{{end}}
+</table>
+
+<h2>Most read keys</h2>
+<table>
+<tr><th>Key</th><th>Hits</th><th></th></tr>
+{{range .Hottest}}
+<tr>
+<td>{{.Key}}</td><td class="num">{{.Hits}}</td>
+<td><form method="post" action="delete"><input type="hidden" name="key" value="{{.Key}}"><button>Delete</button></form></td>
+</tr>
+{{else}}
+<tr><td colspan="3">The cache is empty.</td></tr>
+{{end}}
+</table>
+
+<h2>Controls</h2>
+<form method="post" action="delete"><input name="key" placeholder="key"> <button>Delete key</button></form>
+<form method="post" action="delete"><input name="prefix" placeholder="prefix, e.g. users:"> <button>Delete prefix</button></form>
+<form method="post" action="flush" onsubmit="return confirm('Flush the whole cache?')"><button>Flush cache</button></form>
+<p><a href="dashboard">Dashboard</a> &middot; <a href="snapshot">Download snapshot</a> (key metadata only, no values)</p>
+</body>
+</html>
+`))
@@ -0,0 +1,246 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestMain(m *testing.M) {
+	// Deletes are logged.
+	log.SetOutput(io.Discard)
+	m.Run()
+}
+
+func newHandler(t *testing.T, opts ...Option) (*Handler, *cache.Cache) {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	h := NewHandler(c, opts...)
+	t.Cleanup(func() {
+		h.Close()
+		c.Close()
+	})
+	return h, c
+}
+
+// do sends a request to h. For a POST, form is sent as the body.
+func do(h http.Handler, method, path string, form url.Values, header ...string) *httptest.ResponseRecorder {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	r := httptest.NewRequest(method, path, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIndex(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("users:<1>", "value")
+
+	w := do(h, http.MethodGet, "/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET / returned %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "users:&lt;1&gt;") {
+		t.Errorf("the admin page doesn't list the key, escaped:\n%s", body)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	h, c := newHandler(t)
+	for _, key := range []string{"users:1", "users:2", "orders:1", "orders:2"} {
+		c.Set(key, "value")
+	}
+
+	w := do(h, http.MethodPost, "/delete", url.Values{"key": {"orders:1"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "./" {
+		t.Errorf("deleting a key returned %d to %q, want a 303 back to the page", w.Code, w.Header().Get("Location"))
+	}
+	if c.Has("orders:1") || !c.Has("orders:2") {
+		t.Error("deleting a key didn't delete just that key")
+	}
+
+	do(h, http.MethodPost, "/delete", url.Values{"prefix": {"users:"}})
+	if got := c.Keys(); len(got) != 1 || got[0] != "orders:2" {
+		t.Errorf("deleting the prefix users: left %v", got)
+	}
+
+	if w := do(h, http.MethodPost, "/delete", url.Values{}); w.Code != http.StatusBadRequest {
+		t.Errorf("deleting without a key or prefix returned %d, want 400", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/delete?key=orders:2", nil); w.Code != http.StatusMethodNotAllowed || !c.Has("orders:2") {
+		t.Errorf("a GET to /delete returned %d", w.Code)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("a", "1")
+	c.Set("b", "2")
+
+	if w := do(h, http.MethodPost, "/flush", url.Values{}); w.Code != http.StatusSeeOther {
+		t.Fatalf("flushing returned %d, want 303", w.Code)
+	}
+	if len(c.Keys()) != 0 {
+		t.Errorf("flushing left %v", c.Keys())
+	}
+}
+
+func TestCrossOriginRequestsAreRejected(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("a", "1")
+
+	for _, header := range [][]string{
+		{"Sec-Fetch-Site", "cross-site"},
+		{"Origin", "https://attacker.example"},
+	} {
+		for _, path := range []string{"/flush", "/delete"} {
+			if w := do(h, http.MethodPost, path, url.Values{"key": {"a"}}, header...); w.Code != http.StatusForbidden {
+				t.Errorf("POST %s with %v returned %d, want 403", path, header, w.Code)
+			}
+		}
+	}
+	if !c.Has("a") {
+		t.Fatal("a cross-origin request deleted the key")
+	}
+
+	// Requests from the admin page itself, and from non-browser clients, are allowed.
+	w := do(h, http.MethodPost, "/delete", url.Values{"key": {"a"}}, "Sec-Fetch-Site", "same-origin")
+	if w.Code != http.StatusSeeOther || c.Has("a") {
+		t.Errorf("a same-origin delete returned %d", w.Code)
+	}
+	c.Set("a", "1")
+	if w := do(h, http.MethodPost, "/flush", url.Values{}); w.Code != http.StatusSeeOther || c.Has("a") {
+		t.Errorf("a flush without browser headers returned %d", w.Code)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       Option
+		challenge string
+		good, bad [][]string
+	}{
+		{
+			name:      "bearer",
+			opt:       WithBearerToken("secret"),
+			challenge: "Bearer",
+			good:      [][]string{{"Authorization", "Bearer secret"}},
+			bad:       [][]string{nil, {"Authorization", "Bearer wrong"}, {"Authorization", "secret"}},
+		},
+		{
+			name:      "basic",
+			opt:       WithBasicAuth("admin", "secret"),
+			challenge: `Basic realm="cachez", charset="UTF-8"`,
+			good:      [][]string{{"Authorization", "Basic YWRtaW46c2VjcmV0"}},
+			bad:       [][]string{nil, {"Authorization", "Basic YWRtaW46d3Jvbmc="}, {"Authorization", "Bearer secret"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, c := newHandler(t, tt.opt)
+			c.Set("a", "1")
+
+			for _, header := range tt.bad {
+				for _, req := range []struct{ method, path string }{{http.MethodGet, "/"}, {http.MethodPost, "/flush"}} {
+					var form url.Values
+					if req.method == http.MethodPost {
+						form = url.Values{}
+					}
+					w := do(h, req.method, req.path, form, header...)
+					if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != tt.challenge {
+						t.Errorf("%s %s with %v returned %d, WWW-Authenticate %q, want 401, %q",
+							req.method, req.path, header, w.Code, w.Header().Get("WWW-Authenticate"), tt.challenge)
+					}
+				}
+			}
+			if !c.Has("a") {
+				t.Fatal("an unauthorized flush cleared the cache")
+			}
+
+			for _, header := range tt.good {
+				if w := do(h, http.MethodGet, "/", nil, header...); w.Code != http.StatusOK {
+					t.Errorf("GET / with %v returned %d, want 200", header, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestTopAndKeys(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("small", "v")
+	c.Set("large", strings.Repeat("v", 1000))
+	c.Set("other", "v")
+	c.Get("other")
+	c.Get("other")
+
+	var largest []KeySize
+	if err := json.Unmarshal(do(h, http.MethodGet, "/top?n=1", nil).Body.Bytes(), &largest); err != nil {
+		t.Fatal(err)
+	}
+	if len(largest) != 1 || largest[0].Key != "large" {
+		t.Errorf("/top?n=1 returned %v, want the large key", largest)
+	}
+
+	var hottest []KeyHits
+	if err := json.Unmarshal(do(h, http.MethodGet, "/top?n=1&by=hits", nil).Body.Bytes(), &hottest); err != nil {
+		t.Fatal(err)
+	}
+	if len(hottest) != 1 || hottest[0].Key != "other" || hottest[0].Hits != 2 {
+		t.Errorf("/top?n=1&by=hits returned %v, want other with 2 hits", hottest)
+	}
+
+	var keys struct {
+		Total int              `json:"total"`
+		Keys  []cache.Metadata `json:"keys"`
+	}
+	if err := json.Unmarshal(do(h, http.MethodGet, "/keys?q=l&n=1", nil).Body.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if keys.Total != 2 || len(keys.Keys) != 1 || keys.Keys[0].Key != "large" {
+		t.Errorf("/keys?q=l&n=1 returned %+v, want 2 matches with only large listed", keys)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	mux := http.NewServeMux()
+	h := Register(mux, c)
+	defer h.Close()
+	c.Set("a", "1")
+
+	if w := do(mux, http.MethodGet, DefaultPath, nil); w.Code != http.StatusOK {
+		t.Errorf("GET %s returned %d", DefaultPath, w.Code)
+	}
+	w := do(mux, http.MethodGet, DefaultPath+"snapshot", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("GET %ssnapshot returned %d, Content-Disposition %q", DefaultPath, w.Code, w.Header().Get("Content-Disposition"))
+	}
+	var snapshot cache.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(snapshot.Items, func(m cache.Metadata) bool { return m.Key == "a" }) {
+		t.Errorf("the snapshot doesn't include the key: %+v", snapshot.Items)
+	}
+}
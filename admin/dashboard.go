@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"cmp"
+	_ "embed"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// Dashboard history defaults, see WithHistory.
+const (
+	DefaultHistoryInterval = 10 * time.Second
+	DefaultHistorySamples  = 360
+)
+
+// DefaultKeysLimit is how many keys /keys returns when no ?n= is given.
+const DefaultKeysLimit = 100
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// Sample is the cache's stats at a point in time, as charted by the dashboard.
+type Sample struct {
+	Time    time.Time `json:"time"`
+	Items   int       `json:"items"`
+	Size    int64     `json:"size"`
+	MaxSize int64     `json:"max_size"`
+	Hits    int64     `json:"hits"`
+	Misses  int64     `json:"misses"`
+	Clears  int64     `json:"clears"`
+}
+
+// history keeps the last max samples of a cache's stats.
+type history struct {
+	interval time.Duration
+	max      int
+
+	mu      sync.Mutex
+	samples []Sample
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newHistory() *history {
+	return &history{
+		interval: DefaultHistoryInterval,
+		max:      DefaultHistorySamples,
+		done:     make(chan struct{}),
+	}
+}
+
+func (hist *history) run(c *cache.Cache) {
+	hist.record(c)
+
+	ticker := time.NewTicker(hist.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hist.record(c)
+		case <-hist.done:
+			return
+		}
+	}
+}
+
+func takeSample(c *cache.Cache) Sample {
+	stats := c.Stats()
+	return Sample{
+		Time:    time.Now(),
+		Items:   stats.Items,
+		Size:    stats.Size,
+		MaxSize: stats.MaxSize,
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		Clears:  stats.Clears,
+	}
+}
+
+func (hist *history) record(c *cache.Cache) {
+	s := takeSample(c)
+
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	hist.samples = append(hist.samples, s)
+	if len(hist.samples) > hist.max {
+		hist.samples = slices.Delete(hist.samples, 0, len(hist.samples)-hist.max)
+	}
+}
+
+func (hist *history) stop() {
+	hist.stopOnce.Do(func() { close(hist.done) })
+}
+
+func (hist *history) copy() []Sample {
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	return slices.Clone(hist.samples)
+}
+
+func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// historyJSON serves the recorded samples followed by one taken now, so the charts are
+// up to date however long ago the last sample was.
+func (h *Handler) historyJSON(w http.ResponseWriter, r *http.Request) {
+	samples := append(h.history.copy(), takeSample(h.cache))
+
+	writeJSON(w, struct {
+		Interval time.Duration `json:"interval"`
+		Samples  []Sample      `json:"samples"`
+	}{h.history.interval, samples})
+}
+
+// keys serves the metadata of the first ?n= keys, in order, containing ?q=.
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		n = DefaultKeysLimit
+	}
+
+	matches := []cache.Metadata{}
+	for _, m := range h.cache.Snapshot().Items {
+		if strings.Contains(m.Key, q) {
+			matches = append(matches, m)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b cache.Metadata) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	writeJSON(w, struct {
+		Total int              `json:"total"`
+		Keys  []cache.Metadata `json:"keys"`
+	}{len(matches), matches[:min(n, len(matches))]})
+}
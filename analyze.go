@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultKeyDelimiters are the characters Analyze splits keys at, unless the cache was
+	// created WithKeyspaceAnalysis.
+	DefaultKeyDelimiters = ":"
+
+	// maxTrackedPrefixes caps how many prefixes WithKeyspaceAnalysis counts hits and misses for,
+	// so keys with unbounded prefixes can't grow it without limit.
+	maxTrackedPrefixes = 1024
+)
+
+// PrefixStats is the usage of every key sharing a prefix, see Analyze.
+type PrefixStats struct {
+	// Prefix is empty for keys without a delimiter.
+	Prefix string `json:"prefix"`
+
+	Items int   `json:"items"`
+	Size  int64 `json:"size"`
+
+	// Hits and Misses count reads of keys with the prefix since the cache was created, if it was
+	// created WithKeyspaceAnalysis. Otherwise, Hits is how many times its current items have been
+	// read since they were set, and Misses is always 0.
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// HitRate returns the fraction of lookups of keys with the prefix that were hits.
+func (s PrefixStats) HitRate() float64 {
+	return hitRate(s.Hits, s.Misses)
+}
+
+// WithKeyspaceAnalysis sets how Analyze groups keys, by their first depth segments split at any
+// of delimiters, and counts hits and misses for each prefix as keys are read, so Analyze can
+// report hit rates. Most caches only need Analyze's defaults, e.g. for keys like "users:123",
+// WithKeyspaceAnalysis(":", 1) groups them under "users:".
+//
+// Only the first 1024 prefixes seen are counted, so a keyspace without any real structure
+// doesn't use up memory.
+func WithKeyspaceAnalysis(delimiters string, depth int) Option {
+	return func(c *Cache) {
+		if depth < 1 {
+			c.logf("ignoring keyspace analysis depth %d, it must be at least 1", depth)
+			return
+		}
+		c.keyspace = &keyspaceTracker{delimiters: delimiters, depth: depth}
+	}
+}
+
+// keyspaceTracker counts hits and misses by prefix for WithKeyspaceAnalysis.
+type keyspaceTracker struct {
+	delimiters string
+	depth      int
+
+	prefixes sync.Map // prefix to *prefixCounts
+	tracked  atomic.Int32
+}
+
+// empty returns a tracker like k with no prefixes counted.
+func (k *keyspaceTracker) empty() *keyspaceTracker {
+	return &keyspaceTracker{delimiters: k.delimiters, depth: k.depth}
+}
+
+type prefixCounts struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// record counts a lookup of key. It's called while c.mu is only read locked.
+func (t *keyspaceTracker) record(key string, hit bool) {
+	prefix := keyPrefix(key, t.delimiters, t.depth)
+
+	counts, ok := t.prefixes.Load(prefix)
+	if !ok {
+		if t.tracked.Load() >= maxTrackedPrefixes {
+			return
+		}
+		var loaded bool
+		if counts, loaded = t.prefixes.LoadOrStore(prefix, &prefixCounts{}); !loaded {
+			t.tracked.Add(1)
+		}
+	}
+
+	if hit {
+		counts.(*prefixCounts).hits.Add(1)
+	} else {
+		counts.(*prefixCounts).misses.Add(1)
+	}
+}
+
+// keyPrefix returns key up to and including its depth'th delimiter, or its last one if it has
+// fewer, or "" if it has none.
+func keyPrefix(key, delimiters string, depth int) string {
+	end := 0
+	for range depth {
+		i := strings.IndexAny(key[end:], delimiters)
+		if i < 0 {
+			break
+		}
+		end += i + 1
+	}
+	return key[:end]
+}
+
+// Analyze breaks the cache down by key prefix, so it's clear which part of an application is
+// using up the budget. Keys are split at the delimiters set WithKeyspaceAnalysis, or at the
+// first ":" by default, e.g. "users:123" and "users:456" are both under "users:".
+//
+// The prefixes are ordered by size, largest first.
+func (c *Cache) Analyze() []PrefixStats {
+	delimiters, depth := DefaultKeyDelimiters, 1
+	if c.keyspace != nil {
+		delimiters, depth = c.keyspace.delimiters, c.keyspace.depth
+	}
+
+	c.mu.RLock()
+	now := c.now()
+	byPrefix := make(map[string]*PrefixStats)
+	for key, i := range c.items {
+		e := c.arena.entry(i)
+		if e.expired(now) {
+			continue
+		}
+
+		prefix := keyPrefix(key, delimiters, depth)
+		s, ok := byPrefix[prefix]
+		if !ok {
+			s = &PrefixStats{Prefix: prefix}
+			byPrefix[prefix] = s
+		}
+		s.Items++
+		s.Size += int64(len(key)) + e.size
+		if c.keyspace == nil {
+			s.Hits += atomic.LoadInt64(&e.hits)
+		}
+	}
+	c.mu.RUnlock()
+
+	if c.keyspace != nil {
+		c.keyspace.prefixes.Range(func(prefix, counts any) bool {
+			s, ok := byPrefix[prefix.(string)]
+			if !ok {
+				s = &PrefixStats{Prefix: prefix.(string)}
+				byPrefix[s.Prefix] = s
+			}
+			s.Hits = counts.(*prefixCounts).hits.Load()
+			s.Misses = counts.(*prefixCounts).misses.Load()
+			return true
+		})
+	}
+
+	stats := make([]PrefixStats, 0, len(byPrefix))
+	for _, s := range byPrefix {
+		stats = append(stats, *s)
+	}
+	slices.SortFunc(stats, func(a, b PrefixStats) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Prefix, b.Prefix))
+	})
+	return stats
+}
@@ -0,0 +1,66 @@
+package cache
+
+// arena stores every item's metadata in one pointer-free slice, with values in a parallel
+// slice, both indexed by the slot the items map points to. With millions of items the
+// garbage collector only sees a few large objects instead of one per item, and never has
+// to scan the metadata since it holds no pointers.
+//
+// Slots of deleted items are reused. Pointers returned by entry are only valid until the
+// next add, which may grow the slices.
+type arena struct {
+	entries []entry
+	values  []any
+	free    []uint32
+}
+
+// add stores a new item and returns its slot.
+func (a *arena) add(value any, e entry) uint32 {
+	if n := len(a.free); n > 0 {
+		i := a.free[n-1]
+		a.free = a.free[:n-1]
+		a.entries[i] = e
+		a.values[i] = value
+		return i
+	}
+
+	a.entries = append(a.entries, e)
+	a.values = append(a.values, value)
+	return uint32(len(a.entries) - 1)
+}
+
+// replace overwrites the item in slot i.
+func (a *arena) replace(i uint32, value any, e entry) {
+	a.entries[i] = e
+	a.values[i] = value
+}
+
+func (a *arena) entry(i uint32) *entry {
+	return &a.entries[i]
+}
+
+func (a *arena) value(i uint32) any {
+	return a.values[i]
+}
+
+// release frees slot i for reuse, dropping its value so it can be garbage collected.
+func (a *arena) release(i uint32) {
+	a.entries[i] = entry{}
+	a.values[i] = nil
+	a.free = append(a.free, i)
+}
+
+// clone returns a copy of the arena with every item's hits reset.
+func (a *arena) clone() arena {
+	clone := arena{
+		entries: make([]entry, len(a.entries)),
+		values:  make([]any, len(a.values)),
+		free:    make([]uint32, len(a.free)),
+	}
+	copy(clone.values, a.values)
+	copy(clone.free, a.free)
+	for i, e := range a.entries {
+		e.hits = 0
+		clone.entries[i] = e
+	}
+	return clone
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultAsyncQueueSize is how many writes SetAsync queues unless WithAsyncQueue says otherwise.
+const DefaultAsyncQueueSize = 1024
+
+// WithAsyncQueue sets how many writes SetAsync can queue before it starts dropping them.
+func WithAsyncQueue(size int) Option {
+	return func(c *Cache) {
+		c.asyncQueueSize = size
+	}
+}
+
+// asyncWrite is a write queued by SetAsync, or if flushed is set, a marker that FlushAsync is
+// waiting for the writes queued before it.
+type asyncWrite struct {
+	key       string
+	value     any
+	expiresAt int64
+	flushed   chan struct{}
+}
+
+// SetAsync queues an item to be added to the cache, with the default TTL, by a background
+// writer, so latency-critical code never waits on the cache's lock, on compression or on
+// eviction. The writer is started on first use.
+//
+// If the queue is full, the write is dropped rather than waiting for room: SetAsync returns
+// false and the drop is counted in Stats.AsyncDropped, and a later Get just misses. It also
+// returns false once the cache is closed. Queued writes are applied in order, but a Set or
+// Delete of the same key may be applied before an earlier SetAsync, and a Get straight after
+// SetAsync may not see it yet. Close applies every queued write before closing.
+func (c *Cache) SetAsync(key string, value any) bool {
+	if c.closed.Load() {
+		return false
+	}
+	c.asyncOnce.Do(c.startAsyncWriter)
+
+	select {
+	case c.async <- asyncWrite{key: key, value: value, expiresAt: c.expiresAt(time.Duration(c.defaultTTL.Load()))}:
+		return true
+	default:
+		c.asyncDropped.Add(1)
+		return false
+	}
+}
+
+func (c *Cache) startAsyncWriter() {
+	size := c.asyncQueueSize
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	c.async = make(chan asyncWrite, size)
+	go c.writeAsync()
+}
+
+func (c *Cache) writeAsync() {
+	for {
+		select {
+		case w := <-c.async:
+			if w.flushed != nil {
+				close(w.flushed)
+				continue
+			}
+			if err := c.write(context.Background(), w.key, w.value, w.expiresAt); err != nil {
+				c.logf("error applying async write of %q: %v", w.key, err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// FlushAsync waits until every write queued by SetAsync before it was called has been applied.
+func (c *Cache) FlushAsync() {
+	if c.closed.Load() {
+		return
+	}
+	c.asyncOnce.Do(c.startAsyncWriter)
+
+	flushed := make(chan struct{})
+	select {
+	case c.async <- asyncWrite{flushed: flushed}:
+	case <-c.done:
+		return
+	}
+	select {
+	case <-flushed:
+	case <-c.done:
+	}
+}
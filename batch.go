@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// BatchLoaderFunc loads the values for several keys that are missing from the cache at once,
+// e.g. with a single SQL IN query. Keys that don't exist in the origin are left out of the map.
+type BatchLoaderFunc func(ctx context.Context, keys []string) (map[string]any, error)
+
+// WithBatchLoader loads missing keys like WithLoader, but collects the misses that happen within
+// window of each other, up to maxKeys of them (0 for no limit), and loads them with a single call
+// to loader. Each batch waits up to window for more keys, so keep it to a few milliseconds.
+//
+// Everything else works as it does WithLoader: concurrent misses for the same key share a load,
+// and timeouts, retries and negative caching apply to each key, so a key that fails is retried
+// in a later batch. A key missing from the map loader returns is treated as ErrNotFound. The
+// timeout from WithLoadTimeout applies to each call to loader.
+func WithBatchLoader(loader BatchLoaderFunc, window time.Duration, maxKeys int) Option {
+	return func(c *Cache) {
+		b := &batcher{c: c, load: loader, window: window, maxKeys: maxKeys}
+		c.loader = b.loadOne
+	}
+}
+
+// batcher collects keys for WithBatchLoader.
+type batcher struct {
+	c       *Cache
+	load    BatchLoaderFunc
+	window  time.Duration
+	maxKeys int
+
+	mu      sync.Mutex
+	pending *batch // collecting keys, or nil
+}
+
+// batch is a set of keys that are loaded together. values and err are set before done is closed.
+type batch struct {
+	keys    []string
+	waiting map[string]bool
+	full    chan struct{} // closed when it has maxKeys keys
+	done    chan struct{}
+
+	values map[string]any
+	err    error
+}
+
+// loadOne is the LoaderFunc for WithBatchLoader. It adds key to the pending batch, starting
+// one if there isn't one, and waits for the batch to be loaded.
+func (b *batcher) loadOne(ctx context.Context, key string) (any, error) {
+	b.mu.Lock()
+	p := b.pending
+	if p == nil {
+		p = &batch{waiting: make(map[string]bool), full: make(chan struct{}), done: make(chan struct{})}
+		b.pending = p
+		go b.run(p)
+	}
+	if !p.waiting[key] {
+		p.waiting[key] = true
+		p.keys = append(p.keys, key)
+	}
+	if b.maxKeys > 0 && len(p.keys) >= b.maxKeys {
+		b.pending = nil
+		close(p.full)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	value, ok := p.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// run waits for the batch to fill up or its window to pass, then loads it.
+func (b *batcher) run(p *batch) {
+	timer := b.c.newTimer(b.window)
+	select {
+	case <-timer.C():
+	case <-p.full:
+		timer.Stop()
+	case <-b.c.done:
+		timer.Stop()
+	}
+
+	b.mu.Lock()
+	if b.pending == p {
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	ctx := context.Background()
+	if b.c.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.c.loadTimeout)
+		defer cancel()
+	}
+
+	if b.c.closed.Load() {
+		p.err = ErrClosed
+	} else {
+		p.values, p.err = b.loadBatch(ctx, p.keys)
+	}
+	close(p.done)
+}
+
+// loadBatch calls the BatchLoaderFunc. It runs on run's goroutine, where nothing else could
+// recover a panic, so a panic is returned to every key in the batch as an error instead.
+func (b *batcher) loadBatch(ctx context.Context, keys []string) (values map[string]any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			values, err = nil, fmt.Errorf("%w: %v\n\n%s", ErrLoaderPanicked, r, debug.Stack())
+		}
+	}()
+	return b.load(ctx, keys)
+}
+
+// GetMany retrieves several items at once, loading the missing ones concurrently if the cache
+// has a loader, so WithBatchLoader loads them all in one batch. Keys that aren't cached and
+// couldn't be loaded are left out of the map, and the errors loading them, apart from
+// ErrNotFound, are returned together.
+func (c *Cache) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	values := make(map[string]any, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if value, found, refresh := c.get(key, c.loader != nil); found {
+			values[key] = value
+			if refresh {
+				c.refresh(key, c.loader)
+			}
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 || c.loader == nil {
+		return values, nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, key := range missing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := c.load(ctx, key, c.loader, false)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				values[key] = value
+			case !errors.Is(err, ErrNotFound):
+				errs = append(errs, fmt.Errorf("cache: loading %q: %w", key, err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return values, errors.Join(errs...)
+}
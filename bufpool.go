@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// WithBufferRelease calls release with every []byte value that leaves the cache, whether
+// it's replaced, deleted, evicted, or dropped when the cache clears, so byte caches with a
+// lot of churn can reuse their buffers instead of allocating new ones for every Set.
+//
+// release is called with the cache locked and must not call back into it. Once a value has
+// been released it may be overwritten at any time, so callers must be done with any []byte
+// they got from Get before it can leave the cache. That rules out sharing values with a
+// Clone or with WithWriteBehind, which hold on to them after they leave.
+func WithBufferRelease(release func([]byte)) Option {
+	return func(c *Cache) {
+		c.release = release
+	}
+}
+
+// WithBufferPool returns []byte values that leave the cache to pool, see WithBufferRelease.
+func WithBufferPool(pool *BufferPool) Option {
+	return WithBufferRelease(pool.Put)
+}
+
+// BufferPool is a pool of []byte buffers to pass to WithBufferPool. The zero value is ready to use.
+type BufferPool struct {
+	pool sync.Pool
+}
+
+// Get returns a buffer of length n, reusing a pooled one if it's big enough.
+func (p *BufferPool) Get(n int) []byte {
+	if b, ok := p.pool.Get().(*[]byte); ok && cap(*b) >= n {
+		return (*b)[:n]
+	}
+	return make([]byte, n)
+}
+
+// Put adds b to the pool. b must not be used afterwards.
+func (p *BufferPool) Put(b []byte) {
+	if cap(b) == 0 {
+		return
+	}
+	b = b[:0]
+	p.pool.Put(&b)
+}
+
+// released hands value to the cache's release func if it's a []byte. c.mu must already be locked.
+func (c *Cache) released(value any) {
+	if c.release == nil {
+		return
+	}
+	if b, ok := value.([]byte); ok {
+		c.release(b)
+	}
+}
+
+// sameBuffer reports whether a and b are both []byte backed by the same array, so setting a
+// key to the buffer it already holds doesn't release it.
+func sameBuffer(a, b any) bool {
+	x, ok := a.([]byte)
+	if !ok {
+		return false
+	}
+	y, ok := b.([]byte)
+	return ok && cap(x) > 0 && unsafe.SliceData(x) == unsafe.SliceData(y)
+}
@@ -1,36 +1,552 @@
 package cache
 
 import (
+	"context"
 	"log"
+	"maps"
+	"math"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/radovskyb/self-clearing-in-memory-cache/internal/singleflight"
 )
 
+// NamespaceSeparator separates a key's namespace from the rest of the key, e.g. "users:42".
+const NamespaceSeparator = ":"
+
 // Cache is a simple in-memory cache. Safe for concurrent use and rotates when maxCacheSize is hit.
 type Cache struct {
-	mu             sync.RWMutex
-	items          map[string]any
+	mu contendedMutex
+
+	// items, arena and the size are read by every lookup but only written under c.mu, so
+	// they're kept off the cache lines of the lock and the hit and miss counters, which
+	// every lookup writes.
+	_              [cacheLineSize]byte
+	items          map[string]uint32 // slots in arena
+	arena          arena
 	totalCacheSize int64
-	maxCacheSize   int64
+
+	hits   paddedInt64
+	misses paddedInt64
+	clears int64
+	groups []*group
+
+	config
+
+	subscribers      []subscriber
+	nextSubscriberID int
+	bus              *invalidationBus
+
+	// defaultTTL is a time.Duration. It's atomic since it's read before c.mu is locked.
+	defaultTTL atomic.Int64
+
+	flight     singleflight.Group
+	refreshing sync.Map
+	negative   map[string]*negativeEntry
+
+	storeLocks  [storeLockStripes]sync.Mutex
+	writeBehind *writeBehind
+
+	evictor   chan struct{}
+	evictions int64
+	paused    atomic.Int32 // see PauseEviction
+
+	// generationSize is the size of the current generation. Reads add to it while c.mu is only
+	// read locked, when they promote an item. See WithGenerations.
+	generation     uint32
+	generationSize atomic.Int64
+	rotations      int64
+	idleEvictions  int64
+
+	expiry      *expiryHeap
+	expirations int64
+	janitorBeat atomic.Int64 // when the janitor last woke up, see WithJanitor
+
+	heap     *heapMonitor
+	pressure *pressureMonitor
+
+	queued      queuedPolicy // see EvictSieve and EvictS3FIFO
+	recorder    *TraceRecorder
+	rng         *lockedRand // see WithDeterministic
+	keyspace    *keyspaceTracker
+	doorkeeper  *doorkeeper
+	sketch      *frequencySketch
+	notAdmitted atomic.Int64
+
+	spilled     *spillCounts
+	corruptions atomic.Int64
+
+	hot        atomic.Pointer[hotKeys] // see WithHotKeyReplication
+	hotSamples hotSampler
+	hotTuner   hotTuner
+
+	asyncOnce    sync.Once
+	async        chan asyncWrite // see SetAsync
+	asyncDropped atomic.Int64
+
+	closed atomic.Bool
+	done   chan struct{} // closed by Close
+}
+
+// config is what options and the Set methods in settings.go configure, apart from defaultTTL,
+// which is atomic. It's kept apart from the cache's state so that Clone can copy all of it at
+// once. Anything an option sets up that has state of its own, like a monitor or a filter, goes
+// in Cache instead, and Clone gives the clone its own.
+type config struct {
+	maxCacheSize int64
+
+	ttlJitter    float64
+	maxStale     time.Duration
+	refreshAhead float64
+
+	// earlyExpiration is XFetch's beta, see WithEarlyExpiration.
+	earlyExpiration float64
+
+	loader      LoaderFunc
+	loadTimeout time.Duration
+	retry       RetryPolicy
+	negativeTTL time.Duration
+
+	store Store
+
+	softLimit     int64
+	deferEviction bool
+	overflow      OverflowPolicy
+	idleTimeout   time.Duration
+
+	retainHot         int
+	retainHotFraction float64
+
+	generational    bool
+	janitorInterval time.Duration
+
+	hotKeys         int // see WithHotKeyReplication
+	hotKeyInterval  time.Duration
+	hotKeysAdaptive bool
+
+	release func([]byte)
+
+	logger         *log.Logger
+	evictionPolicy EvictionPolicy
+	onEvict        func(key string, value any)
+	topKeys        int // see WithTopKeys
+	drain          func(key string, value any)
+
+	clock Clock
+
+	codec          Codec
+	compressAbove  int
+	coldCodec      Codec
+	coldAfter      time.Duration
+	keys           *KeyRing
+	serializer     Serializer
+	spillDir       string
+	spillAbove     int
+	checksums      ChecksumMode
+	schedules      []clearSchedule
+	copyOnSet      Copier
+	copyOnGet      Copier
+	asyncQueueSize int
+
+	snapshotOnClose string
+	seedFile        string
 }
 
 // New creates a new in-memory cache.
-func New(maxCacheSize int64) *Cache {
-	return &Cache{
-		maxCacheSize: maxCacheSize,
-		items:        make(map[string]any),
+func New(maxCacheSize int64, opts ...Option) *Cache {
+	c := &Cache{
+		config: config{maxCacheSize: maxCacheSize},
+		items:  make(map[string]uint32),
+		done:   make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.loadSeedFile()
+	c.startSchedules()
+	c.startIdleSweeper()
+	c.startColdSweeper()
+	c.startJanitor()
+	c.startHotKeys()
+
+	return c
+}
+
+// entry is the bookkeeping needed to size and expire a single cached value. The value itself
+// is kept separately in the arena so that entry has no pointers.
+type entry struct {
+	size int64 // estimated size of the value, not including the key
+
+	// createdAt and expiresAt are in unix nanoseconds. An expiresAt of 0 means the entry never expires.
+	createdAt int64
+	expiresAt int64
+
+	// hits and accessedAt are updated while c.mu is only read locked, so they're accessed atomically.
+	// accessedAt is only kept up to date by reads WithIdleTimeout or WithColdCompression.
+	hits       int64
+	accessedAt int64
+
+	// loadTime is how long the value took to load, in nanoseconds, for WithEarlyExpiration.
+	// It's 0 for values that were set rather than loaded.
+	loadTime int64
+
+	sum uint32 // checksum of the value, see WithChecksums
+
+	// expiryIndex is the entry's position in the expiry heap plus 1, or 0 if it isn't in it.
+	// See WithJanitor.
+	expiryIndex uint32
+
+	// generation is the generation the entry is in, see WithGenerations. Reads update it while
+	// c.mu is only read locked, so it's accessed atomically.
+	generation uint32
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.expiresAt > 0 && now >= e.expiresAt
+}
+
+// dueForRefresh reports whether fraction of the entry's lifetime has passed.
+func (e *entry) dueForRefresh(now int64, fraction float64) bool {
+	if e.expiresAt == 0 || fraction <= 0 {
+		return false
+	}
+	return now >= e.createdAt+int64(float64(e.expiresAt-e.createdAt)*fraction)
+}
+
+// dueForEarlyExpiration reports whether XFetch picks this read to refresh the entry early. U is
+// uniform in (0, 1], so -ln(U) is an exponentially distributed head start, scaled by how long
+// the entry took to load.
+func (e *entry) dueForEarlyExpiration(now int64, beta float64, random func() float64) bool {
+	if e.expiresAt == 0 || e.loadTime == 0 || beta <= 0 {
+		return false
+	}
+	return float64(now)-float64(e.loadTime)*beta*math.Log(1-random()) >= float64(e.expiresAt)
+}
+
+// Clone returns an independent copy of the cache with the same configuration and items.
+//
+// Only the map is copied, values themselves are shared, so mutating a pointer or slice
+// value retrieved from the clone will still be visible in the original.
+// Stats, including per item hits, start from 0 in the clone, and so do the doorkeeper, the
+// frequency sketch and keyspace analysis. The clone shares the original's loader, store and
+// write-behind queue, but not its subscribers, invalidation bus, trace recorder or snapshot
+// on close, which would otherwise see both caches' changes mixed together.
+func (c *Cache) Clone() *Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clone := &Cache{
+		config:         c.config,
+		totalCacheSize: c.totalCacheSize,
+		items:          maps.Clone(c.items),
+		arena:          c.arena.clone(),
+		generation:     c.generation,
+		writeBehind:    c.writeBehind,
+		done:           make(chan struct{}),
+	}
+	clone.snapshotOnClose = ""
+	clone.schedules = slices.Clone(c.schedules)
+
+	clone.defaultTTL.Store(c.defaultTTL.Load())
+	clone.generationSize.Store(c.generationSize.Load())
+
+	for _, g := range c.groups {
+		clone.groups = append(clone.groups, &group{name: g.name, match: g.match})
 	}
+	if c.negative != nil {
+		clone.negative = maps.Clone(c.negative)
+	}
+
+	// Whatever has state of its own starts again from the clone's items.
+	if c.spilled != nil {
+		clone.shareSpills()
+	}
+	if c.writeBehind != nil {
+		c.writeBehind.refs.Add(1)
+	}
+	if c.expiry != nil {
+		clone.expiry = &expiryHeap{nodes: slices.Clone(c.expiry.nodes), arena: &clone.arena}
+	}
+	if c.queued != nil {
+		clone.requeue()
+	}
+	if c.rng != nil {
+		clone.rng = newLockedRand(c.rng.seed)
+	}
+	if c.keyspace != nil {
+		clone.keyspace = c.keyspace.empty()
+	}
+	if c.doorkeeper != nil {
+		clone.doorkeeper = c.doorkeeper.empty()
+	}
+	if c.sketch != nil {
+		clone.sketch = c.sketch.empty()
+	}
+	if c.heap != nil {
+		clone.heap = newHeapMonitor(clone, c.heap.limit)
+		go clone.heap.run()
+	}
+	if c.pressure != nil {
+		clone.pressure = newPressureMonitor(clone, c.pressure.source, c.pressure.threshold)
+		go clone.pressure.run()
+	}
+	if c.evictor != nil {
+		clone.startEvictor()
+	}
+
+	clone.startSchedules()
+	clone.startIdleSweeper()
+	clone.startColdSweeper()
+	clone.startJanitor()
+	clone.startHotKeys()
+
+	return clone
 }
 
 // Get retrieves an item from the cache.
+//
+// If the cache was created WithLoader, missing items are loaded and cached before returning.
 func (c *Cache) Get(key string) (any, bool) {
+	value, found, refresh := c.get(key, c.loader != nil)
+	if refresh {
+		c.refresh(key, c.loader)
+	}
+	if found || c.loader == nil {
+		return value, found
+	}
+	return c.getOrLoad(key)
+}
+
+// get retrieves an item from the cache without loading it.
+//
+// If canRefresh is true, refresh is set when the caller should reload the item in the background:
+// either it expired less than maxStale ago (WithStaleWhileRevalidate) and is being returned anyway,
+// or it's far enough through its TTL to be reloaded early (WithRefreshAhead).
+//
+// Get is on the hot path of most callers, so a hit doesn't allocate.
+func (c *Cache) get(key string, canRefresh bool) (value any, found, refresh bool) {
+	value, found = c.getHot(key)
+	var expired bool
+	if !found {
+		value, found, refresh, expired = c.getRLocked(key, canRefresh)
+	}
+	if expired {
+		c.reclaim(key)
+	}
+
+	if found && c.transformsOutput() {
+		value, found = c.output(key, value)
+	}
+	return value, found, refresh
+}
+
+// getRLocked is getLocked with c.mu read locked around it, deferring the unlock so that a
+// panic in getLocked can't leave the cache locked for good.
+func (c *Cache) getRLocked(key string, canRefresh bool) (value any, found, refresh, expired bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	item, found := c.items[key]
-	return item, found
+
+	return c.getLocked(key, canRefresh)
+}
+
+// getLocked is get for callers that have already read locked c.mu.
+//
+// Expired entries are treated as missing. If they're past their stale window too, expired is
+// set, and the caller should reclaim the key once it's unlocked c.mu.
+func (c *Cache) getLocked(key string, canRefresh bool) (value any, found, refresh, expired bool) {
+	i, e := c.lookup(key)
+	found = e != nil
+
+	if found {
+		now := c.now()
+
+		switch {
+		case e.expired(now):
+			found = canRefresh && c.maxStale > 0 && now < e.expiresAt+int64(c.maxStale)
+			refresh = found
+			expired = e.reclaimable(now, c.maxStale)
+		case canRefresh:
+			refresh = e.dueForRefresh(now, c.refreshAhead) || e.dueForEarlyExpiration(now, c.earlyExpiration, c.random)
+		}
+	}
+
+	if found && c.checksums != ChecksumOff {
+		found = c.verify(key, i, e)
+	}
+
+	c.recordAccess(key, found)
+	if c.recorder != nil {
+		var size int64
+		if found {
+			size = e.size
+		}
+		c.recorder.record(TraceGet, key, size)
+	}
+
+	if !found {
+		return nil, false, false, expired
+	}
+	atomic.AddInt64(&e.hits, 1)
+	if c.tracksAccess() {
+		atomic.StoreInt64(&e.accessedAt, c.now())
+	}
+	if c.hotKeys > 0 {
+		c.sampleHotKey(key)
+	}
+	if c.generational {
+		c.promote(key, e)
+	}
+	if c.queued != nil {
+		c.queued.visit(i)
+	}
+	return c.arena.value(i), true, refresh, false
+}
+
+// GetBytes is like Get, but only returns []byte values, including a *Chunked copied into a
+// single slice. Other values are reported as missing.
+func (c *Cache) GetBytes(key string) ([]byte, bool) {
+	value, found := c.Get(key)
+	if v, ok := value.(*Chunked); ok {
+		return v.Bytes(), found
+	}
+	b, ok := value.([]byte)
+	return b, found && ok
+}
+
+// GetString is like Get, but only returns string values. Other values are reported as missing.
+func (c *Cache) GetString(key string) (string, bool) {
+	value, found := c.Get(key)
+	s, ok := value.(string)
+	return s, found && ok
+}
+
+// GetAs is like Get, but only returns values of type T. Other values are reported as missing.
+func GetAs[T any](c *Cache, key string) (T, bool) {
+	value, found := c.Get(key)
+	v, ok := value.(T)
+	return v, found && ok
+}
+
+// Has reports whether key is in the cache without counting towards hit or miss stats.
+func (c *Cache) Has(key string) bool {
+	_, found := c.peek(key)
+	return found
+}
+
+// peek retrieves an item without counting towards hit or miss stats.
+func (c *Cache) peek(key string) (any, bool) {
+	c.mu.RLock()
+	i, e := c.lookup(key)
+	if e == nil || e.expired(c.now()) {
+		expired := e != nil && e.reclaimable(c.now(), c.maxStale)
+		c.mu.RUnlock()
+
+		if expired {
+			c.reclaim(key)
+		}
+		return nil, false
+	}
+	value := c.arena.value(i)
+	c.mu.RUnlock()
+
+	if c.transformsOutput() {
+		return c.output(key, value)
+	}
+	return value, true
+}
+
+// lookup returns key's slot and entry, or a nil entry if it isn't in the cache. c.mu must
+// already be locked.
+func (c *Cache) lookup(key string) (uint32, *entry) {
+	i, found := c.items[key]
+	if !found {
+		return 0, nil
+	}
+	return i, c.arena.entry(i)
+}
+
+// Keys returns the keys of every item in the cache, in no particular order.
+func (c *Cache) Keys() []string {
+	c.mu.RLock()
+
+	now := c.now()
+
+	var expired []string
+	keys := make([]string, 0, len(c.items))
+	for key, i := range c.all() {
+		switch e := c.arena.entry(i); {
+		case !e.expired(now):
+			keys = append(keys, key)
+		case e.reclaimable(now, c.maxStale):
+			expired = append(expired, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.reclaim(expired...)
+	}
+	return keys
+}
+
+// Item is a copy of a single cached item, as passed to Range.
+type Item struct {
+	Key   string
+	Value any
+
+	// Size is the estimated size of Value, not including the key.
+	Size int64
+
+	// ExpiresAt is zero if the item never expires.
+	ExpiresAt time.Time
+}
+
+// Range calls fn for every item in the cache, in no particular order, until fn returns false.
+//
+// The cache is read locked while Range runs, so fn must be quick and must not modify the cache.
+func (c *Cache) Range(fn func(item Item) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+
+	for key, i := range c.all() {
+		e := c.arena.entry(i)
+		if e.expired(now) {
+			continue
+		}
+
+		value, ok := c.output(key, c.arena.value(i))
+		if !ok {
+			continue
+		}
+
+		item := Item{Key: key, Value: value, Size: e.size}
+		if e.expiresAt > 0 {
+			item.ExpiresAt = time.Unix(0, e.expiresAt)
+		}
+		if !fn(item) {
+			return
+		}
+	}
+}
+
+// Sizer can be implemented by values that know their own size, which is then used
+// instead of the cache's estimate.
+type Sizer interface {
+	Size() int64
 }
 
 func estimateItemSize(value any) int64 {
+	if s, ok := value.(Sizer); ok {
+		return s.Size()
+	}
+
 	v := reflect.ValueOf(value)
 
 	switch v.Kind() {
@@ -47,46 +563,282 @@ func estimateItemSize(value any) int64 {
 }
 
 // Set adds an item to the cache, replacing any existing item.
+//
+// If the cache was created WithDefaultTTL, the item expires after the default TTL.
+// If it was created WithWriteThrough, the item is written to the store first,
+// or if it was created WithWriteBehind, it's queued to be written to the store.
 func (c *Cache) Set(key string, value any) {
+	c.write(context.Background(), key, value, c.expiresAt(time.Duration(c.defaultTTL.Load())))
+}
+
+// setLocal adds an item to the cache only, without writing it through to the store. loaded
+// is as for set.
+func (c *Cache) setLocal(key string, value any, expiresAt int64, loaded bool) error {
+	stored, err := c.stored(key, value)
+	if err != nil {
+		c.logf("not caching %q: %v", key, err)
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.set(key, value, stored, expiresAt, loaded)
+}
+
+// set stores value under key. stored is value as it's kept in the arena, from c.stored,
+// which callers get before locking since it may compress or encrypt value. c.mu must already be locked.
+//
+// loaded is true for values that came from the origin rather than the application, from the
+// loader, a seed file or Warm. Other instances would load the same value, so they're not sent
+// an invalidation for it, which would only make them load it again.
+func (c *Cache) set(key string, value, stored any, expiresAt int64, loaded bool) error {
+	if c.closed.Load() {
+		c.dropSpill(stored)
+		return ErrClosed
+	}
+
 	keySize := int64(len(key))
-	newItemSize := estimateItemSize(value)
+	newItemSize := estimateItemSize(stored)
+
+	if c.overflow == OverflowReject && !c.evictionPaused() && !c.fits(key, newItemSize) {
+		c.logf("cache full (%d of %d bytes). rejecting write of %q", c.totalCacheSize, c.maxCacheSize, key)
+		c.dropSpill(stored)
+		return ErrCacheFull
+	}
+	if !c.admitsOverVictim(key, newItemSize) {
+		c.notAdmitted.Add(1)
+		c.dropSpill(stored)
+		return nil
+	}
+
+	now := c.now()
+	e := entry{
+		size:       newItemSize,
+		createdAt:  now,
+		expiresAt:  expiresAt,
+		accessedAt: now,
+	}
+	if c.checksums != ChecksumOff {
+		e.sum, _ = checksum(stored)
+	}
 
-	if oldValue, found := c.items[key]; found {
-		oldItemSize := estimateItemSize(oldValue)
-		c.totalCacheSize -= oldItemSize
+	i, found := c.items[key]
+	if found {
+		c.unreplicate(key)
+		prev := c.arena.entry(i)
+		c.totalCacheSize -= prev.size
+		c.leaveGeneration(key, prev)
+		e.expiryIndex = prev.expiryIndex
+		if old := c.arena.value(i); c.discards() && !sameBuffer(old, stored) {
+			c.discard(old)
+		}
+		c.arena.replace(i, stored, e)
+		if c.queued != nil {
+			c.queued.visit(i)
+		}
 	} else {
 		c.totalCacheSize += keySize
+		i = c.insert(key, stored, e)
 	}
+	c.expiryChanged(key, i)
+	c.joinGeneration(key, c.arena.entry(i))
 
 	c.totalCacheSize += newItemSize
 
-	c.items[key] = value
+	c.forgetError(key)
+
+	if c.recorder != nil {
+		c.recorder.record(TraceSet, key, newItemSize)
+	}
+
+	if loaded {
+		c.notifySubscribers(setEvent(key, value, &e))
+	} else {
+		c.notify(setEvent(key, value, &e))
+	}
 
 	c.checkCurrentSize()
+	return nil
+}
+
+// insert adds key, which mustn't be cached already, to the arena, items and the eviction
+// queues, and returns its slot. Every new item goes through it so the queues never miss one.
+// c.mu must already be locked.
+func (c *Cache) insert(key string, stored any, e entry) uint32 {
+	i := c.arena.add(stored, e)
+	c.items[key] = i
+	if c.queued != nil {
+		c.queued.add(key, i)
+	}
+	return i
 }
 
 // Delete removes an item from the cache and updates the size.
+//
+// If the cache was created WithWriteThrough or WithWriteBehind, the item is deleted from
+// the store too, the same way Set writes to it.
 func (c *Cache) Delete(key string) {
+	if c.closed.Load() {
+		return
+	}
+	if c.store != nil || c.writeBehind != nil {
+		c.deleteThrough(key)
+		return
+	}
+	c.deleteLocal(key)
+}
+
+// deleteLocal removes an item from the cache only.
+func (c *Cache) deleteLocal(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if oldValue, found := c.items[key]; found {
-		c.totalCacheSize -= int64(len(key))
-		c.totalCacheSize -= estimateItemSize(oldValue)
+	c.forgetError(key)
 
-		delete(c.items, key)
+	if c.recorder != nil {
+		c.recorder.record(TraceDelete, key, 0)
+	}
+
+	if i, found := c.items[key]; found {
+		c.remove(key, i)
+		c.notify(Event{Type: EventDelete, Key: key})
 		c.checkCurrentSize()
 	}
 }
 
+// discard is called with every stored value that leaves the cache. c.mu must already be locked.
+func (c *Cache) discard(stored any) {
+	c.dropSpill(stored)
+	c.released(stored)
+}
+
+// discards reports whether discard needs to be called at all.
+func (c *Cache) discards() bool {
+	return c.release != nil || c.spillDir != ""
+}
+
+// remove deletes key, stored in slot i, and subtracts its size. c.mu must already be locked.
+func (c *Cache) remove(key string, i uint32) {
+	c.unreplicate(key)
+	c.totalCacheSize -= int64(len(key))
+	c.totalCacheSize -= c.arena.entry(i).size
+	c.leaveGeneration(key, c.arena.entry(i))
+
+	delete(c.items, key)
+	c.discard(c.arena.value(i))
+	if c.expiry != nil {
+		c.expiry.remove(i)
+	}
+	if c.queued != nil {
+		c.queued.remove(i)
+	}
+	c.arena.release(i)
+}
+
+// Clear removes every item from the cache and resets the size to 0.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clear()
+	c.notify(Event{Type: EventClear})
+
+	c.logf("cache manually cleared. size reset to 0 bytes.")
+}
+
+// ClearNamespace removes every item whose key is prefixed with namespace followed by NamespaceSeparator.
+func (c *Cache) ClearNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.deletePrefix(namespace + NamespaceSeparator)
+
+	c.logf("cleared %d items from namespace %q. current cache size: %d bytes", removed, namespace, c.totalCacheSize)
+}
+
+// DeletePrefix removes every item whose key starts with prefix, e.g. to invalidate a family of
+// keys after a schema change, and returns how many were removed. Like ClearNamespace, it only
+// affects the cache, not the store.
+func (c *Cache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.deletePrefix(prefix)
+
+	c.logf("deleted %d items with prefix %q. current cache size: %d bytes", removed, prefix, c.totalCacheSize)
+	return removed
+}
+
+// deletePrefix removes every item and cached error whose key starts with prefix. c.mu must already be locked.
+func (c *Cache) deletePrefix(prefix string) int {
+	var removed int
+	for key, i := range c.all() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		c.remove(key, i)
+		c.notify(Event{Type: EventDelete, Key: key})
+		removed++
+	}
+
+	c.forgetErrors(prefix)
+
+	return removed
+}
+
+// clear drops every item. c.mu must already be locked.
+func (c *Cache) clear() {
+	if c.discards() {
+		for _, i := range c.items {
+			c.discard(c.arena.value(i))
+		}
+	}
+	c.reset()
+}
+
+// reset empties the cache without discarding its values, which the caller must already have
+// done. c.mu must already be locked.
+func (c *Cache) reset() {
+	c.items = make(map[string]uint32)
+	c.arena = arena{}
+	if c.expiry != nil {
+		c.expiry.reset()
+	}
+	if c.queued != nil {
+		c.queued.reset()
+	}
+	c.negative = nil
+	c.totalCacheSize = 0
+	c.generationSize.Store(0)
+	c.hot.Store(nil)
+}
+
 func (c *Cache) checkCurrentSize() {
-	log.Printf("current cache size: %d bytes", c.totalCacheSize)
+	c.logf("current cache size: %d bytes", c.totalCacheSize)
+
+	if c.evictionPaused() {
+		return
+	}
+
+	c.rotateIfFull()
+
+	if c.deferEviction {
+		// The background evictor does the work, see WithDeferredEviction.
+		c.signalEvictor()
+		return
+	}
+
+	if c.totalCacheSize > c.maxCacheSize && (c.evictor != nil || c.overflow == OverflowEvict) {
+		c.logf("cache size exceeded limit (%d bytes). evicting down to %d bytes...", c.totalCacheSize, c.evictTarget())
+		evicted := c.evict(c.evictTarget(), 0)
+		c.logf("evicted %d items. current cache size: %d bytes", evicted, c.totalCacheSize)
+		return
+	}
 
 	if c.totalCacheSize > c.maxCacheSize {
-		log.Printf("cache size exceeded limit (%d bytes). clearing...", c.totalCacheSize)
+		c.logf("cache size exceeded limit (%d bytes). clearing...", c.totalCacheSize)
 
 		// This is a good place if you want to chuck in some handling. (I've sent admin notifications here which works alright)
 		// You'd run this in a goroutine, since this would likely be a "long" running process.
@@ -95,9 +847,37 @@ func (c *Cache) checkCurrentSize() {
 		// Clear the cache
 		//
 		// No mutex is locked here since we're only calling this func where c.mu is already locked.
-		c.items = make(map[string]any)
-		c.totalCacheSize = 0
+		c.selfClear()
+	}
+
+	c.signalEvictor()
+}
+
+// selfClear clears the cache because it hit a limit, rather than because it was asked to.
+// c.mu must already be locked.
+func (c *Cache) selfClear() {
+	c.clears++
+
+	if c.retainsHot() {
+		kept := c.clearRetainingHot()
+		c.logf("cache successfully cleared, keeping the %d hottest items. size reset to %d bytes.", kept, c.totalCacheSize)
+		return
+	}
+
+	c.dropAllExcept(nil)
+	c.reset()
+
+	// Peers aren't told about size-triggered clears since their copies are still valid.
+	c.notifySubscribers(Event{Type: EventClear, Reason: ReasonEvicted})
+
+	c.logf("cache successfully cleared. size reset to 0 bytes.")
+}
 
-		log.Println("cache successfully cleared. size reset to 0 bytes.")
+// logf logs to the cache's logger, or the standard logger if it wasn't created WithLogger.
+func (c *Cache) logf(format string, args ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, args...)
+		return
 	}
+	log.Printf(format, args...)
 }
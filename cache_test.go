@@ -0,0 +1,157 @@
+package cache_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// configSection returns the config part of c's diagnostics.
+func configSection(t *testing.T, c *cache.Cache) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := c.DumpDiagnostics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	start, end := strings.Index(report, "config:"), strings.Index(report, "\nstats:")
+	if start < 0 || end < 0 {
+		t.Fatalf("no config in diagnostics:\n%s", report)
+	}
+	return report[start:end]
+}
+
+func TestCloneKeepsConfiguration(t *testing.T) {
+	var logs bytes.Buffer
+	c := cache.New(10_000,
+		cache.WithLogger(log.New(&logs, "", 0)),
+		cache.WithOverflowPolicy(cache.OverflowReject),
+		cache.WithEvictionPolicy(cache.EvictSieve),
+		cache.WithDefaultTTL(time.Hour),
+		cache.WithTTLJitter(0.1),
+		cache.WithStaleWhileRevalidate(time.Minute),
+		cache.WithIdleTimeout(time.Hour),
+		cache.WithJanitor(time.Minute),
+		cache.WithRetainHot(10),
+		cache.WithTopKeys(5),
+		cache.WithKeyspaceAnalysis(":", 2),
+		cache.WithDoorkeeper(1000, time.Hour),
+		cache.WithFrequencySketch(1000),
+		cache.WithChecksums(cache.ChecksumAlways),
+		cache.WithAsyncQueue(16),
+	)
+	defer c.Close()
+
+	for i := range 20 {
+		key := "key" + strconv.Itoa(i)
+		c.Set(key, "value")
+		c.Set(key, "value") // past the doorkeeper
+	}
+
+	clone := c.Clone()
+	defer clone.Close()
+
+	if got, want := configSection(t, clone), configSection(t, c); got != want {
+		t.Errorf("clone's config:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := clone.Stats().Items, c.Stats().Items; got != want {
+		t.Errorf("clone has %d items, want %d", got, want)
+	}
+	if err := clone.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs.Reset()
+	big := strings.Repeat("x", 9_900)
+	clone.SetE("big", big) // turned away by the doorkeeper
+	if err := clone.SetE("big", big); !errors.Is(err, cache.ErrCacheFull) {
+		t.Errorf("clone's SetE of an item that doesn't fit returned %v, want ErrCacheFull", err)
+	}
+	if clone.Stats().Items != c.Stats().Items || clone.Stats().Clears != 0 {
+		t.Error("clone cleared instead of rejecting")
+	}
+	if ttl, found := clone.TTL("key0"); !found || ttl <= 0 {
+		t.Errorf("clone lost key0's TTL: %s, %v", ttl, found)
+	}
+
+	clone.SetMaxCacheSize(100)
+	if stats := clone.Stats(); stats.Clears != 0 || stats.Evictions == 0 {
+		t.Errorf("clone cleared instead of evicting with its eviction policy: %+v", stats)
+	}
+	if logs.Len() == 0 {
+		t.Error("clone doesn't log to the original's logger")
+	}
+	if err := clone.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Stats().Items; got != 20 {
+		t.Errorf("evicting from the clone left %d items in the original, want 20", got)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := cache.New(1<<30, cache.WithLogger(nil))
+	defer c.Close()
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.Set(keys[i], "value")
+	}
+
+	b.Run("hit", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			c.Get(keys[i%len(keys)])
+		}
+	})
+	b.Run("miss", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			c.Get("missing")
+		}
+	})
+}
+
+func TestGetHitDoesNotAllocate(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	c.Set("key", "value")
+
+	if allocs := testing.AllocsPerRun(100, func() { c.Get("key") }); allocs != 0 {
+		t.Errorf("Get of a cached key made %v allocations, want 0", allocs)
+	}
+}
+
+// BenchmarkParallel reads and writes from every P at once, 1 write to every 9 reads. Compare
+// runs with and without -tags cachenopad, at -cpu values up to the machine's core count, to
+// see what padding the cache's counters is worth.
+func BenchmarkParallel(b *testing.B) {
+	c := cache.New(1<<30, cache.WithLogger(nil))
+	defer c.Close()
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.Set(keys[i], "value")
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				c.Set(key, "value")
+			} else {
+				c.Get(key)
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+	"unsafe"
+)
+
+// checksumSampleRate is how often ChecksumSample verifies an item: on its first read and then
+// every this many reads.
+const checksumSampleRate = 16
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumMode decides how often WithChecksums verifies values as they're read.
+type ChecksumMode int
+
+const (
+	// ChecksumOff doesn't checksum values. It's the default.
+	ChecksumOff ChecksumMode = iota
+
+	// ChecksumSample verifies an item on its first read and then every 16 reads, which catches
+	// corruption that persists without checksumming every read.
+	ChecksumSample
+
+	// ChecksumAlways verifies every read.
+	ChecksumAlways
+)
+
+func (m ChecksumMode) String() string {
+	switch m {
+	case ChecksumOff:
+		return "off"
+	case ChecksumSample:
+		return "sample"
+	case ChecksumAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+// MarshalText writes m as its name, e.g. "sample", so it reads well in config files.
+func (m ChecksumMode) MarshalText() ([]byte, error) {
+	if m.String() == "unknown" {
+		return nil, fmt.Errorf("cache: unknown checksum mode %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *ChecksumMode) UnmarshalText(text []byte) error {
+	for _, mode := range []ChecksumMode{ChecksumOff, ChecksumSample, ChecksumAlways} {
+		if string(text) == mode.String() {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("cache: unknown checksum mode %q", text)
+}
+
+// WithChecksums stores a CRC-32 of each value when it's set and verifies it when it's read,
+// as often as mode says. This detects values that were changed while cached, e.g. a []byte
+// that's still being written to by the caller, or a bug in a BufferPool or Codec.
+//
+// A value that fails verification is logged, reported as missing and dropped from the cache,
+// and counted in Stats.Corruptions. Only []byte and string values, or any value if the cache
+// was created WithSerializer, can be checksummed. Spilled values aren't checksummed.
+func WithChecksums(mode ChecksumMode) Option {
+	return func(c *Cache) {
+		c.checksums = mode
+	}
+}
+
+// checksum returns the checksum of stored, and false if it's a type that isn't checksummed.
+func checksum(stored any) (uint32, bool) {
+	switch v := stored.(type) {
+	case []byte:
+		return crc32.Checksum(v, castagnoli), true
+	case string:
+		return crc32.Checksum(unsafe.Slice(unsafe.StringData(v), len(v)), castagnoli), true
+	case *encodedValue:
+		if v.spill != nil {
+			return 0, false
+		}
+		return crc32.Checksum(v.data, castagnoli), true
+	}
+	return 0, false
+}
+
+// verify reports whether the value in slot i still matches its checksum, if this is one of the
+// reads that's checked. c.mu must be at least read locked.
+func (c *Cache) verify(key string, i uint32, e *entry) bool {
+	if c.checksums == ChecksumSample && atomic.LoadInt64(&e.hits)%checksumSampleRate != 0 {
+		return true
+	}
+
+	sum, ok := checksum(c.arena.value(i))
+	if !ok || sum == e.sum {
+		return true
+	}
+
+	c.corruptions.Add(1)
+	c.logf("checksum mismatch for %q (want %08x, got %08x). dropping it", key, e.sum, sum)
+
+	// c.mu may only be read locked, so the item's dropped separately, as long as it's still corrupt by then.
+	go c.dropCorrupt(key)
+	return false
+}
+
+func (c *Cache) dropCorrupt(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, e := c.lookup(key)
+	if e == nil {
+		return
+	}
+	if sum, ok := checksum(c.arena.value(i)); ok && sum != e.sum {
+		c.remove(key, i)
+		c.notifySubscribers(Event{Type: EventDelete, Key: key, Reason: ReasonCorrupted})
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ChunkSize is the size of the chunks Append stores values in.
+const ChunkSize = 64 << 10
+
+// Chunked is a []byte value built up by Append and stored as fixed-size chunks, so it can grow
+// without being copied and read in ranges with GetRange without materializing all of it, e.g.
+// for media segments. Get returns it as a *Chunked, which is an io.ReaderAt.
+//
+// A Chunked is never modified once it's been returned: each Append stores a new one sharing
+// all but the last chunk with the old one, so readers can keep using what they got.
+type Chunked struct {
+	chunks [][]byte // every chunk but the last is ChunkSize bytes
+	size   int64
+}
+
+// Len returns the value's length in bytes.
+func (v *Chunked) Len() int64 {
+	return v.size
+}
+
+// Size implements Sizer.
+func (v *Chunked) Size() int64 {
+	return v.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (v *Chunked) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("cache: negative offset %d", off)
+	}
+
+	n := 0
+	for n < len(p) && off < v.size {
+		chunk := v.chunks[off/ChunkSize][off%ChunkSize:]
+		copied := copy(p[n:], chunk)
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Bytes copies the whole value into a single slice.
+func (v *Chunked) Bytes() []byte {
+	b := make([]byte, v.size)
+	v.ReadAt(b, 0)
+	return b
+}
+
+// appended returns a new Chunked with data added to the end of v, which may be nil.
+func (v *Chunked) appended(data []byte) *Chunked {
+	var next Chunked
+	if v != nil {
+		next.chunks = append(next.chunks, v.chunks...)
+		next.size = v.size
+	}
+
+	for len(data) > 0 {
+		var tail []byte
+		if n := len(next.chunks); n > 0 && len(next.chunks[n-1]) < ChunkSize {
+			// The last chunk may be shared with v, so it's copied rather than appended to in place.
+			tail = next.chunks[n-1]
+			next.chunks = next.chunks[:n-1]
+		}
+
+		room := ChunkSize - len(tail)
+		take := min(room, len(data))
+
+		chunk := make([]byte, len(tail)+take, ChunkSize)
+		copy(chunk, tail)
+		copy(chunk[len(tail):], data[:take])
+
+		next.chunks = append(next.chunks, chunk)
+		next.size += int64(take)
+		data = data[take:]
+	}
+
+	return &next
+}
+
+// Append adds data to the end of the *Chunked stored under key, or stores a new one if key
+// isn't in the cache. It returns ErrWrongType if key holds any other value. An existing value
+// keeps its expiry, and a new one gets the default TTL.
+//
+// data is copied, so the caller can reuse it. Chunked values only live in the cache: they're
+// not written to the store, nor serialized, compressed, encrypted or spilled.
+func (c *Cache) Append(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var old *Chunked
+	expiresAt := c.expiresAt(time.Duration(c.defaultTTL.Load()))
+
+	if i, e := c.lookup(key); e != nil && !e.expired(c.now()) {
+		v, ok := c.arena.value(i).(*Chunked)
+		if !ok {
+			return fmt.Errorf("%w: %q isn't a *Chunked", ErrWrongType, key)
+		}
+		old, expiresAt = v, e.expiresAt
+	}
+
+	v := old.appended(data)
+	return c.set(key, v, v, expiresAt, false)
+}
+
+// GetRange returns up to n bytes of key's value starting at off, which can be a *Chunked built
+// by Append or a []byte. The result is shorter than n if the value ends first. Other values,
+// and offsets past the end, are reported as missing.
+//
+// For a *Chunked, only the chunks covering the range are read, into a new slice. For a []byte,
+// the result shares its memory.
+func (c *Cache) GetRange(key string, off, n int64) ([]byte, bool) {
+	value, found := c.Get(key)
+	if !found || off < 0 || n < 0 {
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case *Chunked:
+		if off > v.size {
+			return nil, false
+		}
+		b := make([]byte, min(n, v.size-off))
+		v.ReadAt(b, off)
+		return b, true
+	case []byte:
+		if off > int64(len(v)) {
+			return nil, false
+		}
+		return v[off : off+min(n, int64(len(v))-off)], true
+	}
+	return nil, false
+}
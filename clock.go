@@ -0,0 +1,52 @@
+package cache
+
+import "time"
+
+// Clock is the cache's source of time, for TTLs, refresh-ahead, negative caching, load
+// retry backoff, scheduled clears, idle timeouts and the janitor. It's there so tests can
+// control time with the clocktest package rather than sleeping; everything else should
+// leave the default, which uses the time package.
+//
+// The background monitors (WithHeapLimit, WithMemoryPressure, NewWithMemoryFraction) and
+// write-behind batching always use real time, since they watch resources outside the cache.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer the cache uses.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock makes the cache get the time from clock.
+func WithClock(clock Clock) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// now returns the current time in unix nanoseconds.
+func (c *Cache) now() int64 {
+	if c.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return c.clock.Now().UnixNano()
+}
+
+func (c *Cache) newTimer(d time.Duration) Timer {
+	if c.clock == nil {
+		return systemTimer{time.NewTimer(d)}
+	}
+	return c.clock.NewTimer(d)
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
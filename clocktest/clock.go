@@ -0,0 +1,130 @@
+// Package clocktest provides a cache.Clock that only moves when told to, so tests of TTLs,
+// refresh-ahead and retry backoff run instantly and deterministically.
+//
+//	clock := clocktest.New(time.Now())
+//	c := cache.New(1<<20, cache.WithClock(clock))
+//	c.SetWithTTL("key", "value", time.Minute)
+//	clock.Advance(time.Minute)
+//	_, found := c.Get("key") // false
+package clocktest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// Clock is a manually advanced cache.Clock. It's safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+// New returns a Clock stopped at now.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d, firing every timer that's due along the way in order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		i := -1
+		for j, t := range c.timers {
+			if !t.when.After(end) && (i < 0 || t.when.Before(c.timers[i].when)) {
+				i = j
+			}
+		}
+		if i < 0 {
+			break
+		}
+
+		t := c.timers[i]
+		c.timers = slices.Delete(c.timers, i, i+1)
+		c.now = t.when
+
+		// Like time.Timer, C is buffered, so a timer that nobody's waiting on doesn't block.
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+	c.now = end
+}
+
+// Set moves the clock to now, firing timers as Advance does. It can't move the clock backwards.
+func (c *Clock) Set(now time.Time) {
+	c.Advance(now.Sub(c.Now()))
+}
+
+func (c *Clock) NewTimer(d time.Duration) cache.Timer {
+	t := &timer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Timers returns how many timers are waiting to fire, so tests can wait for the code under
+// test to start one before advancing the clock.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+type timer struct {
+	clock *Clock
+	when  time.Time
+	c     chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.remove()
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.remove()
+	t.when = t.clock.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- t.clock.now:
+		default:
+		}
+		return active
+	}
+	t.clock.timers = append(t.clock.timers, t)
+	return active
+}
+
+// remove takes t off the clock and reports whether it was waiting to fire. t.clock.mu must already be locked.
+func (t *timer) remove() bool {
+	i := slices.Index(t.clock.timers, t)
+	if i < 0 {
+		return false
+	}
+	t.clock.timers = slices.Delete(t.clock.timers, i, i+1)
+	return true
+}
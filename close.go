@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrClosed is returned by the E variants, GetCtx and SetCtx once the cache has been closed,
+// and by Close if it's called more than once.
+var ErrClosed = errors.New("cache: closed")
+
+// WithSnapshotOnClose makes Close write a final Snapshot to path, e.g. to see what was in the
+// cache when a service was shut down.
+func WithSnapshotOnClose(path string) Option {
+	return func(c *Cache) {
+		c.snapshotOnClose = path
+	}
+}
+
+// Close shuts the cache down: it applies the writes queued by SetAsync, stops every background
+// goroutine the cache's options started, flushes the write-behind queue and trace recorder,
+// disables invalidation, writes the final snapshot if the cache was created
+// WithSnapshotOnClose, and then empties the cache.
+//
+// Afterwards, Set and Delete do nothing, Get always misses without calling the loader, and
+// the methods that return errors return ErrClosed. Every step is attempted even if an
+// earlier one fails, and all of their errors are returned.
+func (c *Cache) Close() error {
+	c.FlushAsync()
+
+	if !c.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	close(c.done)
+
+	if c.heap != nil {
+		close(c.heap.done)
+	}
+	if c.pressure != nil {
+		close(c.pressure.done)
+	}
+
+	var errs []error
+
+	if c.writeBehind != nil {
+		if err := c.writeBehind.flush(); err != nil {
+			errs = append(errs, fmt.Errorf("cache: flushing write-behind queue: %w", err))
+		}
+		// A clone shares the write-behind queue with the cache it was cloned from, so the
+		// worker keeps running for whichever of them is still open.
+		if c.writeBehind.refs.Add(-1) == 0 {
+			close(c.writeBehind.done)
+		}
+	}
+
+	if c.recorder != nil {
+		if err := c.recorder.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("cache: flushing trace: %w", err))
+		}
+	}
+
+	if err := c.DisableInvalidation(); err != nil {
+		errs = append(errs, fmt.Errorf("cache: closing invalidation transport: %w", err))
+	}
+
+	if c.snapshotOnClose != "" {
+		if err := c.writeSnapshotFile(c.snapshotOnClose); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.evictor != nil {
+		close(c.evictor)
+	}
+	c.clear()
+	c.logf("cache closed")
+
+	return errors.Join(errs...)
+}
+
+func (c *Cache) writeSnapshotFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cache: writing snapshot: %w", err)
+	}
+	if err := c.WriteSnapshot(f); err != nil {
+		f.Close()
+		return fmt.Errorf("cache: writing snapshot to %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cache: writing snapshot to %s: %w", path, err)
+	}
+	return nil
+}
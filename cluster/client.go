@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/radovskyb/self-clearing-in-memory-cache/resp"
+)
+
+// ErrNoNodes is returned when the client has no nodes to send a key to.
+var ErrNoNodes = errors.New("cluster: no nodes")
+
+// Client shards keys across cache instances served with resp.Server (or real Redis servers).
+// Safe for concurrent use.
+type Client struct {
+	ring *Ring
+
+	mu    sync.Mutex
+	conns map[string]*resp.Client
+}
+
+// NewClient creates a Client for the RESP servers at addrs. Connections are opened on first use.
+func NewClient(addrs ...string) *Client {
+	return NewClientWithRing(NewRing(DefaultReplicas), addrs...)
+}
+
+// NewClientWithRing is NewClient with keys sharded by ring, e.g. one made with NewRingWithHash
+// to choose the hash and number of replicas. addrs are added to the ring.
+func NewClientWithRing(ring *Ring, addrs ...string) *Client {
+	c := &Client{
+		ring:  ring,
+		conns: make(map[string]*resp.Client),
+	}
+	c.ring.Add(addrs...)
+	return c
+}
+
+// AddNode adds a server to the cluster. Only the keys it now owns move to it.
+func (c *Client) AddNode(addr string) {
+	c.ring.Add(addr)
+}
+
+// RemoveNode removes a server from the cluster and closes its connection.
+func (c *Client) RemoveNode(addr string) {
+	c.ring.Remove(addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, found := c.conns[addr]; found {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+// Nodes returns the address of every server in the cluster.
+func (c *Client) Nodes() []string {
+	return c.ring.Nodes()
+}
+
+// Get retrieves key from the server that owns it.
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do(key, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("cluster: unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores key on the server that owns it. A ttl <= 0 means the item never expires.
+func (c *Client) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+
+	_, err := c.do(key, args...)
+	return err
+}
+
+// Delete removes key from the server that owns it.
+func (c *Client) Delete(key string) error {
+	_, err := c.do(key, "DEL", key)
+	return err
+}
+
+// Close closes every open connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for addr, conn := range c.conns {
+		err = errors.Join(err, conn.Close())
+		delete(c.conns, addr)
+	}
+	return err
+}
+
+// do sends a command to the server that owns key. A connection that fails is
+// dropped so the next command redials.
+func (c *Client) do(key string, args ...string) (any, error) {
+	addr, ok := c.ring.Get(key)
+	if !ok {
+		return nil, ErrNoNodes
+	}
+
+	conn, err := c.conn(addr)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: connecting to %s: %w", addr, err)
+	}
+
+	reply, err := conn.Do(args...)
+	if err != nil {
+		var replyErr resp.Error
+		if !errors.As(err, &replyErr) {
+			c.dropConn(addr, conn)
+		}
+		return nil, fmt.Errorf("cluster: %s: %w", addr, err)
+	}
+	return reply, nil
+}
+
+func (c *Client) conn(addr string) (*resp.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, found := c.conns[addr]; found {
+		return conn, nil
+	}
+
+	conn, err := resp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+func (c *Client) dropConn(addr string, conn *resp.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conns[addr] == conn {
+		delete(c.conns, addr)
+	}
+	conn.Close()
+}
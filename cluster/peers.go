@@ -0,0 +1,246 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/internal/singleflight"
+)
+
+// DefaultBasePath is where a PeerGroup serves requests from other peers unless set otherwise.
+const DefaultBasePath = "/_cachepeers/"
+
+// maxPeerResponseSize stops a misbehaving peer from making us buffer an unbounded response.
+const maxPeerResponseSize = 64 << 20
+
+// PeerGroupConfig configures a PeerGroup.
+type PeerGroupConfig struct {
+	// Self is this instance's base URL as the other peers see it, e.g. "http://10.0.0.1:8080".
+	Self string
+
+	// Peers are the base URLs of every instance in the group, including Self.
+	Peers []string
+
+	// Getter loads a value from the origin. It's only called on the peer that owns the key,
+	// or locally if that peer can't be reached.
+	Getter func(ctx context.Context, key string) ([]byte, error)
+
+	// TTL is how long loaded values are cached for. 0 means they never expire.
+	TTL time.Duration
+
+	// BasePath is the path the PeerGroup handler is mounted at. Empty uses DefaultBasePath.
+	BasePath string
+
+	// Client is used to make requests to other peers. Nil uses http.DefaultClient.
+	Client *http.Client
+
+	// Replicas is how many virtual nodes each peer gets on the ring. 0 uses DefaultReplicas.
+	Replicas int
+
+	// Hash places keys on the ring, see HashFunc. Nil uses DefaultHash. Every peer must be
+	// configured with the same Replicas and Hash.
+	Hash HashFunc
+}
+
+// PeerGroup loads missing keys through the peer that owns them, so each key is only
+// loaded from the origin by one instance, instead of by every instance that misses on it.
+//
+// Concurrent loads of the same key are also collapsed into one, both for local callers
+// and for requests coming in from other peers. The two are collapsed separately, so a request
+// from a peer never waits on a local load that's forwarding the same key to that peer.
+//
+// Mount the group at BasePath on each instance's HTTP server so the peers can reach it.
+type PeerGroup struct {
+	cache    *cache.Cache
+	self     string
+	ring     atomic.Pointer[Ring] // replaced as a whole by SetPeers
+	replicas int
+	hash     HashFunc
+	getter   func(ctx context.Context, key string) ([]byte, error)
+	ttl      time.Duration
+	basePath string
+	client   *http.Client
+	flight   singleflight.Group // local loads, see Get
+	served   singleflight.Group // loads forwarded from other peers, see ServeHTTP
+}
+
+// NewPeerGroup creates a PeerGroup storing loaded values in c.
+func NewPeerGroup(c *cache.Cache, cfg PeerGroupConfig) *PeerGroup {
+	g := &PeerGroup{
+		cache:    c,
+		self:     cfg.Self,
+		replicas: cfg.Replicas,
+		hash:     cfg.Hash,
+		getter:   cfg.Getter,
+		ttl:      cfg.TTL,
+		basePath: cfg.BasePath,
+		client:   cfg.Client,
+	}
+	if g.basePath == "" {
+		g.basePath = DefaultBasePath
+	}
+	if !strings.HasSuffix(g.basePath, "/") {
+		g.basePath += "/"
+	}
+	if g.client == nil {
+		g.client = http.DefaultClient
+	}
+
+	g.SetPeers(cfg.Peers...)
+	return g
+}
+
+// SetPeers replaces the group's peers, e.g. after a deploy changes the set of instances.
+// Loads running at the same time see either the old peers or the new ones, never a mix.
+func (g *PeerGroup) SetPeers(peers ...string) {
+	ring := NewRingWithHash(g.replicas, g.hash)
+	ring.Add(peers...)
+	g.ring.Store(ring)
+}
+
+// Get returns key from the local cache, loading it through the owning peer if it's missing.
+func (g *PeerGroup) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, found := g.cache.Get(key); found {
+		if b, ok := value.([]byte); ok {
+			return b, nil
+		}
+	}
+
+	value, err, _ := g.flight.Do(key, func() (any, error) {
+		owner, ok := g.ring.Load().Get(key)
+		if !ok || owner == g.self {
+			return g.load(ctx, key)
+		}
+
+		value, err := g.fetch(ctx, owner, key)
+
+		// The owner's Getter failing is reported as is, since loading locally would just
+		// repeat the same origin call. Not being able to reach the owner falls back to loading locally.
+		var getterErr *peerGetterError
+		if err != nil && !errors.As(err, &getterErr) {
+			log.Printf("cluster: loading %q from peer %s: %v. loading locally", key, owner, err)
+			return g.load(ctx, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		g.cache.SetWithTTL(key, value, g.ttl)
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cluster: loading %q returned %T, not []byte", key, value)
+	}
+	return b, nil
+}
+
+// ServeHTTP handles loads forwarded from other peers. Keys are always loaded locally,
+// never forwarded again, so peers with different views of the group can't loop.
+func (g *PeerGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.EscapedPath(), g.basePath)
+	if !ok || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	key, err := url.PathUnescape(key)
+	if err != nil || key == "" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	var value []byte
+	if cached, found := g.cache.Get(key); found {
+		value, _ = cached.([]byte)
+	}
+	if value == nil {
+		loaded, err, _ := g.served.Do(key, func() (any, error) {
+			return g.load(r.Context(), key)
+		})
+		if err == nil {
+			var ok bool
+			if value, ok = loaded.([]byte); !ok {
+				err = fmt.Errorf("loading returned %T, not []byte", loaded)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// load calls the Getter and caches the result.
+func (g *PeerGroup) load(ctx context.Context, key string) (any, error) {
+	value, err := g.getter(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	g.cache.SetWithTTL(key, value, g.ttl)
+	return value, nil
+}
+
+// peerGetterError is returned by fetch when the peer was reached, but its Getter failed.
+type peerGetterError struct {
+	peer string
+	msg  string
+}
+
+func (e *peerGetterError) Error() string {
+	return fmt.Sprintf("peer %s: %s", e.peer, e.msg)
+}
+
+func (g *PeerGroup) fetch(ctx context.Context, peer, key string) ([]byte, error) {
+	u := strings.TrimSuffix(peer, "/") + g.basePath + url.PathEscape(key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.ContentLength > maxPeerResponseSize {
+		return nil, fmt.Errorf("response of %d bytes is bigger than the %d byte limit", res.ContentLength, maxPeerResponseSize)
+	}
+
+	// Reading one byte past the limit tells a value that's too big apart from one that fits
+	// exactly, rather than caching it cut short.
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxPeerResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxPeerResponseSize {
+		return nil, fmt.Errorf("response is bigger than the %d byte limit", maxPeerResponseSize)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusBadGateway:
+		return nil, &peerGetterError{peer: peer, msg: string(bytes.TrimSpace(body))}
+	}
+	return nil, fmt.Errorf("unexpected status %s", res.Status)
+}
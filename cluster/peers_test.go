@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestFetch(t *testing.T) {
+	value := []byte("value")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case DefaultBasePath + "small":
+			w.Write(value)
+		case DefaultBasePath + "declared":
+			w.Header().Set("Content-Length", strconv.Itoa(maxPeerResponseSize+1))
+		case DefaultBasePath + "streamed":
+			chunk := make([]byte, 1<<20)
+			for written := 0; written <= maxPeerResponseSize; written += len(chunk) {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	g := NewPeerGroup(c, PeerGroupConfig{Self: "http://self", Peers: []string{srv.URL}})
+
+	if got, err := g.fetch(context.Background(), srv.URL, "small"); err != nil || !bytes.Equal(got, value) {
+		t.Errorf("fetching a small value returned %q, %v", got, err)
+	}
+	for _, key := range []string{"declared", "streamed"} {
+		if got, err := g.fetch(context.Background(), srv.URL, key); err == nil {
+			t.Errorf("fetching a %s value past the limit returned %d bytes, want an error", key, len(got))
+		}
+	}
+}
+
+func TestPeerGroupGetterPanic(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	g := NewPeerGroup(c, PeerGroupConfig{
+		Self:  "http://self",
+		Peers: []string{"http://self"},
+		Getter: func(ctx context.Context, key string) ([]byte, error) {
+			panic("boom")
+		},
+	})
+
+	if value, err := g.Get(context.Background(), "key"); err == nil {
+		t.Errorf("Get with a panicking Getter returned %q, want an error", value)
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DefaultBasePath+"key", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("serving a key with a panicking Getter returned %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+}
+
+// barrierTransport holds every request until n have been made.
+type barrierTransport struct {
+	wg sync.WaitGroup
+}
+
+func (b *barrierTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.wg.Done()
+	b.wg.Wait()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPeerGroupsDisagreeingOnOwner(t *testing.T) {
+	// Each peer thinks the other owns every key, and both load the same key at once, so
+	// each forwards the key to the other while loading it itself.
+	var a, b *PeerGroup
+	srvA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { a.ServeHTTP(w, r) }))
+	defer srvA.Close()
+	srvB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { b.ServeHTTP(w, r) }))
+	defer srvB.Close()
+
+	barrier := &barrierTransport{}
+	barrier.wg.Add(2)
+	getter := func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("value"), ctx.Err()
+	}
+	newGroup := func(self, peer string) *PeerGroup {
+		c := cache.New(1<<20, cache.WithLogger(nil))
+		t.Cleanup(func() { c.Close() })
+		return NewPeerGroup(c, PeerGroupConfig{
+			Self:   self,
+			Peers:  []string{peer},
+			Getter: getter,
+			Client: &http.Client{Transport: barrier},
+		})
+	}
+	a, b = newGroup(srvA.URL, srvB.URL), newGroup(srvB.URL, srvA.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, g := range []*PeerGroup{a, b} {
+		wg.Go(func() {
+			if value, err := g.Get(ctx, "key"); err != nil || string(value) != "value" {
+				t.Errorf("Get returned %q, %v, want the value", value, err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestPeerGroupSetPeers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	g := NewPeerGroup(c, PeerGroupConfig{
+		Self:  "http://self",
+		Peers: []string{srv.URL},
+		Getter: func(ctx context.Context, key string) ([]byte, error) {
+			t.Errorf("%q was loaded locally while the peers were being replaced", key)
+			return nil, errors.New("not the owner")
+		},
+	})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				g.SetPeers(srv.URL)
+			}
+		}
+	})
+	for i := range 50 {
+		if _, err := g.Get(context.Background(), "key"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
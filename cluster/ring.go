@@ -0,0 +1,136 @@
+// Package cluster spreads keys across several cache instances using consistent hashing.
+package cluster
+
+import (
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplicas is the number of virtual nodes each node gets on the ring unless set otherwise.
+const DefaultReplicas = 128
+
+// HashFunc hashes keys, and nodes' virtual node names, onto the ring.
+//
+// The default, CRC-32, spreads most keys evenly, but keys an attacker chooses can be made to
+// land on one node, and keys that only differ in a few characters can cluster on a small ring.
+// For those, hash with a seed the attacker doesn't know, e.g. maphash.String with a seed shared
+// by every client, or with a 64-bit hash like xxhash, and give each node more replicas. Every
+// client and peer must use the same function and replicas, or they'll disagree on who owns a key.
+type HashFunc func(key string) uint64
+
+// DefaultHash is the HashFunc rings use unless set otherwise: CRC-32 (IEEE).
+func DefaultHash(key string) uint64 {
+	return uint64(crc32.ChecksumIEEE([]byte(key)))
+}
+
+// Ring is a consistent hash ring with virtual nodes. Adding or removing a node only
+// moves the keys that node owns. Safe for concurrent use.
+type Ring struct {
+	mu       sync.RWMutex
+	replicas int
+	hash     HashFunc
+	hashes   []uint64 // sorted
+	owners   map[uint64]string
+	nodes    map[string]struct{}
+}
+
+// NewRing creates an empty ring where each node gets replicas virtual nodes.
+// replicas <= 0 uses DefaultReplicas.
+func NewRing(replicas int) *Ring {
+	return NewRingWithHash(replicas, nil)
+}
+
+// NewRingWithHash is NewRing with keys placed by hash instead of DefaultHash, see HashFunc.
+// A nil hash uses DefaultHash.
+func NewRingWithHash(replicas int, hash HashFunc) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	if hash == nil {
+		hash = DefaultHash
+	}
+	return &Ring{
+		replicas: replicas,
+		hash:     hash,
+		owners:   make(map[uint64]string),
+		nodes:    make(map[string]struct{}),
+	}
+}
+
+// Add adds nodes to the ring. Nodes already on the ring are ignored.
+func (r *Ring) Add(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, node := range nodes {
+		if _, found := r.nodes[node]; found {
+			continue
+		}
+		r.nodes[node] = struct{}{}
+
+		for i := range r.replicas {
+			h := r.hash(node + "#" + strconv.Itoa(i))
+
+			// On the rare collision, the first node to claim a point keeps it.
+			if _, taken := r.owners[h]; taken {
+				continue
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+
+	slices.Sort(r.hashes)
+}
+
+// Remove removes a node from the ring.
+func (r *Ring) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.nodes[node]; !found {
+		return
+	}
+	delete(r.nodes, node)
+
+	r.hashes = slices.DeleteFunc(r.hashes, func(h uint64) bool {
+		if r.owners[h] == node {
+			delete(r.owners, h)
+			return true
+		}
+		return false
+	})
+}
+
+// Get returns the node that owns key, or false if the ring is empty.
+func (r *Ring) Get(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hashes) == 0 {
+		return "", false
+	}
+
+	h := r.hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]], true
+}
+
+// Nodes returns every node on the ring, sorted.
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	return nodes
+}
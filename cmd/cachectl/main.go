@@ -0,0 +1,268 @@
+// Command cachectl inspects and edits a cache served by the httpapi and admin packages.
+//
+// Usage:
+//
+//	cachectl [flags] keys [prefix]
+//	cachectl [flags] get <key>
+//	cachectl [flags] set [-ttl 30s] <key> <value|->
+//	cachectl [flags] del <key>
+//	cachectl [flags] flush [prefix]
+//	cachectl [flags] stats
+//	cachectl [flags] snapshot [file]
+//
+// A value of - for set reads the value from stdin. snapshot writes to stdout unless given a file.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	addr      = flag.String("addr", "http://localhost:8080", "base URL of the httpapi handler")
+	adminAddr = flag.String("admin", "", "base URL of the admin handler (default: -addr followed by /cachez)")
+	token     = flag.String("token", os.Getenv("CACHECTL_TOKEN"), "bearer token, defaults to $CACHECTL_TOKEN")
+	timeout   = flag.Duration("timeout", 10*time.Second, "request timeout")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	*addr = strings.TrimSuffix(*addr, "/")
+	*adminAddr = strings.TrimSuffix(*adminAddr, "/")
+
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "cachectl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: cachectl [flags] <command> [args]
+
+commands:
+  keys [prefix]                list keys
+  get <key>                    print a value
+  set [-ttl 30s] <key> <value> set a value, - reads it from stdin
+  del <key>                    delete a key
+  flush [prefix]               delete every key with a prefix, or everything
+  stats                        print stats as JSON
+  snapshot [file]              download a key metadata snapshot
+
+flags:`)
+	flag.PrintDefaults()
+}
+
+func run(cmd string, args []string) error {
+	switch cmd {
+	case "keys":
+		return keys(args)
+	case "get":
+		return get(args)
+	case "set":
+		return set(args)
+	case "del", "delete":
+		return del(args)
+	case "flush":
+		return flush(args)
+	case "stats":
+		return stats(args)
+	case "snapshot":
+		return snapshot(args)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func keys(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: keys [prefix]")
+	}
+
+	u := *addr + "/keys"
+	if len(args) == 1 {
+		u += "?prefix=" + url.QueryEscape(args[0])
+	}
+
+	body, err := do(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return fmt.Errorf("decoding keys: %w", err)
+	}
+	for _, key := range keys {
+		fmt.Println(key)
+	}
+	return nil
+}
+
+func get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <key>")
+	}
+
+	body, err := do(http.MethodGet, keyURL(args[0]), nil)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(body)
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		fmt.Println()
+	}
+	return nil
+}
+
+func set(args []string) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", 0, "expire the value after ttl")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: set [-ttl 30s] <key> <value|->")
+	}
+
+	value := []byte(fs.Arg(1))
+	if fs.Arg(1) == "-" {
+		var err error
+		if value, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+	}
+
+	u := keyURL(fs.Arg(0))
+	if *ttl > 0 {
+		u += "?ttl=" + ttl.String()
+	}
+
+	_, err := do(http.MethodPut, u, value)
+	return err
+}
+
+func del(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: del <key>")
+	}
+
+	_, err := do(http.MethodDelete, keyURL(args[0]), nil)
+	return err
+}
+
+func flush(args []string) error {
+	switch len(args) {
+	case 0:
+		_, err := do(http.MethodPost, *addr+"/flush", nil)
+		return err
+	case 1:
+		body, err := do(http.MethodDelete, *addr+"/keys?prefix="+url.QueryEscape(args[0]), nil)
+		if err != nil {
+			return err
+		}
+
+		var res struct {
+			Deleted int `json:"deleted"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		fmt.Printf("deleted %d keys\n", res.Deleted)
+		return nil
+	default:
+		return errors.New("usage: flush [prefix]")
+	}
+}
+
+func stats(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: stats")
+	}
+
+	body, err := do(http.MethodGet, *addr+"/stats", nil)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return fmt.Errorf("decoding stats: %w", err)
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+func snapshot(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: snapshot [file]")
+	}
+
+	base := *adminAddr
+	if base == "" {
+		base = *addr + "/cachez"
+	}
+
+	body, err := do(http.MethodGet, base+"/snapshot", nil)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(args[0], body, 0o644)
+}
+
+// keyURL escapes each segment of key separately, so keys containing slashes keep them.
+func keyURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return *addr + "/cache/" + strings.Join(segments, "/")
+}
+
+func do(method, u string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
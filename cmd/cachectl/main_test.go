@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/admin"
+	"github.com/radovskyb/self-clearing-in-memory-cache/httpapi"
+)
+
+// serve points cachectl at an httpapi handler with the admin handler under /cachez, both
+// requiring the bearer token "secret".
+func serve(t *testing.T) *cache.Cache {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+
+	mux := http.NewServeMux()
+	mux.Handle("/", httpapi.NewHandler(c, httpapi.WithBearerToken("secret")))
+	h := admin.Register(mux, c, admin.WithBearerToken("secret"))
+	t.Cleanup(func() { h.Close() })
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	oldAddr, oldAdmin, oldToken := *addr, *adminAddr, *token
+	*addr, *adminAddr, *token = srv.URL, "", "secret"
+	t.Cleanup(func() { *addr, *adminAddr, *token = oldAddr, oldAdmin, oldToken })
+	return c
+}
+
+// capture runs cmd with args, returning what it wrote to stdout.
+func capture(t *testing.T, cmd string, args ...string) (string, error) {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	err = run(cmd, args)
+	os.Stdout = stdout
+
+	out, readErr := os.ReadFile(f.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(out), err
+}
+
+func TestSetGetDel(t *testing.T) {
+	c := serve(t)
+
+	if _, err := capture(t, "set", "-ttl", "1m", "users/1 a", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := c.TTL("users/1 a"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("set -ttl 1m left a TTL of %s", ttl)
+	}
+
+	out, err := capture(t, "get", "users/1 a")
+	if err != nil || out != "value\n" {
+		t.Errorf("get printed %q, %v, want value", out, err)
+	}
+
+	if _, err := capture(t, "del", "users/1 a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := capture(t, "get", "users/1 a"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("get of a deleted key returned %v, want a 404", err)
+	}
+}
+
+func TestKeysAndFlush(t *testing.T) {
+	c := serve(t)
+	for _, key := range []string{"users:2", "users:1", "orders:1"} {
+		c.Set(key, "v")
+	}
+
+	out, err := capture(t, "keys", "users:")
+	if err != nil || out != "users:1\nusers:2\n" {
+		t.Errorf("keys users: printed %q, %v", out, err)
+	}
+
+	out, err = capture(t, "flush", "users:")
+	if err != nil || out != "deleted 2 keys\n" {
+		t.Errorf("flush users: printed %q, %v", out, err)
+	}
+	if _, err := capture(t, "flush"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Keys()) != 0 {
+		t.Errorf("flush left %v", c.Keys())
+	}
+}
+
+func TestStatsAndSnapshot(t *testing.T) {
+	c := serve(t)
+	c.Set("a", "1")
+
+	out, err := capture(t, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats cache.Stats
+	if err := json.Unmarshal([]byte(out), &stats); err != nil || stats.Items != 1 {
+		t.Errorf("stats printed %q, %v, want 1 item", out, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if _, err := capture(t, "snapshot", path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot cache.Snapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil || len(snapshot.Items) != 1 || snapshot.Items[0].Key != "a" {
+		t.Errorf("snapshot wrote %s, %v", b, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	serve(t)
+
+	tests := []struct {
+		cmd  string
+		args []string
+		want string
+	}{
+		{"frobnicate", nil, "unknown command"},
+		{"get", nil, "usage: get"},
+		{"set", []string{"key"}, "usage: set"},
+		{"flush", []string{"a", "b"}, "usage: flush"},
+	}
+	for _, tt := range tests {
+		if _, err := capture(t, tt.cmd, tt.args...); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v returned %v, want %q", tt.cmd, tt.args, err, tt.want)
+		}
+	}
+
+	*token = "wrong"
+	if _, err := capture(t, "keys"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("keys with the wrong token returned %v, want a 401", err)
+	}
+}
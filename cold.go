@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// coldSweeps is how many times per WithColdCompression interval the cache looks for items
+	// to compress or expand, like idleSweeps.
+	coldSweeps = 4
+
+	// coldMinSize is the smallest value worth compressing when it goes cold, since smaller ones
+	// rarely shrink by more than the codec's framing.
+	coldMinSize = 128
+)
+
+// WithColdCompression compresses []byte and string values with codec once they haven't been
+// read for after, and expands them again once they're read, so rarely read values take a
+// fraction of the budget while hot ones are served without decompressing. Values set
+// WithSerializer are compressed the same way, in their serialized form.
+//
+// Values are compressed and expanded by a background sweep that runs four times per interval,
+// so the first few reads of a cold value pay for decompressing it. Values that are already
+// compressed, encrypted or spilled, values under 128 bytes and values that don't get any
+// smaller are left alone.
+//
+// Unlike WithCompression, an item's size changes as it goes cold and hot again, which the
+// cache's limits account for: a cold value is only expanded if it still fits. Neither change
+// is sent to subscribers, since the value itself doesn't change.
+func WithColdCompression(codec Codec, after time.Duration) Option {
+	return func(c *Cache) {
+		c.coldCodec = codec
+		c.coldAfter = after
+	}
+}
+
+// tracksAccess reports whether reads need to update accessedAt.
+func (c *Cache) tracksAccess() bool {
+	return c.idleTimeout > 0 || c.coldAfter > 0
+}
+
+// startColdSweeper starts the sweep for WithColdCompression. Like startIdleSweeper, it's called
+// once every option has been applied.
+func (c *Cache) startColdSweeper() {
+	if c.coldCodec == nil || c.coldAfter <= 0 || c.deterministic() {
+		return
+	}
+	go c.sweepCold()
+}
+
+func (c *Cache) sweepCold() {
+	for {
+		timer := c.newTimer(c.coldAfter / coldSweeps)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		if compressed, expanded := c.recompress(); compressed+expanded > 0 {
+			c.logf("compressed %d cold items and expanded %d hot ones", compressed, expanded)
+		}
+	}
+}
+
+// recompress compresses every value that's gone cold and expands every cold value that's been
+// read since, returning how many of each it changed. Like evictIdle, it finds them with only a
+// read lock, then changes them a batch at a time.
+func (c *Cache) recompress() (compressed, expanded int) {
+	c.mu.RLock()
+	now := c.now()
+	var keys []string
+	for key, i := range c.all() {
+		if c.coldCandidate(c.arena.value(i), c.arena.entry(i), now) {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), softEvictionBatch)]
+		keys = keys[len(batch):]
+
+		c.mu.Lock()
+		now = c.now()
+		for _, key := range batch {
+			// The item may have been read, replaced or removed since it was found.
+			i, e := c.lookup(key)
+			if e == nil || !c.coldCandidate(c.arena.value(i), e, now) {
+				continue
+			}
+
+			if v, ok := c.arena.value(i).(*encodedValue); ok && v.cold {
+				if c.expand(key, i, e, v) {
+					expanded++
+				}
+			} else if c.compressCold(key, i, e) {
+				compressed++
+			}
+		}
+		c.mu.Unlock()
+	}
+	return compressed, expanded
+}
+
+// coldCandidate reports whether stored should be compressed because it's gone cold, or
+// expanded because it's cold but has been read recently.
+func (c *Cache) coldCandidate(stored any, e *entry, now int64) bool {
+	cold := now-atomic.LoadInt64(&e.accessedAt) >= int64(c.coldAfter)
+
+	switch v := stored.(type) {
+	case []byte:
+		return cold && len(v) >= coldMinSize
+	case string:
+		return cold && len(v) >= coldMinSize
+	case *encodedValue:
+		if v.cold {
+			return !cold
+		}
+		return cold && v.serialized && !v.compressed && !v.encrypted && v.spill == nil && len(v.data) >= coldMinSize
+	}
+	return false
+}
+
+// compressCold replaces the value in slot i with a compressed copy, if it's any smaller.
+// c.mu must already be locked.
+func (c *Cache) compressCold(key string, i uint32, e *entry) bool {
+	v := &encodedValue{cold: true}
+	switch stored := c.arena.value(i).(type) {
+	case []byte:
+		v.data = stored
+	case string:
+		v.data, v.string = []byte(stored), true
+	case *encodedValue:
+		v.data, v.serialized = stored.data, true
+	}
+
+	data, err := c.coldCodec.Compress(v.data)
+	if err != nil {
+		c.logf("error compressing cold item %q: %v", key, err)
+		return false
+	}
+	if len(data) >= len(v.data) {
+		return false
+	}
+	v.data = data
+
+	c.replaceStored(key, i, e, v)
+	return true
+}
+
+// expand replaces the cold value v in slot i with its original, uncompressed form.
+// c.mu must already be locked.
+func (c *Cache) expand(key string, i uint32, e *entry, v *encodedValue) bool {
+	data, err := c.coldCodec.Decompress(v.data)
+	if err != nil {
+		c.logf("error expanding %q: %v", key, err)
+		return false
+	}
+
+	var stored any
+	switch {
+	case v.serialized:
+		stored = &encodedValue{data: data, serialized: true}
+	case v.string:
+		stored = string(data)
+	default:
+		stored = data
+	}
+	if !c.fits(key, estimateItemSize(stored)) {
+		return false
+	}
+
+	c.replaceStored(key, i, e, stored)
+	return true
+}
+
+// replaceStored swaps the value in slot i for stored, an equivalent encoding of the same value,
+// keeping its entry but updating its size and checksum. c.mu must already be locked.
+func (c *Cache) replaceStored(key string, i uint32, e *entry, stored any) {
+	size := estimateItemSize(stored)
+
+	c.totalCacheSize += size - e.size
+	c.leaveGeneration(key, e)
+	e.size = size
+	if e.generation == c.generation {
+		c.generationSize.Add(generationSize(key, e))
+	}
+	if c.checksums != ChecksumOff {
+		e.sum, _ = checksum(stored)
+	}
+	c.unreplicate(key)
+	c.arena.replace(i, stored, *e)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Codec compresses values for WithCompression. The standard library only has gzip, see
+// GzipCodec, but any compression library can be plugged in, e.g. snappy or zstd for speed.
+type Codec interface {
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// WithCompression compresses []byte and string values of at least threshold bytes with codec
+// before caching them, and decompresses them on Get, so the same maxCacheSize holds several
+// times more data for compressible values like JSON. Values that don't get any smaller are
+// cached as they are.
+//
+// Sizes, and so the limits, count the compressed size. Gets of compressed values allocate
+// and cost a decompression, so pick threshold to leave small, hot values alone.
+//
+// Values are only compressed in the cache, stores and subscribers see them as they were set.
+// WithBufferRelease is never called with a []byte that was compressed, since the cache only
+// keeps the compressed copy.
+func WithCompression(codec Codec, threshold int) Option {
+	return func(c *Cache) {
+		c.codec = codec
+		c.compressAbove = threshold
+	}
+}
+
+// GzipCodec is a Codec using gzip at the given level, e.g. gzip.BestSpeed.
+type GzipCodec struct {
+	Level int
+
+	writers sync.Pool
+}
+
+func (g *GzipCodec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, ok := g.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		var err error
+		if w, err = gzip.NewWriterLevel(&buf, g.Level); err != nil {
+			return nil, err
+		}
+	}
+	defer g.writers.Put(w)
+
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *GzipCodec) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the cache's configuration as a plain struct, so it can be unmarshalled from a JSON
+// or YAML config file and passed to NewFromConfig. Zero values leave the corresponding feature off.
+type Config struct {
+	// MaxSize is the size in bytes at which the cache clears or evicts, see New. Exactly one of
+	// MaxSize and MemoryFraction must be set.
+	MaxSize int64 `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+
+	// MemoryFraction sizes the cache from the memory available to the process, see NewWithMemoryFraction.
+	MemoryFraction float64 `json:"memory_fraction,omitempty" yaml:"memory_fraction,omitempty"`
+
+	// SoftLimit, if set, must be less than MaxSize. See WithSoftLimit.
+	SoftLimit int64 `json:"soft_limit,omitempty" yaml:"soft_limit,omitempty"`
+
+	DeferredEviction bool           `json:"deferred_eviction,omitempty" yaml:"deferred_eviction,omitempty"`
+	EvictionPolicy   EvictionPolicy `json:"eviction_policy,omitempty" yaml:"eviction_policy,omitempty"`
+	OverflowPolicy   OverflowPolicy `json:"overflow_policy,omitempty" yaml:"overflow_policy,omitempty"`
+	Checksums        ChecksumMode   `json:"checksums,omitempty" yaml:"checksums,omitempty"`
+
+	DefaultTTL           Duration `json:"default_ttl,omitempty" yaml:"default_ttl,omitempty"`
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate,omitempty" yaml:"stale_while_revalidate,omitempty"`
+
+	// TTLJitter, if set, must be between 0 and 1. See WithTTLJitter.
+	TTLJitter float64 `json:"ttl_jitter,omitempty" yaml:"ttl_jitter,omitempty"`
+
+	// RefreshAhead, if set, must be between 0 and 1. See WithRefreshAhead.
+	RefreshAhead float64 `json:"refresh_ahead,omitempty" yaml:"refresh_ahead,omitempty"`
+
+	// EarlyExpiration is XFetch's beta, see WithEarlyExpiration.
+	EarlyExpiration float64 `json:"early_expiration,omitempty" yaml:"early_expiration,omitempty"`
+
+	IdleTimeout      Duration `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`
+	LoadTimeout      Duration `json:"load_timeout,omitempty" yaml:"load_timeout,omitempty"`
+	NegativeCacheTTL Duration `json:"negative_cache_ttl,omitempty" yaml:"negative_cache_ttl,omitempty"`
+
+	HeapLimit int64 `json:"heap_limit,omitempty" yaml:"heap_limit,omitempty"`
+
+	// MemoryPressureThreshold, if set, clears the cache when the Go runtime's memory use passes
+	// this fraction of its memory limit. See WithMemoryPressure and MemoryLimitPressure.
+	MemoryPressureThreshold float64 `json:"memory_pressure_threshold,omitempty" yaml:"memory_pressure_threshold,omitempty"`
+}
+
+// Duration is a time.Duration that's written as a string like "1m30s" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// NewFromConfig validates cfg and creates a cache from it. Every problem with cfg is reported
+// in the returned error, not just the first one. opts are applied after cfg, e.g. for a loader,
+// which can't be expressed in a config file.
+func NewFromConfig(cfg Config, opts ...Option) (*Cache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts = append(cfg.options(), opts...)
+	if cfg.MemoryFraction > 0 {
+		return NewWithMemoryFraction(cfg.MemoryFraction, opts...)
+	}
+	return New(cfg.MaxSize, opts...), nil
+}
+
+// Validate reports every invalid field or combination of fields in cfg.
+func (cfg Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("cache: config: "+format, args...))
+	}
+
+	switch {
+	case cfg.MaxSize < 0:
+		invalid("max_size must be positive, got %d", cfg.MaxSize)
+	case cfg.MemoryFraction < 0 || cfg.MemoryFraction > 1:
+		invalid("memory_fraction must be in (0, 1], got %v", cfg.MemoryFraction)
+	case cfg.MaxSize == 0 && cfg.MemoryFraction == 0:
+		invalid("one of max_size or memory_fraction is required")
+	case cfg.MaxSize > 0 && cfg.MemoryFraction > 0:
+		invalid("max_size and memory_fraction can't both be set")
+	}
+
+	if cfg.SoftLimit < 0 {
+		invalid("soft_limit must be positive, got %d", cfg.SoftLimit)
+	}
+	if cfg.MaxSize > 0 && cfg.SoftLimit >= cfg.MaxSize {
+		invalid("soft_limit (%d) must be less than max_size (%d)", cfg.SoftLimit, cfg.MaxSize)
+	}
+	if cfg.EvictionPolicy.String() == "unknown" {
+		invalid("unknown eviction_policy %d", cfg.EvictionPolicy)
+	}
+	if cfg.OverflowPolicy.String() == "unknown" {
+		invalid("unknown overflow_policy %d", cfg.OverflowPolicy)
+	}
+	if cfg.Checksums.String() == "unknown" {
+		invalid("unknown checksums mode %d", cfg.Checksums)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"default_ttl", cfg.DefaultTTL},
+		{"stale_while_revalidate", cfg.StaleWhileRevalidate},
+		{"idle_timeout", cfg.IdleTimeout},
+		{"load_timeout", cfg.LoadTimeout},
+		{"negative_cache_ttl", cfg.NegativeCacheTTL},
+	} {
+		if d.value < 0 {
+			invalid("%s can't be negative, got %s", d.name, time.Duration(d.value))
+		}
+	}
+
+	if cfg.TTLJitter < 0 || cfg.TTLJitter >= 1 {
+		invalid("ttl_jitter must be in [0, 1), got %v", cfg.TTLJitter)
+	}
+	if cfg.RefreshAhead < 0 || cfg.RefreshAhead >= 1 {
+		invalid("refresh_ahead must be in [0, 1), got %v", cfg.RefreshAhead)
+	}
+	if cfg.EarlyExpiration < 0 {
+		invalid("early_expiration can't be negative, got %v", cfg.EarlyExpiration)
+	}
+	if cfg.HeapLimit < 0 {
+		invalid("heap_limit must be positive, got %d", cfg.HeapLimit)
+	}
+	if cfg.MemoryPressureThreshold < 0 || cfg.MemoryPressureThreshold > 1 {
+		invalid("memory_pressure_threshold must be in (0, 1], got %v", cfg.MemoryPressureThreshold)
+	}
+
+	return errors.Join(errs...)
+}
+
+// options converts cfg to the equivalent options. cfg must be valid.
+func (cfg Config) options() []Option {
+	opts := []Option{
+		WithEvictionPolicy(cfg.EvictionPolicy),
+		WithOverflowPolicy(cfg.OverflowPolicy),
+		WithChecksums(cfg.Checksums),
+		WithDefaultTTL(time.Duration(cfg.DefaultTTL)),
+		WithTTLJitter(cfg.TTLJitter),
+		WithStaleWhileRevalidate(time.Duration(cfg.StaleWhileRevalidate)),
+		WithRefreshAhead(cfg.RefreshAhead),
+		WithEarlyExpiration(cfg.EarlyExpiration),
+		WithIdleTimeout(time.Duration(cfg.IdleTimeout)),
+		WithLoadTimeout(time.Duration(cfg.LoadTimeout)),
+		WithNegativeCaching(time.Duration(cfg.NegativeCacheTTL)),
+	}
+
+	if cfg.SoftLimit > 0 {
+		opts = append(opts, WithSoftLimit(cfg.SoftLimit))
+	}
+	if cfg.DeferredEviction {
+		opts = append(opts, WithDeferredEviction())
+	}
+	if cfg.HeapLimit > 0 {
+		opts = append(opts, WithHeapLimit(cfg.HeapLimit))
+	}
+	if cfg.MemoryPressureThreshold > 0 {
+		opts = append(opts, WithMemoryPressure(MemoryLimitPressure(), cfg.MemoryPressureThreshold))
+	}
+	return opts
+}
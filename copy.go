@@ -0,0 +1,150 @@
+package cache
+
+import "reflect"
+
+// Copier returns a copy of value that shares no mutable memory with it, for WithCopyOnSet and
+// WithCopyOnGet. DeepCopy works for most values; pass a specialised Copier for types it can't
+// copy fully, or to copy hot types faster.
+type Copier func(value any) any
+
+// WithCopyOnSet caches a copy of every value made with copier (DeepCopy if nil), so callers
+// can keep modifying a value after setting it without changing what's cached.
+func WithCopyOnSet(copier Copier) Option {
+	return func(c *Cache) {
+		c.copyOnSet = orDeepCopy(copier)
+	}
+}
+
+// WithCopyOnGet returns a copy of the cached value made with copier (DeepCopy if nil) from Get
+// and every other method that returns values, so callers can modify what they get without
+// racing with other readers. Gets of anything but strings and numbers allocate.
+func WithCopyOnGet(copier Copier) Option {
+	return func(c *Cache) {
+		c.copyOnGet = orDeepCopy(copier)
+	}
+}
+
+func orDeepCopy(copier Copier) Copier {
+	if copier == nil {
+		return DeepCopy
+	}
+	return copier
+}
+
+// output returns stored as callers should see it: decoded, and copied if the cache was
+// created WithCopyOnGet. found is false if stored couldn't be decoded.
+func (c *Cache) output(key string, stored any) (value any, found bool) {
+	value, found = c.decode(key, stored)
+	if !found || c.copyOnGet == nil {
+		return value, found
+	}
+
+	// Decoding already made a fresh copy.
+	if _, encoded := stored.(*encodedValue); encoded {
+		return value, true
+	}
+	return c.copyOnGet(value), true
+}
+
+// transformsOutput reports whether values need to go through output before being returned.
+func (c *Cache) transformsOutput() bool {
+	return c.encodes() || c.coldCodec != nil || c.copyOnGet != nil
+}
+
+// DeepCopy copies value recursively, following pointers and copying slices, arrays, maps and
+// structs, so the copy shares no mutable memory with value. Strings, numbers and other
+// immutable values are returned as they are, and []byte is copied without reflection.
+//
+// Unexported struct fields, channels and funcs can't be copied, so they're shared with value.
+// Pointers to the same thing are copied once, so cycles and sharing are preserved.
+func DeepCopy(value any) any {
+	switch v := value.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		uintptr, float32, float64, complex64, complex128:
+		return value
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	case []string:
+		if v == nil {
+			return v
+		}
+		return append([]string{}, v...)
+	}
+
+	src := reflect.ValueOf(value)
+	dst := reflect.New(src.Type()).Elem()
+	copyValue(dst, src, make(map[uintptr]reflect.Value))
+	return dst.Interface()
+}
+
+// copyValue copies src into dst deeply. seen maps pointers already copied to their copies.
+func copyValue(dst, src reflect.Value, seen map[uintptr]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		if p, ok := seen[src.Pointer()]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		seen[src.Pointer()] = p
+		copyValue(p.Elem(), src.Elem(), seen)
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		copyValue(elem, src.Elem(), seen)
+		dst.Set(elem)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := range src.Len() {
+			copyValue(s.Index(i), src.Index(i), seen)
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := range src.Len() {
+			copyValue(dst.Index(i), src.Index(i), seen)
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			copyValue(k, iter.Key(), seen)
+			v := reflect.New(src.Type().Elem()).Elem()
+			copyValue(v, iter.Value(), seen)
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	case reflect.Struct:
+		// Copy everything first so unexported fields are at least shared, then replace
+		// the exported ones with deep copies.
+		dst.Set(src)
+		for i := range src.NumField() {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i), seen)
+			}
+		}
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Increment atomically adds delta to the int64 stored under key and returns the result.
+// A missing or expired key starts from 0 and gets the default TTL, while an existing counter
+// keeps its expiry. It returns ErrWrongType if key holds any other value, and ErrClosed if the
+// cache has been closed.
+//
+// Like Append, counters only live in the cache and aren't written to the store.
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	return c.increment(key, delta, time.Duration(c.defaultTTL.Load()))
+}
+
+// IncrementWithTTL is like Increment, but a new counter expires after ttl.
+func (c *Cache) IncrementWithTTL(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.increment(key, delta, ttl)
+}
+
+func (c *Cache) increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	expiresAt := c.expiresAt(ttl)
+
+	if i, e := c.lookup(key); e != nil && !e.expired(c.now()) {
+		value, _ := c.decode(key, c.arena.value(i))
+		current, ok := value.(int64)
+		if !ok {
+			return 0, fmt.Errorf("%w: %q isn't an int64", ErrWrongType, key)
+		}
+		n, expiresAt = current, e.expiresAt
+	}
+
+	n += delta
+
+	// Encoding an int64 is cheap enough to do while locked, unlike the values Set is given.
+	stored, err := c.stored(key, n)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.set(key, n, stored, expiresAt, false); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// GetCtx is like Get, but gives up with ctx.Err() if ctx is done while it's waiting for the
+// cache's lock or for the loader, so a cancelled request doesn't keep waiting behind a long
+// eviction pass or a slow origin. ctx is passed to the loader.
+//
+// Unlike Get, loader errors other than ErrNotFound are returned rather than only logged.
+func (c *Cache) GetCtx(ctx context.Context, key string) (value any, found bool, err error) {
+	if c.closed.Load() {
+		return nil, false, ErrClosed
+	}
+	if err := c.rlockContext(ctx); err != nil {
+		return nil, false, err
+	}
+	value, found, refresh, expired := c.getLocked(key, c.loader != nil)
+	c.mu.RUnlock()
+
+	if expired {
+		c.reclaim(key)
+	}
+
+	if found && c.transformsOutput() {
+		value, found = c.output(key, value)
+	}
+
+	if refresh {
+		c.refresh(key, c.loader)
+	}
+	if found || c.loader == nil {
+		return value, found, nil
+	}
+
+	value, err = c.load(ctx, key, c.loader, false)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// SetCtx is like SetE, but gives up with ctx.Err() if ctx is done while it's waiting for the
+// cache's lock. If the cache was created WithWriteThrough, ctx is passed to the store instead.
+func (c *Cache) SetCtx(ctx context.Context, key string, value any) error {
+	return c.setE(ctx, key, value, time.Duration(c.defaultTTL.Load()))
+}
+
+// lockContext locks c.mu, giving up if ctx is done first.
+func (c *Cache) lockContext(ctx context.Context) error {
+	return acquire(ctx, c.mu.TryLock, c.mu.Lock, c.mu.Unlock)
+}
+
+// rlockContext read locks c.mu, giving up if ctx is done first.
+func (c *Cache) rlockContext(ctx context.Context) error {
+	return acquire(ctx, c.mu.TryRLock, c.mu.RLock, c.mu.RUnlock)
+}
+
+// acquire calls lock, giving up if ctx is done first. If it gives up, the goroutine left waiting
+// on lock calls unlock as soon as it gets the lock.
+func acquire(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+	if tryLock() {
+		return nil
+	}
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	locked := make(chan struct{})
+	abandoned := make(chan struct{})
+
+	go func() {
+		lock()
+		select {
+		case locked <- struct{}{}:
+		case <-abandoned:
+			// Nobody's waiting anymore, so let go straight away.
+			unlock()
+		}
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		close(abandoned)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"iter"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// WithDeterministic makes the cache behave the same way every time it's given the same
+// operations, for tests that assert on which items were evicted, expired or returned:
+//
+//   - Random choices, like which items are sampled for eviction, the jitter from WithTTLJitter
+//     and the early refreshes from WithEarlyExpiration, come from a generator seeded with seed.
+//   - Keys, Range, Snapshot, Drain and the events sent to subscribers go through items in key
+//     order rather than Go's random map order.
+//   - Eviction happens during the write that needs it, rather than in the background.
+//   - The janitor, idle timeout and cold compression sweeps only run when Sweep is called, so
+//     with a clocktest clock, tests decide exactly when they happen.
+//
+// It's much slower with a lot of items, since it sorts the keys wherever they're iterated, and
+// is only meant for tests.
+func WithDeterministic(seed uint64) Option {
+	return func(c *Cache) {
+		c.rng = newLockedRand(seed)
+	}
+}
+
+// lockedRand is a seeded generator that's safe for concurrent use.
+type lockedRand struct {
+	mu   sync.Mutex
+	r    *rand.Rand
+	seed uint64
+}
+
+func newLockedRand(seed uint64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewPCG(seed, seed)), seed: seed}
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
+
+func (r *lockedRand) IntN(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.IntN(n)
+}
+
+// deterministic reports whether the cache was created WithDeterministic.
+func (c *Cache) deterministic() bool {
+	return c.rng != nil
+}
+
+// random returns a random number in [0, 1), from the seeded generator in deterministic mode.
+func (c *Cache) random() float64 {
+	if c.rng != nil {
+		return c.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+// all iterates over every item's key and slot, in key order in deterministic mode and in map
+// order otherwise. Items can be removed while iterating. c.mu must already be locked.
+func (c *Cache) all() iter.Seq2[string, uint32] {
+	if !c.deterministic() {
+		return maps.All(c.items)
+	}
+
+	keys := slices.Sorted(maps.Keys(c.items))
+	return func(yield func(string, uint32) bool) {
+		for _, key := range keys {
+			i, found := c.items[key]
+			if !found {
+				continue
+			}
+			if !yield(key, i) {
+				return
+			}
+		}
+	}
+}
+
+// sampleDeterministically is evictionCandidate's sample in deterministic mode: evictionSample
+// items picked with the seeded generator from the sorted keys. c.mu must already be locked.
+func (c *Cache) sampleDeterministically(yield func(key string, i uint32) bool) {
+	keys := slices.Sorted(maps.Keys(c.items))
+	for range evictionSample {
+		key := keys[c.rng.IntN(len(keys))]
+		if !yield(key, c.items[key]) {
+			return
+		}
+	}
+}
+
+// Sweep removes expired items if the cache was created WithJanitor, and idle items if it was
+// created WithIdleTimeout, straight away rather than waiting for the next background sweep.
+// It returns how many items it removed. In deterministic mode it's the only way they're swept,
+// and the only way values are compressed and expanded WithColdCompression, or hot keys are
+// chosen WithHotKeyReplication.
+func (c *Cache) Sweep() int {
+	var n int
+	if c.expiry != nil {
+		n += c.removeExpired()
+	}
+	if c.idleTimeout > 0 {
+		n += c.evictIdle()
+	}
+	if c.coldCodec != nil && c.coldAfter > 0 {
+		c.recompress()
+	}
+	if c.hotKeys > 0 {
+		c.replicateHotKeys()
+	}
+	return n
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// DiagnosticsTopKeys is how many of the largest and most read keys DumpDiagnostics lists.
+const DiagnosticsTopKeys = 100
+
+// DumpDiagnostics writes a plain text report of the cache's configuration, stats and
+// largest and most read keys to w, for debugging memory issues. Values aren't included.
+func (c *Cache) DumpDiagnostics(w io.Writer) error {
+	snapshot := c.Snapshot()
+	stats := snapshot.Stats
+
+	slices.SortFunc(snapshot.Items, func(a, b Metadata) int {
+		return cmp.Or(cmp.Compare(b.Size+int64(len(b.Key)), a.Size+int64(len(a.Key))), cmp.Compare(a.Key, b.Key))
+	})
+	top := snapshot.Items[:min(DiagnosticsTopKeys, len(snapshot.Items))]
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "cache diagnostics at %s\n\n", snapshot.TakenAt.Format(time.RFC3339))
+
+	fmt.Fprintln(tw, "config:")
+	for _, setting := range c.settings() {
+		fmt.Fprintf(tw, "  %s\t%v\n", setting[0], setting[1])
+	}
+
+	fmt.Fprintln(tw, "\nstats:")
+	fmt.Fprintf(tw, "  items\t%d\n", stats.Items)
+	fmt.Fprintf(tw, "  size\t%d bytes\n", stats.Size)
+	fmt.Fprintf(tw, "  max size\t%d bytes\n", stats.MaxSize)
+	fmt.Fprintf(tw, "  hits\t%d\n", stats.Hits)
+	fmt.Fprintf(tw, "  misses\t%d\n", stats.Misses)
+	fmt.Fprintf(tw, "  hit rate\t%.1f%%\n", stats.HitRate()*100)
+	fmt.Fprintf(tw, "  clears\t%d\n", stats.Clears)
+	if stats.Rotations > 0 {
+		fmt.Fprintf(tw, "  rotations\t%d\n", stats.Rotations)
+	}
+	fmt.Fprintf(tw, "  evictions\t%d\n", stats.Evictions)
+	if stats.Expirations > 0 {
+		fmt.Fprintf(tw, "  expirations\t%d\n", stats.Expirations)
+	}
+	if stats.IdleEvictions > 0 {
+		fmt.Fprintf(tw, "  idle evictions\t%d\n", stats.IdleEvictions)
+	}
+	if stats.NotAdmitted > 0 {
+		fmt.Fprintf(tw, "  not admitted\t%d\n", stats.NotAdmitted)
+	}
+	if stats.AsyncDropped > 0 {
+		fmt.Fprintf(tw, "  async writes dropped\t%d\n", stats.AsyncDropped)
+	}
+	if stats.LockWaits > 0 {
+		fmt.Fprintf(tw, "  lock waits\t%d, %s in total\n", stats.LockWaits, stats.LockWaitTime)
+	}
+	if stats.Corruptions > 0 {
+		fmt.Fprintf(tw, "  corruptions\t%d\n", stats.Corruptions)
+	}
+	if stats.Spilled > 0 {
+		fmt.Fprintf(tw, "  spilled\t%d items, %d bytes\n", stats.Spilled, stats.SpilledSize)
+	}
+	for name, g := range stats.Groups {
+		fmt.Fprintf(tw, "  group %s\t%d items, %d bytes, %.1f%% hit rate\n", name, g.Items, g.Size, g.HitRate()*100)
+	}
+
+	fmt.Fprintf(tw, "\ntop %d keys by size:\n", len(top))
+	fmt.Fprintln(tw, "  size\thits\tage\tkey")
+	for _, m := range top {
+		age := snapshot.TakenAt.Sub(m.CreatedAt).Round(time.Second)
+		fmt.Fprintf(tw, "  %d\t%d\t%s\t%q\n", m.Size+int64(len(m.Key)), m.Hits, age, m.Key)
+	}
+
+	prefixes := c.Analyze()
+	fmt.Fprintln(tw, "\nkeyspace by prefix:")
+	fmt.Fprintln(tw, "  items\tsize\thits\tmisses\tprefix")
+	for _, p := range prefixes[:min(DiagnosticsTopKeys, len(prefixes))] {
+		fmt.Fprintf(tw, "  %d\t%d\t%d\t%d\t%q\n", p.Items, p.Size, p.Hits, p.Misses, p.Prefix)
+	}
+
+	hottest := c.TopKeys(DiagnosticsTopKeys).Hottest
+	fmt.Fprintf(tw, "\ntop %d keys by hits:\n", len(hottest))
+	fmt.Fprintln(tw, "  hits\tsize\tage\tkey")
+	for _, m := range hottest {
+		age := snapshot.TakenAt.Sub(m.CreatedAt).Round(time.Second)
+		fmt.Fprintf(tw, "  %d\t%d\t%s\t%q\n", m.Hits, m.Size+int64(len(m.Key)), age, m.Key)
+	}
+
+	return tw.Flush()
+}
+
+// settings returns the cache's configuration as name, value pairs, in a fixed order.
+func (c *Cache) settings() [][2]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	softLimit := "off"
+	if c.softLimit > 0 {
+		softLimit = fmt.Sprintf("%d bytes", c.softLimit)
+	}
+
+	heapLimit := "off"
+	if c.heap != nil {
+		heapLimit = fmt.Sprintf("%d bytes", c.heap.limit)
+	}
+
+	pressureThreshold := "off"
+	if c.pressure != nil {
+		pressureThreshold = fmt.Sprintf("%.0f%%", c.pressure.threshold*100)
+	}
+
+	compression := "off"
+	if c.codec != nil {
+		compression = fmt.Sprintf("%T above %d bytes", c.codec, c.compressAbove)
+	}
+
+	serializer := "off"
+	if c.serializer != nil {
+		serializer = fmt.Sprintf("%T", c.serializer)
+	}
+
+	encryption := "off"
+	if c.keys != nil {
+		encryption = fmt.Sprintf("key %d", c.keys.Current())
+	}
+
+	retainHot := "off"
+	switch {
+	case c.retainHot > 0 && c.retainHotFraction > 0:
+		retainHot = fmt.Sprintf("%d items or %.0f%%, whichever is more", c.retainHot, c.retainHotFraction*100)
+	case c.retainHot > 0:
+		retainHot = fmt.Sprintf("%d items", c.retainHot)
+	case c.retainHotFraction > 0:
+		retainHot = fmt.Sprintf("%.0f%%", c.retainHotFraction*100)
+	}
+
+	coldCompression := "off"
+	if c.coldCodec != nil {
+		coldCompression = fmt.Sprintf("%T after %s", c.coldCodec, c.coldAfter)
+	}
+
+	doorkeeper := "off"
+	if c.doorkeeper != nil {
+		doorkeeper = fmt.Sprintf("%d keys per %s", c.doorkeeper.capacity, c.doorkeeper.window)
+	}
+
+	hotKeys := "off"
+	switch {
+	case c.hotKeys > 0 && c.hotKeysAdaptive:
+		hotKeys = fmt.Sprintf("adaptive, up to %d keys every %s", c.hotKeys, c.hotKeyInterval)
+	case c.hotKeys > 0:
+		hotKeys = fmt.Sprintf("%d keys every %s", c.hotKeys, c.hotKeyInterval)
+	}
+
+	spill := "off"
+	if c.spilled != nil {
+		spill = fmt.Sprintf("above %d bytes to %s", c.spillAbove, c.spillDir)
+	}
+
+	return [][2]any{
+		{"max size", fmt.Sprintf("%d bytes", c.maxCacheSize)},
+		{"soft limit", softLimit},
+		{"deferred eviction", c.deferEviction},
+		{"eviction policy", c.evictionPolicy},
+		{"overflow policy", c.overflow},
+		{"retain hot", retainHot},
+		{"doorkeeper", doorkeeper},
+		{"frequency sketch", c.sketch != nil},
+		{"hot key replication", hotKeys},
+		{"generations", c.generational},
+		{"heap limit", heapLimit},
+		{"memory pressure threshold", pressureThreshold},
+		{"serializer", serializer},
+		{"compression", compression},
+		{"cold compression", coldCompression},
+		{"encryption", encryption},
+		{"spill", spill},
+		{"checksums", c.checksums},
+		{"scheduled clears", len(c.schedules)},
+		{"copy on set", c.copyOnSet != nil},
+		{"copy on get", c.copyOnGet != nil},
+		{"default ttl", time.Duration(c.defaultTTL.Load())},
+		{"ttl jitter", fmt.Sprintf("%.0f%%", c.ttlJitter*100)},
+		{"stale while revalidate", c.maxStale},
+		{"idle timeout", c.idleTimeout},
+		{"janitor", c.janitorInterval},
+		{"refresh ahead", c.refreshAhead},
+		{"early expiration", c.earlyExpiration},
+		{"loader", c.loader != nil},
+		{"load timeout", c.loadTimeout},
+		{"load attempts", max(c.retry.MaxAttempts, 1)},
+		{"negative caching", c.negativeTTL},
+		{"write through", c.store != nil},
+		{"write behind", c.writeBehind != nil},
+		{"invalidation", c.bus != nil},
+		{"subscribers", len(c.subscribers)},
+		{"drain", c.drain != nil},
+		{"trace recorder", c.recorder != nil},
+		{"top keys in stats", c.topKeys},
+		{"keyspace analysis", c.keyspace != nil},
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cache
+
+// DumpDiagnosticsOnSignal writes DumpDiagnostics to path every time the process receives
+// SIGUSR1. There's no SIGUSR1 on this platform, so it only logs that and does nothing.
+func (c *Cache) DumpDiagnosticsOnSignal(path string) (stop func()) {
+	c.logf("DumpDiagnosticsOnSignal: SIGUSR1 isn't supported on this platform")
+	return func() {}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package cache
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// DumpDiagnosticsOnSignal writes DumpDiagnostics to path every time the process receives
+// SIGUSR1, replacing the previous dump, or to the log if path is empty. It's for boxes
+// without remote admin access: `kill -USR1 <pid>` and read the file.
+//
+// Call stop to stop listening for the signal.
+func (c *Cache) DumpDiagnosticsOnSignal(path string) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				c.dumpDiagnosticsTo(path)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func (c *Cache) dumpDiagnosticsTo(path string) {
+	var buf bytes.Buffer
+	if err := c.DumpDiagnostics(&buf); err != nil {
+		c.logf("error writing diagnostics: %v", err)
+		return
+	}
+
+	if path == "" {
+		c.logf("%s", buf.Bytes())
+		return
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		c.logf("error writing diagnostics to %s: %v", path, err)
+		return
+	}
+	c.logf("wrote cache diagnostics to %s", path)
+}
@@ -0,0 +1,237 @@
+// Package dnscache caches DNS lookups in a cache.Cache for as long as their records' TTLs
+// allow, for services that open lots of outbound connections:
+//
+//	lookups := dnscache.New(cache.New(1<<20), nil)
+//	transport := &http.Transport{DialContext: lookups.DialContext}
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/internal/singleflight"
+)
+
+// Defaults for the options.
+const (
+	DefaultMinTTL      = time.Second
+	DefaultMaxTTL      = 5 * time.Minute
+	DefaultFallbackTTL = 30 * time.Second
+	DefaultKeyPrefix   = "dnscache:"
+)
+
+// Option configures a LookupCache.
+type Option func(*LookupCache)
+
+// WithMinTTL caches answers for at least ttl, even if their records say less.
+func WithMinTTL(ttl time.Duration) Option {
+	return func(l *LookupCache) {
+		l.minTTL = ttl
+	}
+}
+
+// WithMaxTTL caches answers for at most ttl, even if their records say more.
+func WithMaxTTL(ttl time.Duration) Option {
+	return func(l *LookupCache) {
+		l.maxTTL = ttl
+	}
+}
+
+// WithFallbackTTL caches answers that aren't from DNS, like /etc/hosts entries, for ttl.
+func WithFallbackTTL(ttl time.Duration) Option {
+	return func(l *LookupCache) {
+		l.fallbackTTL = ttl
+	}
+}
+
+// WithNegativeTTL caches "no such host" errors for ttl. They aren't cached by default.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(l *LookupCache) {
+		l.negativeTTL = ttl
+	}
+}
+
+// WithKeyPrefix changes the prefix put in front of every key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(l *LookupCache) {
+		l.prefix = prefix
+	}
+}
+
+// LookupCache resolves host names with a net.Resolver and caches the answers.
+//
+// net.Resolver doesn't report TTLs, so LookupCache uses a copy of it with PreferGo set and
+// Dial wrapped to read the TTLs from the DNS responses as they arrive. Answers are cached for
+// the lowest TTL of their records, including any CNAMEs, between the min and max TTL.
+type LookupCache struct {
+	cache    *cache.Cache
+	resolver *net.Resolver
+	ttls     *ttlRecorder
+	group    singleflight.Group
+
+	minTTL      time.Duration
+	maxTTL      time.Duration
+	fallbackTTL time.Duration
+	negativeTTL time.Duration
+	prefix      string
+}
+
+// New creates a LookupCache resolving with r, or net.DefaultResolver if r is nil, and caching in c.
+func New(c *cache.Cache, r *net.Resolver, opts ...Option) *LookupCache {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+
+	l := &LookupCache{
+		cache:       c,
+		ttls:        &ttlRecorder{ttls: make(map[string]uint32)},
+		minTTL:      DefaultMinTTL,
+		maxTTL:      DefaultMaxTTL,
+		fallbackTTL: DefaultFallbackTTL,
+		prefix:      DefaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	dial := r.Dial
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	l.resolver = &net.Resolver{
+		PreferGo:     true,
+		StrictErrors: r.StrictErrors,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			return l.ttls.wrap(conn, network), nil
+		},
+	}
+
+	return l
+}
+
+// failure is a cached lookup error.
+type failure struct {
+	err error
+}
+
+// LookupIPAddr is like net.Resolver.LookupIPAddr, but answers from the cache when it can.
+// The returned slice is shared with the cache and other callers, so it must not be modified.
+func (l *LookupCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := l.prefix + host
+
+	value, found := l.cache.Get(key)
+	if found {
+		switch v := value.(type) {
+		case []net.IPAddr:
+			return v, nil
+		case *failure:
+			return nil, v.err
+		}
+	}
+
+	// The lookup is shared, so it isn't cancelled with the first caller's ctx, and callers
+	// whose ctx is done stop waiting instead.
+	ch := l.group.DoChan(key, func() (any, error) {
+		return l.lookup(context.WithoutCancel(ctx), key, host)
+	})
+	select {
+	case res := <-ch:
+		addrs, _ := res.Value.([]net.IPAddr)
+		return addrs, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *LookupCache) lookup(ctx context.Context, key, host string) ([]net.IPAddr, error) {
+	addrs, err := l.resolver.LookupIPAddr(ctx, host)
+	ttl, fromDNS := l.ttls.take(host)
+
+	if err != nil {
+		var dnsErr *net.DNSError
+		if l.negativeTTL > 0 && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			l.cache.SetWithTTL(key, &failure{err: err}, l.negativeTTL)
+		}
+		return nil, err
+	}
+
+	expiry := l.fallbackTTL
+	if fromDNS {
+		expiry = min(max(time.Duration(ttl)*time.Second, l.minTTL), l.maxTTL)
+	}
+	l.cache.SetWithTTL(key, addrs, expiry)
+	return addrs, nil
+}
+
+// LookupHost is like net.Resolver.LookupHost, but answers from the cache when it can.
+func (l *LookupCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := l.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = addr.String()
+	}
+	return hosts, nil
+}
+
+// DialContext connects to address like net.Dialer.DialContext, resolving its host with the
+// cache and trying each address in turn until one connects. It can be used as an
+// http.Transport's DialContext.
+func (l *LookupCache) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	if net.ParseIP(host) != nil {
+		return d.DialContext(ctx, network, address)
+	}
+
+	addrs, err := l.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		if !matchesNetwork(network, addr.IP) {
+			continue
+		}
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// matchesNetwork reports whether ip can be dialed on network, e.g. only IPv4 addresses on "tcp4".
+func matchesNetwork(network string, ip net.IP) bool {
+	switch network[len(network)-1] {
+	case '4':
+		return ip.To4() != nil
+	case '6':
+		return ip.To4() == nil
+	}
+	return true
+}
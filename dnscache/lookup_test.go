@@ -0,0 +1,269 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+// record is a name served by dnsServer, either an A record for 127.0.0.1 or a CNAME.
+type record struct {
+	ttl   uint32
+	cname string
+}
+
+// dnsServer is a DNS server on a local UDP port answering for a fixed set of names, and
+// NXDOMAIN for everything else.
+type dnsServer struct {
+	conn    net.PacketConn
+	records map[string]record
+	queries atomic.Int64 // A queries, whether or not the name exists
+}
+
+func newDNSServer(t *testing.T, records map[string]record) *dnsServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &dnsServer{conn: conn, records: records}
+	go s.serve()
+	return s
+}
+
+func (s *dnsServer) serve() {
+	buf := make([]byte, 1<<16)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp, err := s.answer(buf[:n]); err == nil {
+			s.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *dnsServer) answer(msg []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	if q.Type == dnsmessage.TypeA {
+		s.queries.Add(1)
+	}
+
+	rh := dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true}
+	if _, found := s.records[q.Name.String()]; !found {
+		rh.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, rh)
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+
+	for name := q.Name.String(); ; {
+		r, found := s.records[name]
+		if !found {
+			break
+		}
+		rh := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: r.ttl}
+		if r.cname != "" {
+			b.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(r.cname)})
+			name = r.cname
+			continue
+		}
+		if q.Type == dnsmessage.TypeA {
+			b.AResource(rh, dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}})
+		}
+		break
+	}
+	return b.Finish()
+}
+
+// resolver returns a net.Resolver that sends every query to s.
+func (s *dnsServer) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.conn.LocalAddr().String())
+		},
+	}
+}
+
+// newLookupCache returns a LookupCache resolving with s, caching on a fake clock.
+func newLookupCache(t *testing.T, s *dnsServer, opts ...Option) (*LookupCache, *clocktest.Clock) {
+	t.Helper()
+
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock))
+	t.Cleanup(func() { c.Close() })
+	return New(c, s.resolver(), opts...), clock
+}
+
+// lookup resolves host with l and checks it resolved to 127.0.0.1.
+func lookup(t *testing.T, l *LookupCache, host string) {
+	t.Helper()
+
+	addrs, err := l.LookupIPAddr(context.Background(), host)
+	if err != nil {
+		t.Fatalf("looking up %s: %v", host, err)
+	}
+	if len(addrs) != 1 || !addrs[0].IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("%s resolved to %v, want 127.0.0.1", host, addrs)
+	}
+}
+
+func TestLookupRespectsTTL(t *testing.T) {
+	s := newDNSServer(t, map[string]record{"db.example.test.": {ttl: 60}})
+	l, clock := newLookupCache(t, s)
+
+	lookup(t, l, "db.example.test")
+	lookup(t, l, "db.example.test")
+	clock.Advance(59 * time.Second)
+	lookup(t, l, "db.example.test")
+	if got := s.queries.Load(); got != 1 {
+		t.Fatalf("three lookups within the TTL sent %d queries, want 1", got)
+	}
+
+	clock.Advance(2 * time.Second)
+	lookup(t, l, "db.example.test")
+	if got := s.queries.Load(); got != 2 {
+		t.Errorf("a lookup after the TTL sent %d queries in all, want 2", got)
+	}
+}
+
+func TestLookupClampsTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    uint32
+		opts   []Option
+		cached time.Duration // how long the answer should be cached for
+	}{
+		{"min", 1, []Option{WithMinTTL(10 * time.Second)}, 10 * time.Second},
+		{"max", 3600, []Option{WithMaxTTL(time.Minute)}, time.Minute},
+		{"default max", 86400, nil, DefaultMaxTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDNSServer(t, map[string]record{"db.example.test.": {ttl: tt.ttl}})
+			l, clock := newLookupCache(t, s, tt.opts...)
+
+			lookup(t, l, "db.example.test")
+			clock.Advance(tt.cached - time.Second)
+			lookup(t, l, "db.example.test")
+			if got := s.queries.Load(); got != 1 {
+				t.Fatalf("a record with a TTL of %ds was cached for less than %s", tt.ttl, tt.cached)
+			}
+
+			clock.Advance(2 * time.Second)
+			lookup(t, l, "db.example.test")
+			if got := s.queries.Load(); got != 2 {
+				t.Errorf("a record with a TTL of %ds was cached for more than %s", tt.ttl, tt.cached)
+			}
+		})
+	}
+}
+
+func TestLookupUsesLowestTTLInChain(t *testing.T) {
+	s := newDNSServer(t, map[string]record{
+		"www.example.test.": {ttl: 10, cname: "cdn.example.test."},
+		"cdn.example.test.": {ttl: 300},
+	})
+	l, clock := newLookupCache(t, s)
+
+	lookup(t, l, "www.example.test")
+	clock.Advance(11 * time.Second)
+	lookup(t, l, "www.example.test")
+	if got := s.queries.Load(); got != 2 {
+		t.Errorf("an answer through a CNAME with a TTL of 10s was cached for more than 10s (%d queries)", got)
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	for _, negativeTTL := range []time.Duration{0, time.Minute} {
+		t.Run(negativeTTL.String(), func(t *testing.T) {
+			s := newDNSServer(t, map[string]record{})
+			l, clock := newLookupCache(t, s, WithNegativeTTL(negativeTTL))
+
+			lookupMissing := func() {
+				t.Helper()
+				_, err := l.LookupIPAddr(context.Background(), "missing.example.test")
+				var dnsErr *net.DNSError
+				if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+					t.Fatalf("looking up a missing host returned %v, want a not found DNSError", err)
+				}
+			}
+
+			lookupMissing()
+			first := s.queries.Load()
+			lookupMissing()
+			second := s.queries.Load() - first
+			switch {
+			case negativeTTL == 0 && second == 0:
+				t.Error("a not found error was cached without WithNegativeTTL")
+			case negativeTTL > 0 && second != 0:
+				t.Errorf("a not found error wasn't cached with WithNegativeTTL(%s)", negativeTTL)
+			}
+
+			if negativeTTL > 0 {
+				clock.Advance(negativeTTL + time.Second)
+				lookupMissing()
+				if s.queries.Load() == first {
+					t.Error("a not found error was cached for longer than the negative TTL")
+				}
+			}
+		})
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	s := newDNSServer(t, map[string]record{"svc.example.test.": {ttl: 60}})
+	l, _ := newLookupCache(t, s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+	conn, err := l.DialContext(context.Background(), "tcp", net.JoinHostPort("svc.example.test", port))
+	if err != nil {
+		t.Fatalf("dialing through the cache: %v", err)
+	}
+	conn.Close()
+
+	// The host only has an IPv4 address.
+	_, err = l.DialContext(context.Background(), "tcp6", net.JoinHostPort("svc.example.test", port))
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Errorf("dialing tcp6 to a host with only IPv4 addresses returned %v, want a DNSError", err)
+	}
+	if got := s.queries.Load(); got != 1 {
+		t.Errorf("two dials sent %d queries, want 1", got)
+	}
+}
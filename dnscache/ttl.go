@@ -0,0 +1,145 @@
+package dnscache
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ttlRecorder remembers the lowest TTL of the answers to each name the resolver asks about,
+// since net.Resolver doesn't return TTLs itself.
+type ttlRecorder struct {
+	mu   sync.Mutex
+	ttls map[string]uint32
+}
+
+// observe records the answers' TTL from a DNS response.
+func (r *ttlRecorder) observe(msg []byte) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return
+	}
+	q, err := p.Question()
+	if err != nil {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+
+	lowest, found := uint32(0), false
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			break
+		}
+		if !found || h.TTL < lowest {
+			lowest, found = h.TTL, true
+		}
+		if err := p.SkipAnswer(); err != nil {
+			break
+		}
+	}
+	if !found {
+		return
+	}
+
+	name := normalize(q.Name.String())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ttl, seen := r.ttls[name]; !seen || lowest < ttl {
+		r.ttls[name] = lowest
+	}
+}
+
+// take returns and forgets the lowest TTL recorded for host, including under any search
+// domain it was resolved with, e.g. "db.internal.example.com" for "db".
+func (r *ttlRecorder) take(host string) (uint32, bool) {
+	host = normalize(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lowest, found := uint32(0), false
+	for name, ttl := range r.ttls {
+		if name != host && !strings.HasPrefix(name, host+".") {
+			continue
+		}
+		if !found || ttl < lowest {
+			lowest, found = ttl, true
+		}
+		delete(r.ttls, name)
+	}
+	return lowest, found
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// recordingConn passes DNS responses read from a connection to a ttlRecorder.
+type recordingConn struct {
+	net.Conn
+	rec *ttlRecorder
+
+	// stream is set for TCP, where each message is prefixed with its length and may take
+	// several reads. buf holds what's been read of the current message.
+	stream bool
+	buf    []byte
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.received(p[:n])
+	}
+	return n, err
+}
+
+func (c *recordingConn) received(b []byte) {
+	if !c.stream {
+		c.rec.observe(b)
+		return
+	}
+
+	c.buf = append(c.buf, b...)
+	for len(c.buf) >= 2 {
+		size := int(c.buf[0])<<8 | int(c.buf[1])
+		if len(c.buf) < 2+size {
+			return
+		}
+		c.rec.observe(c.buf[2 : 2+size])
+		c.buf = c.buf[2+size:]
+	}
+}
+
+// recordingPacketConn is a recordingConn for UDP. The resolver checks for net.PacketConn to
+// decide whether messages are length-prefixed, so it has to keep implementing it.
+type recordingPacketConn struct {
+	*recordingConn
+	packetConn net.PacketConn
+}
+
+func (c *recordingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.packetConn.ReadFrom(p)
+	if n > 0 {
+		c.rec.observe(p[:n])
+	}
+	return n, addr, err
+}
+
+func (c *recordingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	return c.packetConn.WriteTo(p, addr)
+}
+
+// wrap returns conn recording to rec.
+func (rec *ttlRecorder) wrap(conn net.Conn, network string) net.Conn {
+	rc := &recordingConn{Conn: conn, rec: rec, stream: strings.HasPrefix(network, "tcp")}
+	if pc, ok := conn.(net.PacketConn); ok {
+		return &recordingPacketConn{recordingConn: rc, packetConn: pc}
+	}
+	return rc
+}
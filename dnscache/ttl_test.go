@@ -0,0 +1,77 @@
+package dnscache
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// response returns a DNS response for name with an A record for each TTL.
+func response(t *testing.T, name string, ttls ...uint32) []byte {
+	t.Helper()
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET})
+	b.StartAnswers()
+	for i, ttl := range ttls {
+		rh := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: ttl}
+		b.AResource(rh, dnsmessage.AResource{A: [4]byte{10, 0, 0, byte(i)}})
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestRecorderTakesLowestTTL(t *testing.T) {
+	rec := &ttlRecorder{ttls: make(map[string]uint32)}
+	rec.observe(response(t, "DB.example.test.", 300, 60, 120))
+	rec.observe(response(t, "db.internal.example.test.", 30))
+	rec.observe(response(t, "dbx.example.test.", 5))
+
+	// "db" under a search domain counts, but "dbx" doesn't.
+	if ttl, found := rec.take("db"); !found || ttl != 30 {
+		t.Errorf("take(db) = %d, %v, want 30, true", ttl, found)
+	}
+	if ttl, found := rec.take("db.example.test"); found {
+		t.Errorf("take returned a TTL of %d that was already taken", ttl)
+	}
+	if ttl, found := rec.take("dbx.example.test"); !found || ttl != 5 {
+		t.Errorf("take(dbx.example.test) = %d, %v, want 5, true", ttl, found)
+	}
+}
+
+func TestRecordingConnStream(t *testing.T) {
+	rec := &ttlRecorder{ttls: make(map[string]uint32)}
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := rec.wrap(client, "tcp")
+
+	// Two length-prefixed messages, written a few bytes at a time so they take several reads.
+	var stream []byte
+	for _, msg := range [][]byte{response(t, "a.example.test.", 40), response(t, "b.example.test.", 50)} {
+		stream = append(stream, byte(len(msg)>>8), byte(len(msg)))
+		stream = append(stream, msg...)
+	}
+	go func() {
+		for len(stream) > 0 {
+			n := min(7, len(stream))
+			server.Write(stream[:n])
+			stream = stream[n:]
+		}
+		server.Close()
+	}()
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	for host, want := range map[string]uint32{"a.example.test": 40, "b.example.test": 50} {
+		if ttl, found := rec.take(host); !found || ttl != want {
+			t.Errorf("take(%s) = %d, %v, want %d, true", host, ttl, found, want)
+		}
+	}
+}
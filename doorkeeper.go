@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"hash/fnv"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// doorkeeperHashes is how many bits each key sets in the doorkeeper's bloom filter, and
+// doorkeeperBitsPerKey how big it is per key, which together give about a 1% false positive rate.
+const (
+	doorkeeperHashes     = 7
+	doorkeeperBitsPerKey = 9.6
+)
+
+// WithDoorkeeper only caches a key the second time it's written or loaded within window, so keys
+// that are only ever used once never take up any of the budget. Keys are remembered in a bloom
+// filter sized for keys different keys per window, which is forgotten every window, or as soon
+// as it's seen that many keys, whichever comes first. A window of 0 only does the latter.
+//
+// About 1% of first writes are cached anyway, when the filter mistakes them for keys it's seen.
+// Writes to keys that are already cached are always cached, as are values from Warm, seed files
+// and writes in write-behind mode, which have to be cached until they're written to the store.
+// Writes that aren't cached still go through to the store, and are counted in Stats.NotAdmitted.
+func WithDoorkeeper(keys int, window time.Duration) Option {
+	return func(c *Cache) {
+		if keys <= 0 {
+			c.logf("ignoring doorkeeper for %d keys, it must be at least 1", keys)
+			return
+		}
+
+		words := int(math.Ceil(float64(keys) * doorkeeperBitsPerKey / 64))
+		c.doorkeeper = &doorkeeper{
+			bits:     make([]atomic.Uint64, words),
+			capacity: int64(keys),
+			window:   window,
+			seed:     maphash.MakeSeed(),
+		}
+	}
+}
+
+// doorkeeper is a bloom filter of recently written keys for WithDoorkeeper. It's safe for
+// concurrent use without c.mu.
+type doorkeeper struct {
+	bits     []atomic.Uint64
+	capacity int64
+	window   time.Duration
+	seed     maphash.Seed
+
+	added     atomic.Int64 // keys added since the last reset
+	resetAt   atomic.Int64 // unix nanoseconds, 0 until the first key is added
+	resetting sync.Mutex
+}
+
+// empty returns a filter like d with no keys in it.
+func (d *doorkeeper) empty() *doorkeeper {
+	return &doorkeeper{
+		bits:     make([]atomic.Uint64, len(d.bits)),
+		capacity: d.capacity,
+		window:   d.window,
+		seed:     d.seed,
+	}
+}
+
+// seen adds key to the filter, reporting whether it was already in it.
+func (d *doorkeeper) seen(key string, now int64, deterministic bool) bool {
+	if d.added.Load() >= d.capacity || (d.window > 0 && now >= d.resetAt.Load()) {
+		d.reset(now)
+	}
+
+	h := hashKey(d.seed, key, deterministic)
+
+	// Double hashing derives every bit position from the two halves of one hash.
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	n := uint32(len(d.bits) * 64)
+	found := true
+	for j := range uint32(doorkeeperHashes) {
+		bit := (h1 + j*h2) % n
+		mask := uint64(1) << (bit % 64)
+		if d.bits[bit/64].Or(mask)&mask == 0 {
+			found = false
+		}
+	}
+
+	if !found {
+		d.added.Add(1)
+	}
+	return found
+}
+
+// reset forgets every key. If several callers find the filter due for a reset at once, only
+// one of them resets it.
+func (d *doorkeeper) reset(now int64) {
+	if !d.resetting.TryLock() {
+		return
+	}
+	defer d.resetting.Unlock()
+
+	if d.added.Load() < d.capacity && (d.window <= 0 || now < d.resetAt.Load()) {
+		return // another caller just reset it
+	}
+	for i := range d.bits {
+		d.bits[i].Store(0)
+	}
+	d.added.Store(0)
+	d.resetAt.Store(now + int64(d.window))
+}
+
+// hashKey hashes key with seed, or in deterministic mode without it, since maphash's seeds are
+// random, which would make bloom filter and sketch collisions differ from run to run.
+func hashKey(seed maphash.Seed, key string, deterministic bool) uint64 {
+	if !deterministic {
+		return maphash.String(seed, key)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// admitted reports whether key should be cached, for WithDoorkeeper: because it's been written
+// before within the window, or it's already cached. Writes that aren't are counted.
+func (c *Cache) admitted(key string) bool {
+	if c.doorkeeper == nil || c.doorkeeper.seen(key, c.now(), c.deterministic()) {
+		return true
+	}
+
+	c.mu.RLock()
+	_, cached := c.items[key]
+	c.mu.RUnlock()
+
+	if !cached {
+		c.notAdmitted.Add(1)
+	}
+	return cached
+}
@@ -0,0 +1,57 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+func TestDoorkeeper(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock), cache.WithDeterministic(1), cache.WithDoorkeeper(1000, time.Hour))
+	defer c.Close()
+
+	c.Set("key", "first")
+	if _, found := c.Get("key"); found {
+		t.Fatal("first write was cached")
+	}
+	if got := c.Stats().NotAdmitted; got != 1 {
+		t.Errorf("NotAdmitted = %d, want 1", got)
+	}
+
+	c.Set("key", "second")
+	c.Set("key", "third")
+	if got, _ := c.Get("key"); got != "third" {
+		t.Errorf("after writing it again, got %v, want third", got)
+	}
+
+	c.Set("forgotten", "value")
+	clock.Advance(2 * time.Hour)
+	c.Set("forgotten", "value")
+	if _, found := c.Get("forgotten"); found {
+		t.Error("second write, outside the window, was cached")
+	}
+	if got := c.Stats().NotAdmitted; got != 3 {
+		t.Errorf("NotAdmitted = %d, want 3", got)
+	}
+}
+
+func TestDoorkeeperCapacity(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithDeterministic(1), cache.WithDoorkeeper(3, 0))
+	defer c.Close()
+
+	c.Set("a", "value")
+	c.Set("b", "value")
+	c.Set("c", "value")
+	c.Set("d", "value") // the filter is full, so it forgets a, b and c
+	c.Set("a", "value")
+	if _, found := c.Get("a"); found {
+		t.Error("key written before the filter was reset was still remembered")
+	}
+	c.Set("a", "value")
+	if _, found := c.Get("a"); !found {
+		t.Error("key written twice since the filter was reset wasn't cached")
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+// WithDrain hands every item dropped when the cache clears itself for going over maxCacheSize to
+// fn, so it can be persisted or demoted to a slower tier instead of lost. Expired items and items
+// kept by WithRetainHot aren't drained.
+//
+// Unlike WithOnEvict, fn is called from its own goroutine once the cache is unlocked, so it can
+// be slow, and it can call back into the cache.
+func WithDrain(fn func(key string, value any)) Option {
+	return func(c *Cache) {
+		c.drain = fn
+	}
+}
+
+// Drain removes every item from the cache, like Clear, and calls fn with each of them, after the
+// cache has been unlocked. It returns how many items were drained, not counting expired ones,
+// which are dropped.
+func (c *Cache) Drain(fn func(key string, value any)) int {
+	c.mu.Lock()
+	items := c.detach(nil)
+	c.reset()
+	c.notify(Event{Type: EventClear})
+	c.logf("cache drained. size reset to 0 bytes.")
+	c.mu.Unlock()
+
+	c.drainItems(items, fn)
+	return len(items)
+}
+
+// drainedItem is an item taken out of the cache to be drained.
+type drainedItem struct {
+	key    string
+	stored any
+}
+
+// detach returns every live item not in keep, to be drained, and discards expired ones. The
+// caller must reset the cache afterwards. c.mu must already be locked.
+func (c *Cache) detach(keep map[string]bool) []drainedItem {
+	now := c.now()
+
+	items := make([]drainedItem, 0, len(c.items))
+	for key, i := range c.all() {
+		switch {
+		case keep[key]:
+		case c.arena.entry(i).expired(now):
+			c.discard(c.arena.value(i))
+		default:
+			items = append(items, drainedItem{key: key, stored: c.arena.value(i)})
+		}
+	}
+	return items
+}
+
+// dropAllExcept drops every item not in keep as the cache clears itself, draining them if the
+// cache was created WithDrain. The caller must reset the cache afterwards. c.mu must already be locked.
+func (c *Cache) dropAllExcept(keep map[string]bool) {
+	switch {
+	case c.drain != nil:
+		if items := c.detach(keep); len(items) > 0 {
+			go c.drainItems(items, c.drain)
+		}
+	case c.discards():
+		for key, i := range c.items {
+			if !keep[key] {
+				c.discard(c.arena.value(i))
+			}
+		}
+	}
+}
+
+// drainItems calls fn with each detached item, and discards them afterwards. It's called without
+// c.mu locked, and locks it again to discard them, since WithBufferRelease's release is only
+// ever called with the cache locked.
+func (c *Cache) drainItems(items []drainedItem, fn func(key string, value any)) {
+	for _, item := range items {
+		if value, ok := c.output(item.key, item.stored); ok {
+			fn(item.key, value)
+		}
+	}
+
+	if !c.discards() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, item := range items {
+		c.discard(item.stored)
+	}
+}
@@ -0,0 +1,48 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestDrainReleasesBuffers(t *testing.T) {
+	// released isn't synchronized, since release is only ever called with the cache locked.
+	var released int
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithBufferRelease(func(b []byte) { released++ }))
+	defer c.Close()
+
+	for i := range 10 {
+		c.Set("key"+strconv.Itoa(i), []byte("value"))
+	}
+	n := c.Drain(func(key string, value any) {
+		if released > 0 {
+			t.Errorf("%q was drained after buffers had been released", key)
+		}
+	})
+	if n != 10 || released != 10 {
+		t.Errorf("draining 10 buffers drained %d and released %d", n, released)
+	}
+}
+
+func TestDrainReleasesBuffersWhileLocked(t *testing.T) {
+	var released int
+	c := cache.New(1000, cache.WithLogger(nil),
+		cache.WithBufferRelease(func(b []byte) { released++ }),
+		cache.WithDrain(func(key string, value any) {}))
+	defer c.Close()
+
+	// Replacing keys releases buffers from the writers, while clearing for going over the
+	// limit releases them from the drain goroutine. Run with -race to see them collide.
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Go(func() {
+			for i := range 500 {
+				c.Set("key"+strconv.Itoa(w*10+i%10), make([]byte, 50))
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,148 @@
+package cache
+
+import "fmt"
+
+// encodedValue is how values serialized by WithSerializer, compressed by WithCompression or
+// encrypted by WithEncryption are kept in the arena.
+type encodedValue struct {
+	data []byte
+
+	string     bool // the value was a string rather than a []byte
+	serialized bool // the value was neither, and data is its serialized form
+	compressed bool
+	cold       bool // data was compressed by WithColdCompression rather than when it was set
+	encrypted  bool
+	keyID      uint32 // the KeyRing key data was encrypted with
+
+	spill *spillFile // if set, data is in a file instead, see WithSpill
+}
+
+func (v *encodedValue) Size() int64 {
+	if v.spill != nil {
+		return spillHandleSize
+	}
+	return int64(len(v.data))
+}
+
+// bytes returns v's data, reading it back in if it was spilled.
+func (v *encodedValue) bytes() ([]byte, error) {
+	if v.spill != nil {
+		return v.spill.read()
+	}
+	return v.data, nil
+}
+
+// encodes reports whether values are serialized, compressed, encrypted or spilled before being stored.
+func (c *Cache) encodes() bool {
+	return c.serializer != nil || c.codec != nil || c.keys != nil || c.spillDir != ""
+}
+
+// stored returns value as it should be kept in the arena: serialized if the cache was created
+// WithSerializer, compressed if it's worth it, encrypted if the cache was created WithEncryption,
+// and otherwise copied if it was created WithCopyOnSet, since the rest already copy. Callers
+// get it before locking the cache.
+func (c *Cache) stored(key string, value any) (any, error) {
+	if !c.encodes() {
+		return c.copied(value), nil
+	}
+
+	v := &encodedValue{}
+	switch value := value.(type) {
+	case []byte:
+		v.data = value
+	case string:
+		v.data, v.string = []byte(value), true
+	default:
+		if c.serializer != nil {
+			data, err := c.serializer.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("cache: serializing %q: %w", key, err)
+			}
+			v.data, v.serialized = data, true
+			break
+		}
+		if c.keys != nil {
+			return nil, fmt.Errorf("%w: %q is %T", ErrUnencryptable, key, value)
+		}
+		return c.copied(value), nil
+	}
+
+	if c.codec != nil && len(v.data) >= c.compressAbove {
+		data, err := c.codec.Compress(v.data)
+		switch {
+		case err != nil:
+			c.logf("error compressing %q, caching it uncompressed: %v", key, err)
+		case len(data) < len(v.data):
+			v.data, v.compressed = data, true
+		}
+	}
+
+	if c.keys != nil {
+		if err := c.keys.seal(key, v); err != nil {
+			return nil, fmt.Errorf("cache: encrypting %q: %w", key, err)
+		}
+	}
+
+	if err := c.spill(key, v); err != nil {
+		return nil, err
+	}
+
+	if !v.serialized && !v.compressed && !v.encrypted && v.spill == nil {
+		return c.copied(value), nil
+	}
+	return v, nil
+}
+
+// copied returns a copy of value if the cache was created WithCopyOnSet.
+func (c *Cache) copied(value any) any {
+	if c.copyOnSet == nil {
+		return value
+	}
+	return c.copyOnSet(value)
+}
+
+// decode returns the value that was set for stored. found is false if it couldn't be
+// decrypted or decompressed, e.g. because its key was removed from the KeyRing.
+func (c *Cache) decode(key string, stored any) (value any, found bool) {
+	v, ok := stored.(*encodedValue)
+	if !ok {
+		return stored, true
+	}
+
+	b, err := v.bytes()
+	if err != nil {
+		c.logf("error reading %q: %v", key, err)
+		return nil, false
+	}
+	if v.encrypted {
+		if b, err = c.keys.open(key, v.keyID, b); err != nil {
+			c.logf("error decrypting %q: %v", key, err)
+			return nil, false
+		}
+	}
+	if v.compressed {
+		if b, err = c.codec.Decompress(b); err != nil {
+			c.logf("error decompressing %q: %v", key, err)
+			return nil, false
+		}
+	}
+	if v.cold {
+		if b, err = c.coldCodec.Decompress(b); err != nil {
+			c.logf("error decompressing %q: %v", key, err)
+			return nil, false
+		}
+	}
+
+	switch {
+	case v.serialized:
+		value, err := c.serializer.Unmarshal(b)
+		if err != nil {
+			c.logf("error deserializing %q: %v", key, err)
+			return nil, false
+		}
+		return value, true
+	case v.string:
+		return string(b), true
+	}
+	return b, true
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnencryptable is returned by SetE for values other than []byte and string when the cache
+// was created WithEncryption, since only those can be encrypted. Set drops them and logs why.
+// Encode other values before caching them, or create the cache WithSerializer too.
+var ErrUnencryptable = errors.New("cache: only []byte and string values can be encrypted")
+
+// WithEncryption encrypts every value with the current key in keys before caching it, and
+// decrypts it on Get, so cached PII doesn't sit in memory, or in a core dump, in the clear.
+// Each value is bound to its key, so ciphertexts can't be swapped between keys.
+//
+// Only []byte and string values can be cached unless the cache also has a serializer, see ErrUnencryptable. Stores, subscribers,
+// Range and the network servers all see values decrypted, so use TLS between instances.
+// Expect every Get to allocate.
+func WithEncryption(keys *KeyRing) Option {
+	return func(c *Cache) {
+		c.keys = keys
+	}
+}
+
+// KeyRing holds the keys WithEncryption encrypts values with. New values are encrypted with
+// the current key, and values encrypted with any key still in the ring can be read, so keys
+// can be rotated without losing what's cached:
+//
+//	keys.Add(2, newAEAD)
+//	keys.Rotate(2)   // new values use key 2
+//	c.Reencrypt()    // move existing values over
+//	keys.Remove(1)
+//
+// It's safe for concurrent use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[uint32]cipher.AEAD
+	current uint32
+}
+
+// NewKeyRing returns a KeyRing that encrypts with aead, under key id.
+func NewKeyRing(id uint32, aead cipher.AEAD) *KeyRing {
+	return &KeyRing{
+		keys:    map[uint32]cipher.AEAD{id: aead},
+		current: id,
+	}
+}
+
+// NewAESGCM returns an AES-GCM AEAD for a 16, 24 or 32 byte key, to add to a KeyRing.
+func NewAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Add adds aead to the ring under id, replacing any key already using id. It doesn't start
+// being used for new values until it's made current with Rotate.
+func (r *KeyRing) Add(id uint32, aead cipher.AEAD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id] = aead
+}
+
+// Rotate makes key id the one new values are encrypted with.
+func (r *KeyRing) Rotate(id uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[id]; !ok {
+		return fmt.Errorf("cache: no key %d in key ring", id)
+	}
+	r.current = id
+	return nil
+}
+
+// Remove removes key id. Values still encrypted with it can't be read anymore and are
+// treated as missing. The current key can't be removed.
+func (r *KeyRing) Remove(id uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id == r.current {
+		return fmt.Errorf("cache: can't remove current key %d", id)
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+// Current returns the id of the key new values are encrypted with.
+func (r *KeyRing) Current() uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current
+}
+
+// seal encrypts v.data with the current key, using the cache key as additional data.
+func (r *KeyRing) seal(key string, v *encodedValue) error {
+	r.mu.RLock()
+	id, aead := r.current, r.keys[r.current]
+	r.mu.RUnlock()
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(v.data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	v.data = aead.Seal(nonce, nonce, v.data, []byte(key))
+	v.encrypted, v.keyID = true, id
+	return nil
+}
+
+// open decrypts data, which was sealed for key with key id.
+func (r *KeyRing) open(key string, id uint32, data []byte) ([]byte, error) {
+	r.mu.RLock()
+	aead, ok := r.keys[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("key %d isn't in the key ring", id)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(key))
+}
+
+// reencryptBatch is how many items Reencrypt re-encrypts per lock.
+const reencryptBatch = 128
+
+// Reencrypt re-encrypts every item that isn't encrypted with the KeyRing's current key, so
+// old keys can be removed after a rotation. It locks the cache in small batches, so it can
+// run alongside other operations. It returns how many items it re-encrypted.
+func (c *Cache) Reencrypt() int {
+	if c.keys == nil {
+		return 0
+	}
+	current := c.keys.Current()
+
+	c.mu.RLock()
+	var stale []string
+	for key, i := range c.items {
+		if v, ok := c.arena.value(i).(*encodedValue); ok && v.encrypted && v.keyID != current {
+			stale = append(stale, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	var n int
+	for len(stale) > 0 {
+		batch := stale[:min(reencryptBatch, len(stale))]
+		stale = stale[len(batch):]
+
+		c.mu.Lock()
+		for _, key := range batch {
+			if c.reencrypt(key, current) {
+				n++
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	c.logf("re-encrypted %d items with key %d", n, current)
+	return n
+}
+
+// reencrypt re-encrypts key with the current key in place. c.mu must already be locked.
+func (c *Cache) reencrypt(key string, current uint32) bool {
+	i, e := c.lookup(key)
+	if e == nil {
+		return false
+	}
+	old, ok := c.arena.value(i).(*encodedValue)
+	if !ok || !old.encrypted || old.keyID == current {
+		return false
+	}
+
+	data, err := old.bytes()
+	if err != nil {
+		c.logf("error reading %q to re-encrypt it: %v", key, err)
+		return false
+	}
+	plain, err := c.keys.open(key, old.keyID, data)
+	if err != nil {
+		c.logf("error decrypting %q to re-encrypt it: %v", key, err)
+		return false
+	}
+
+	v := &encodedValue{data: plain, string: old.string, serialized: old.serialized, compressed: old.compressed}
+	if err := c.keys.seal(key, v); err != nil {
+		c.logf("error re-encrypting %q: %v", key, err)
+		return false
+	}
+	if old.spill != nil {
+		if err := c.spill(key, v); err != nil {
+			c.logf("error re-encrypting %q: %v", key, err)
+			return false
+		}
+		c.dropSpill(old)
+	}
+
+	// The new key's AEAD may have a different overhead.
+	c.totalCacheSize += v.Size() - e.size
+	c.leaveGeneration(key, e)
+	e.size = v.Size()
+	if e.generation == c.generation {
+		c.generationSize.Add(generationSize(key, e))
+	}
+	c.unreplicate(key)
+	c.arena.replace(i, v, *e)
+	return true
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NewFromEnv creates a cache configured by environment variables, so a service can be tuned
+// without code changes. Each Config field is read from prefix followed by the field's name in
+// config files, upper cased, e.g. with prefix "CACHE":
+//
+//	CACHE_MAX_SIZE=67108864
+//	CACHE_SOFT_LIMIT=50331648
+//	CACHE_DEFAULT_TTL=5m
+//	CACHE_EVICTION_POLICY=least-hit
+//
+// Unset variables leave the field at its zero value. opts are applied after the environment.
+func NewFromEnv(prefix string, opts ...Option) (*Cache, error) {
+	cfg, err := ConfigFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(cfg, opts...)
+}
+
+// ConfigFromEnv reads a Config from environment variables, see NewFromEnv. It only reports
+// variables that can't be parsed; use Config.Validate to check the result makes sense.
+func ConfigFromEnv(prefix string) (Config, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	var (
+		cfg  Config
+		errs []error
+	)
+
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		env := prefix + strings.ToUpper(name)
+
+		s, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(v.Field(i), strings.TrimSpace(s)); err != nil {
+			errs = append(errs, fmt.Errorf("cache: parsing %s=%q: %w", env, s, err))
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+func setFromEnv(field reflect.Value, s string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch field.Kind() {
+	case reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package cache
+
+import "time"
+
+// EventType describes the kind of change an Event is for.
+type EventType int
+
+const (
+	// EventSet is sent when an item is added or replaced, or its expiration changes.
+	EventSet EventType = iota + 1
+
+	// EventDelete is sent when a single item is removed.
+	EventDelete
+
+	// EventClear is sent when every item is removed, either by Clear or because the cache got too big.
+	EventClear
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventClear:
+		return "clear"
+	}
+	return "unknown"
+}
+
+// EventReason says why items were removed, for EventDelete and EventClear.
+type EventReason int
+
+const (
+	// ReasonExplicit is for removals the application asked for, with Delete, Clear and the
+	// like, or that a peer sent as invalidations. It's the zero value.
+	ReasonExplicit EventReason = iota
+
+	// ReasonEvicted is for items evicted, or cleared, to keep the cache within its limits.
+	ReasonEvicted
+
+	// ReasonExpired is for items removed once their TTL ran out.
+	ReasonExpired
+
+	// ReasonIdle is for items evicted for not being read, see WithIdleTimeout.
+	ReasonIdle
+
+	// ReasonRotated is for items dropped with the previous generation, see WithGenerations.
+	ReasonRotated
+
+	// ReasonCorrupted is for items that failed checksum verification, see WithChecksums.
+	ReasonCorrupted
+)
+
+func (r EventReason) String() string {
+	switch r {
+	case ReasonExplicit:
+		return "explicit"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonIdle:
+		return "idle"
+	case ReasonRotated:
+		return "rotated"
+	case ReasonCorrupted:
+		return "corrupted"
+	}
+	return "unknown"
+}
+
+// Event describes a single change to the cache.
+type Event struct {
+	Type EventType
+
+	// Key is empty for EventClear.
+	Key string
+
+	// Value and ExpiresAt are only set for EventSet. ExpiresAt is zero if the item never expires.
+	Value     any
+	ExpiresAt time.Time
+
+	// Reason is why the items were removed, for EventDelete and EventClear.
+	Reason EventReason
+}
+
+// Local reports whether ev only removed items because of this cache's own limits or state,
+// rather than because the application asked for it, so copies elsewhere are still valid and
+// shouldn't be removed along with them.
+func (ev Event) Local() bool {
+	return ev.Reason != ReasonExplicit
+}
+
+type subscriber struct {
+	id int
+	fn func(Event)
+}
+
+// Subscribe registers fn to be called for every change to the cache and returns a func that unsubscribes it.
+//
+// fn is called synchronously while the cache is locked so events arrive in order,
+// which also means fn must be quick and must not call back into the cache.
+// Hand events off to a channel or goroutine for anything slower.
+func (c *Cache) Subscribe(fn func(Event)) (unsubscribe func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextSubscriberID++
+	id := c.nextSubscriberID
+	c.subscribers = append(c.subscribers, subscriber{id: id, fn: fn})
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, s := range c.subscribers {
+			if s.id == id {
+				c.subscribers = append(c.subscribers[:i:i], c.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify sends ev to every subscriber and broadcasts it to peers if invalidation is enabled.
+// c.mu must already be locked.
+func (c *Cache) notify(ev Event) {
+	c.notifySubscribers(ev)
+
+	if c.bus != nil {
+		c.bus.publish(ev)
+	}
+}
+
+// notifySubscribers sends ev to every subscriber only. c.mu must already be locked.
+func (c *Cache) notifySubscribers(ev Event) {
+	for _, s := range c.subscribers {
+		s.fn(ev)
+	}
+}
+
+// setEvent builds the EventSet for an entry.
+func setEvent(key string, value any, e *entry) Event {
+	ev := Event{Type: EventSet, Key: key, Value: value}
+	if e.expiresAt > 0 {
+		ev.ExpiresAt = time.Unix(0, e.expiresAt)
+	}
+	return ev
+}
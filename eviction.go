@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"sync/atomic"
+)
+
+// evictionSample is how many items are compared to choose each one to evict.
+// Comparing a few random items gets close to evicting the best one without tracking order.
+const evictionSample = 5
+
+// softEvictionBatch is the most items the background evictor removes per lock, so reads and
+// writes can interleave with a long eviction.
+const softEvictionBatch = 128
+
+// WithSoftLimit evicts items gradually in the background once the cache grows past softLimit,
+// instead of clearing everything when it hits maxCacheSize. maxCacheSize becomes the hard limit:
+// if a write takes the cache past it anyway, the write evicts down to softLimit before returning.
+//
+// Items are evicted a few at a time, picking expired items first and otherwise the oldest of
+// a small random sample. Evictions aren't broadcast to peers.
+func WithSoftLimit(softLimit int64) Option {
+	return func(c *Cache) {
+		c.softLimit = softLimit
+		c.startEvictor()
+	}
+}
+
+// EvictionPolicy decides which items WithSoftLimit, WithDeferredEviction and SetMaxCacheSize evict first.
+// Whatever the policy, expired items are evicted before live ones: out of the sample compared for
+// each eviction, or for EvictSieve and EvictS3FIFO, as soon as they get to the end of a queue.
+type EvictionPolicy int
+
+const (
+	// EvictOldest evicts the items that were set longest ago. It's the default.
+	EvictOldest EvictionPolicy = iota
+
+	// EvictLeastHit evicts the items that have been read the fewest times since they were set,
+	// oldest first when that's tied.
+	EvictLeastHit
+
+	// EvictSieve evicts with SIEVE: items are kept in the order they were set, and a hand moves
+	// from the oldest towards the newest, evicting the first item that hasn't been read since the
+	// hand last passed it. It keeps popular items better than EvictOldest on most workloads, and
+	// unlike LRU, a read only sets a bit rather than moving the item, so reads stay lock-light.
+	EvictSieve
+
+	// EvictS3FIFO evicts with S3-FIFO: new items go in a small queue, and only move to the main
+	// queue if they're read again before they reach its end, so a scan through keys that are
+	// never read again only ever displaces other new items. Keys evicted from the small queue
+	// are remembered for a while, without their values, and go straight to the main queue if
+	// they're set again. Like EvictSieve, reads only update a counter.
+	EvictS3FIFO
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictOldest:
+		return "oldest"
+	case EvictLeastHit:
+		return "least-hit"
+	case EvictSieve:
+		return "sieve"
+	case EvictS3FIFO:
+		return "s3-fifo"
+	}
+	return "unknown"
+}
+
+// MarshalText writes p as its name, e.g. "least-hit", so it reads well in config files.
+func (p EvictionPolicy) MarshalText() ([]byte, error) {
+	if p.String() == "unknown" {
+		return nil, fmt.Errorf("cache: unknown eviction policy %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *EvictionPolicy) UnmarshalText(text []byte) error {
+	for _, policy := range []EvictionPolicy{EvictOldest, EvictLeastHit, EvictSieve, EvictS3FIFO} {
+		if string(text) == policy.String() {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("cache: unknown eviction policy %q", text)
+}
+
+// WithEvictionPolicy changes which items are evicted first, see EvictionPolicy.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *Cache) {
+		c.evictionPolicy = policy
+		c.queued = newQueuedPolicy(policy)
+	}
+}
+
+// newQueuedPolicy returns empty queues for policy, or nil if it samples items instead.
+func newQueuedPolicy(policy EvictionPolicy) queuedPolicy {
+	switch policy {
+	case EvictSieve:
+		return &sieve{}
+	case EvictS3FIFO:
+		return &s3fifo{}
+	}
+	return nil
+}
+
+// requeue replaces the queues with new ones for c.evictionPolicy holding every item in the
+// cache, oldest first, or drops them if the policy samples items instead. c.mu must already be
+// locked.
+func (c *Cache) requeue() {
+	c.queued = newQueuedPolicy(c.evictionPolicy)
+	if c.queued == nil {
+		return
+	}
+
+	keys := slices.Collect(maps.Keys(c.items))
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(c.arena.entry(c.items[a]).createdAt, c.arena.entry(c.items[b]).createdAt), cmp.Compare(a, b))
+	})
+	for _, key := range keys {
+		c.queued.add(key, c.items[key])
+	}
+}
+
+// WithOnEvict calls fn with every item evicted to make room, but not with items that are deleted,
+// expire or are dropped when the whole cache clears. Like Subscribe, fn is called while the cache
+// is locked, so it must be quick and must not call back into the cache.
+func WithOnEvict(fn func(key string, value any)) Option {
+	return func(c *Cache) {
+		c.onEvict = fn
+	}
+}
+
+// WithDeferredEviction takes eviction off the write path entirely: a write that takes the cache
+// past maxCacheSize only marks it as over budget, and the background evictor evicts in small
+// batches until it fits again, so no single Set pays for evicting thousands of items. The cache
+// can briefly be bigger than maxCacheSize while the evictor catches up.
+//
+// Combined with WithSoftLimit, the evictor evicts down to the soft limit, and maxCacheSize is no
+// longer enforced by writes.
+func WithDeferredEviction() Option {
+	return func(c *Cache) {
+		c.deferEviction = true
+		c.startEvictor()
+	}
+}
+
+func (c *Cache) startEvictor() {
+	if c.evictor != nil {
+		return
+	}
+	c.evictor = make(chan struct{}, 1)
+	go c.evictInBackground()
+}
+
+// evictTarget is the size the background evictor evicts down to: the soft limit if there is
+// one, otherwise maxCacheSize. c.mu must already be locked.
+func (c *Cache) evictTarget() int64 {
+	if c.softLimit > 0 {
+		return c.softLimit
+	}
+	return c.maxCacheSize
+}
+
+// evict removes up to max items, or every item if max is 0, until the cache is at most
+// target bytes, and returns how many it removed. c.mu must already be locked.
+func (c *Cache) evict(target int64, max int) int {
+	now := c.now()
+
+	var evicted int
+	for c.totalCacheSize > target && len(c.items) > 0 && (max == 0 || evicted < max) {
+		key, i := c.evictionCandidate(now)
+		if c.onEvict != nil {
+			c.onEvict(key, c.arena.value(i))
+		}
+		c.remove(key, i)
+		c.evictions++
+		c.notifySubscribers(Event{Type: EventDelete, Key: key, Reason: ReasonEvicted})
+		evicted++
+	}
+	return evicted
+}
+
+// evictionCandidate picks the item to evict next out of a random sample, or from the queues of
+// EvictSieve and EvictS3FIFO.
+// c.mu must already be locked.
+func (c *Cache) evictionCandidate(now int64) (string, uint32) {
+	if c.queued != nil {
+		return c.queued.victim(&c.arena, now)
+	}
+
+	var (
+		victimKey  string
+		victimSlot uint32
+		victim     *entry
+		n          int
+	)
+
+	// Ranging over a map starts at a random position, which is the sample.
+	sample := maps.All(c.items)
+	if c.deterministic() {
+		sample = c.sampleDeterministically
+	}
+	for key, i := range sample {
+		if e := c.arena.entry(i); victim == nil || c.evictsBefore(key, e, victimKey, victim, now) {
+			victimKey, victimSlot, victim = key, i, e
+		}
+		if n++; n == evictionSample {
+			break
+		}
+	}
+	return victimKey, victimSlot
+}
+
+// evictsBefore reports whether a should be evicted before b, keyed aKey and bKey. With
+// EvictLeastHit and a frequency sketch, the less frequent key goes first, unless only one of
+// them has expired. c.mu must already be locked.
+func (c *Cache) evictsBefore(aKey string, a *entry, bKey string, b *entry, now int64) bool {
+	if c.sketch != nil && c.evictionPolicy == EvictLeastHit && a.expired(now) == b.expired(now) {
+		if aFreq, bFreq := c.Frequency(aKey), c.Frequency(bKey); aFreq != bFreq {
+			return aFreq < bFreq
+		}
+	}
+	return c.evictionPolicy.evictsBefore(a, b, now)
+}
+
+// evictsBefore reports whether a should be evicted before b: expired items go first, then
+// whichever the policy prefers. c.mu must already be locked.
+func (p EvictionPolicy) evictsBefore(a, b *entry, now int64) bool {
+	if aExpired, bExpired := a.expired(now), b.expired(now); aExpired != bExpired {
+		return aExpired
+	}
+	if p == EvictLeastHit {
+		if aHits, bHits := atomic.LoadInt64(&a.hits), atomic.LoadInt64(&b.hits); aHits != bHits {
+			return aHits < bHits
+		}
+	}
+	return a.createdAt < b.createdAt
+}
+
+// signalEvictor wakes the background evictor if the cache is over its target. c.mu must already be locked.
+func (c *Cache) signalEvictor() {
+	if c.evictor == nil || c.closed.Load() || c.evictionPaused() || c.totalCacheSize <= c.evictTarget() {
+		return
+	}
+	if c.deterministic() {
+		c.evict(c.evictTarget(), 0)
+		return
+	}
+	select {
+	case c.evictor <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Cache) evictInBackground() {
+	for range c.evictor {
+		var (
+			total  int
+			target int64
+		)
+		for !c.evictionPaused() {
+			c.mu.Lock()
+			target = c.evictTarget()
+			evicted := c.evict(target, softEvictionBatch)
+			done := c.totalCacheSize <= target
+			c.mu.Unlock()
+
+			total += evicted
+			if done || evicted == 0 {
+				break
+			}
+			runtime.Gosched()
+		}
+
+		if total > 0 {
+			c.logf("evicted %d items in the background to get back under %d bytes", total, target)
+		}
+	}
+}
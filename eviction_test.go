@@ -0,0 +1,111 @@
+package cache_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// itemSize is how much an item keyed like "key0" with value "value" counts towards the size.
+func itemSize(t *testing.T) int64 {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	c.Set("key0", "value")
+	return c.Stats().Size
+}
+
+func TestSoftLimit(t *testing.T) {
+	size := itemSize(t)
+	c := cache.New(20*size, cache.WithLogger(nil), cache.WithSoftLimit(10*size))
+	defer c.Close()
+
+	for i := range 15 {
+		c.Set("key"+strconv.Itoa(i), "value")
+	}
+	for deadline := time.Now().Add(5 * time.Second); c.Stats().Size > 10*size; {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache is still %d bytes, want at most the soft limit of %d", c.Stats().Size, 10*size)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Past the hard limit, the write itself evicts, rather than leaving it to the background.
+	c.Set("big", strings.Repeat("x", int(15*size)))
+	if got := c.Stats().Size; got > 10*size {
+		t.Errorf("write past the hard limit left the cache at %d bytes, want at most %d", got, 10*size)
+	}
+
+	if stats := c.Stats(); stats.Clears != 0 || stats.Evictions == 0 {
+		t.Errorf("cache cleared instead of evicting: %+v", stats)
+	}
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	size := itemSize(t)
+
+	for _, tt := range []struct {
+		policy cache.EvictionPolicy
+		kept   string
+	}{
+		{cache.EvictOldest, "key3"},
+		{cache.EvictLeastHit, "key0"},
+	} {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			c := cache.New(1<<20, cache.WithLogger(nil), cache.WithDeterministic(1), cache.WithEvictionPolicy(tt.policy))
+			defer c.Close()
+
+			// The older the key, the more it's read.
+			for i := range 4 {
+				key := "key" + strconv.Itoa(i)
+				c.Set(key, "value")
+				for range 3 - i {
+					c.Get(key)
+				}
+			}
+			c.SetMaxCacheSize(size)
+
+			if keys := c.Keys(); len(keys) != 1 || keys[0] != tt.kept {
+				t.Errorf("kept %q, want only %s", keys, tt.kept)
+			}
+		})
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	size := itemSize(t)
+
+	for _, policy := range []cache.OverflowPolicy{cache.OverflowClear, cache.OverflowEvict, cache.OverflowReject} {
+		t.Run(policy.String(), func(t *testing.T) {
+			c := cache.New(4*size, cache.WithLogger(nil), cache.WithOverflowPolicy(policy))
+			defer c.Close()
+
+			for i := range 4 {
+				c.Set("key"+strconv.Itoa(i), "value")
+			}
+			err := c.SetE("key4", "value")
+
+			stats := c.Stats()
+			_, stored := c.Get("key4")
+			switch policy {
+			case cache.OverflowClear:
+				if err != nil || stats.Clears != 1 || stats.Items != 0 {
+					t.Errorf("SetE returned %v, stats %+v, want the cache cleared", err, stats)
+				}
+			case cache.OverflowEvict:
+				if err != nil || !stored || stats.Clears != 0 || stats.Evictions != 1 || stats.Items != 4 {
+					t.Errorf("SetE returned %v, stored %v, stats %+v, want 1 item evicted for it", err, stored, stats)
+				}
+			case cache.OverflowReject:
+				if !errors.Is(err, cache.ErrCacheFull) || stored || stats.Clears != 0 || stats.Items != 4 {
+					t.Errorf("SetE returned %v, stored %v, stats %+v, want ErrCacheFull and the cache left as it was", err, stored, stats)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+)
+
+// WithJanitor removes expired items every interval, instead of leaving them until they're
+// replaced or the cache clears, so items that are never read again don't hold on to memory.
+//
+// Items with a TTL are kept in a min-heap ordered by expiry, so each sweep only touches the
+// items that have actually expired, however many there are in the cache. Items within their
+// stale window (see WithStaleWhileRevalidate) are kept until it's over.
+//
+// Sweeps are timed with the cache's Clock and stop when the cache is closed. Expired items are
+// counted in Stats and sent to subscribers, but not broadcast to peers, which expire their own.
+func WithJanitor(interval time.Duration) Option {
+	return func(c *Cache) {
+		c.janitorInterval = interval
+		if c.expiry == nil {
+			c.expiry = &expiryHeap{arena: &c.arena}
+		}
+	}
+}
+
+// reclaimable reports whether the entry has expired and can't be served stale any more, so it
+// can be removed.
+func (e *entry) reclaimable(now int64, maxStale time.Duration) bool {
+	return e.expiresAt > 0 && now >= e.expiresAt+int64(maxStale)
+}
+
+// reclaim removes keys that were found to have expired while c.mu was only read locked, so
+// expired items are freed when they're next looked up, even without WithJanitor. Each key is
+// checked again, since it may have been set since.
+func (c *Cache) reclaim(keys ...string) {
+	if c.evictionPaused() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for _, key := range keys {
+		i, e := c.lookup(key)
+		if e == nil || !e.reclaimable(now, c.maxStale) {
+			continue
+		}
+
+		c.remove(key, i)
+		c.expirations++
+		c.notifySubscribers(Event{Type: EventDelete, Key: key, Reason: ReasonExpired})
+	}
+}
+
+// expiryNode is an item with a TTL in the expiry heap.
+type expiryNode struct {
+	key       string
+	slot      uint32
+	expiresAt int64
+}
+
+// expiryHeap is a min-heap of every item with a TTL, by expiry. Each item's entry records
+// its position in the heap, plus 1 so that 0 means it isn't in it, so the heap can be updated
+// when the item changes. It's only accessed with c.mu locked.
+type expiryHeap struct {
+	nodes []expiryNode
+	arena *arena
+}
+
+func (h *expiryHeap) Len() int { return len(h.nodes) }
+
+func (h *expiryHeap) Less(i, j int) bool { return h.nodes[i].expiresAt < h.nodes[j].expiresAt }
+
+func (h *expiryHeap) Swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+	h.arena.entry(h.nodes[i].slot).expiryIndex = uint32(i + 1)
+	h.arena.entry(h.nodes[j].slot).expiryIndex = uint32(j + 1)
+}
+
+func (h *expiryHeap) Push(x any) {
+	n := x.(expiryNode)
+	h.nodes = append(h.nodes, n)
+	h.arena.entry(n.slot).expiryIndex = uint32(len(h.nodes))
+}
+
+func (h *expiryHeap) Pop() any {
+	n := h.nodes[len(h.nodes)-1]
+	h.nodes = h.nodes[:len(h.nodes)-1]
+	h.arena.entry(n.slot).expiryIndex = 0
+	return n
+}
+
+// update adds, moves or removes key, stored in slot i, to match its entry's expiry.
+func (h *expiryHeap) update(key string, i uint32) {
+	e := h.arena.entry(i)
+
+	switch {
+	case e.expiryIndex == 0 && e.expiresAt > 0:
+		heap.Push(h, expiryNode{key: key, slot: i, expiresAt: e.expiresAt})
+	case e.expiryIndex > 0 && e.expiresAt == 0:
+		heap.Remove(h, int(e.expiryIndex-1))
+	case e.expiryIndex > 0:
+		h.nodes[e.expiryIndex-1].expiresAt = e.expiresAt
+		heap.Fix(h, int(e.expiryIndex-1))
+	}
+}
+
+// remove takes slot i out of the heap, if it's in it.
+func (h *expiryHeap) remove(i uint32) {
+	if e := h.arena.entry(i); e.expiryIndex > 0 {
+		heap.Remove(h, int(e.expiryIndex-1))
+	}
+}
+
+// reset empties the heap, for when the whole cache is cleared.
+func (h *expiryHeap) reset() {
+	h.nodes = nil
+}
+
+// expiryChanged keeps the expiry heap in step with key, stored in slot i, after it's set or its
+// TTL changes. c.mu must already be locked.
+func (c *Cache) expiryChanged(key string, i uint32) {
+	if c.expiry != nil {
+		c.expiry.update(key, i)
+	}
+}
+
+// startJanitor starts the sweep for WithJanitor. Like startSchedules, it's called once every
+// option has been applied.
+func (c *Cache) startJanitor() {
+	if c.expiry == nil || c.janitorInterval <= 0 || c.deterministic() {
+		return
+	}
+	c.janitorBeat.Store(c.now())
+	go c.runJanitor()
+}
+
+func (c *Cache) runJanitor() {
+	for {
+		timer := c.newTimer(c.janitorInterval)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		if !c.evictionPaused() {
+			if n := c.removeExpired(); n > 0 {
+				c.logf("removed %d expired items", n)
+			}
+		}
+		c.janitorBeat.Store(c.now())
+	}
+}
+
+// janitorHealthy reports the janitor as stalled once it's gone janitorStallAfter intervals
+// without finishing a sweep, which means expired items are piling up.
+func (c *Cache) janitorHealthy() error {
+	if c.expiry == nil || c.janitorInterval <= 0 || c.deterministic() {
+		return nil
+	}
+
+	stallAfter := janitorStallAfter * c.janitorInterval
+	if since := time.Duration(c.now() - c.janitorBeat.Load()); since > stallAfter {
+		return fmt.Errorf("cache: janitor hasn't swept in %s, %d items waiting to expire", since.Round(time.Millisecond), c.expiry.Len())
+	}
+	return nil
+}
+
+// removeExpired removes every item whose expiry, and stale window, has passed and returns how
+// many it removed. Like the background evictor, it removes a batch at a time so reads and
+// writes can interleave.
+func (c *Cache) removeExpired() int {
+	var removed int
+	for {
+		c.mu.Lock()
+		now := c.now()
+		var n int
+		for ; n < softEvictionBatch && c.expiry.Len() > 0; n++ {
+			top := c.expiry.nodes[0]
+			if !c.arena.entry(top.slot).reclaimable(now, c.maxStale) {
+				break
+			}
+			c.remove(top.key, top.slot)
+			c.expirations++
+			c.notifySubscribers(Event{Type: EventDelete, Key: top.key, Reason: ReasonExpired})
+		}
+		c.mu.Unlock()
+
+		removed += n
+		if n < softEvictionBatch {
+			return removed
+		}
+	}
+}
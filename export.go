@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format is a file format ExportMetadata can write.
+type Format int
+
+const (
+	// FormatCSV writes a header row followed by one row per item.
+	FormatCSV Format = iota
+
+	// FormatJSONL writes one JSON object per line, as BigQuery and most log pipelines load.
+	FormatJSONL
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatJSONL:
+		return "jsonl"
+	}
+	return "unknown"
+}
+
+// MarshalText writes f as its name, e.g. "jsonl".
+func (f Format) MarshalText() ([]byte, error) {
+	if f.String() == "unknown" {
+		return nil, fmt.Errorf("cache: unknown format %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+func (f *Format) UnmarshalText(text []byte) error {
+	for _, format := range []Format{FormatCSV, FormatJSONL} {
+		if string(text) == format.String() {
+			*f = format
+			return nil
+		}
+	}
+	return fmt.Errorf("cache: unknown format %q", text)
+}
+
+// exportedMetadata is a row of ExportMetadata. Durations are in seconds so they load as plain
+// numbers into spreadsheets.
+type exportedMetadata struct {
+	Key  string  `json:"key"`
+	Size int64   `json:"size"`
+	Age  float64 `json:"age_seconds"`
+	Hits int64   `json:"hits"`
+	TTL  float64 `json:"ttl_seconds"`
+}
+
+// ExportMetadata writes the key, size, age, hits and remaining TTL of every item to w in
+// format, for loading into a spreadsheet or BigQuery during capacity reviews. Sizes are in
+// bytes and don't include the key; ages and TTLs are in seconds, with a TTL of 0 for items
+// that never expire. Values aren't included, and items are in no particular order.
+func (c *Cache) ExportMetadata(w io.Writer, format Format) error {
+	snapshot := c.Snapshot()
+
+	rows := make([]exportedMetadata, len(snapshot.Items))
+	for i, m := range snapshot.Items {
+		rows[i] = exportedMetadata{
+			Key:  m.Key,
+			Size: m.Size,
+			Age:  snapshot.TakenAt.Sub(m.CreatedAt).Round(time.Millisecond).Seconds(),
+			Hits: m.Hits,
+		}
+		if !m.ExpiresAt.IsZero() {
+			rows[i].TTL = max(m.ExpiresAt.Sub(snapshot.TakenAt), 0).Round(time.Millisecond).Seconds()
+		}
+	}
+
+	switch format {
+	case FormatCSV:
+		return exportCSV(w, rows)
+	case FormatJSONL:
+		return exportJSONL(w, rows)
+	}
+	return fmt.Errorf("cache: unknown format %d", int(format))
+}
+
+func exportCSV(w io.Writer, rows []exportedMetadata) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"key", "size", "age_seconds", "hits", "ttl_seconds"})
+	for _, row := range rows {
+		cw.Write([]string{
+			row.Key,
+			strconv.FormatInt(row.Size, 10),
+			formatSeconds(row.Age),
+			strconv.FormatInt(row.Hits, 10),
+			formatSeconds(row.TTL),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportJSONL(w io.Writer, rows []exportedMetadata) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
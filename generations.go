@@ -0,0 +1,68 @@
+package cache
+
+import "sync/atomic"
+
+// WithGenerations splits the cache into a current and a previous generation instead of clearing
+// it when it fills up. Items are set in the current generation, and a read of an item in the
+// previous generation moves it to the current one. Once the current generation takes up half of
+// maxCacheSize, the previous generation is dropped and the current one becomes the previous, so
+// anything read since the last rotation survives it, for the cost of an atomic load per read.
+//
+// Rotations are counted in Stats. If the cache still doesn't fit after a rotation, like when a
+// single item is bigger than half of maxCacheSize, it clears itself as usual.
+func WithGenerations() Option {
+	return func(c *Cache) {
+		c.generational = true
+	}
+}
+
+// generationSize returns how much of the cache the entry counts towards its generation.
+func generationSize(key string, e *entry) int64 {
+	return int64(len(key)) + e.size
+}
+
+// promote moves an entry that was just read into the current generation. It's called while c.mu
+// is only read locked, so the entry's generation and the current generation's size are updated
+// atomically, and only one of several concurrent reads promotes it.
+func (c *Cache) promote(key string, e *entry) {
+	if prev := atomic.LoadUint32(&e.generation); prev != c.generation && atomic.CompareAndSwapUint32(&e.generation, prev, c.generation) {
+		c.generationSize.Add(generationSize(key, e))
+	}
+}
+
+// joinGeneration adds a new or replaced entry to the current generation. c.mu must already be locked.
+func (c *Cache) joinGeneration(key string, e *entry) {
+	e.generation = c.generation
+	c.generationSize.Add(generationSize(key, e))
+}
+
+// leaveGeneration takes an entry that's being replaced or removed out of its generation's size.
+// c.mu must already be locked.
+func (c *Cache) leaveGeneration(key string, e *entry) {
+	if e.generation == c.generation {
+		c.generationSize.Add(-generationSize(key, e))
+	}
+}
+
+// rotateIfFull rotates the generations if the current one has filled half of the cache, or
+// the previous one is just big enough that both don't fit. c.mu must already be locked.
+func (c *Cache) rotateIfFull() {
+	if !c.generational || (c.generationSize.Load() <= c.maxCacheSize/2 && c.totalCacheSize <= c.maxCacheSize) {
+		return
+	}
+
+	var dropped int
+	for key, i := range c.all() {
+		if c.arena.entry(i).generation != c.generation {
+			c.remove(key, i)
+			c.notifySubscribers(Event{Type: EventDelete, Key: key, Reason: ReasonRotated})
+			dropped++
+		}
+	}
+
+	c.generation++
+	c.generationSize.Store(0)
+	c.rotations++
+
+	c.logf("rotated generations, dropping %d items. current cache size: %d bytes", dropped, c.totalCacheSize)
+}
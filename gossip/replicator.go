@@ -0,0 +1,344 @@
+// Package gossip replicates cache entries between instances using memberlist-style gossip,
+// so every instance gets a warm near-cache once any one of them loads a value.
+//
+// Only string and []byte values are replicated since they're the only values that can be
+// sent over the wire as-is, and only deletes the application asked for: items an instance
+// evicts, expires or drops for its own reasons are still valid on the others. Set
+// Config.MinFrequency to only replicate hot entries.
+//
+// Entries from peers are only applied to the local cache, with SetLocal and DeleteLocal, and
+// never written to a store it writes through to, since the peer they came from already has.
+//
+// Don't combine a Replicator with Cache.EnableInvalidation on the same cache, replicated sets
+// would be broadcast as invalidations and undo each other.
+package gossip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultMaxValueSize keeps replicated entries small enough to piggyback on gossip packets.
+const DefaultMaxValueSize = 1024
+
+// leaveTimeout is how long Close waits for the leave message to propagate.
+const leaveTimeout = 5 * time.Second
+
+const (
+	msgSet byte = iota + 1
+	msgDelete
+)
+
+const (
+	kindString byte = iota + 1
+	kindBytes
+)
+
+// Config configures a Replicator.
+type Config struct {
+	// Memberlist is the gossip configuration. Nil uses memberlist.DefaultLANConfig.
+	// Its Delegate is replaced by the Replicator.
+	Memberlist *memberlist.Config
+
+	// Peers are existing members to join. Empty starts a new cluster.
+	Peers []string
+
+	// MaxValueSize is the largest value, in bytes, that's replicated. 0 uses DefaultMaxValueSize.
+	MaxValueSize int
+
+	// Filter optionally limits which entries are replicated, e.g. to a hot key prefix.
+	Filter func(key string, value any) bool
+
+	// MinFrequency limits replication to hot entries: only Sets of keys that the cache's
+	// frequency sketch estimates were read or written at least MinFrequency times recently
+	// are gossiped, so one-off keys don't use up gossip bandwidth on every instance. The cache
+	// must be created WithFrequencySketch. 0 replicates every Set.
+	MinFrequency int
+}
+
+// Replicator gossips Sets and Deletes on a cache to every other member of the cluster.
+type Replicator struct {
+	cache        *cache.Cache
+	list         *memberlist.Memberlist
+	queue        *memberlist.TransmitLimitedQueue
+	maxValueSize int
+	filter       func(key string, value any) bool
+	minFrequency int
+	unsubscribe  func()
+
+	// applying holds entries received from peers while they're being set, so the
+	// resulting event isn't gossiped straight back out.
+	mu       sync.Mutex
+	applying map[string]applied
+}
+
+type applied struct {
+	deleted bool
+	value   any
+}
+
+// New starts gossiping changes to c, joining cfg.Peers if there are any.
+func New(c *cache.Cache, cfg Config) (*Replicator, error) {
+	mlConfig := cfg.Memberlist
+	if mlConfig == nil {
+		mlConfig = memberlist.DefaultLANConfig()
+	}
+
+	r := &Replicator{
+		cache:        c,
+		maxValueSize: cfg.MaxValueSize,
+		filter:       cfg.Filter,
+		minFrequency: cfg.MinFrequency,
+		applying:     make(map[string]applied),
+	}
+	if r.maxValueSize <= 0 {
+		r.maxValueSize = DefaultMaxValueSize
+	}
+
+	mlConfig.Delegate = (*delegate)(r)
+
+	list, err := memberlist.Create(mlConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating memberlist: %w", err)
+	}
+	r.list = list
+	r.queue = &memberlist.TransmitLimitedQueue{
+		NumNodes:       list.NumMembers,
+		RetransmitMult: mlConfig.RetransmitMult,
+	}
+
+	if len(cfg.Peers) > 0 {
+		if _, err := list.Join(cfg.Peers); err != nil {
+			list.Shutdown()
+			return nil, fmt.Errorf("joining cluster: %w", err)
+		}
+	}
+
+	r.unsubscribe = c.Subscribe(r.onEvent)
+
+	return r, nil
+}
+
+// Members returns the addresses of every live member of the cluster, including this one.
+func (r *Replicator) Members() []string {
+	var addrs []string
+	for _, node := range r.list.Members() {
+		addrs = append(addrs, node.Address())
+	}
+	return addrs
+}
+
+// Close stops replicating, leaves the cluster and shuts down gossip.
+func (r *Replicator) Close() error {
+	r.unsubscribe()
+
+	err := r.list.Leave(leaveTimeout)
+	return errors.Join(err, r.list.Shutdown())
+}
+
+// onEvent queues local Sets and Deletes for gossip. It's called with the cache locked,
+// so it only encodes and queues the message.
+func (r *Replicator) onEvent(ev cache.Event) {
+	if r.isApplying(ev) {
+		return
+	}
+
+	switch ev.Type {
+	case cache.EventSet:
+		kind, value, ok := encodeValue(ev.Value)
+		if !ok || len(value) > r.maxValueSize {
+			return
+		}
+		if r.filter != nil && !r.filter(ev.Key, ev.Value) {
+			return
+		}
+		if r.minFrequency > 0 && r.cache.Frequency(ev.Key) < r.minFrequency {
+			return
+		}
+
+		var expiresAt int64
+		if !ev.ExpiresAt.IsZero() {
+			expiresAt = ev.ExpiresAt.UnixNano()
+		}
+		r.queue.QueueBroadcast(&broadcast{key: ev.Key, msg: encodeSet(ev.Key, expiresAt, kind, value)})
+	case cache.EventDelete:
+		if ev.Local() {
+			return
+		}
+		r.queue.QueueBroadcast(&broadcast{key: ev.Key, msg: encodeDelete(ev.Key)})
+	}
+}
+
+// isApplying reports whether ev is the result of applying a peer's message.
+func (r *Replicator) isApplying(ev cache.Event) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, found := r.applying[ev.Key]
+	if !found {
+		return false
+	}
+
+	// A local change to the same key can race with applying a peer's, but it's only
+	// skipped if it's identical, in which case the peers already have it anyway.
+	switch {
+	case ev.Type == cache.EventDelete && a.deleted:
+	case ev.Type == cache.EventSet && !a.deleted && equalValues(ev.Value, a.value):
+	default:
+		return false
+	}
+
+	delete(r.applying, ev.Key)
+	return true
+}
+
+func (r *Replicator) apply(msg []byte) {
+	typ, key, expiresAt, value, err := decodeMessage(msg)
+	if err != nil {
+		log.Printf("gossip: ignoring invalid message: %v", err)
+		return
+	}
+
+	switch typ {
+	case msgSet:
+		var ttl time.Duration
+		if expiresAt > 0 {
+			if ttl = time.Until(time.Unix(0, expiresAt)); ttl <= 0 {
+				return
+			}
+		}
+
+		r.setApplying(key, applied{value: value})
+		r.cache.SetLocal(key, value, ttl)
+	case msgDelete:
+		if !r.cache.Has(key) {
+			return
+		}
+
+		r.setApplying(key, applied{deleted: true})
+		r.cache.DeleteLocal(key)
+	}
+
+	// Clean up in case the change didn't generate an event, e.g. the key was deleted in between.
+	r.mu.Lock()
+	delete(r.applying, key)
+	r.mu.Unlock()
+}
+
+func (r *Replicator) setApplying(key string, a applied) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applying[key] = a
+}
+
+// delegate implements memberlist.Delegate without adding those methods to Replicator's API.
+type delegate Replicator
+
+func (d *delegate) NodeMeta(limit int) []byte { return nil }
+
+func (d *delegate) NotifyMsg(msg []byte) {
+	// msg is reused by memberlist once we return, but decoding copies everything we keep.
+	(*Replicator)(d).apply(msg)
+}
+
+func (d *delegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.queue.GetBroadcasts(overhead, limit)
+}
+
+func (d *delegate) LocalState(join bool) []byte { return nil }
+
+func (d *delegate) MergeRemoteState(buf []byte, join bool) {}
+
+// broadcast is a single queued change. A newer change to the same key replaces an older one still in the queue.
+type broadcast struct {
+	key string
+	msg []byte
+}
+
+func (b *broadcast) Invalidates(other memberlist.Broadcast) bool {
+	o, ok := other.(*broadcast)
+	return ok && o.key == b.key
+}
+
+func (b *broadcast) Message() []byte { return b.msg }
+
+func (b *broadcast) Finished() {}
+
+// encodeSet builds a set message: type, uvarint key length, key, varint expiresAt, value kind and then the value.
+func encodeSet(key string, expiresAt int64, kind byte, value []byte) []byte {
+	msg := make([]byte, 0, 2+2*binary.MaxVarintLen64+len(key)+len(value))
+	msg = append(msg, msgSet)
+	msg = binary.AppendUvarint(msg, uint64(len(key)))
+	msg = append(msg, key...)
+	msg = binary.AppendVarint(msg, expiresAt)
+	msg = append(msg, kind)
+	return append(msg, value...)
+}
+
+// encodeDelete builds a delete message: type and then the key.
+func encodeDelete(key string) []byte {
+	return append([]byte{msgDelete}, key...)
+}
+
+func decodeMessage(msg []byte) (typ byte, key string, expiresAt int64, value any, err error) {
+	if len(msg) == 0 {
+		return 0, "", 0, nil, errors.New("empty message")
+	}
+
+	typ, msg = msg[0], msg[1:]
+	switch typ {
+	case msgDelete:
+		return typ, string(msg), 0, nil, nil
+	case msgSet:
+	default:
+		return 0, "", 0, nil, fmt.Errorf("unknown type %d", typ)
+	}
+
+	n, read := binary.Uvarint(msg)
+	if read <= 0 || uint64(len(msg)-read) < n {
+		return 0, "", 0, nil, errors.New("invalid key length")
+	}
+	key, msg = string(msg[read:read+int(n)]), msg[read+int(n):]
+
+	expiresAt, read = binary.Varint(msg)
+	if read <= 0 || len(msg) == read {
+		return 0, "", 0, nil, errors.New("invalid expiration")
+	}
+	msg = msg[read:]
+
+	switch msg[0] {
+	case kindString:
+		value = string(msg[1:])
+	case kindBytes:
+		value = bytes.Clone(msg[1:])
+	default:
+		return 0, "", 0, nil, fmt.Errorf("unknown value kind %d", msg[0])
+	}
+
+	return typ, key, expiresAt, value, nil
+}
+
+func encodeValue(value any) (kind byte, b []byte, ok bool) {
+	switch v := value.(type) {
+	case string:
+		return kindString, []byte(v), true
+	case []byte:
+		return kindBytes, v, true
+	}
+	return 0, nil, false
+}
+
+func equalValues(a, b any) bool {
+	aKind, aBytes, aOK := encodeValue(a)
+	bKind, bBytes, bOK := encodeValue(b)
+	return aOK && bOK && aKind == bKind && bytes.Equal(aBytes, bBytes)
+}
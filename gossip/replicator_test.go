@@ -0,0 +1,129 @@
+package gossip
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// newTestReplicator returns a Replicator that queues c's changes without gossiping them.
+func newTestReplicator(c *cache.Cache, cfg Config) *Replicator {
+	r := &Replicator{
+		cache:        c,
+		queue:        &memberlist.TransmitLimitedQueue{NumNodes: func() int { return 2 }, RetransmitMult: 1},
+		maxValueSize: DefaultMaxValueSize,
+		minFrequency: cfg.MinFrequency,
+		applying:     make(map[string]applied),
+	}
+	r.unsubscribe = c.Subscribe(r.onEvent)
+	return r
+}
+
+// queued returns the types of the messages queued for gossip, and empties the queue.
+func queued(r *Replicator) []byte {
+	var types []byte
+	for _, msg := range r.queue.GetBroadcasts(0, 1<<20) {
+		types = append(types, msg[0])
+	}
+	r.queue.Reset()
+	return types
+}
+
+func TestOnlyExplicitDeletesAreReplicated(t *testing.T) {
+	c := cache.New(200, cache.WithLogger(nil), cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithJanitor(time.Hour))
+	defer c.Close()
+	r := newTestReplicator(c, Config{})
+	defer r.unsubscribe()
+
+	c.Set("deleted", "value")
+	c.Delete("deleted")
+	// The delete replaces the set in the queue.
+	if got := queued(r); len(got) != 1 || got[0] != msgDelete {
+		t.Fatalf("Set and Delete queued %v, want a delete", got)
+	}
+
+	c.SetWithTTL("expired", "value", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	c.Sweep()
+	if c.Stats().Expirations == 0 {
+		t.Fatal("nothing expired")
+	}
+	for i := range 20 {
+		c.Set(string(rune('a'+i)), "0123456789")
+	}
+	if c.Stats().Evictions == 0 {
+		t.Fatal("nothing was evicted")
+	}
+	for _, typ := range queued(r) {
+		if typ == msgDelete {
+			t.Fatal("a local expiry or eviction was replicated as a delete")
+		}
+	}
+}
+
+func TestMinFrequency(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithFrequencySketch(1000))
+	defer c.Close()
+	r := newTestReplicator(c, Config{MinFrequency: 3})
+	defer r.unsubscribe()
+
+	c.Set("cold", "value")
+	if got := queued(r); len(got) != 0 {
+		t.Fatalf("a key set once was replicated: %v", got)
+	}
+
+	c.Get("hot")
+	c.Get("hot")
+	c.Set("hot", "value")
+	if got := queued(r); len(got) != 1 || got[0] != msgSet {
+		t.Fatalf("a hot key queued %v, want a set", got)
+	}
+}
+
+// countingStore is a Store that counts writes.
+type countingStore struct {
+	writes atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) (any, error) {
+	return nil, cache.ErrNotFound
+}
+
+func (s *countingStore) Set(ctx context.Context, key string, value any) error {
+	s.writes.Add(1)
+	return nil
+}
+
+func (s *countingStore) Delete(ctx context.Context, key string) error {
+	s.writes.Add(1)
+	return nil
+}
+
+func TestApplyDoesNotWriteThrough(t *testing.T) {
+	store := &countingStore{}
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithWriteThrough(store))
+	defer c.Close()
+	r := newTestReplicator(c, Config{})
+	defer r.unsubscribe()
+
+	r.apply(encodeSet("key", 0, kindString, []byte("value")))
+	if value, found := c.Get("key"); !found || value != "value" {
+		t.Errorf("applying a set left %v, %v in the cache", value, found)
+	}
+	r.apply(encodeDelete("key"))
+	if c.Has("key") {
+		t.Error("applying a delete left the key in the cache")
+	}
+
+	if got := store.writes.Load(); got != 0 {
+		t.Errorf("applying a peer's set and delete wrote to the store %d times, want 0", got)
+	}
+	if got := queued(r); len(got) != 0 {
+		t.Errorf("applying a peer's changes queued %v to gossip back", got)
+	}
+}
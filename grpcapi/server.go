@@ -0,0 +1,257 @@
+// Package grpcapi serves a cache.Cache over gRPC so services written in other languages can share it.
+//
+// The service is defined in cachepb/cache.proto. Register it with:
+//
+//	s := grpc.NewServer()
+//	cachepb.RegisterCacheServer(s, grpcapi.NewServer(c))
+//
+// Pass WithBearerToken to require clients to send "authorization: Bearer <token>" metadata.
+package grpcapi
+
+//go:generate buf generate
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/grpcapi/cachepb"
+)
+
+// watchBuffer is how many events a Watch stream can fall behind before it's closed.
+const watchBuffer = 256
+
+// Server implements cachepb.CacheServer on top of a cache.
+type Server struct {
+	cachepb.UnimplementedCacheServer
+
+	cache     *cache.Cache
+	authorize func(ctx context.Context) bool
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAuth only allows calls for which authorize returns true. Other calls fail with Unauthenticated.
+func WithAuth(authorize func(ctx context.Context) bool) Option {
+	return func(s *Server) {
+		s.authorize = authorize
+	}
+}
+
+// WithBearerToken only allows calls with "authorization: Bearer <token>" metadata.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(ctx context.Context) bool {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			got, ok := strings.CutPrefix(v, "Bearer ")
+			if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// NewServer creates a new Server backed by c.
+func NewServer(c *cache.Cache, opts ...Option) *Server {
+	s := &Server{cache: c}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Server) checkAuth(ctx context.Context) error {
+	if s.authorize != nil && !s.authorize(ctx) {
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return nil
+}
+
+// Get implements cachepb.CacheServer.
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	if err := s.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+	return s.get(req), nil
+}
+
+// Set implements cachepb.CacheServer.
+func (s *Server) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	if err := s.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.set(req); err != nil {
+		return nil, err
+	}
+	return &cachepb.SetResponse{}, nil
+}
+
+// Delete implements cachepb.CacheServer.
+func (s *Server) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	if err := s.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+	return s.delete(req), nil
+}
+
+// Flush implements cachepb.CacheServer.
+func (s *Server) Flush(ctx context.Context, req *cachepb.FlushRequest) (*cachepb.FlushResponse, error) {
+	if err := s.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.GetPrefix() == "" {
+		s.cache.Clear()
+		return &cachepb.FlushResponse{}, nil
+	}
+
+	n := s.cache.DeletePrefix(req.GetPrefix())
+	return &cachepb.FlushResponse{Deleted: int64(n)}, nil
+}
+
+// Batch implements cachepb.CacheServer. Operations run in order, but not atomically.
+func (s *Server) Batch(ctx context.Context, req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+	if err := s.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+
+	res := &cachepb.BatchResponse{
+		Results: make([]*cachepb.Result, 0, len(req.GetOperations())),
+	}
+
+	for i, op := range req.GetOperations() {
+		var result *cachepb.Result
+
+		switch op := op.GetOp().(type) {
+		case *cachepb.Operation_Get:
+			result = &cachepb.Result{Result: &cachepb.Result_Get{Get: s.get(op.Get)}}
+		case *cachepb.Operation_Set:
+			if err := s.set(op.Set); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "operation %d: %s", i, status.Convert(err).Message())
+			}
+			result = &cachepb.Result{Result: &cachepb.Result_Set{Set: &cachepb.SetResponse{}}}
+		case *cachepb.Operation_Delete:
+			result = &cachepb.Result{Result: &cachepb.Result_Delete{Delete: s.delete(op.Delete)}}
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "operation %d: no operation set", i)
+		}
+
+		res.Results = append(res.Results, result)
+	}
+
+	return res, nil
+}
+
+// Watch implements cachepb.CacheServer.
+//
+// Events are buffered, and a client that falls too far behind has its stream closed
+// with ResourceExhausted rather than holding up the cache.
+func (s *Server) Watch(req *cachepb.WatchRequest, stream cachepb.Cache_WatchServer) error {
+	if err := s.checkAuth(stream.Context()); err != nil {
+		return err
+	}
+
+	events := make(chan *cachepb.Event, watchBuffer)
+	overflow := make(chan struct{})
+	var overflowed bool
+
+	unsubscribe := s.cache.Subscribe(func(ev cache.Event) {
+		if overflowed || (ev.Type != cache.EventClear && !strings.HasPrefix(ev.Key, req.GetPrefix())) {
+			return
+		}
+
+		select {
+		case events <- toProtoEvent(ev, req.GetIncludeValues()):
+		default:
+			// Subscribers are called with the cache locked, so overflowed doesn't need its own lock.
+			overflowed = true
+			close(overflow)
+		}
+	})
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "watcher fell too far behind")
+		case ev := <-events:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) get(req *cachepb.GetRequest) *cachepb.GetResponse {
+	value, found := s.cache.Get(req.GetKey())
+	if !found {
+		return &cachepb.GetResponse{}
+	}
+	return &cachepb.GetResponse{Found: true, Value: valueBytes(value)}
+}
+
+func (s *Server) set(req *cachepb.SetRequest) error {
+	if req.GetKey() == "" {
+		return status.Error(codes.InvalidArgument, "key is required")
+	}
+
+	ttl := req.GetTtl().AsDuration()
+	if ttl < 0 {
+		return status.Error(codes.InvalidArgument, "ttl must not be negative")
+	}
+
+	s.cache.SetWithTTL(req.GetKey(), string(req.GetValue()), ttl)
+	return nil
+}
+
+func (s *Server) delete(req *cachepb.DeleteRequest) *cachepb.DeleteResponse {
+	found := s.cache.Has(req.GetKey())
+	if found {
+		s.cache.Delete(req.GetKey())
+	}
+	return &cachepb.DeleteResponse{Deleted: found}
+}
+
+func toProtoEvent(ev cache.Event, includeValue bool) *cachepb.Event {
+	pev := &cachepb.Event{Key: ev.Key}
+
+	switch ev.Type {
+	case cache.EventSet:
+		pev.Type = cachepb.Event_TYPE_SET
+		if includeValue {
+			pev.Value = valueBytes(ev.Value)
+		}
+		if !ev.ExpiresAt.IsZero() {
+			pev.ExpiresAt = timestamppb.New(ev.ExpiresAt)
+		}
+	case cache.EventDelete:
+		pev.Type = cachepb.Event_TYPE_DELETE
+	case cache.EventClear:
+		pev.Type = cachepb.Event_TYPE_CLEAR
+	}
+
+	return pev
+}
+
+// valueBytes converts a cached value into the bytes sent back to clients.
+// Values set over gRPC are strings, anything else set directly on the cache is formatted with fmt.
+func valueBytes(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	return []byte(fmt.Sprint(value))
+}
@@ -0,0 +1,235 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/grpcapi/cachepb"
+)
+
+// dial serves a Server for a new cache on an in-memory listener, and returns a client for it.
+func dial(t *testing.T, opts ...Option) (cachepb.CacheClient, *cache.Cache) {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	cachepb.RegisterCacheServer(s, NewServer(c, opts...))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cachepb.NewCacheClient(conn), c
+}
+
+func TestSetGetDelete(t *testing.T) {
+	client, c := dial(t)
+	ctx := context.Background()
+
+	if _, err := client.Set(ctx, &cachepb.SetRequest{Key: "a", Value: []byte("1"), Ttl: durationpb.New(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Set with a TTL of a minute left a TTL of %s", ttl)
+	}
+
+	res, err := client.Get(ctx, &cachepb.GetRequest{Key: "a"})
+	if err != nil || !res.Found || string(res.Value) != "1" {
+		t.Fatalf("Get returned %v, %v, want 1", res, err)
+	}
+	c.Set("n", 42)
+	if res, _ := client.Get(ctx, &cachepb.GetRequest{Key: "n"}); string(res.GetValue()) != "42" {
+		t.Errorf("Get of an int set on the cache returned %q, want 42", res.GetValue())
+	}
+
+	for _, want := range []bool{true, false} {
+		res, err := client.Delete(ctx, &cachepb.DeleteRequest{Key: "a"})
+		if err != nil || res.Deleted != want {
+			t.Errorf("Delete returned %v, %v, want Deleted %v", res, err, want)
+		}
+	}
+	if res, _ := client.Get(ctx, &cachepb.GetRequest{Key: "a"}); res.GetFound() {
+		t.Error("Get found a deleted key")
+	}
+
+	for _, req := range []*cachepb.SetRequest{{Value: []byte("no key")}, {Key: "a", Ttl: durationpb.New(-time.Second)}} {
+		if _, err := client.Set(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Set(%v) returned %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	client, c := dial(t)
+	ctx := context.Background()
+	for _, key := range []string{"users:1", "users:2", "orders:1"} {
+		c.Set(key, "v")
+	}
+
+	res, err := client.Flush(ctx, &cachepb.FlushRequest{Prefix: "users:"})
+	if err != nil || res.Deleted != 2 {
+		t.Fatalf("Flush of users: returned %v, %v, want 2 deleted", res, err)
+	}
+	if got := c.Keys(); len(got) != 1 || got[0] != "orders:1" {
+		t.Errorf("Flush of users: left %v", got)
+	}
+
+	if _, err := client.Flush(ctx, &cachepb.FlushRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Keys()) != 0 {
+		t.Errorf("Flush without a prefix left %v", c.Keys())
+	}
+}
+
+func TestBatch(t *testing.T) {
+	client, _ := dial(t)
+	ctx := context.Background()
+
+	res, err := client.Batch(ctx, &cachepb.BatchRequest{Operations: []*cachepb.Operation{
+		{Op: &cachepb.Operation_Set{Set: &cachepb.SetRequest{Key: "a", Value: []byte("1")}}},
+		{Op: &cachepb.Operation_Get{Get: &cachepb.GetRequest{Key: "a"}}},
+		{Op: &cachepb.Operation_Delete{Delete: &cachepb.DeleteRequest{Key: "a"}}},
+		{Op: &cachepb.Operation_Get{Get: &cachepb.GetRequest{Key: "a"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := res.GetResults()
+	if len(results) != 4 {
+		t.Fatalf("a batch of 4 operations returned %d results", len(results))
+	}
+	if get := results[1].GetGet(); !get.GetFound() || string(get.GetValue()) != "1" {
+		t.Errorf("a Get after a Set in the same batch returned %v", get)
+	}
+	if !results[2].GetDelete().GetDeleted() {
+		t.Error("a Delete in the batch didn't delete the key")
+	}
+	if results[3].GetGet().GetFound() {
+		t.Error("a Get after a Delete in the same batch found the key")
+	}
+
+	_, err = client.Batch(ctx, &cachepb.BatchRequest{Operations: []*cachepb.Operation{{}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("a batch with an empty operation returned %v, want InvalidArgument", err)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	client, c := dial(t, WithBearerToken("secret"))
+	c.Set("a", "1")
+
+	for _, md := range []metadata.MD{nil, metadata.Pairs("authorization", "Bearer wrong")} {
+		ctx := metadata.NewOutgoingContext(context.Background(), md)
+		if _, err := client.Get(ctx, &cachepb.GetRequest{Key: "a"}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Get with %v returned %v, want Unauthenticated", md, err)
+		}
+		if _, err := client.Flush(ctx, &cachepb.FlushRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Flush with %v returned %v, want Unauthenticated", md, err)
+		}
+	}
+	if !c.Has("a") {
+		t.Fatal("an unauthenticated Flush cleared the cache")
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if res, err := client.Get(ctx, &cachepb.GetRequest{Key: "a"}); err != nil || !res.Found {
+		t.Errorf("Get with the right token returned %v, %v", res, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client, c := dial(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &cachepb.WatchRequest{Prefix: "w:", IncludeValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *cachepb.Event)
+	go func() {
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	// The stream only sees changes once the server has subscribed, so set a key until it does.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+	c.Set("w:ready", "")
+wait:
+	for {
+		select {
+		case ev := <-events:
+			if ev.GetKey() == "w:ready" {
+				break wait
+			}
+		case <-ticker.C:
+			c.Set("w:ready", "")
+		case <-timeout:
+			t.Fatal("the watch stream didn't receive any events")
+		}
+	}
+	// Drain any extra events from setting w:ready more than once.
+	for drained := false; !drained; {
+		select {
+		case <-events:
+		case <-time.After(50 * time.Millisecond):
+			drained = true
+		}
+	}
+
+	c.Set("other", "not watched")
+	c.SetWithTTL("w:a", "1", time.Minute)
+	c.Delete("w:a")
+	c.Clear()
+
+	want := []struct {
+		typ   cachepb.Event_Type
+		key   string
+		value string
+	}{
+		{cachepb.Event_TYPE_SET, "w:a", "1"},
+		{cachepb.Event_TYPE_DELETE, "w:a", ""},
+		{cachepb.Event_TYPE_CLEAR, "", ""},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-events:
+			if ev.GetType() != w.typ || ev.GetKey() != w.key || string(ev.GetValue()) != w.value {
+				t.Fatalf("got event %v, want %v %q %q", ev, w.typ, w.key, w.value)
+			}
+			if w.typ == cachepb.Event_TYPE_SET && ev.GetExpiresAt() == nil {
+				t.Error("a set with a TTL was sent without an expiry")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v %q", w.typ, w.key)
+		}
+	}
+}
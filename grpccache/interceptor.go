@@ -0,0 +1,170 @@
+// Package grpccache caches the responses of idempotent unary gRPC methods in a cache.Cache,
+// keyed on the method and a hash of the request, with interceptors for servers and clients:
+//
+//	c := cache.New(64 << 20)
+//	opts := []grpccache.Option{grpccache.WithMethod("/users.Users/GetUser", time.Minute)}
+//	s := grpc.NewServer(grpc.UnaryInterceptor(grpccache.UnaryServerInterceptor(c, opts...)))
+//	conn, err := grpc.NewClient(addr, grpc.WithUnaryInterceptor(grpccache.UnaryClientInterceptor(c, opts...)))
+//
+// Only the methods passed to WithMethod are cached. Errors aren't cached.
+package grpccache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultKeyPrefix is put in front of every key unless changed with WithKeyPrefix.
+const DefaultKeyPrefix = "grpccache:"
+
+// Option configures UnaryServerInterceptor and UnaryClientInterceptor.
+type Option func(*config)
+
+type config struct {
+	methods  map[string]time.Duration
+	metadata []string
+	prefix   string
+}
+
+// WithMethod caches responses to fullMethod, like "/package.Service/Method", for ttl.
+// A ttl of 0 caches them until the cache drops them.
+func WithMethod(fullMethod string, ttl time.Duration) Option {
+	return func(cfg *config) {
+		cfg.methods[fullMethod] = ttl
+	}
+}
+
+// WithKeyMetadata adds the values of the named metadata keys to the cache key, for methods
+// whose response depends on them, e.g. "authorization" if responses are per user.
+func WithKeyMetadata(names ...string) Option {
+	return func(cfg *config) {
+		for _, name := range names {
+			cfg.metadata = append(cfg.metadata, strings.ToLower(name))
+		}
+	}
+}
+
+// WithKeyPrefix changes the prefix put in front of every key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.prefix = prefix
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		methods: make(map[string]time.Duration),
+		prefix:  DefaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// cachedResponse is a cached response message. It's cloned on the way in and out, so
+// neither the handler nor callers can change what's cached.
+type cachedResponse struct {
+	msg  proto.Message
+	size int
+}
+
+// Size implements cache.Sizer.
+func (r *cachedResponse) Size() int64 {
+	return int64(r.size)
+}
+
+func newCachedResponse(msg proto.Message) *cachedResponse {
+	return &cachedResponse{msg: proto.Clone(msg), size: proto.Size(msg)}
+}
+
+// key returns the key the response to req for method is cached under, and false if the call
+// isn't cached: method wasn't passed to WithMethod or req isn't a proto message.
+func (cfg *config) key(method string, req any, md metadata.MD) (string, time.Duration, bool) {
+	ttl, ok := cfg.methods[method]
+	if !ok {
+		return "", 0, false
+	}
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", 0, false
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", 0, false
+	}
+
+	h := sha256.New()
+	h.Write(b)
+	for _, name := range cfg.metadata {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.Join(md.Get(name), ",")))
+	}
+
+	return cfg.prefix + method + "\x00" + hex.EncodeToString(h.Sum(nil)), ttl, true
+}
+
+// UnaryServerInterceptor returns a server interceptor that answers calls to the methods passed
+// to WithMethod from c when it can, and caches the handler's response when it can't.
+func UnaryServerInterceptor(c *cache.Cache, opts ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(opts)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		key, ttl, ok := cfg.key(info.FullMethod, req, md)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if cached, found := cache.GetAs[*cachedResponse](c, key); found {
+			return proto.Clone(cached.msg), nil
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if msg, ok := resp.(proto.Message); ok {
+			c.SetWithTTL(key, newCachedResponse(msg), ttl)
+		}
+		return resp, nil
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor that answers calls to the methods passed
+// to WithMethod from c when it can, without calling the server, and caches the server's
+// response when it can't.
+func UnaryClientInterceptor(c *cache.Cache, opts ...Option) grpc.UnaryClientInterceptor {
+	cfg := newConfig(opts)
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		key, ttl, ok := cfg.key(method, req, md)
+		msg, isMsg := reply.(proto.Message)
+		if !ok || !isMsg {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+
+		if cached, found := cache.GetAs[*cachedResponse](c, key); found {
+			proto.Reset(msg)
+			proto.Merge(msg, cached.msg)
+			return nil
+		}
+
+		if err := invoker(ctx, method, req, reply, cc, callOpts...); err != nil {
+			return err
+		}
+		c.SetWithTTL(key, newCachedResponse(msg), ttl)
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+package grpccache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+	"github.com/radovskyb/self-clearing-in-memory-cache/grpcapi/cachepb"
+)
+
+// countingServer answers Get with the key and how many calls it's had, and fails Gets of "error".
+type countingServer struct {
+	cachepb.UnimplementedCacheServer
+	gets, sets atomic.Int64
+}
+
+func (s *countingServer) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	n := s.gets.Add(1)
+	if req.Key == "error" {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	return &cachepb.GetResponse{Found: true, Value: []byte(req.Key + strconv.FormatInt(n, 10))}, nil
+}
+
+func (s *countingServer) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	s.sets.Add(1)
+	return &cachepb.SetResponse{}, nil
+}
+
+// dial starts srv with serverOpts on an in-memory listener, and returns a client for it.
+func dial(t *testing.T, srv cachepb.CacheServer, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) cachepb.CacheClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(serverOpts...)
+	cachepb.RegisterCacheServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	dialOpts = append(dialOpts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("passthrough:///bufconn", dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return cachepb.NewCacheClient(conn)
+}
+
+// newCache returns a cache on a fake clock.
+func newCache(t *testing.T) (*cache.Cache, *clocktest.Clock) {
+	t.Helper()
+
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock))
+	t.Cleanup(func() { c.Close() })
+	return c, clock
+}
+
+// testInterceptor checks the caching rules every interceptor follows: only the methods passed
+// to WithMethod are cached, for their TTL, and errors aren't cached.
+func testInterceptor(t *testing.T, client cachepb.CacheClient, srv *countingServer, clock *clocktest.Clock) {
+	t.Helper()
+	ctx := context.Background()
+
+	get := func(key string) string {
+		t.Helper()
+		resp, err := client.Get(ctx, &cachepb.GetRequest{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(resp.Value)
+	}
+
+	if first, second := get("key"), get("key"); first != "key1" || second != "key1" {
+		t.Errorf("Get twice returned %q and %q, want the first response both times", first, second)
+	}
+	if got := get("other"); got != "other2" {
+		t.Errorf("Get of another key returned %q, want a new response", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got := get("key"); got != "key3" {
+		t.Errorf("Get after the method's TTL returned %q, want a new response", got)
+	}
+
+	for range 2 {
+		if _, err := client.Get(ctx, &cachepb.GetRequest{Key: "error"}); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Get of a failing key returned %v", err)
+		}
+		client.Set(ctx, &cachepb.SetRequest{Key: "key"})
+	}
+	if srv.gets.Load() != 5 || srv.sets.Load() != 2 {
+		t.Errorf("server got %d Gets and %d Sets, want 5 and 2: errors and methods not passed to WithMethod aren't cached",
+			srv.gets.Load(), srv.sets.Load())
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	c, clock := newCache(t)
+	srv := &countingServer{}
+	interceptor := UnaryServerInterceptor(c, WithMethod(cachepb.Cache_Get_FullMethodName, time.Minute))
+	client := dial(t, srv, []grpc.ServerOption{grpc.UnaryInterceptor(interceptor)})
+
+	testInterceptor(t, client, srv, clock)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	c, clock := newCache(t)
+	srv := &countingServer{}
+	interceptor := UnaryClientInterceptor(c, WithMethod(cachepb.Cache_Get_FullMethodName, time.Minute))
+	client := dial(t, srv, nil, grpc.WithUnaryInterceptor(interceptor))
+
+	testInterceptor(t, client, srv, clock)
+
+	// Changing a cached reply doesn't change what's cached.
+	resp, err := client.Get(context.Background(), &cachepb.GetRequest{Key: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Value[0] = 'x'
+	if resp, _ := client.Get(context.Background(), &cachepb.GetRequest{Key: "key"}); string(resp.Value) != "key3" {
+		t.Errorf("changing a cached reply changed the cache: got %q", resp.Value)
+	}
+}
+
+func TestKeyMetadata(t *testing.T) {
+	c, _ := newCache(t)
+	srv := &countingServer{}
+	interceptor := UnaryClientInterceptor(c,
+		WithMethod(cachepb.Cache_Get_FullMethodName, 0), WithKeyMetadata("Authorization"))
+	client := dial(t, srv, nil, grpc.WithUnaryInterceptor(interceptor))
+
+	for _, token := range []string{"a", "b", "a", "b"} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+		if _, err := client.Get(ctx, &cachepb.GetRequest{Key: "key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := srv.gets.Load(); got != 2 {
+		t.Errorf("server got %d Gets, want 2: one per authorization", got)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HealthCheckTimeout is how long Healthy waits to lock the cache before reporting it as stuck.
+const HealthCheckTimeout = time.Second
+
+// janitorStallAfter is how many janitor intervals can go by without a sweep before Healthy
+// reports the janitor as stalled.
+const janitorStallAfter = 10
+
+// writeBehindStallAfter is how many flush intervals write-behind can go without finishing
+// a batch, while there are writes waiting, before Healthy reports it as stalled.
+const writeBehindStallAfter = 10
+
+// Healthy checks the cache's internal invariants and returns an error describing every
+// problem found, or nil if there are none. It's meant for readiness probes.
+//
+// It checks that the cache can be locked within HealthCheckTimeout, that its size matches
+// the sum of its items, and that the janitor, write-behind and invalidation aren't falling
+// behind.
+// The size check walks every item, so it shouldn't be called too often on big caches.
+func (c *Cache) Healthy() error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+
+	if !c.lockWithin(HealthCheckTimeout) {
+		return fmt.Errorf("cache: lock not acquired within %s", HealthCheckTimeout)
+	}
+	defer c.mu.Unlock()
+
+	var errs []error
+
+	var size int64
+	for key, i := range c.items {
+		size += int64(len(key)) + c.arena.entry(i).size
+	}
+	for key := range c.negative {
+		size += int64(len(key))
+	}
+	if size != c.totalCacheSize {
+		errs = append(errs, fmt.Errorf("cache: size is %d bytes but items add up to %d bytes", c.totalCacheSize, size))
+	}
+
+	if c.queued != nil && c.queued.len() != len(c.items) {
+		errs = append(errs, fmt.Errorf("cache: eviction queues have %d items but the cache has %d", c.queued.len(), len(c.items)))
+	}
+
+	if err := c.janitorHealthy(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.writeBehind != nil {
+		if err := c.writeBehind.healthy(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.bus != nil && len(c.bus.queue) == cap(c.bus.queue) {
+		errs = append(errs, errors.New("cache: invalidation queue is full, invalidations are being dropped"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// lockWithin locks c.mu, giving up if it takes longer than timeout.
+// It reports whether the lock was acquired.
+func (c *Cache) lockWithin(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.lockContext(ctx) == nil
+}
+
+func (wb *writeBehind) healthy() error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	stallAfter := writeBehindStallAfter * wb.interval
+	if waiting := time.Since(wb.waitingSince); !wb.waitingSince.IsZero() && waiting > stallAfter {
+		return fmt.Errorf("cache: write-behind hasn't written to the store in %s, %d writes waiting", waiting.Round(time.Millisecond), len(wb.pending)+len(wb.inflight))
+	}
+	return nil
+}
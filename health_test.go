@@ -0,0 +1,67 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+// stalledClock is a clock whose timers never fire, like a janitor that's stuck.
+type stalledClock struct {
+	*clocktest.Clock
+}
+
+func (c stalledClock) NewTimer(d time.Duration) cache.Timer {
+	return c.Clock.NewTimer(100 * 365 * 24 * time.Hour)
+}
+
+// waitForTimer waits until something is waiting on one of clock's timers.
+func waitForTimer(t *testing.T, clock *clocktest.Clock) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); clock.Timers() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("no timer was started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHealthyWithJanitor(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock), cache.WithJanitor(time.Minute))
+	defer c.Close()
+
+	c.SetWithTTL("key", "value", time.Second)
+	for range 20 {
+		waitForTimer(t, clock)
+		clock.Advance(time.Minute)
+	}
+	waitForTimer(t, clock)
+
+	if err := c.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.Items != 0 {
+		t.Errorf("janitor left %d expired items", stats.Items)
+	}
+}
+
+func TestHealthyReportsStalledJanitor(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(stalledClock{clock}), cache.WithJanitor(time.Minute))
+	defer c.Close()
+
+	c.SetWithTTL("key", "value", time.Second)
+	if err := c.Healthy(); err != nil {
+		t.Fatalf("before the janitor was due: %v", err)
+	}
+
+	clock.Advance(11 * time.Minute)
+	if err := c.Healthy(); err == nil || !strings.Contains(err.Error(), "janitor") {
+		t.Errorf("Healthy returned %v for a janitor that hasn't swept in 11 intervals", err)
+	}
+}
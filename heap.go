@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// heapCheckInterval is how often WithHeapLimit checks the heap.
+const heapCheckInterval = time.Second
+
+const (
+	metricHeapLive    = "/gc/heap/live:bytes"
+	metricHeapObjects = "/memory/classes/heap/objects:bytes"
+	metricGCCycles    = "/gc/cycles/total:gc-cycles"
+)
+
+// WithHeapLimit also clears the cache when the live heap, as measured by the garbage collector,
+// has grown by more than limit bytes since the cache was last empty. Unlike maxCacheSize, which
+// is based on estimates of each item's size, this catches values whose real footprint is much
+// bigger than their estimate, like maps and structs full of pointers.
+//
+// The heap is checked every second. Growth can't be attributed to the cache exactly, so anything
+// else the process allocates and keeps while the cache is filling up counts towards limit too.
+func WithHeapLimit(limit int64) Option {
+	return func(c *Cache) {
+		c.heap = newHeapMonitor(c, uint64(limit))
+		go c.heap.run()
+	}
+}
+
+// heapMonitor clears the cache when the heap grows too far past its baseline, which is the
+// live heap just after the cache was last emptied.
+type heapMonitor struct {
+	cache *Cache
+	limit uint64
+
+	baseline uint64
+
+	// After the cache is cleared, the heap doesn't shrink until the next GC, so the baseline
+	// is only re-measured once the GC count passes clearedAt.
+	remeasure bool
+	clearedAt uint64
+
+	samples []metrics.Sample
+	done    chan struct{}
+}
+
+func newHeapMonitor(c *Cache, limit uint64) *heapMonitor {
+	m := &heapMonitor{
+		cache: c,
+		limit: limit,
+		samples: []metrics.Sample{
+			{Name: metricHeapLive},
+			{Name: metricHeapObjects},
+			{Name: metricGCCycles},
+		},
+		done: make(chan struct{}),
+	}
+
+	// There may not have been a GC yet to measure the live heap, so the baseline starts as
+	// everything allocated so far, including garbage. That only makes it err on the high side.
+	_, objects, _ := m.read()
+	m.baseline = objects
+
+	return m
+}
+
+func (m *heapMonitor) read() (live, objects, cycles uint64) {
+	metrics.Read(m.samples)
+	return m.samples[0].Value.Uint64(), m.samples[1].Value.Uint64(), m.samples[2].Value.Uint64()
+}
+
+func (m *heapMonitor) run() {
+	ticker := time.NewTicker(heapCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *heapMonitor) check() {
+	live, _, cycles := m.read()
+
+	if m.remeasure {
+		if cycles > m.clearedAt {
+			m.baseline = live
+			m.remeasure = false
+		}
+		return
+	}
+
+	if live <= m.baseline+m.limit {
+		return
+	}
+
+	c := m.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logf("heap grew by %d bytes since the cache was last empty, over the limit of %d bytes. clearing...", live-m.baseline, m.limit)
+	c.selfClear()
+
+	m.remeasure = true
+	m.clearedAt = cycles
+}
@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// hotKeySampleRate is how many reads there are, on average, for each one that's counted
+	// towards finding hot keys, so that finding them costs most reads nothing but a random number.
+	hotKeySampleRate = 64
+
+	// hotKeyMinSamples is the fewest sampled reads in an interval that make a key hot, about
+	// 256 reads, so keys aren't copied for a handful of reads.
+	hotKeyMinSamples = 4
+
+	// hotKeyCandidates is how many different keys are counted per key that can be copied.
+	// Once that many have been sampled in an interval, new keys aren't counted until the next,
+	// but the hottest keys are almost always among the first sampled anyway.
+	hotKeyCandidates = 8
+
+	// With WithAdaptiveHotKeyReplication, twice as many keys are copied after any interval in
+	// which waiting for the lock took more than hotKeyGrowAbove of the time, and half as many
+	// after hotKeyCalmIntervals in a row below hotKeyShrinkBelow. The gap between them, and
+	// waiting for several intervals, stop it from flapping once the copies have taken the
+	// contention away.
+	hotKeyGrowAbove     = 0.01
+	hotKeyShrinkBelow   = 0.001
+	hotKeyCalmIntervals = 10
+)
+
+// WithHotKeyReplication copies up to n of the most read items into a read-only map every
+// interval, which Get checks with a single atomic load before it locks the cache. Readers of
+// a few very hot keys then don't take the lock at all, so they don't queue behind writers, or
+// make writers queue behind them. Hot keys are found by sampling about 1 in 64 reads, so it
+// costs other reads next to nothing, and a key has to be read about 256 times in an interval
+// to be copied. Stats.HotKeys is how many items are copied.
+//
+// Setting, deleting or changing the TTL of a copied item drops its copy before the change is
+// made, so Get never returns a value that's been replaced, other than to a read running at the
+// same time, and copies that have expired are never used. Reads of copies count as hits as
+// usual, and towards the item's hits, for eviction and WithRetainHot, once per interval.
+//
+// Nothing is copied if the cache was created WithChecksums, WithGenerations, WithRefreshAhead,
+// WithEarlyExpiration or WithTraceRecorder, since they have to see every read under the lock.
+func WithHotKeyReplication(n int, interval time.Duration) Option {
+	return func(c *Cache) {
+		c.hotKeys = n
+		c.hotKeyInterval = interval
+	}
+}
+
+// WithAdaptiveHotKeyReplication is like WithHotKeyReplication, but only copies as many hot keys
+// as contention for the cache's lock calls for, up to n. It starts with none, copies twice as
+// many, or 1, at the end of any interval in which reads and writes spent more than 1% of it
+// waiting for the lock (see Stats.LockWaitTime), and half as many after 10 intervals in a row
+// below 0.1%. It's meant for libraries embedded in applications whose concurrency isn't known
+// up front, where a fixed number would either be too small or copy keys for nothing.
+func WithAdaptiveHotKeyReplication(n int, interval time.Duration) Option {
+	return func(c *Cache) {
+		c.hotKeys = n
+		c.hotKeyInterval = interval
+		c.hotKeysAdaptive = true
+	}
+}
+
+// hotKeys is the copies of the hottest items. It's never modified once it's stored in c.hot;
+// changes swap in a new one.
+type hotKeys struct {
+	items map[string]*hotItem
+}
+
+// hotItem is a copy of a single item.
+type hotItem struct {
+	stored    any
+	expiresAt int64
+	groups    []*group // the groups the key is in, so reads don't look at c.groups
+
+	// samples counts sampled reads of the copy since it was made, for the next choice of hot
+	// keys, and estimates how many times the item was read.
+	samples atomic.Int64
+}
+
+// hotSampler counts sampled reads of keys that aren't copied, between choices of hot keys.
+type hotSampler struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// hotTuner sizes the copies for WithAdaptiveHotKeyReplication.
+type hotTuner struct {
+	mu     sync.Mutex
+	limit  int
+	calm   int // intervals in a row below hotKeyShrinkBelow
+	last   time.Time
+	waited int64 // c.mu's total wait time at last
+}
+
+// hotKeyLimit returns how many hot keys to copy: c.hotKeys, or with
+// WithAdaptiveHotKeyReplication, as many as the lock contention since it was last called calls
+// for. Waits are timed with the wall clock, so it is too.
+func (c *Cache) hotKeyLimit() int {
+	if !c.hotKeysAdaptive {
+		return c.hotKeys
+	}
+
+	t := &c.hotTuner
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now, waited := time.Now(), c.mu.waited.Load()
+	if !t.last.IsZero() && now.After(t.last) {
+		limit := t.limit
+		switch share := float64(waited-t.waited) / float64(now.Sub(t.last)); {
+		case share > hotKeyGrowAbove:
+			limit = min(max(2*limit, 1), c.hotKeys)
+			t.calm = 0
+		case share < hotKeyShrinkBelow:
+			if t.calm++; t.calm >= hotKeyCalmIntervals {
+				limit /= 2
+				t.calm = 0
+			}
+		default:
+			t.calm = 0
+		}
+
+		if limit != t.limit {
+			c.logf("lock contention changed, copying up to %d hot keys instead of %d", limit, t.limit)
+			t.limit = limit
+		}
+	}
+	t.last, t.waited = now, waited
+	return t.limit
+}
+
+// replicates reports whether hot keys can be copied: not if the cache has to see every read
+// under c.mu. c.mu must already be locked.
+func (c *Cache) replicates() bool {
+	return c.hotKeys > 0 && c.checksums == ChecksumOff && !c.generational && c.recorder == nil &&
+		c.refreshAhead <= 0 && c.earlyExpiration <= 0
+}
+
+// getHot returns key's value from its copy, if it's a hot key that hasn't expired. It doesn't
+// lock, so it only counts the read with atomics.
+func (c *Cache) getHot(key string) (any, bool) {
+	hot := c.hot.Load()
+	if hot == nil {
+		return nil, false
+	}
+	item, found := hot.items[key]
+	if !found || item.expiresAt > 0 && c.now() >= item.expiresAt {
+		return nil, false
+	}
+
+	if c.random() < 1.0/hotKeySampleRate {
+		item.samples.Add(1)
+	}
+	c.hits.Add(1)
+	for _, g := range item.groups {
+		g.hits.Add(1)
+	}
+	if c.keyspace != nil {
+		c.keyspace.record(key, true)
+	}
+	c.recordFrequency(key)
+	return item.stored, true
+}
+
+// sampleHotKey counts about 1 in hotKeySampleRate reads of key, which was found in the cache
+// rather than in a copy, towards making it hot. c.mu must already be read locked.
+func (c *Cache) sampleHotKey(key string) {
+	if c.random() >= 1.0/hotKeySampleRate {
+		return
+	}
+
+	s := &c.hotSamples
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		s.counts = make(map[string]int64)
+	}
+	if _, found := s.counts[key]; found || len(s.counts) < hotKeyCandidates*c.hotKeys {
+		s.counts[key]++
+	}
+}
+
+// startHotKeys starts choosing hot keys for WithHotKeyReplication. Like startJanitor, it's
+// called once every option has been applied.
+func (c *Cache) startHotKeys() {
+	if c.hotKeys <= 0 || c.hotKeyInterval <= 0 || c.deterministic() {
+		return
+	}
+	go c.runHotKeys()
+}
+
+func (c *Cache) runHotKeys() {
+	for {
+		timer := c.newTimer(c.hotKeyInterval)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		c.replicateHotKeys()
+	}
+}
+
+// replicateHotKeys swaps in copies of the hottest keys, the ones with the most sampled reads
+// since the last time, whether they were copied already or not. It only read locks the cache,
+// since it only changes what reads change too.
+func (c *Cache) replicateHotKeys() {
+	limit := c.hotKeyLimit()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.hotSamples.mu.Lock()
+	scores := c.hotSamples.counts
+	c.hotSamples.counts = nil
+	c.hotSamples.mu.Unlock()
+
+	if scores == nil {
+		scores = make(map[string]int64)
+	}
+	if hot := c.hot.Load(); hot != nil {
+		for key, item := range hot.items {
+			scores[key] += c.foldHot(key, item)
+		}
+	}
+
+	if !c.replicates() || limit == 0 {
+		c.hot.Store(nil)
+		return
+	}
+
+	var keys []string
+	for key, n := range scores {
+		if n >= hotKeyMinSamples {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(scores[b], scores[a]), cmp.Compare(a, b))
+	})
+
+	now := c.now()
+	items := make(map[string]*hotItem, min(len(keys), limit))
+	for _, key := range keys {
+		if len(items) == limit {
+			break
+		}
+		i, e := c.lookup(key)
+		if e == nil || e.expired(now) {
+			continue
+		}
+
+		item := &hotItem{stored: c.arena.value(i), expiresAt: e.expiresAt}
+		for _, g := range c.groups {
+			if g.match(key) {
+				item.groups = append(item.groups, g)
+			}
+		}
+		items[key] = item
+	}
+
+	if len(items) == 0 {
+		c.hot.Store(nil)
+		return
+	}
+	c.hot.Store(&hotKeys{items: items})
+}
+
+// foldHot adds the reads of key's copy to the item it was copied from, and returns how many
+// reads were sampled. c.mu must already be locked, at least for reading.
+func (c *Cache) foldHot(key string, item *hotItem) int64 {
+	n := item.samples.Swap(0)
+	i, e := c.lookup(key)
+	if n == 0 || e == nil {
+		return n
+	}
+
+	atomic.AddInt64(&e.hits, n*hotKeySampleRate)
+	if c.tracksAccess() {
+		atomic.StoreInt64(&e.accessedAt, c.now())
+	}
+	if c.queued != nil {
+		c.queued.visit(i)
+	}
+	return n
+}
+
+// unreplicate drops key's copy, if it has one, before the item is changed or removed.
+// c.mu must already be locked.
+func (c *Cache) unreplicate(key string) {
+	hot := c.hot.Load()
+	if hot == nil {
+		return
+	}
+	item, found := hot.items[key]
+	if !found {
+		return
+	}
+
+	c.foldHot(key, item)
+	items := maps.Clone(hot.items)
+	delete(items, key)
+	c.hot.Store(&hotKeys{items: items})
+}
+
+// dropHotKeys drops every copy, after a change that they'd miss, until hot keys are next
+// chosen. c.mu must already be locked.
+func (c *Cache) dropHotKeys() {
+	hot := c.hot.Load()
+	if hot == nil {
+		return
+	}
+	for key, item := range hot.items {
+		c.foldHot(key, item)
+	}
+	c.hot.Store(nil)
+}
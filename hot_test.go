@@ -0,0 +1,221 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+// readN gets key n times.
+func readN(c *cache.Cache, key string, n int) {
+	for range n {
+		c.Get(key)
+	}
+}
+
+func TestHotKeyReplication(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock), cache.WithDeterministic(1),
+		cache.WithTopKeys(1), cache.WithHotKeyReplication(2, time.Second))
+	defer c.Close()
+
+	c.Set("hot", "value")
+	c.Set("warm", "value")
+	c.SetWithTTL("expiring", "value", time.Minute)
+	c.Set("cold", "value")
+	readN(c, "hot", 3000)
+	readN(c, "warm", 2000)
+	readN(c, "expiring", 1000)
+	readN(c, "cold", 10)
+
+	c.Sweep()
+	if got := c.Stats().HotKeys; got != 2 {
+		t.Fatalf("HotKeys = %d after choosing them, want 2", got)
+	}
+
+	// Reads of the copies still count, and are added to the item's hits when hot keys are next chosen.
+	hits := c.Stats().Hits
+	readN(c, "hot", 3000)
+	if got := c.Stats().Hits - hits; got != 3000 {
+		t.Errorf("3000 reads of a copy counted %d hits", got)
+	}
+	c.Sweep()
+	if top := c.Stats().TopKeys.Hottest; len(top) != 1 || top[0].Key != "hot" || top[0].Hits < 5000 {
+		t.Errorf("hottest item is %+v, want hot with 3000 hits and about 3000 more", top)
+	}
+
+	c.Set("hot", "new value")
+	if got, _ := c.Get("hot"); got != "new value" {
+		t.Errorf("after setting a hot key, got %v, want the new value", got)
+	}
+	c.Delete("warm")
+	if _, found := c.Get("warm"); found {
+		t.Error("deleted hot key was still found")
+	}
+	if got := c.Stats().HotKeys; got != 0 {
+		t.Errorf("HotKeys = %d after changing both, want 0", got)
+	}
+
+	readN(c, "hot", 3000)
+	readN(c, "expiring", 3000)
+	c.Sweep()
+	if got := c.Stats().HotKeys; got != 2 {
+		t.Fatalf("HotKeys = %d after choosing them again, want 2", got)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, found := c.Get("expiring"); found {
+		t.Error("copy of an expired item was served")
+	}
+
+	c.Clear()
+	if got := c.Stats().HotKeys; got != 0 {
+		t.Errorf("HotKeys = %d after clearing, want 0", got)
+	}
+	if err := c.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHotKeyReplicationNeedsLockedReads(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithDeterministic(1),
+		cache.WithChecksums(cache.ChecksumAlways), cache.WithHotKeyReplication(1, time.Second))
+	defer c.Close()
+
+	c.Set("hot", "value")
+	readN(c, "hot", 3000)
+	c.Sweep()
+	if got := c.Stats().HotKeys; got != 0 {
+		t.Errorf("HotKeys = %d with checksums, want 0", got)
+	}
+}
+
+func TestHotKeyReplicationConcurrent(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithHotKeyReplication(4, time.Millisecond))
+	defer c.Close()
+
+	c.Set("hot", 0)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					c.Get("hot")
+				}
+			}
+		})
+	}
+
+	for i := range 10 {
+		// Wait for the readers to get the key copied, then change it under them.
+		for deadline := time.Now().Add(5 * time.Second); c.Stats().HotKeys == 0; {
+			if time.Now().After(deadline) {
+				t.Fatal("hot key was never copied")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		c.Set("hot", i)
+		if got, _ := c.Get("hot"); got != i {
+			t.Fatalf("after setting %d, got %v", i, got)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestAdaptiveHotKeyReplication(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithDeterministic(1),
+		cache.WithAdaptiveHotKeyReplication(4, time.Second))
+	defer c.Close()
+
+	keys := make([]string, 8)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.Set(keys[i], "value")
+	}
+	locked := make(chan struct{})
+	c.Subscribe(func(ev cache.Event) {
+		if ev.Key == "slow" {
+			locked <- struct{}{}
+			time.Sleep(20 * time.Millisecond) // with the cache locked
+		}
+	})
+
+	// sweep reads every key after a Get that waits for a slow Set if contended, and then
+	// chooses hot keys.
+	sweep := func(contended bool) int {
+		if contended {
+			go c.Set("slow", "value")
+			<-locked
+			c.Get("slow")
+		}
+		for _, key := range keys {
+			readN(c, key, 1000)
+		}
+		c.Sweep()
+		return c.Stats().HotKeys
+	}
+
+	sweep(false) // the first interval only starts timing
+	if got := sweep(false); got != 0 {
+		t.Fatalf("HotKeys = %d without contention, want 0", got)
+	}
+	for _, want := range []int{1, 2, 4, 4} {
+		if got := sweep(true); got != want {
+			t.Fatalf("HotKeys = %d after an interval with contention, want %d", got, want)
+		}
+	}
+	for i := range 9 {
+		if got := sweep(false); got != 4 {
+			t.Fatalf("HotKeys = %d after %d intervals without contention, want 4 still", got, i+1)
+		}
+	}
+	if got := sweep(false); got != 2 {
+		t.Errorf("HotKeys = %d after 10 intervals without contention, want 2", got)
+	}
+}
+
+// BenchmarkParallelHotKey reads a single key from every P at once while a writer sets other
+// keys, with and without WithHotKeyReplication.
+func BenchmarkParallelHotKey(b *testing.B) {
+	for _, replicate := range []bool{false, true} {
+		b.Run("replicate="+strconv.FormatBool(replicate), func(b *testing.B) {
+			opts := []cache.Option{cache.WithLogger(nil)}
+			if replicate {
+				opts = append(opts, cache.WithHotKeyReplication(16, 10*time.Millisecond))
+			}
+			c := cache.New(1<<30, opts...)
+			defer c.Close()
+			c.Set("hot", "value")
+
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for i := 0; ; i++ {
+					select {
+					case <-done:
+						return
+					default:
+						c.Set("key"+strconv.Itoa(i%1024), "value")
+					}
+				}
+			}()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.Get("hot")
+				}
+			})
+		})
+	}
+}
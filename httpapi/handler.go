@@ -0,0 +1,211 @@
+// Package httpapi exposes a cache.Cache over a small REST API so other services
+// and curl can read and invalidate entries.
+//
+// Routes:
+//
+//	GET    /cache/{key}   returns the value, 404 if missing
+//	PUT    /cache/{key}   sets the value to the request body, with an optional ?ttl=30s
+//	DELETE /cache/{key}   removes the value
+//	GET    /keys          lists keys as JSON, optionally filtered by ?prefix=
+//	DELETE /keys?prefix=  removes every key with the prefix, returning the number removed as JSON
+//	POST   /flush         clears the cache
+//	GET    /stats         returns cache.Stats as JSON
+//	GET    /healthz       returns 200 if cache.Healthy passes, 503 and the problems if not
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultMaxBodySize is the largest value accepted by PUT unless changed with WithMaxBodySize.
+const DefaultMaxBodySize = 1 << 20
+
+// Handler serves the REST API for a cache.
+type Handler struct {
+	cache       *cache.Cache
+	mux         *http.ServeMux
+	authorize   func(r *http.Request) bool
+	maxBodySize int64
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithAuth only allows requests for which authorize returns true. Other requests get a 401.
+func WithAuth(authorize func(r *http.Request) bool) Option {
+	return func(h *Handler) {
+		h.authorize = authorize
+	}
+}
+
+// WithBearerToken only allows requests with an "Authorization: Bearer <token>" header.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(r *http.Request) bool {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	})
+}
+
+// WithMaxBodySize sets the largest value accepted by PUT.
+func WithMaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// NewHandler creates a Handler for c. Mount it under a prefix with http.StripPrefix.
+func NewHandler(c *cache.Cache, opts ...Option) *Handler {
+	h := &Handler{
+		cache:       c,
+		mux:         http.NewServeMux(),
+		maxBodySize: DefaultMaxBodySize,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux.HandleFunc("GET /cache/{key...}", h.get)
+	h.mux.HandleFunc("PUT /cache/{key...}", h.put)
+	h.mux.HandleFunc("DELETE /cache/{key...}", h.delete)
+	h.mux.HandleFunc("GET /keys", h.keys)
+	h.mux.HandleFunc("DELETE /keys", h.deletePrefix)
+	h.mux.HandleFunc("POST /flush", h.flush)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	h.mux.HandleFunc("GET /healthz", h.healthz)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize != nil && !h.authorize(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	value, found := h.cache.Get(r.PathValue("key"))
+	if !found {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, v)
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+	default:
+		// Values set directly on the cache rather than through the API are sent as JSON.
+		writeJSON(w, value)
+	}
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if s := r.URL.Query().Get("ttl"); s != "" {
+		var err error
+		if ttl, err = time.ParseDuration(s); err != nil || ttl < 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	h.cache.SetWithTTL(key, string(body), ttl)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	h.cache.Delete(r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	keys := []string{}
+	for _, key := range h.cache.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	writeJSON(w, keys)
+}
+
+func (h *Handler) deletePrefix(w http.ResponseWriter, r *http.Request) {
+	// An empty prefix would match everything, which is what /flush is for.
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		http.Error(w, "missing prefix, use POST /flush to clear everything", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, struct {
+		Deleted int `json:"deleted"`
+	}{h.cache.DeletePrefix(prefix)})
+}
+
+func (h *Handler) flush(w http.ResponseWriter, r *http.Request) {
+	h.cache.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.cache.Stats())
+}
+
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	if err := h.cache.Healthy(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("httpapi: encoding response: %v", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func newHandler(t *testing.T, opts ...Option) (*Handler, *cache.Cache) {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+	return NewHandler(c, opts...), c
+}
+
+// do sends a request with body, which may be empty, to h.
+func do(h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Add(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPutGetDelete(t *testing.T) {
+	h, c := newHandler(t)
+
+	if w := do(h, http.MethodGet, "/cache/a/b", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET of a missing key returned %d, want 404", w.Code)
+	}
+	if w := do(h, http.MethodPut, "/cache/a/b?ttl=1m", "value"); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT returned %d: %s", w.Code, w.Body)
+	}
+	if ttl, _ := c.TTL("a/b"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("PUT with ?ttl=1m set a TTL of %s", ttl)
+	}
+
+	w := do(h, http.MethodGet, "/cache/a/b", "")
+	if w.Code != http.StatusOK || w.Body.String() != "value" {
+		t.Fatalf("GET returned %d %q, want 200 value", w.Code, w.Body)
+	}
+
+	// Values set on the cache directly come back as JSON.
+	c.Set("n", map[string]int{"n": 1})
+	if w := do(h, http.MethodGet, "/cache/n", ""); w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"n":1}` {
+		t.Errorf("GET of a map returned %s %q", w.Header().Get("Content-Type"), w.Body)
+	}
+
+	if w := do(h, http.MethodDelete, "/cache/a/b", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE returned %d", w.Code)
+	}
+	if c.Has("a/b") {
+		t.Error("DELETE left the key in the cache")
+	}
+}
+
+func TestPutRejectsBadRequests(t *testing.T) {
+	h, c := newHandler(t, WithMaxBodySize(4))
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/cache/a?ttl=soon", "v", http.StatusBadRequest},
+		{"/cache/a?ttl=-1s", "v", http.StatusBadRequest},
+		{"/cache/a", "too large", http.StatusRequestEntityTooLarge},
+		{"/cache/", "v", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := do(h, http.MethodPut, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("PUT %s %q returned %d, want %d", tt.path, tt.body, w.Code, tt.want)
+		}
+	}
+	if len(c.Keys()) != 0 {
+		t.Errorf("rejected PUTs left %v in the cache", c.Keys())
+	}
+}
+
+func TestKeysAndDeletePrefix(t *testing.T) {
+	h, c := newHandler(t)
+	for _, key := range []string{"user:2", "user:1", "order:1"} {
+		c.Set(key, "v")
+	}
+
+	var keys []string
+	if err := json.Unmarshal(do(h, http.MethodGet, "/keys?prefix=user:", "").Body.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "user:1,user:2" {
+		t.Errorf("GET /keys?prefix=user: returned %v", keys)
+	}
+
+	if w := do(h, http.MethodDelete, "/keys", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("DELETE /keys without a prefix returned %d, want 400", w.Code)
+	}
+	if len(c.Keys()) != 3 {
+		t.Fatal("DELETE /keys without a prefix deleted keys")
+	}
+
+	w := do(h, http.MethodDelete, "/keys?prefix=user:", "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"deleted":2}` {
+		t.Errorf("DELETE /keys?prefix=user: returned %d %q", w.Code, w.Body)
+	}
+	if got := c.Keys(); len(got) != 1 || got[0] != "order:1" {
+		t.Errorf("DELETE /keys?prefix=user: left %v", got)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("a", "1")
+	c.Set("b", "2")
+
+	if w := do(h, http.MethodGet, "/flush", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /flush returned %d, want 405", w.Code)
+	}
+	if w := do(h, http.MethodPost, "/flush", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /flush returned %d", w.Code)
+	}
+	if len(c.Keys()) != 0 {
+		t.Errorf("POST /flush left %v", c.Keys())
+	}
+}
+
+func TestStatsAndHealthz(t *testing.T) {
+	h, c := newHandler(t)
+	c.Set("a", "1")
+	c.Get("a")
+
+	var stats cache.Stats
+	if err := json.Unmarshal(do(h, http.MethodGet, "/stats", "").Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 {
+		t.Errorf("GET /stats reported %d hits, want 1", stats.Hits)
+	}
+
+	if w := do(h, http.MethodGet, "/healthz", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /healthz returned %d: %s", w.Code, w.Body)
+	}
+	c.Close()
+	if w := do(h, http.MethodGet, "/healthz", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /healthz on a closed cache returned %d, want 503", w.Code)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	h, c := newHandler(t, WithBearerToken("secret"))
+	c.Set("a", "1")
+
+	for _, header := range [][]string{
+		nil,
+		{"Authorization", "Bearer wrong"},
+		{"Authorization", "Basic c2VjcmV0"},
+		{"Authorization", "secret"},
+	} {
+		w := do(h, http.MethodGet, "/cache/a", "", header...)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("GET with %v returned %d, want 401 with a WWW-Authenticate header", header, w.Code)
+		}
+	}
+	if w := do(h, http.MethodPost, "/flush", "", "Authorization", "Bearer wrong"); w.Code != http.StatusUnauthorized || !c.Has("a") {
+		t.Errorf("an unauthorized flush returned %d", w.Code)
+	}
+
+	if w := do(h, http.MethodGet, "/cache/a", "", "Authorization", "Bearer secret"); w.Code != http.StatusOK {
+		t.Errorf("GET with the right token returned %d", w.Code)
+	}
+}
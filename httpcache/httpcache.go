@@ -0,0 +1,277 @@
+// Package httpcache caches HTTP responses in a cache.Cache, following the Cache-Control
+// caching rules of RFC 9111.
+//
+// Middleware caches a server's own responses, as a shared cache in front of its handlers:
+//
+//	c := cache.New(64 << 20)
+//	http.ListenAndServe(":8080", httpcache.Middleware(c)(mux))
+//
+// Transport caches the responses an http.Client receives, as a private cache, revalidating
+// stale responses with their ETag or Last-Modified:
+//
+//	client := &http.Client{Transport: httpcache.NewTransport(c, nil)}
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultMaxBodySize is the largest response body that's cached unless changed with WithMaxBodySize.
+const DefaultMaxBodySize = 1 << 20
+
+// DefaultKeyPrefix is put in front of every key unless changed with WithKeyPrefix, so responses
+// can share a cache with other values and be dropped with DeletePrefix.
+const DefaultKeyPrefix = "httpcache:"
+
+// cacheableStatus holds the status codes that can be cached without explicit freshness
+// information, from RFC 9110 section 15.1. Other statuses aren't cached at all.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+	http.StatusPermanentRedirect:    true,
+}
+
+// response is a cached response.
+type response struct {
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+	age      time.Duration // the response's Age when it was stored
+	lifetime time.Duration // how long the response is fresh for from when it was generated
+}
+
+// fresh returns how much longer r is fresh for at now, which is zero or negative once it's stale.
+func (r *response) fresh(now time.Time) time.Duration {
+	return r.lifetime - r.currentAge(now)
+}
+
+// Size implements cache.Sizer.
+func (r *response) Size() int64 {
+	n := int64(len(r.body))
+	for name, values := range r.header {
+		n += int64(len(name))
+		for _, v := range values {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+// currentAge is the response's age at now, for the Age header.
+func (r *response) currentAge(now time.Time) time.Duration {
+	return r.age + now.Sub(r.storedAt)
+}
+
+// write sends a copy of r to w.
+func (r *response) write(w http.ResponseWriter, now time.Time, method string) {
+	header := w.Header()
+	for name, values := range r.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(r.currentAge(now).Seconds())))
+
+	w.WriteHeader(r.status)
+	if method != http.MethodHead {
+		w.Write(r.body)
+	}
+}
+
+// key returns the key the response to r is stored under, if it was made with method.
+func (cfg *config) key(method string, r *http.Request) string {
+	return cfg.prefix + method + " " + r.Host + r.URL.RequestURI()
+}
+
+// invalidate drops every response cached for r's URL.
+func (cfg *config) invalidate(c *cache.Cache, r *http.Request) {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		key := cfg.key(method, r)
+		c.Delete(key)
+		c.DeletePrefix(key + "\x00")
+	}
+}
+
+// storable returns the response to r to cache, and the request headers it varies on, or false
+// if it can't be cached. shared applies the stricter rules for caches shared between users.
+// A private cache can store responses that are already stale, or have no-cache, to revalidate them.
+func (cfg *config) storable(r *http.Request, status int, header http.Header, body []byte, now time.Time, shared bool) (*response, []string, bool) {
+	if !cacheableStatus[status] {
+		return nil, nil, false
+	}
+
+	cc := parseCacheControl(header)
+	if cc.has("no-store") {
+		return nil, nil, false
+	}
+	if shared {
+		if cc.has("no-cache") || cc.has("private") || header.Get("Set-Cookie") != "" {
+			return nil, nil, false
+		}
+		if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") {
+			return nil, nil, false
+		}
+	}
+
+	vary, ok := varyHeaders(header)
+	if !ok {
+		return nil, nil, false
+	}
+
+	lifetime, ok := freshness(header, cc, shared)
+	if !ok {
+		lifetime = cfg.defaultTTL
+	}
+	if cc.has("no-cache") {
+		lifetime = 0
+	}
+
+	return &response{
+		status:   status,
+		header:   header,
+		body:     body,
+		storedAt: now,
+		age:      ageOf(header),
+		lifetime: lifetime,
+	}, vary, true
+}
+
+// lookup returns the response cached for r under key.
+func lookup(c *cache.Cache, key string, r *http.Request) (*response, bool) {
+	value, found := c.Get(key)
+	if v, ok := value.(*varies); found && ok {
+		value, found = c.Get(variantKey(key, v.headers, r))
+	}
+
+	resp, ok := value.(*response)
+	return resp, found && ok
+}
+
+// store caches resp, the response to r, under key for ttl, or until it's dropped if ttl is 0.
+func store(c *cache.Cache, key string, r *http.Request, resp *response, vary []string, ttl time.Duration) {
+	if len(vary) > 0 {
+		c.SetWithTTL(key, &varies{headers: vary}, ttl)
+		key = variantKey(key, vary, r)
+	}
+	c.SetWithTTL(key, resp, ttl)
+}
+
+// varies is stored under a URL's key when its responses have a Vary header. The response
+// itself is stored under the key plus the request's values for those headers.
+type varies struct {
+	headers []string
+}
+
+// variantKey returns the key a response varying on headers is stored under for r.
+func variantKey(key string, headers []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range headers {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// varyHeaders returns the headers listed in h's Vary header, and false for Vary: *, which
+// means the response can't be cached.
+func varyHeaders(h http.Header) ([]string, bool) {
+	var headers []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+			case "*":
+				return nil, false
+			default:
+				headers = append(headers, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return headers, true
+}
+
+// cacheControl holds the directives of a Cache-Control header, keyed by lowercased name.
+// Directives without an argument map to an empty string.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the argument of a delta-seconds directive like max-age.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshness returns how long a response with header h stays fresh from when it was generated,
+// from s-maxage (for shared caches), max-age or Expires. ok is false if h doesn't say.
+func freshness(h http.Header, cc cacheControl, shared bool) (lifetime time.Duration, ok bool) {
+	if shared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d, true
+		}
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d, true
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		// An invalid Expires, like "0", means the response is already stale.
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, true
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		return max(t.Sub(date), 0), true
+	}
+	return 0, false
+}
+
+// ageOf returns the Age header of h, or 0 if there isn't a valid one.
+func ageOf(h http.Header) time.Duration {
+	n, err := strconv.ParseInt(h.Get("Age"), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,184 @@
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// Option configures Middleware and NewTransport.
+type Option func(*config)
+
+// config holds the settings shared by Middleware and Transport.
+type config struct {
+	defaultTTL  time.Duration
+	maxBodySize int64
+	prefix      string
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		maxBodySize: DefaultMaxBodySize,
+		prefix:      DefaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithDefaultTTL caches responses that don't say how long they're fresh for, with max-age,
+// s-maxage or Expires, for ttl. By default they aren't cached.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(cfg *config) {
+		cfg.defaultTTL = ttl
+	}
+}
+
+// WithMaxBodySize sets the largest response body that's cached. Bigger responses are still
+// sent to the client, just not cached.
+func WithMaxBodySize(n int64) Option {
+	return func(cfg *config) {
+		cfg.maxBodySize = n
+	}
+}
+
+// WithKeyPrefix changes the prefix put in front of every key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.prefix = prefix
+	}
+}
+
+// Middleware returns middleware that caches GET and HEAD responses in c, keyed on the method,
+// host and URL, plus the request headers named by the response's Vary header. Cached responses
+// are sent with an Age header and "X-Cache: HIT", and others with "X-Cache: MISS".
+//
+// Responses are cached as in a shared cache: for as long as their s-maxage, max-age or Expires
+// allows, and not at all if they have Cache-Control no-store, no-cache or private, a Set-Cookie
+// header, "Vary: *", or a status that isn't cacheable by default. Responses to requests with an
+// Authorization header are only cached if they're marked public. Requests can skip the cache
+// with no-store, no-cache or max-age.
+//
+// A successful POST, PUT, PATCH or DELETE drops the cached responses for its URL.
+func Middleware(c *cache.Cache, opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		return &handler{config: cfg, cache: c, next: next}
+	}
+}
+
+type handler struct {
+	config
+	cache *cache.Cache
+	next  http.Handler
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.serveUnsafe(w, r)
+		return
+	}
+
+	reqCC := parseCacheControl(r.Header)
+	if reqCC.has("no-store") {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := h.key(r.Method, r)
+	now := time.Now()
+
+	if resp, found := lookup(h.cache, key, r); found && !reqCC.has("no-cache") {
+		if maxAge, ok := reqCC.seconds("max-age"); !ok || resp.currentAge(now) <= maxAge {
+			w.Header().Set("X-Cache", "HIT")
+			resp.write(w, now, r.Method)
+			return
+		}
+	}
+
+	w.Header().Set("X-Cache", "MISS")
+	rec := &recorder{ResponseWriter: w, buffer: true, maxBodySize: h.maxBodySize}
+	h.next.ServeHTTP(rec, r)
+	h.store(key, r, rec, now)
+}
+
+// serveUnsafe serves a request that may change the resource, and drops what's cached for it if it succeeds.
+func (h *handler) serveUnsafe(w http.ResponseWriter, r *http.Request) {
+	rec := &recorder{ResponseWriter: w}
+	h.next.ServeHTTP(rec, r)
+	rec.finish()
+
+	if rec.status < 400 {
+		h.invalidate(h.cache, r)
+	}
+}
+
+// store caches the response recorded by rec, if it can be.
+func (h *handler) store(key string, r *http.Request, rec *recorder, now time.Time) {
+	rec.finish()
+	if rec.tooBig {
+		return
+	}
+
+	rec.header.Del("X-Cache")
+	resp, vary, ok := h.storable(r, rec.status, rec.header, rec.body.Bytes(), now, true)
+	if !ok || resp.fresh(now) <= 0 {
+		return
+	}
+	store(h.cache, key, r, resp, vary, resp.fresh(now))
+}
+
+// recorder passes a response through to the client while recording its status, headers
+// and, if buffer is set, up to maxBodySize bytes of its body.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header // the headers as they were when the status was written
+
+	buffer      bool
+	maxBodySize int64
+	body        bytes.Buffer
+	tooBig      bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	// Informational responses like 103 Early Hints come before the real status.
+	if rec.status == 0 && status >= 200 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if rec.buffer && !rec.tooBig {
+		if int64(rec.body.Len()+len(p)) > rec.maxBodySize {
+			rec.tooBig = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter, e.g. to flush.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// finish records the implicit 200 of a handler that didn't write anything.
+func (rec *recorder) finish() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+		rec.header = rec.Header().Clone()
+	}
+}
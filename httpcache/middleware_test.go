@@ -0,0 +1,219 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// origin serves a response with the given headers and body, and counts its requests.
+type origin struct {
+	status int
+	header http.Header
+	body   string
+	calls  atomic.Int64
+}
+
+func (o *origin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.calls.Add(1)
+	for name, values := range o.header {
+		w.Header()[name] = values
+	}
+	if r.Method == http.MethodPost && r.URL.Query().Get("fail") != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+	}
+	w.Write([]byte(o.body))
+}
+
+// newMiddleware returns the middleware in front of an origin with header.
+func newMiddleware(t *testing.T, header http.Header, opts ...Option) (http.Handler, *origin) {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+	o := &origin{header: header, body: "body"}
+	return Middleware(c, opts...)(o), o
+}
+
+// do sends a request for path through h, with header "Name: value" pairs.
+func do(h http.Handler, method, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareCachesFreshResponses(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}})
+
+	if w := do(h, http.MethodGet, "/a"); w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "body" {
+		t.Fatalf("first request: X-Cache %q, body %q", w.Header().Get("X-Cache"), w.Body)
+	}
+	w := do(h, http.MethodGet, "/a")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "body" || w.Header().Get("Age") == "" {
+		t.Errorf("second request: X-Cache %q, Age %q, body %q", w.Header().Get("X-Cache"), w.Header().Get("Age"), w.Body)
+	}
+	if w.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("cached response lost its headers: %v", w.Header())
+	}
+
+	do(h, http.MethodGet, "/a?other")
+	if got := o.calls.Load(); got != 2 {
+		t.Errorf("origin got %d requests, want 2: one per URL", got)
+	}
+
+	// HEAD is cached separately, and served without a body.
+	do(h, http.MethodHead, "/a")
+	if w := do(h, http.MethodHead, "/a"); w.Header().Get("X-Cache") != "HIT" || w.Body.Len() != 0 {
+		t.Errorf("cached HEAD: X-Cache %q, body %q", w.Header().Get("X-Cache"), w.Body)
+	}
+}
+
+func TestMiddlewareDoesNotCache(t *testing.T) {
+	for name, header := range map[string]http.Header{
+		"no-store":            {"Cache-Control": {"max-age=60, no-store"}},
+		"no-cache":            {"Cache-Control": {"max-age=60, no-cache"}},
+		"private":             {"Cache-Control": {"private, max-age=60"}},
+		"Set-Cookie":          {"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=1"}},
+		"Vary: *":             {"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+		"no freshness":        {},
+		"stale":               {"Cache-Control": {"max-age=60"}, "Age": {"120"}},
+		"s-maxage over max":   {"Cache-Control": {"max-age=60, s-maxage=0"}},
+		"Expires in the past": {"Expires": {"Thu, 01 Jan 1970 00:00:00 GMT"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, o := newMiddleware(t, header)
+			do(h, http.MethodGet, "/")
+			if w := do(h, http.MethodGet, "/"); w.Header().Get("X-Cache") != "MISS" {
+				t.Errorf("response was served from the cache")
+			}
+			if got := o.calls.Load(); got != 2 {
+				t.Errorf("origin got %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareUncacheableStatus(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}})
+	o.status = http.StatusInternalServerError
+
+	do(h, http.MethodGet, "/")
+	do(h, http.MethodGet, "/")
+	if got := o.calls.Load(); got != 2 {
+		t.Errorf("a 500 was cached: origin got %d requests, want 2", got)
+	}
+}
+
+func TestMiddlewareFreshness(t *testing.T) {
+	for name, tt := range map[string]struct {
+		header http.Header
+		opts   []Option
+	}{
+		"default TTL": {header: http.Header{}, opts: []Option{WithDefaultTTL(time.Minute)}},
+		"s-maxage":    {header: http.Header{"Cache-Control": {"s-maxage=60"}}},
+		"Expires": {header: http.Header{
+			"Date":    {time.Now().UTC().Format(http.TimeFormat)},
+			"Expires": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, o := newMiddleware(t, tt.header, tt.opts...)
+			do(h, http.MethodGet, "/")
+			if w := do(h, http.MethodGet, "/"); w.Header().Get("X-Cache") != "HIT" || o.calls.Load() != 1 {
+				t.Errorf("second request: X-Cache %q, origin got %d requests", w.Header().Get("X-Cache"), o.calls.Load())
+			}
+		})
+	}
+}
+
+func TestMiddlewareAuthorization(t *testing.T) {
+	for cacheControl, cached := range map[string]bool{
+		"max-age=60":         false,
+		"public, max-age=60": true,
+		"s-maxage=60":        true,
+	} {
+		h, o := newMiddleware(t, http.Header{"Cache-Control": {cacheControl}})
+		do(h, http.MethodGet, "/", "Authorization", "Bearer token")
+		do(h, http.MethodGet, "/", "Authorization", "Bearer token")
+		if got := o.calls.Load() == 1; got != cached {
+			t.Errorf("authorized response with %q: cached %v, want %v", cacheControl, got, cached)
+		}
+	}
+}
+
+func TestMiddlewareVary(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding"}})
+
+	for range 2 {
+		do(h, http.MethodGet, "/", "Accept-Encoding", "gzip")
+		do(h, http.MethodGet, "/", "Accept-Encoding", "br")
+		do(h, http.MethodGet, "/")
+	}
+	if got := o.calls.Load(); got != 3 {
+		t.Errorf("origin got %d requests, want 3: one per variant", got)
+	}
+}
+
+func TestMiddlewareRequestDirectives(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}})
+	do(h, http.MethodGet, "/")
+
+	for _, cacheControl := range []string{"no-store", "no-cache", "max-age=0"} {
+		before := o.calls.Load()
+		if w := do(h, http.MethodGet, "/", "Cache-Control", cacheControl); w.Header().Get("X-Cache") == "HIT" {
+			t.Errorf("request with %q was served from the cache", cacheControl)
+		}
+		if o.calls.Load() != before+1 {
+			t.Errorf("request with %q didn't reach the origin", cacheControl)
+		}
+	}
+}
+
+func TestMiddlewareInvalidation(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept"}})
+	do(h, http.MethodGet, "/a", "Accept", "text/html")
+	do(h, http.MethodHead, "/a")
+
+	do(h, http.MethodPost, "/a?fail=1")
+	do(h, http.MethodPost, "/b")
+	if w := do(h, http.MethodGet, "/a", "Accept", "text/html"); w.Header().Get("X-Cache") != "HIT" {
+		t.Error("a failed POST, or a POST to another URL, dropped the cached response")
+	}
+
+	o.calls.Store(0)
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		do(h, method, "/a")
+		do(h, http.MethodGet, "/a", "Accept", "text/html")
+		do(h, http.MethodHead, "/a")
+	}
+	if got := o.calls.Load(); got != 12 {
+		t.Errorf("origin got %d requests, want 12: every unsafe request followed by a GET and HEAD miss", got)
+	}
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	h, o := newMiddleware(t, http.Header{"Cache-Control": {"max-age=60"}}, WithMaxBodySize(10))
+	o.body = strings.Repeat("x", 11)
+
+	for range 2 {
+		if w := do(h, http.MethodGet, "/"); w.Body.String() != o.body {
+			t.Fatalf("response bigger than the limit was cut short: %q", w.Body)
+		}
+	}
+	if got := o.calls.Load(); got != 2 {
+		t.Errorf("response bigger than the limit was cached: origin got %d requests", got)
+	}
+}
@@ -0,0 +1,187 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// Transport is an http.RoundTripper that caches GET and HEAD responses in a cache, as a private
+// cache for a single client. Responses are served from the cache while they're fresh, as given
+// by max-age or Expires. Once they're stale, or if they have Cache-Control no-cache, they're
+// revalidated with If-None-Match or If-Modified-Since if they have an ETag or Last-Modified, and
+// a 304 Not Modified serves the cached body. Responses with a validator are kept until the cache
+// drops them, since even a stale copy saves downloading the body again.
+//
+// Cached responses have an Age header and "X-Cache: HIT", including revalidated ones.
+// A successful request with any other method drops the cached responses for its URL.
+type Transport struct {
+	config
+	cache *cache.Cache
+	next  http.RoundTripper
+}
+
+// NewTransport creates a Transport storing responses in c and sending requests with next,
+// or http.DefaultTransport if next is nil.
+func NewTransport(c *cache.Cache, next http.RoundTripper, opts ...Option) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{config: newConfig(opts), cache: c, next: next}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && resp.StatusCode < 400 {
+			t.invalidate(t.cache, req)
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") {
+		return t.next.RoundTrip(req)
+	}
+
+	key := t.key(req.Method, req)
+	now := time.Now()
+
+	cached, found := lookup(t.cache, key, req)
+	if found && !reqCC.has("no-cache") && cached.fresh(now) > 0 {
+		if maxAge, ok := reqCC.seconds("max-age"); !ok || cached.currentAge(now) <= maxAge {
+			return cached.httpResponse(req, now), nil
+		}
+	}
+
+	outgoing := req
+	if found && isUnconditional(req) {
+		outgoing = revalidation(req, cached)
+	}
+
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && outgoing != req && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return t.refreshed(key, req, cached, resp.Header, time.Now()), nil
+	}
+
+	return t.store(key, req, resp, time.Now())
+}
+
+// isUnconditional reports whether req doesn't have its own conditional headers, which the
+// caller wants applied to the origin rather than the cache.
+func isUnconditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+}
+
+// revalidation returns a copy of req asking for cached only if it's changed. It returns req
+// itself if cached has no validator to ask with.
+func revalidation(req *http.Request, cached *response) *http.Request {
+	etag, lastModified := cached.header.Get("ETag"), cached.header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+
+	outgoing := req.Clone(req.Context())
+	if etag != "" {
+		outgoing.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		outgoing.Header.Set("If-Modified-Since", lastModified)
+	}
+	return outgoing
+}
+
+// refreshed updates cached with the headers of a 304 response to revalidating it, and returns it.
+func (t *Transport) refreshed(key string, req *http.Request, cached *response, header http.Header, now time.Time) *http.Response {
+	merged := cached.header.Clone()
+	for name, values := range header {
+		// A 304 has no body, so its Content-Length isn't the cached body's.
+		if name != "Content-Length" {
+			merged[name] = values
+		}
+	}
+
+	resp, vary, ok := t.storable(req, cached.status, merged, cached.body, now, false)
+	if !ok {
+		t.cache.Delete(key)
+		resp = &response{status: cached.status, header: merged, body: cached.body, storedAt: now}
+		return resp.httpResponse(req, now)
+	}
+
+	t.save(key, req, resp, vary, now)
+	return resp.httpResponse(req, now)
+}
+
+// store caches resp if it can be, and returns it with its body replaced by what was read.
+func (t *Transport) store(key string, req *http.Request, resp *http.Response, now time.Time) (*http.Response, error) {
+	if !cacheableStatus[resp.StatusCode] {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("httpcache: reading response: %w", err)
+	}
+	if int64(len(body)) > t.maxBodySize {
+		// Too big to cache, so the caller gets what's been read followed by the rest.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	cached, vary, ok := t.storable(req, resp.StatusCode, resp.Header.Clone(), body, now, false)
+	if ok {
+		t.save(key, req, cached, vary, now)
+	}
+	return resp, nil
+}
+
+// save caches resp for as long as it's fresh, or until the cache drops it if it can be revalidated.
+func (t *Transport) save(key string, req *http.Request, resp *response, vary []string, now time.Time) {
+	var ttl time.Duration
+	if resp.header.Get("ETag") == "" && resp.header.Get("Last-Modified") == "" {
+		if ttl = resp.fresh(now); ttl <= 0 {
+			return
+		}
+	}
+	store(t.cache, key, req, resp, vary, ttl)
+}
+
+// httpResponse returns a copy of r as a response to req.
+func (r *response) httpResponse(req *http.Request, now time.Time) *http.Response {
+	header := r.header.Clone()
+	header.Set("Age", fmt.Sprint(int(r.currentAge(now).Seconds())))
+	header.Set("X-Cache", "HIT")
+
+	var body []byte
+	if req.Method != http.MethodHead {
+		body = r.body
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
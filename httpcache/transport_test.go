@@ -0,0 +1,191 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// newClient returns a client caching with a Transport, talking to a server running handler.
+func newClient(t *testing.T, handler http.HandlerFunc, opts ...Option) (*http.Client, string) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+	return &http.Client{Transport: NewTransport(c, nil, opts...)}, srv.URL
+}
+
+// get sends a method request for url with header "Name: value" pairs, and returns the response and its body.
+func get(t *testing.T, client *http.Client, method, url string, header ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Add(header[i], header[i+1])
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestTransportFresh(t *testing.T) {
+	var calls atomic.Int64
+	client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	})
+
+	get(t, client, http.MethodGet, url)
+	resp, body := get(t, client, http.MethodGet, url)
+	if resp.Header.Get("X-Cache") != "HIT" || body != "body" || calls.Load() != 1 {
+		t.Errorf("second request: X-Cache %q, body %q, %d requests to the server", resp.Header.Get("X-Cache"), body, calls.Load())
+	}
+
+	// Requests can still skip the cache.
+	get(t, client, http.MethodGet, url, "Cache-Control", "no-cache")
+	if calls.Load() != 2 {
+		t.Error("request with no-cache was served from the cache")
+	}
+}
+
+func TestTransportRevalidates(t *testing.T) {
+	for name, validator := range map[string][2]string{
+		"ETag":          {"ETag", "If-None-Match"},
+		"Last-Modified": {"Last-Modified", "If-Modified-Since"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			value := time.Now().UTC().Format(http.TimeFormat)
+			if name == "ETag" {
+				value = `"v1"`
+			}
+
+			var calls, revalidations atomic.Int64
+			client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Cache-Control", "no-cache")
+				w.Header().Set(validator[0], value)
+				if r.Header.Get(validator[1]) == value {
+					revalidations.Add(1)
+					w.Header().Set("X-Version", "2")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("X-Version", "1")
+				w.Write([]byte("body"))
+			})
+
+			get(t, client, http.MethodGet, url)
+			for range 2 {
+				resp, body := get(t, client, http.MethodGet, url)
+				if resp.StatusCode != http.StatusOK || body != "body" || resp.Header.Get("X-Cache") != "HIT" {
+					t.Errorf("revalidated response: %s, X-Cache %q, body %q", resp.Status, resp.Header.Get("X-Cache"), body)
+				}
+				if got := resp.Header.Get("X-Version"); got != "2" {
+					t.Errorf("revalidated response has X-Version %q, want the 304's 2", got)
+				}
+			}
+			if calls.Load() != 3 || revalidations.Load() != 2 {
+				t.Errorf("%d requests to the server, %d of them revalidations, want 3 and 2", calls.Load(), revalidations.Load())
+			}
+		})
+	}
+}
+
+func TestTransportRevalidationChanged(t *testing.T) {
+	var version atomic.Int64
+	version.Store(1)
+	client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		etag := `"v` + strconv.FormatInt(version.Load(), 10) + `"`
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body " + etag))
+	})
+
+	get(t, client, http.MethodGet, url)
+	version.Store(2)
+	if _, body := get(t, client, http.MethodGet, url); body != `body "v2"` {
+		t.Errorf("after the resource changed, got %q", body)
+	}
+	if resp, body := get(t, client, http.MethodGet, url); body != `body "v2"` || resp.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("after revalidating the new version, got %q, X-Cache %q", body, resp.Header.Get("X-Cache"))
+	}
+}
+
+func TestTransportCallersConditional(t *testing.T) {
+	client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"mine"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	})
+
+	get(t, client, http.MethodGet, url)
+	// The caller's own validator is sent as is, and its 304 returned to the caller.
+	if resp, _ := get(t, client, http.MethodGet, url, "If-None-Match", `"mine"`); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("caller's conditional request returned %s, want 304", resp.Status)
+	}
+}
+
+func TestTransportInvalidation(t *testing.T) {
+	var calls atomic.Int64
+	client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	})
+
+	get(t, client, http.MethodGet, url)
+	get(t, client, http.MethodPost, url)
+	if resp, _ := get(t, client, http.MethodGet, url); resp.Header.Get("X-Cache") == "HIT" {
+		t.Error("a POST didn't drop the cached response")
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d requests to the server, want 3", calls.Load())
+	}
+}
+
+func TestTransportMaxBodySize(t *testing.T) {
+	var calls atomic.Int64
+	body := strings.Repeat("x", 100)
+	client, url := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(body))
+	}, WithMaxBodySize(10))
+
+	for range 2 {
+		if _, got := get(t, client, http.MethodGet, url); got != body {
+			t.Fatalf("response bigger than the limit was cut short: %q", got)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Error("response bigger than the limit was cached")
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// idleSweeps is how many times per idle timeout the cache looks for idle items, so an item
+// is evicted at most a quarter of the timeout late.
+const idleSweeps = 4
+
+// WithIdleTimeout evicts items that haven't been read for timeout, even if the cache is under
+// its size limit, so a long running process doesn't hold on to data nobody reads any more.
+// Only Get and the functions built on it count as reads; Has, Keys and Range don't.
+//
+// Idle items are found by a background sweep, timed with the cache's Clock, that stops when the
+// cache is closed. Like other evictions, they're counted in Stats and sent to subscribers, but
+// not broadcast to peers or passed to WithOnEvict, which is only for items evicted to make room.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.idleTimeout = timeout
+	}
+}
+
+// idle reports whether the entry hasn't been read for timeout.
+func (e *entry) idle(now int64, timeout time.Duration) bool {
+	return now-atomic.LoadInt64(&e.accessedAt) >= int64(timeout)
+}
+
+// startIdleSweeper starts the sweep for WithIdleTimeout. Like startSchedules, it's called once
+// every option has been applied.
+func (c *Cache) startIdleSweeper() {
+	if c.idleTimeout <= 0 || c.deterministic() {
+		return
+	}
+	go c.sweepIdle()
+}
+
+func (c *Cache) sweepIdle() {
+	for {
+		timer := c.newTimer(c.idleTimeout / idleSweeps)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		if c.evictionPaused() {
+			continue
+		}
+		if n := c.evictIdle(); n > 0 {
+			c.logf("evicted %d items idle for %s", n, c.idleTimeout)
+		}
+	}
+}
+
+// evictIdle evicts every idle item and returns how many it evicted. Idle keys are found with
+// only a read lock, then evicted a batch at a time so reads and writes can interleave.
+func (c *Cache) evictIdle() int {
+	c.mu.RLock()
+	now := c.now()
+	var keys []string
+	for key, i := range c.all() {
+		if c.arena.entry(i).idle(now, c.idleTimeout) {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	var evicted int
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), softEvictionBatch)]
+		keys = keys[len(batch):]
+
+		c.mu.Lock()
+		now = c.now()
+		for _, key := range batch {
+			// The item may have been read or replaced since it was found.
+			i, e := c.lookup(key)
+			if e == nil || !e.idle(now, c.idleTimeout) {
+				continue
+			}
+
+			c.remove(key, i)
+			c.evictions++
+			c.idleEvictions++
+			c.notifySubscribers(Event{Type: EventDelete, Key: key, Reason: ReasonIdle})
+			evicted++
+		}
+		c.mu.Unlock()
+	}
+	return evicted
+}
@@ -0,0 +1,86 @@
+// Package singleflight makes sure only one call for a given key is in flight at a time,
+// with every other caller waiting on and sharing its result.
+package singleflight
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the error every caller gets, the caller that ran fn included, when fn panics.
+type PanicError struct {
+	Value any
+	Stack []byte // where fn panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", e.Value, e.Stack)
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	value any
+	err   error
+}
+
+// Group tracks in flight calls. The zero value is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// Do runs fn for key, unless a call for key is already in flight, in which case it waits
+// for that call and returns its result. shared reports whether the result came from another caller.
+func (g *Group) Do(key string, fn func() (any, error)) (value any, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, found := g.calls[key]; found {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// A panic in fn is recovered and returned as a *PanicError, both here and to every
+	// waiter, since fn may be running on a goroutine (see DoChan) where nothing else could
+	// recover it, and waiters mustn't mistake it for a nil result.
+	defer func() {
+		if r := recover(); r != nil {
+			c.value, c.err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+			value, err = c.value, c.err
+		}
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
+
+// Result is the result of a call passed to DoChan.
+type Result struct {
+	Value  any
+	Err    error
+	Shared bool
+}
+
+// DoChan is like Do but returns a channel that receives the result once it's ready,
+// so callers can stop waiting without affecting the call itself.
+func (g *Group) DoChan(key string, fn func() (any, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	go func() {
+		value, err, shared := g.Do(key, fn)
+		ch <- Result{Value: value, Err: err, Shared: shared}
+	}()
+	return ch
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// invalidationQueueSize is how many messages can be waiting to be published before new ones are dropped.
+const invalidationQueueSize = 1024
+
+const invalidationVersion = 1
+
+// Invalidation message types. Sets are sent as deletes since peers only need to drop their stale copy.
+const (
+	invalidateKey byte = iota + 1
+	invalidateAll
+)
+
+// ErrInvalidationEnabled is returned by EnableInvalidation if the cache is already broadcasting invalidations.
+var ErrInvalidationEnabled = errors.New("cache: invalidation already enabled")
+
+// Transport carries invalidation messages between cache instances, e.g. over Redis pub/sub or NATS.
+type Transport interface {
+	// Publish sends msg to every instance subscribed to the transport.
+	Publish(msg []byte) error
+
+	// Subscribe registers handler to be called with every message published to the transport,
+	// which may include this instance's own messages. It returns once the subscription is active.
+	Subscribe(handler func(msg []byte)) error
+
+	// Close stops delivering messages and releases the transport's resources.
+	Close() error
+}
+
+type invalidationBus struct {
+	id        string
+	transport Transport
+	queue     chan []byte
+	done      chan struct{}
+	wg        sync.WaitGroup
+	logf      func(format string, args ...any)
+}
+
+// EnableInvalidation broadcasts a message over t whenever an item is Set, Deleted or Cleared,
+// so other instances sharing t drop their stale copy, and applies the messages they send.
+//
+// Clears triggered by the cache exceeding its size limit are local and aren't broadcast, and
+// neither are values that came from the origin, through the loader, a seed file or Warm, since
+// the other instances' copies of them are just as fresh.
+// Messages are published in the background. If t falls too far behind, messages are dropped and logged.
+func (c *Cache) EnableInvalidation(t Transport) error {
+	var id [8]byte
+	rand.Read(id[:])
+
+	bus := &invalidationBus{
+		id:        hex.EncodeToString(id[:]),
+		transport: t,
+		queue:     make(chan []byte, invalidationQueueSize),
+		done:      make(chan struct{}),
+		logf:      c.logf,
+	}
+
+	c.mu.Lock()
+	if c.bus != nil {
+		c.mu.Unlock()
+		return ErrInvalidationEnabled
+	}
+	c.bus = bus
+	c.mu.Unlock()
+
+	if err := t.Subscribe(c.applyInvalidation); err != nil {
+		c.mu.Lock()
+		c.bus = nil
+		c.mu.Unlock()
+		return fmt.Errorf("subscribing to invalidations: %w", err)
+	}
+
+	bus.wg.Add(1)
+	go bus.run()
+
+	return nil
+}
+
+// DisableInvalidation stops broadcasting and applying invalidations and closes the transport.
+func (c *Cache) DisableInvalidation() error {
+	c.mu.Lock()
+	bus := c.bus
+	c.bus = nil
+	c.mu.Unlock()
+
+	if bus == nil {
+		return nil
+	}
+
+	close(bus.done)
+	bus.wg.Wait()
+
+	return bus.transport.Close()
+}
+
+// applyInvalidation applies a message published by another instance. Subscribers are notified
+// the same as for a local change, but nothing is broadcast back out.
+func (c *Cache) applyInvalidation(msg []byte) {
+	origin, typ, key, err := decodeInvalidation(msg)
+	if err != nil {
+		c.logf("ignoring invalid invalidation message: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.bus == nil || origin == c.bus.id {
+		return
+	}
+
+	switch typ {
+	case invalidateKey:
+		c.forgetError(key)
+		if i, found := c.items[key]; found {
+			c.remove(key, i)
+			c.notifySubscribers(Event{Type: EventDelete, Key: key})
+		}
+	case invalidateAll:
+		c.clear()
+		c.notifySubscribers(Event{Type: EventClear})
+		c.logf("cache cleared by peer. size reset to 0 bytes.")
+	}
+}
+
+// publish queues an invalidation for ev. c.mu must already be locked.
+func (b *invalidationBus) publish(ev Event) {
+	typ := invalidateKey
+	if ev.Type == EventClear {
+		typ = invalidateAll
+	}
+
+	select {
+	case b.queue <- encodeInvalidation(b.id, typ, ev.Key):
+	default:
+		b.logf("invalidation queue full. dropping %s invalidation for key %q", ev.Type, ev.Key)
+	}
+}
+
+func (b *invalidationBus) run() {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case msg := <-b.queue:
+			if err := b.transport.Publish(msg); err != nil {
+				b.logf("error publishing invalidation: %v", err)
+			}
+		}
+	}
+}
+
+// encodeInvalidation builds a message: version, type, uvarint origin length, origin and then the key.
+func encodeInvalidation(origin string, typ byte, key string) []byte {
+	msg := make([]byte, 0, 2+binary.MaxVarintLen64+len(origin)+len(key))
+	msg = append(msg, invalidationVersion, typ)
+	msg = binary.AppendUvarint(msg, uint64(len(origin)))
+	msg = append(msg, origin...)
+	return append(msg, key...)
+}
+
+func decodeInvalidation(msg []byte) (origin string, typ byte, key string, err error) {
+	if len(msg) < 2 {
+		return "", 0, "", errors.New("message too short")
+	}
+	if msg[0] != invalidationVersion {
+		return "", 0, "", fmt.Errorf("unsupported version %d", msg[0])
+	}
+
+	typ = msg[1]
+	if typ != invalidateKey && typ != invalidateAll {
+		return "", 0, "", fmt.Errorf("unknown type %d", typ)
+	}
+
+	n, read := binary.Uvarint(msg[2:])
+	if read <= 0 || uint64(len(msg)-2-read) < n {
+		return "", 0, "", errors.New("invalid origin length")
+	}
+
+	rest := msg[2+read:]
+	return string(rest[:n]), typ, string(rest[n:]), nil
+}
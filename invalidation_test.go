@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// memTransport is an in-process Transport. Every instance created with the same hub gets
+// every message published to it.
+type memTransport struct {
+	hub *memHub
+}
+
+type memHub struct {
+	mu       sync.Mutex
+	handlers []func(msg []byte)
+}
+
+func (t memTransport) Publish(msg []byte) error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	for _, handler := range t.hub.handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (t memTransport) Subscribe(handler func(msg []byte)) error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	t.hub.handlers = append(t.hub.handlers, handler)
+	return nil
+}
+
+func (t memTransport) Close() error { return nil }
+
+func TestInvalidationSkipsLoadedValues(t *testing.T) {
+	var loads atomic.Int64
+	loader := cache.WithLoader(func(ctx context.Context, key string) (any, error) {
+		loads.Add(1)
+		return "value", nil
+	})
+
+	hub := &memHub{}
+	a := cache.New(1<<20, cache.WithLogger(nil), loader)
+	defer a.Close()
+	b := cache.New(1<<20, cache.WithLogger(nil), loader)
+	defer b.Close()
+	for _, c := range []*cache.Cache{a, b} {
+		if err := c.EnableInvalidation(memTransport{hub: hub}); err != nil {
+			t.Fatal(err)
+		}
+		defer c.DisableInvalidation()
+	}
+
+	for range 5 {
+		b.Get("k")
+		a.Get("k")
+	}
+
+	// Invalidations are published in order, so once B has dropped a key A set, it's seen
+	// anything A sent before it.
+	b.Set("set", "value")
+	a.Set("set", "value")
+	for deadline := time.Now().Add(5 * time.Second); b.Has("set"); {
+		if time.Now().After(deadline) {
+			t.Fatal("a Set on one instance never invalidated the other")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if !b.Has("k") {
+		t.Error("loading a key on one instance invalidated the other's copy")
+	}
+	if got := loads.Load(); got != 2 {
+		t.Errorf("5 reads of a key on each instance loaded it %d times, want once each", got)
+	}
+}
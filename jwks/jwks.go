@@ -0,0 +1,402 @@
+// Package jwks fetches JSON Web Key Sets, like an identity provider's
+// /.well-known/jwks.json, parses their public keys and caches them in a cache.Cache, so
+// auth middleware can verify tokens without fetching the keys on every request:
+//
+//	keys := jwks.New(c, "https://auth.example.com/.well-known/jwks.json")
+//	...
+//	key, err := keys.Key(ctx, token.Header["kid"])
+//
+// Key sets are cached for as long as their Cache-Control max-age allows. They're refreshed
+// in the background before they expire, and a stale key set keeps being used while it's
+// refreshed, so a slow or down identity provider doesn't hold up requests.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/internal/singleflight"
+)
+
+// Defaults for the options.
+const (
+	DefaultTTL                  = time.Hour
+	DefaultRefreshAhead         = 0.8
+	DefaultStaleWhileRevalidate = 24 * time.Hour
+	DefaultMinRefreshInterval   = time.Minute
+	DefaultKeyPrefix            = "jwks:"
+)
+
+// maxDocumentSize is the largest key set document that's read.
+const maxDocumentSize = 1 << 20
+
+// refreshTimeout bounds background refreshes, which don't have a caller's ctx.
+const refreshTimeout = 30 * time.Second
+
+// ErrKeyNotFound is returned by Key when the key set doesn't have a key with the kid, even after refreshing it.
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient fetches key sets with client instead of http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cl *Client) {
+		cl.http = client
+	}
+}
+
+// WithTTL sets how long key sets without a Cache-Control max-age are fresh, DefaultTTL by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(cl *Client) {
+		cl.ttl = ttl
+	}
+}
+
+// WithRefreshAhead refreshes a key set in the background once it's this fraction of the way
+// through its TTL, DefaultRefreshAhead by default. 0 turns refresh-ahead off.
+func WithRefreshAhead(fraction float64) Option {
+	return func(cl *Client) {
+		cl.refreshAhead = fraction
+	}
+}
+
+// WithStaleWhileRevalidate keeps using a key set for up to maxStale after it expires, while
+// it's refreshed in the background, DefaultStaleWhileRevalidate by default.
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(cl *Client) {
+		cl.maxStale = maxStale
+	}
+}
+
+// WithMinRefreshInterval sets how soon after a fetch Key can fetch the key set again, for a
+// kid it doesn't have yet, DefaultMinRefreshInterval by default. It stops tokens with made up
+// kids from making the client hammer the identity provider.
+func WithMinRefreshInterval(interval time.Duration) Option {
+	return func(cl *Client) {
+		cl.minRefreshInterval = interval
+	}
+}
+
+// WithKeyPrefix changes the prefix put in front of the cache key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(cl *Client) {
+		cl.prefix = prefix
+	}
+}
+
+// KeySet is a parsed JSON Web Key Set.
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+	size int
+}
+
+// Key returns the public key with kid: an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// KeyIDs returns the kid of every key in the set.
+func (s *KeySet) KeyIDs() []string {
+	ids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		ids = append(ids, kid)
+	}
+	return ids
+}
+
+// entry is a cached key set.
+type entry struct {
+	set        *KeySet
+	fetchedAt  time.Time
+	freshUntil time.Time
+}
+
+// Size implements cache.Sizer.
+func (e *entry) Size() int64 {
+	return int64(e.set.size)
+}
+
+// Client fetches and caches the key set at a URL.
+type Client struct {
+	cache *cache.Cache
+	url   string
+	key   string
+	http  *http.Client
+	group singleflight.Group
+
+	ttl                time.Duration
+	refreshAhead       float64
+	maxStale           time.Duration
+	minRefreshInterval time.Duration
+	prefix             string
+}
+
+// New creates a Client for the key set at url, caching it in c.
+func New(c *cache.Cache, url string, opts ...Option) *Client {
+	cl := &Client{
+		cache:              c,
+		url:                url,
+		http:               http.DefaultClient,
+		ttl:                DefaultTTL,
+		refreshAhead:       DefaultRefreshAhead,
+		maxStale:           DefaultStaleWhileRevalidate,
+		minRefreshInterval: DefaultMinRefreshInterval,
+		prefix:             DefaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	cl.key = cl.prefix + url
+	return cl
+}
+
+// KeySet returns the key set, fetching it if it isn't cached or is too stale to use.
+func (cl *Client) KeySet(ctx context.Context) (*KeySet, error) {
+	e, err := cl.entry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.set, nil
+}
+
+// Key returns the public key with kid. If the key set doesn't have it, the key set is fetched
+// again in case the key's new, unless it was fetched less than the min refresh interval ago.
+func (cl *Client) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	e, err := cl.entry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := e.set.Key(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(e.fetchedAt) >= cl.minRefreshInterval {
+		if e, err = cl.fetchShared(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok := e.set.Key(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+}
+
+func (cl *Client) entry(ctx context.Context) (*entry, error) {
+	e, found := cache.GetAs[*entry](cl.cache, cl.key)
+	if !found {
+		return cl.fetchShared(ctx)
+	}
+
+	now := time.Now()
+	lifetime := e.freshUntil.Sub(e.fetchedAt)
+	if now.After(e.freshUntil) || cl.refreshAhead > 0 && now.Sub(e.fetchedAt) >= time.Duration(float64(lifetime)*cl.refreshAhead) {
+		go cl.refresh()
+	}
+	return e, nil
+}
+
+// refresh fetches the key set in the background, unless a fetch is already running.
+func (cl *Client) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	if _, err := cl.fetchShared(ctx); err != nil {
+		log.Printf("jwks: error refreshing %s: %v", cl.url, err)
+	}
+}
+
+// fetchShared fetches and caches the key set, sharing the fetch with any other callers.
+// Callers whose ctx is done stop waiting, without cancelling the fetch for the others.
+func (cl *Client) fetchShared(ctx context.Context) (*entry, error) {
+	ch := cl.group.DoChan(cl.key, func() (any, error) {
+		return cl.fetch(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-ch:
+		e, _ := res.Value.(*entry)
+		return e, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (cl *Client) fetch(ctx context.Context) (*entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cl.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetching %s: %w", cl.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetching %s: %s", cl.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("jwks: reading %s: %w", cl.url, err)
+	}
+	set, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lifetime := cl.ttl
+	if maxAge, ok := maxAge(resp.Header.Get("Cache-Control")); ok {
+		lifetime = maxAge
+	}
+
+	e := &entry{set: set, fetchedAt: now, freshUntil: now.Add(lifetime)}
+	cl.cache.SetWithTTL(cl.key, e, lifetime+cl.maxStale)
+	return e, nil
+}
+
+// maxAge returns the max-age directive of a Cache-Control header.
+func maxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	return 0, false
+}
+
+// jwk holds the fields of a JSON Web Key that are needed to parse public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Parse parses a JSON Web Key Set document. Keys that aren't for signatures, or of a type
+// that isn't supported, are skipped. RSA, EC (P-256, P-384 and P-521) and Ed25519 keys are supported.
+func Parse(doc []byte) (*KeySet, error) {
+	var raw struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(doc, &raw); err != nil {
+		return nil, fmt.Errorf("jwks: parsing key set: %w", err)
+	}
+
+	set := &KeySet{keys: make(map[string]crypto.PublicKey, len(raw.Keys)), size: len(doc)}
+	for _, k := range raw.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: parsing key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			set.keys[k.Kid] = key
+		}
+	}
+	return set, nil
+}
+
+// publicKey returns the key k holds, or nil if its type isn't supported.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("%s coordinates must be %d bytes", k.Crv, size)
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 key is %d bytes", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
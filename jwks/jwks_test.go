@@ -0,0 +1,255 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// origin is an identity provider serving a key set that can be changed, or made to fail.
+type origin struct {
+	*httptest.Server
+	requests atomic.Int64
+
+	mu           sync.Mutex
+	keys         []map[string]string
+	cacheControl string
+	failing      bool
+}
+
+func newOrigin(t *testing.T, keys ...map[string]string) *origin {
+	t.Helper()
+
+	o := &origin{keys: keys}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.requests.Add(1)
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if o.cacheControl != "" {
+			w.Header().Set("Cache-Control", o.cacheControl)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": o.keys})
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+// set changes what the origin serves.
+func (o *origin) set(fn func(o *origin)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fn(o)
+}
+
+// waitForRequests waits for the origin to have had n requests.
+func (o *origin) waitForRequests(t *testing.T, n int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for o.requests.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("the origin had %d requests, want %d", o.requests.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newClient(t *testing.T, o *origin, opts ...Option) *Client {
+	t.Helper()
+
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+	return New(c, o.URL, opts...)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// rsaKey returns a new RSA key as a JWK.
+func rsaKey(t *testing.T, kid string) (*rsa.PublicKey, map[string]string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &key.PublicKey, map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestParse(t *testing.T) {
+	rsaPub, rsaJWK := rsaKey(t, "rsa")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecJWK := map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecPoint[1:33]), "y": encode(ecPoint[33:])}
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edJWK := map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": encode(edPub)}
+
+	_, encJWK := rsaKey(t, "enc")
+	encJWK["use"] = "enc"
+	unknownJWK := map[string]string{"kty": "oct", "kid": "oct", "k": encode([]byte("secret"))}
+
+	doc, err := json.Marshal(map[string]any{"keys": []map[string]string{rsaJWK, ecJWK, edJWK, encJWK, unknownJWK}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key, ok := set.Key("rsa"); !ok || !rsaPub.Equal(key) {
+		t.Errorf("the RSA key parsed as %v", key)
+	}
+	if key, ok := set.Key("ec"); !ok || !ecKey.PublicKey.Equal(key) {
+		t.Errorf("the EC key parsed as %v", key)
+	}
+	if key, ok := set.Key("ed"); !ok || !edPub.Equal(key) {
+		t.Errorf("the Ed25519 key parsed as %v", key)
+	}
+	if got := len(set.KeyIDs()); got != 3 {
+		t.Errorf("the key set has %d keys, want 3 without the encryption and symmetric keys: %v", got, set.KeyIDs())
+	}
+
+	ecJWK["x"] = encode(ecPoint[1:32])
+	doc, _ = json.Marshal(map[string]any{"keys": []map[string]string{ecJWK}})
+	if _, err := Parse(doc); err == nil {
+		t.Error("an EC key with a short coordinate parsed")
+	}
+}
+
+func TestKeyIsCached(t *testing.T) {
+	pub, jwk := rsaKey(t, "a")
+	o := newOrigin(t, jwk)
+	cl := newClient(t, o)
+
+	for range 3 {
+		key, err := cl.Key(context.Background(), "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pub.Equal(key) {
+			t.Fatalf("Key returned %v, want the origin's key", key)
+		}
+	}
+	if got := o.requests.Load(); got != 1 {
+		t.Errorf("three calls to Key fetched the key set %d times, want 1", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, oldJWK := rsaKey(t, "old")
+	newPub, newJWK := rsaKey(t, "new")
+
+	t.Run("refetches for a new kid", func(t *testing.T) {
+		o := newOrigin(t, oldJWK)
+		cl := newClient(t, o, WithMinRefreshInterval(0))
+
+		if _, err := cl.Key(context.Background(), "old"); err != nil {
+			t.Fatal(err)
+		}
+		o.set(func(o *origin) { o.keys = append(o.keys, newJWK) })
+
+		key, err := cl.Key(context.Background(), "new")
+		if err != nil {
+			t.Fatalf("a key added to the origin wasn't found: %v", err)
+		}
+		if !newPub.Equal(key) {
+			t.Errorf("Key returned %v, want the new key", key)
+		}
+		if got := o.requests.Load(); got != 2 {
+			t.Errorf("the key set was fetched %d times, want 2", got)
+		}
+	})
+
+	t.Run("min refresh interval", func(t *testing.T) {
+		o := newOrigin(t, oldJWK)
+		cl := newClient(t, o)
+
+		if _, err := cl.Key(context.Background(), "old"); err != nil {
+			t.Fatal(err)
+		}
+		o.set(func(o *origin) { o.keys = append(o.keys, newJWK) })
+
+		for range 3 {
+			if _, err := cl.Key(context.Background(), "made-up"); !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("Key for an unknown kid returned %v, want ErrKeyNotFound", err)
+			}
+		}
+		if got := o.requests.Load(); got != 1 {
+			t.Errorf("unknown kids fetched the key set %d times within the min refresh interval, want 1", got)
+		}
+	})
+}
+
+func TestStaleWhileOriginFails(t *testing.T) {
+	// Failed refreshes are logged.
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(w) })
+
+	pub, jwk := rsaKey(t, "a")
+	o := newOrigin(t, jwk)
+	o.set(func(o *origin) { o.cacheControl = "max-age=0" })
+	const maxStale = 200 * time.Millisecond
+	cl := newClient(t, o, WithStaleWhileRevalidate(maxStale))
+
+	start := time.Now()
+	if _, err := cl.Key(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	o.set(func(o *origin) { o.failing = true })
+
+	// The key set is already stale, so this starts a refresh, which fails, and the stale key
+	// set is used in the meantime and afterwards.
+	key, err := cl.Key(context.Background(), "a")
+	if err != nil || !pub.Equal(key) {
+		t.Fatalf("Key returned %v, %v while the origin was failing, want the stale key", key, err)
+	}
+	o.waitForRequests(t, 2)
+	key, err = cl.Key(context.Background(), "a")
+	if time.Since(start) < maxStale && (err != nil || !pub.Equal(key)) {
+		t.Fatalf("Key returned %v, %v after a failed refresh, want the stale key", key, err)
+	}
+
+	time.Sleep(time.Until(start.Add(maxStale + 50*time.Millisecond)))
+	if _, err := cl.Key(context.Background(), "a"); err == nil {
+		t.Error("a key set past its stale-while-revalidate window was used while the origin was failing")
+	}
+}
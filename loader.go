@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/radovskyb/self-clearing-in-memory-cache/internal/singleflight"
+)
+
+// ErrNoLoader is returned by GetOrCompute when it's called without a loader and the cache wasn't created WithLoader.
+var ErrNoLoader = errors.New("cache: no loader")
+
+// ErrNotFound can be returned by loaders when the key doesn't exist in the origin.
+// Get treats it as a plain miss instead of logging it as an error.
+var ErrNotFound = errors.New("cache: not found")
+
+// ErrLoaderPanicked is returned by GetOrCompute when the loader panics. Loads run on a
+// goroutine of their own, shared by every caller waiting on them, so the panic can't be
+// recovered by the caller and is returned to each of them instead, along with the stack.
+var ErrLoaderPanicked = errors.New("cache: loader panicked")
+
+// LoaderFunc loads the value for a key that's missing from the cache.
+type LoaderFunc func(ctx context.Context, key string) (any, error)
+
+// GetOrCompute retrieves an item from the cache, calling compute to load and cache it if it's missing.
+// If compute is nil, the loader from WithLoader is used.
+//
+// Errors from compute are returned as is and nothing is cached. If compute panics, the error
+// wraps ErrLoaderPanicked.
+func (c *Cache) GetOrCompute(ctx context.Context, key string, compute LoaderFunc) (any, error) {
+	if compute == nil {
+		compute = c.loader
+	}
+
+	value, found, refresh := c.get(key, compute != nil)
+	if refresh {
+		c.refresh(key, compute)
+	}
+	if found {
+		return value, nil
+	}
+
+	if compute == nil {
+		return nil, ErrNoLoader
+	}
+
+	return c.load(ctx, key, compute, false)
+}
+
+// load calls compute and caches its result.
+//
+// Concurrent loads of the same key share a single call to compute, so a burst of misses
+// (say, right after the cache clears) only hits the origin once per key. Every caller
+// waiting on that call gets its result, including an error caused by the first caller's ctx.
+// Callers whose own ctx is done stop waiting and get ctx.Err() instead.
+//
+// If reload is false and the key is already cached by the time the call starts, the
+// cached value is returned instead.
+func (c *Cache) load(ctx context.Context, key string, compute LoaderFunc, reload bool) (any, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	ch := c.flight.DoChan(key, func() (any, error) {
+		// Another load may have finished between our miss and getting here.
+		if value, found := c.peek(key); found && !reload {
+			return value, nil
+		}
+		if err := c.cachedError(key); err != nil && !reload {
+			return nil, err
+		}
+
+		start := c.now()
+		value, err := c.callLoader(ctx, key, compute)
+		if err != nil {
+			c.cacheError(key, err)
+			return nil, err
+		}
+
+		// Loaded values came from the origin, so they aren't written back to the store.
+		if !c.admitted(key) {
+			return value, nil
+		}
+		if c.setLocal(key, value, c.expiresAt(time.Duration(c.defaultTTL.Load())), true) == nil && c.earlyExpiration > 0 {
+			c.recordLoadTime(key, c.now()-start)
+		}
+		return value, nil
+	})
+
+	select {
+	case res := <-ch:
+		if p, ok := res.Err.(*singleflight.PanicError); ok {
+			return nil, fmt.Errorf("%w: %w", ErrLoaderPanicked, p)
+		}
+		return res.Value, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// recordLoadTime records how long key took to load, for WithEarlyExpiration.
+func (c *Cache) recordLoadTime(key string, took int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, e := c.lookup(key); e != nil {
+		// Never 0, so a load that took no time on a coarse clock still counts as loaded.
+		e.loadTime = max(took, 1)
+	}
+}
+
+// RetryPolicy configures how failed loads are retried, see WithLoadRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the most times the loader is called for a single load, including the first.
+	MaxAttempts int
+
+	// Backoff is how long to wait before the first retry. It doubles after each retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a load that failed with err should be retried.
+	// If nil, every error except ErrNotFound and context.Canceled is retried.
+	Retryable func(err error) bool
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, context.Canceled)
+}
+
+// callLoader calls compute, applying the timeout from WithLoadTimeout to each attempt and
+// retrying failures according to WithLoadRetry. It gives up early if ctx is done.
+func (c *Cache) callLoader(ctx context.Context, key string, compute LoaderFunc) (any, error) {
+	backoff := c.retry.Backoff
+
+	for attempt := 1; ; attempt++ {
+		value, err := c.callLoaderOnce(ctx, key, compute)
+		if err == nil || attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !c.retry.retryable(err) {
+			return value, err
+		}
+
+		c.logf("error loading %q (attempt %d of %d), retrying in %s: %v", key, attempt, c.retry.MaxAttempts, backoff, err)
+
+		timer := c.newTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		backoff *= 2
+		if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+func (c *Cache) callLoaderOnce(ctx context.Context, key string, compute LoaderFunc) (any, error) {
+	if c.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.loadTimeout)
+		defer cancel()
+	}
+	return compute(ctx, key)
+}
+
+// getOrLoad is Get's miss handling when the cache has a loader. Errors are logged, since Get can't return them.
+func (c *Cache) getOrLoad(key string) (any, bool) {
+	value, err := c.load(context.Background(), key, c.loader, false)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			c.logf("error loading %q: %v", key, err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+// refresh reloads key with compute in the background. Only one refresh per key runs at a time,
+// any others requested while it's running are dropped.
+func (c *Cache) refresh(key string, compute LoaderFunc) {
+	if _, running := c.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer c.refreshing.Delete(key)
+
+		if _, err := c.load(context.Background(), key, compute, true); err != nil {
+			c.logf("error refreshing %q: %v", key, err)
+		}
+	}()
+}
+
+// negativeEntry is a cached loader error.
+type negativeEntry struct {
+	err       error
+	expiresAt int64
+}
+
+// cachedError returns the loader error cached for key by WithNegativeCaching, if there is one.
+func (c *Cache) cachedError(key string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if n, found := c.negative[key]; found && c.now() < n.expiresAt {
+		return n.err
+	}
+	return nil
+}
+
+// cacheError caches a loader error if the cache was created WithNegativeCaching.
+//
+// Context errors aren't cached since they're down to the caller that triggered the load,
+// not the key itself.
+func (c *Cache) cacheError(key string, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.negativeTTL <= 0 {
+		return
+	}
+
+	if c.negative == nil {
+		c.negative = make(map[string]*negativeEntry)
+	}
+
+	// The key counts towards the cache size so a flood of lookups for missing keys
+	// still triggers a clear, rather than growing the negative cache forever.
+	if _, found := c.negative[key]; !found {
+		c.totalCacheSize += int64(len(key))
+	}
+	c.negative[key] = &negativeEntry{err: err, expiresAt: c.now() + int64(c.negativeTTL)}
+
+	c.checkCurrentSize()
+}
+
+// forgetError drops any cached loader error for key. c.mu must already be locked.
+func (c *Cache) forgetError(key string) {
+	if _, found := c.negative[key]; found {
+		c.totalCacheSize -= int64(len(key))
+		delete(c.negative, key)
+	}
+}
+
+// forgetErrors drops every cached loader error for keys starting with prefix. c.mu must already be locked.
+func (c *Cache) forgetErrors(prefix string) {
+	for key := range c.negative {
+		if strings.HasPrefix(key, prefix) {
+			c.forgetError(key)
+		}
+	}
+}
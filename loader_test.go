@@ -0,0 +1,69 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestLoaderPanic(t *testing.T) {
+	var logs bytes.Buffer
+	release := make(chan struct{})
+	c := cache.New(1<<20, cache.WithLogger(log.New(&logs, "", 0)),
+		cache.WithLoader(func(ctx context.Context, key string) (any, error) {
+			<-release
+			panic("boom")
+		}))
+	defer c.Close()
+
+	// Every caller waiting on the load gets the panic as an error, rather than the process
+	// crashing on the goroutine the load ran on.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = c.GetOrCompute(context.Background(), "key", nil)
+		})
+	}
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		if !errors.Is(err, cache.ErrLoaderPanicked) || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("GetOrCompute with a panicking loader returned %v, want ErrLoaderPanicked", err)
+		}
+	}
+
+	if value, found := c.Get("key"); found {
+		t.Errorf("Get with a panicking loader found %v", value)
+	}
+	if !strings.Contains(logs.String(), "boom") {
+		t.Errorf("Get didn't log the panic, logged %q", logs.String())
+	}
+
+	value, err := c.GetOrCompute(context.Background(), "key", func(ctx context.Context, key string) (any, error) {
+		return "value", nil
+	})
+	if err != nil || value != "value" {
+		t.Errorf("loading after a panic returned %v, %v", value, err)
+	}
+}
+
+func TestBatchLoaderPanic(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil),
+		cache.WithBatchLoader(func(ctx context.Context, keys []string) (map[string]any, error) {
+			panic("boom")
+		}, time.Millisecond, 0))
+	defer c.Close()
+
+	values, err := c.GetMany(context.Background(), []string{"a", "b"})
+	if len(values) != 0 || !errors.Is(err, cache.ErrLoaderPanicked) {
+		t.Errorf("GetMany with a panicking batch loader returned %v, %v, want ErrLoaderPanicked", values, err)
+	}
+}
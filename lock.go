@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// contendedMutex is a sync.RWMutex that counts how often, and for how long, callers had to
+// wait for it. The cache has a single lock rather than shards, so this is where contention
+// shows up: a high LockWaitTime means readers and writers are queueing behind each other, and
+// splitting the data across several caches, e.g. one per key group, will help more than
+// tuning. Uncontended locks cost one extra compare-and-swap, and never read the clock.
+type contendedMutex struct {
+	sync.RWMutex
+	waits  atomic.Int64
+	waited atomic.Int64 // nanoseconds
+}
+
+func (m *contendedMutex) Lock() {
+	if m.TryLock() {
+		return
+	}
+	start := time.Now()
+	m.RWMutex.Lock()
+	m.record(start)
+}
+
+func (m *contendedMutex) RLock() {
+	if m.TryRLock() {
+		return
+	}
+	start := time.Now()
+	m.RWMutex.RLock()
+	m.record(start)
+}
+
+// record counts a wait that started at start. It uses the wall clock rather than c.now, since
+// a test clock doesn't move while goroutines wait for each other.
+func (m *contendedMutex) record(start time.Time) {
+	m.waits.Add(1)
+	m.waited.Add(int64(time.Since(start)))
+}
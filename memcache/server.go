@@ -0,0 +1,399 @@
+// Package memcache serves a cache.Cache over the memcached text protocol, so existing
+// memcached clients can use it without changes.
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+const (
+	// maxKeyLen is memcached's own key length limit.
+	maxKeyLen = 250
+
+	// maxValueLen matches memcached's default item size limit of 1mb.
+	maxValueLen = 1 << 20
+
+	// Expiration times larger than this are unix timestamps rather than relative seconds.
+	relativeExpirationLimit = 60 * 60 * 24 * 30
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close is called.
+var ErrServerClosed = errors.New("memcache: server closed")
+
+// Item is how values set over the memcached protocol are stored in the cache,
+// so the client's flags survive the round trip.
+type Item struct {
+	Flags uint32
+	Value []byte
+}
+
+// Size implements cache.Sizer.
+func (i *Item) Size() int64 {
+	return int64(len(i.Value)) + 4
+}
+
+// Server serves a cache over the memcached text protocol.
+//
+// It supports get, set, delete, flush_all, stats, version and quit.
+type Server struct {
+	cache   *cache.Cache
+	started time.Time
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// NewServer creates a new Server backed by c.
+func NewServer(c *cache.Cache) *Server {
+	return &Server{
+		cache:     c,
+		started:   time.Now(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called. l is closed when Serve returns.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops all listeners and closes every open connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	var err error
+	for l := range s.listeners {
+		err = errors.Join(err, l.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("memcache: reading from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		if name == "quit" {
+			return
+		}
+
+		if err := s.dispatch(r, w, name, fields[1:]); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("memcache: serving %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch runs a single command. Errors returned are I/O errors that should close
+// the connection, command failures are reported to the client.
+func (s *Server) dispatch(r *bufio.Reader, w *bufio.Writer, name string, args []string) error {
+	switch name {
+	case "get":
+		return s.get(w, args)
+	case "set":
+		return s.set(r, w, args)
+	case "delete":
+		return s.delete(w, args)
+	case "flush_all":
+		return s.flushAll(w, args)
+	case "stats":
+		return s.stats(w)
+	case "version":
+		_, err := w.WriteString("VERSION self-clearing-in-memory-cache\r\n")
+		return err
+	}
+
+	_, err := w.WriteString("ERROR\r\n")
+	return err
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string) error {
+	if len(keys) == 0 {
+		return clientError(w, "bad command line format")
+	}
+
+	for _, key := range keys {
+		value, found := s.cache.Get(key)
+		if !found {
+			continue
+		}
+
+		item := toItem(value)
+		if _, err := fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, item.Flags, len(item.Value)); err != nil {
+			return err
+		}
+		if _, err := w.Write(item.Value); err != nil {
+			return err
+		}
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.WriteString("END\r\n")
+	return err
+}
+
+// set handles "set <key> <flags> <exptime> <bytes> [noreply]" followed by the data block.
+func (s *Server) set(r *bufio.Reader, w *bufio.Writer, args []string) error {
+	if len(args) != 4 && len(args) != 5 {
+		return clientError(w, "bad command line format")
+	}
+
+	key := args[0]
+	flags, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(args[2], 10, 64)
+	n, nErr := strconv.Atoi(args[3])
+	noreply := len(args) == 5 && args[4] == "noreply"
+
+	if !validKey(key) || flagsErr != nil || exptimeErr != nil || nErr != nil || n < 0 {
+		return clientError(w, "bad command line format")
+	}
+
+	// Without a valid length we can't tell where the data block ends, so the connection
+	// is closed the same way memcached does.
+	if n > maxValueLen {
+		clientError(w, "object too large for cache")
+		w.Flush()
+		return fmt.Errorf("value of %d bytes exceeds limit", n)
+	}
+
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	if data[n] != '\r' || data[n+1] != '\n' {
+		return clientError(w, "bad data chunk")
+	}
+
+	item := &Item{Flags: uint32(flags), Value: data[:n]}
+
+	if ttl := expirationTTL(exptime); ttl < 0 {
+		// Already expired, which memcached treats as an immediate delete.
+		s.cache.Delete(key)
+	} else {
+		s.cache.SetWithTTL(key, item, ttl)
+	}
+
+	if noreply {
+		return nil
+	}
+	_, err := w.WriteString("STORED\r\n")
+	return err
+}
+
+func (s *Server) delete(w *bufio.Writer, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return clientError(w, "bad command line format")
+	}
+
+	key := args[0]
+	noreply := len(args) == 2 && args[1] == "noreply"
+
+	found := s.cache.Has(key)
+	if found {
+		s.cache.Delete(key)
+	}
+
+	if noreply {
+		return nil
+	}
+	if found {
+		_, err := w.WriteString("DELETED\r\n")
+		return err
+	}
+	_, err := w.WriteString("NOT_FOUND\r\n")
+	return err
+}
+
+// flushAll clears the cache. A delay argument is accepted for compatibility,
+// but the cache is always cleared immediately.
+func (s *Server) flushAll(w *bufio.Writer, args []string) error {
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+
+	s.cache.Clear()
+
+	if noreply {
+		return nil
+	}
+	_, err := w.WriteString("OK\r\n")
+	return err
+}
+
+func (s *Server) stats(w *bufio.Writer) error {
+	stats := s.cache.Stats()
+
+	for _, stat := range []struct {
+		name  string
+		value any
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(time.Since(s.started).Seconds())},
+		{"time", time.Now().Unix()},
+		{"curr_items", stats.Items},
+		{"bytes", stats.Size},
+		{"limit_maxbytes", stats.MaxSize},
+		{"get_hits", stats.Hits},
+		{"get_misses", stats.Misses},
+		{"cache_clears", stats.Clears},
+	} {
+		if _, err := fmt.Fprintf(w, "STAT %s %v\r\n", stat.name, stat.value); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.WriteString("END\r\n")
+	return err
+}
+
+func clientError(w *bufio.Writer, msg string) error {
+	_, err := fmt.Fprintf(w, "CLIENT_ERROR %s\r\n", msg)
+	return err
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// expirationTTL converts a memcached exptime into a ttl. 0 means never expire,
+// values up to 30 days are relative seconds and anything larger is a unix timestamp.
+// A negative ttl means the item has already expired.
+func expirationTTL(exptime int64) time.Duration {
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime < 0:
+		return -1
+	case exptime <= relativeExpirationLimit:
+		return time.Duration(exptime) * time.Second
+	}
+
+	ttl := time.Until(time.Unix(exptime, 0))
+	if ttl <= 0 {
+		return -1
+	}
+	return ttl
+}
+
+// toItem converts a cached value into an Item. Values stored directly on the cache
+// rather than through the protocol are returned with 0 flags.
+func toItem(value any) *Item {
+	switch v := value.(type) {
+	case *Item:
+		return v
+	case []byte:
+		return &Item{Value: v}
+	case string:
+		return &Item{Value: []byte(v)}
+	}
+	return &Item{Value: []byte(fmt.Sprint(value))}
+}
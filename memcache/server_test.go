@@ -0,0 +1,153 @@
+package memcache
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// dialServer serves c on a local port until the test ends and returns a connection to it.
+func dialServer(t *testing.T, c *cache.Cache) net.Conn {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return conn
+}
+
+func TestProtocol(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	conn := dialServer(t, c)
+	r := bufio.NewReader(conn)
+
+	c.Set("direct", "value")
+
+	for _, tt := range []struct{ send, want string }{
+		{"set a 5 0 3\r\nabc\r\n", "STORED\r\n"},
+		{"get a missing\r\n", "VALUE a 5 3\r\nabc\r\nEND\r\n"},
+		{"get direct\r\n", "VALUE direct 0 5\r\nvalue\r\nEND\r\n"},
+		{"set b 0 0 1 noreply\r\nx\r\nget b\r\n", "VALUE b 0 1\r\nx\r\nEND\r\n"},
+		{"delete a\r\n", "DELETED\r\n"},
+		{"delete a\r\n", "NOT_FOUND\r\n"},
+		{"set c 0 -1 1\r\nx\r\nget c\r\n", "STORED\r\nEND\r\n"},
+		{"set d 0 " + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + " 1\r\nx\r\nget d\r\n", "STORED\r\nVALUE d 0 1\r\nx\r\nEND\r\n"},
+		{"set e 0 0\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"set e 0 0 x\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"get\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"set e 0 0 3\r\nabcde\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"get e\r\n", "END\r\n"},
+		{"bogus\r\n", "ERROR\r\n"},
+		{"version\r\n", "VERSION self-clearing-in-memory-cache\r\n"},
+		{"get d\r\nflush_all\r\nget d\r\n", "VALUE d 0 1\r\nx\r\nEND\r\nOK\r\nEND\r\n"},
+	} {
+		if _, err := io.WriteString(conn, tt.send); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(tt.want))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("sending %q: %v", tt.send, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("sending %q got %q, want %q", tt.send, got, tt.want)
+		}
+	}
+}
+
+func TestProtocolValueTooLarge(t *testing.T) {
+	c := cache.New(1<<30, cache.WithLogger(nil))
+	defer c.Close()
+	conn := dialServer(t, c)
+
+	io.WriteString(conn, "set big 0 0 "+strconv.Itoa(maxValueLen+1)+"\r\n")
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "CLIENT_ERROR object too large for cache\r\n"; string(got) != want {
+		t.Errorf("got %q, want %q then the connection closed", got, want)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	c.Set("key", "value")
+	c.Get("key")
+	conn := dialServer(t, c)
+
+	io.WriteString(conn, "stats\r\n")
+	stats := make(map[string]string)
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "END\r\n" {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			t.Fatalf("invalid stats line %q", line)
+		}
+		stats[fields[1]] = fields[2]
+	}
+
+	if stats["curr_items"] != "1" || stats["get_hits"] != "1" || stats["limit_maxbytes"] != "1048576" {
+		t.Errorf("stats = %v", stats)
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"key":                    true,
+		"ns:key/1":               true,
+		strings.Repeat("k", 250): true,
+		"":                       false,
+		strings.Repeat("k", 251): false,
+		"two words":              false,
+		"tab\tkey":               false,
+		"del\x7f":                false,
+	} {
+		if got := validKey(key); got != want {
+			t.Errorf("validKey(%.20q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestExpirationTTL(t *testing.T) {
+	if ttl := expirationTTL(0); ttl != 0 {
+		t.Errorf("exptime 0 gave a TTL of %s, want none", ttl)
+	}
+	if ttl := expirationTTL(-1); ttl >= 0 {
+		t.Errorf("exptime -1 gave a TTL of %s, want already expired", ttl)
+	}
+	if ttl := expirationTTL(relativeExpirationLimit); ttl != 30*24*time.Hour {
+		t.Errorf("exptime of 30 days gave a TTL of %s", ttl)
+	}
+	if ttl := expirationTTL(time.Now().Add(-time.Minute).Unix()); ttl >= 0 {
+		t.Errorf("timestamp in the past gave a TTL of %s, want already expired", ttl)
+	}
+	if ttl := expirationTTL(time.Now().Add(time.Hour).Unix()); ttl < 59*time.Minute || ttl > time.Hour {
+		t.Errorf("timestamp an hour from now gave a TTL of %s", ttl)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// memoryLimitCheckInterval is how often NewWithMemoryFraction re-reads the memory limit.
+const memoryLimitCheckInterval = 30 * time.Second
+
+// NewWithMemoryFraction creates a cache whose maxCacheSize is fraction (e.g. 0.25) of the
+// memory available to the process: its cgroup memory limit when running in a container,
+// otherwise the machine's total memory.
+//
+// The limit is re-read every 30 seconds and the cache resized with SetMaxCacheSize if it's
+// changed, so the cache follows a container being resized. Reading the limit is only
+// supported on Linux.
+func NewWithMemoryFraction(fraction float64, opts ...Option) (*Cache, error) {
+	if fraction <= 0 || fraction > 1 {
+		return nil, fmt.Errorf("cache: memory fraction must be in (0, 1], got %v", fraction)
+	}
+
+	limit, err := memoryLimit()
+	if err != nil {
+		return nil, fmt.Errorf("cache: reading memory limit: %w", err)
+	}
+
+	c := New(int64(float64(limit)*fraction), opts...)
+	go c.followMemoryLimit(fraction, limit)
+
+	return c, nil
+}
+
+// followMemoryLimit resizes the cache whenever the memory limit changes from last.
+func (c *Cache) followMemoryLimit(fraction float64, last int64) {
+	ticker := time.NewTicker(memoryLimitCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		limit, err := memoryLimit()
+		if err != nil {
+			c.logf("error reading memory limit: %v", err)
+			continue
+		}
+		if limit == last {
+			continue
+		}
+
+		c.logf("memory limit changed from %d to %d bytes", last, limit)
+		c.SetMaxCacheSize(int64(float64(limit) * fraction))
+		last = limit
+	}
+}
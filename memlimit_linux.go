@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// memoryLimit returns the smaller of the process's cgroup memory limit, if it has one,
+// and the machine's total memory.
+func memoryLimit() (int64, error) {
+	total, err := totalMemory()
+	if err != nil {
+		return 0, err
+	}
+
+	if limit, ok := cgroupMemoryLimit(); ok && limit < total {
+		return limit, nil
+	}
+	return total, nil
+}
+
+// totalMemory reads MemTotal from /proc/meminfo.
+func totalMemory() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		value, found := strings.CutPrefix(s.Text(), "MemTotal:")
+		if !found {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no MemTotal in /proc/meminfo")
+}
+
+// cgroupMemoryLimit returns the tightest memory limit on the process's cgroup or any of its
+// parents, for both cgroup v2 and v1. ok is false if there's no limit or it can't be read.
+func cgroupMemoryLimit() (limit int64, ok bool) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		// Each line is hierarchy-ID:controllers:path. The v2 hierarchy has an ID of 0 and no controllers.
+		parts := strings.SplitN(string(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		var mount, file string
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			mount, file = "/sys/fs/cgroup", "memory.max"
+		case hasController(parts[1], "memory"):
+			mount, file = "/sys/fs/cgroup/memory", "memory.limit_in_bytes"
+		default:
+			continue
+		}
+
+		if l, found := tightestLimit(mount, parts[2], file); found && (!ok || l < limit) {
+			limit, ok = l, true
+		}
+	}
+	return limit, ok
+}
+
+func hasController(controllers, name string) bool {
+	for _, c := range strings.Split(controllers, ",") {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tightestLimit walks from the cgroup at path up to the root of mount, returning the smallest
+// limit in file. Inside a container the cgroup path often isn't visible, which leaves the root.
+func tightestLimit(mount, path, file string) (limit int64, found bool) {
+	for dir := filepath.Join(mount, path); ; dir = filepath.Dir(dir) {
+		if b, err := os.ReadFile(filepath.Join(dir, file)); err == nil {
+			// v2 uses "max" for no limit, v1 a huge number which the MemTotal check takes care of.
+			if l, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err == nil && (!found || l < limit) {
+				limit, found = l, true
+			}
+		}
+		if len(dir) <= len(mount) {
+			return limit, found
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cache
+
+import "errors"
+
+func memoryLimit() (int64, error) {
+	return 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Metadata describes a cached item without its value.
+type Metadata struct {
+	Key string `json:"key"`
+
+	// Size is the estimated size of the value, not including the key.
+	Size int64 `json:"size"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// ExpiresAt is zero if the item never expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// Hits is how many times the item has been read since it was set.
+	Hits int64 `json:"hits"`
+}
+
+// Snapshot is a point in time copy of the cache's stats and the metadata of every item in it.
+type Snapshot struct {
+	TakenAt time.Time  `json:"taken_at"`
+	Stats   Stats      `json:"stats"`
+	Items   []Metadata `json:"items"`
+}
+
+// Snapshot returns the metadata of every unexpired item in the cache, in no particular order,
+// along with the cache's stats. Values aren't included, so it's safe to pull from production
+// for offline analysis of what's taking up space.
+func (c *Cache) Snapshot() *Snapshot {
+	stats := c.Stats()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Unix(0, c.now())
+	s := &Snapshot{
+		TakenAt: now,
+		Stats:   stats,
+		Items:   make([]Metadata, 0, len(c.items)),
+	}
+
+	for key, i := range c.all() {
+		if e := c.arena.entry(i); !e.expired(now.UnixNano()) {
+			s.Items = append(s.Items, e.metadata(key))
+		}
+	}
+	return s
+}
+
+// WriteSnapshot writes Snapshot to w as JSON.
+func (c *Cache) WriteSnapshot(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c.Snapshot())
+}
+
+func (e *entry) metadata(key string) Metadata {
+	m := Metadata{
+		Key:       key,
+		Size:      e.size,
+		CreatedAt: time.Unix(0, e.createdAt),
+		Hits:      atomic.LoadInt64(&e.hits),
+	}
+	if e.expiresAt > 0 {
+		m.ExpiresAt = time.Unix(0, e.expiresAt)
+	}
+	return m
+}
+
+// EntryInfo is everything the cache knows about a single item, besides its value, see
+// Cache.Metadata.
+type EntryInfo struct {
+	Metadata
+
+	// AccessedAt is when the item was last read. It's only tracked if the cache was created
+	// WithIdleTimeout or WithColdCompression, and is zero otherwise.
+	AccessedAt time.Time `json:"accessed_at,omitzero"`
+
+	// TTL is how long the item has left before it expires, or 0 if it never expires.
+	TTL time.Duration `json:"ttl,omitempty"`
+
+	// Groups are the names of the registered key groups the key belongs to.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Metadata returns everything the cache knows about key's item besides its value, for debugging
+// and for eviction decisions made outside the cache. Like Has, it doesn't count towards hit or
+// miss stats, and expired items are reported as missing.
+func (c *Cache) Metadata(key string) (EntryInfo, bool) {
+	c.mu.RLock()
+	now := c.now()
+	_, e := c.lookup(key)
+	if e == nil || e.expired(now) {
+		expired := e != nil && e.reclaimable(now, c.maxStale)
+		c.mu.RUnlock()
+
+		if expired {
+			c.reclaim(key)
+		}
+		return EntryInfo{}, false
+	}
+	defer c.mu.RUnlock()
+
+	info := EntryInfo{Metadata: e.metadata(key)}
+	if c.tracksAccess() {
+		info.AccessedAt = time.Unix(0, atomic.LoadInt64(&e.accessedAt))
+	}
+	if e.expiresAt > 0 {
+		info.TTL = time.Duration(e.expiresAt - now)
+	}
+	for _, g := range c.groups {
+		if g.match(key) {
+			info.Groups = append(info.Groups, g.name)
+		}
+	}
+	return info, true
+}
@@ -0,0 +1,86 @@
+// Package natstransport carries cache invalidations over NATS.
+//
+//	t, err := natstransport.Dial(nats.DefaultURL, "cache.invalidations")
+//	...
+//	err = c.EnableInvalidation(t)
+package natstransport
+
+import (
+	"errors"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Transport is a cache.Transport that publishes and subscribes to a single NATS subject.
+//
+// Every instance sharing the subject receives every message, so don't use a queue group.
+// Use a different subject per cache to keep their invalidations separate.
+type Transport struct {
+	conn     *nats.Conn
+	subject  string
+	ownsConn bool
+	sub      *nats.Subscription
+}
+
+// New creates a Transport using an existing connection. Close leaves the connection open.
+func New(conn *nats.Conn, subject string) *Transport {
+	return &Transport{conn: conn, subject: subject}
+}
+
+// Dial connects to the NATS server at url and creates a Transport for subject.
+// Close also closes the connection.
+func Dial(url, subject string, opts ...nats.Option) (*Transport, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t := New(conn, subject)
+	t.ownsConn = true
+	return t, nil
+}
+
+// Publish implements cache.Transport.
+func (t *Transport) Publish(msg []byte) error {
+	return t.conn.Publish(t.subject, msg)
+}
+
+// Subscribe implements cache.Transport. It returns once the server has registered the subscription.
+func (t *Transport) Subscribe(handler func(msg []byte)) error {
+	if t.sub != nil {
+		return errors.New("natstransport: already subscribed")
+	}
+
+	sub, err := t.conn.Subscribe(t.subject, func(m *nats.Msg) {
+		handler(m.Data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Flush round trips to the server, so the subscription is active before we return.
+	if err := t.conn.Flush(); err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+
+	t.sub = sub
+	return nil
+}
+
+// Close implements cache.Transport.
+func (t *Transport) Close() error {
+	var err error
+	if t.sub != nil {
+		err = t.sub.Unsubscribe()
+	}
+	if t.ownsConn {
+		t.conn.Close()
+	}
+
+	// Unsubscribing fails once the connection is closed, which isn't worth reporting.
+	if errors.Is(err, nats.ErrConnectionClosed) {
+		return nil
+	}
+	return err
+}
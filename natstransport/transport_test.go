@@ -0,0 +1,241 @@
+package natstransport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// server speaks just enough of the NATS client protocol to connect, subscribe and publish
+// on plain subjects, without wildcards or queue groups.
+type server struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	subs map[string]map[*client]string // subject to subscribers and their sids
+	wg   sync.WaitGroup
+}
+
+type client struct {
+	mu   sync.Mutex // serializes writes
+	conn net.Conn
+}
+
+func (c *client) write(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.conn, format, args...)
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{ln: ln, subs: make(map[string]map[*client]string)}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *server) url() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+func (s *server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(&client{conn: conn})
+	}
+}
+
+func (s *server) handle(c *client) {
+	defer func() {
+		s.mu.Lock()
+		for _, subs := range s.subs {
+			delete(subs, c)
+		}
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	c.write(`INFO {"server_id":"test","version":"2.10.0","proto":1,"max_payload":1048576,"headers":false}` + "\r\n")
+
+	sids := make(map[string]string) // sid to subject
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			c.write("PONG\r\n")
+		case "SUB":
+			subject, sid := args[1], args[len(args)-1]
+			sids[sid] = subject
+			s.mu.Lock()
+			if s.subs[subject] == nil {
+				s.subs[subject] = make(map[*client]string)
+			}
+			s.subs[subject][c] = sid
+			s.mu.Unlock()
+		case "UNSUB":
+			s.mu.Lock()
+			delete(s.subs[sids[args[1]]], c)
+			s.mu.Unlock()
+		case "PUB":
+			n, err := strconv.Atoi(args[len(args)-1])
+			if err != nil {
+				return
+			}
+			payload := make([]byte, n+2) // with the trailing \r\n
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			for sub, sid := range s.subs[args[1]] {
+				sub.write("MSG %s %s %d\r\n%s", args[1], sid, n, payload)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	s := newServer(t)
+
+	received := make(chan string, 10)
+	sub, err := Dial(s.url(), "invalidations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := sub.Subscribe(func(msg []byte) { received <- string(msg) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Subscribe(func(msg []byte) {}); err == nil {
+		t.Error("subscribing twice succeeded")
+	}
+
+	other, err := Dial(s.url(), "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	pub, err := Dial(s.url(), "invalidations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	if err := other.Publish([]byte("other subject")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Errorf("received %q, want hello", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscriber didn't receive the message")
+	}
+}
+
+func TestCloseOwnership(t *testing.T) {
+	s := newServer(t)
+
+	conn, err := nats.Connect(s.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	shared := New(conn, "invalidations")
+	if err := shared.Subscribe(func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if conn.IsClosed() {
+		t.Error("closing a Transport from New closed the caller's connection")
+	}
+
+	owned, err := Dial(s.url(), "invalidations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owned.Subscribe(func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := owned.Close(); err != nil {
+		t.Errorf("closing a Transport from Dial returned %v", err)
+	}
+	if !owned.conn.IsClosed() {
+		t.Error("closing a Transport from Dial left its connection open")
+	}
+}
+
+func TestInvalidatesOtherCaches(t *testing.T) {
+	s := newServer(t)
+
+	caches := make([]*cache.Cache, 2)
+	for i := range caches {
+		caches[i] = cache.New(1<<20, cache.WithLogger(nil))
+		defer caches[i].Close()
+	}
+	// Set before invalidation is enabled, so its own invalidation can't arrive after the Set below.
+	caches[1].Set("key", "stale")
+
+	for _, c := range caches {
+		tr, err := Dial(s.url(), "invalidations")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.EnableInvalidation(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	caches[0].Set("key", "fresh")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for caches[1].Has("key") {
+		if time.Now().After(deadline) {
+			t.Fatal("a Set on one cache didn't invalidate the other's copy")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if value, _ := caches[0].Get("key"); value != "fresh" {
+		t.Errorf("the cache that set the key has %v, want fresh", value)
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"io"
+	"log"
+	"time"
+)
+
+// Option configures a Cache created with New.
+type Option func(*Cache)
+
+// WithLogger sends everything the cache logs to logger instead of the standard logger.
+// A nil logger turns logging off.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Cache) {
+		if logger == nil {
+			logger = log.New(io.Discard, "", 0)
+		}
+		c.logger = logger
+	}
+}
+
+// WithLoader makes Get load missing keys with loader and cache the result, so callers
+// don't each have to handle misses themselves.
+func WithLoader(loader LoaderFunc) Option {
+	return func(c *Cache) {
+		c.loader = loader
+	}
+}
+
+// WithDefaultTTL makes items added with Set, or by a loader, expire after ttl.
+// SetWithTTL still uses the ttl it's given.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultTTL.Store(int64(ttl))
+	}
+}
+
+// WithStaleWhileRevalidate keeps returning items for up to maxStale after they expire,
+// while the loader refreshes them in the background, so callers don't wait on the loader
+// every time a popular item expires.
+//
+// It only applies to Get on caches created WithLoader, and to GetOrCompute.
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(c *Cache) {
+		c.maxStale = maxStale
+	}
+}
+
+// WithRefreshAhead reloads items in the background when they're accessed after fraction
+// of their TTL has passed (e.g. 0.8 for 80%), so popular items are refreshed before they ever expire.
+//
+// Like WithStaleWhileRevalidate, it only applies to Get on caches created WithLoader, and to GetOrCompute.
+func WithRefreshAhead(fraction float64) Option {
+	return func(c *Cache) {
+		c.refreshAhead = fraction
+	}
+}
+
+// WithEarlyExpiration reloads loaded items in the background a little before they expire,
+// with a probability that rises as expiry nears and with how long the item took to load, so
+// one request refreshes a hot key early instead of every request waiting on it at expiry.
+// It's the XFetch algorithm from "Optimal Probabilistic Cache Stampede Prevention"
+// (Vattani et al.); beta is its tuning parameter, where 1 is a good default and larger values
+// refresh earlier.
+//
+// Only items that were loaded are refreshed early, since Set doesn't know how long a value took
+// to compute. Like WithRefreshAhead, it applies to Get on caches created WithLoader, and to GetOrCompute.
+func WithEarlyExpiration(beta float64) Option {
+	return func(c *Cache) {
+		c.earlyExpiration = beta
+	}
+}
+
+// WithLoadTimeout cancels the context passed to the loader (or GetOrCompute's compute)
+// after timeout, so a slow origin can't hold up callers indefinitely. With WithLoadRetry,
+// each attempt gets its own timeout.
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.loadTimeout = timeout
+	}
+}
+
+// WithLoadRetry retries failed loads according to policy, e.g. to ride out a brief origin outage.
+// Only the final error is returned, or cached by WithNegativeCaching.
+func WithLoadRetry(policy RetryPolicy) Option {
+	return func(c *Cache) {
+		c.retry = policy
+	}
+}
+
+// WithNegativeCaching caches loader errors, including ErrNotFound, for ttl, so repeated
+// lookups of a key that doesn't exist (or whose origin is failing) don't each call the loader.
+//
+// ttl is separate from the cache's default TTL and is usually much shorter.
+func WithNegativeCaching(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithWriteThrough makes Set, SetWithTTL and Delete write to store before updating the cache,
+// so the cache and the store stay consistent.
+//
+// Unless the cache also has a loader, missing keys are read through from store.
+func WithWriteThrough(store Store) Option {
+	return func(c *Cache) {
+		c.store = store
+		if c.loader == nil {
+			c.loader = store.Get
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCacheFull is returned by SetE when the cache was created WithOverflowPolicy(OverflowReject)
+// and the write would take it past maxCacheSize.
+var ErrCacheFull = errors.New("cache: cache is full")
+
+// OverflowPolicy decides what a write that would take the cache past maxCacheSize does.
+type OverflowPolicy int
+
+const (
+	// OverflowClear clears the whole cache, as it always has. It's the default.
+	OverflowClear OverflowPolicy = iota
+
+	// OverflowEvict evicts items until the cache fits again, down to the soft limit if it
+	// was created WithSoftLimit, otherwise to maxCacheSize. See WithEvictionPolicy.
+	OverflowEvict
+
+	// OverflowReject leaves the cache as it is and drops the write. SetE returns ErrCacheFull.
+	OverflowReject
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowClear:
+		return "clear"
+	case OverflowEvict:
+		return "evict"
+	case OverflowReject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+// MarshalText writes p as its name, e.g. "reject", so it reads well in config files.
+func (p OverflowPolicy) MarshalText() ([]byte, error) {
+	if p.String() == "unknown" {
+		return nil, fmt.Errorf("cache: unknown overflow policy %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *OverflowPolicy) UnmarshalText(text []byte) error {
+	for _, policy := range []OverflowPolicy{OverflowClear, OverflowEvict, OverflowReject} {
+		if string(text) == policy.String() {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("cache: unknown overflow policy %q", text)
+}
+
+// WithOverflowPolicy changes what happens when a write would take the cache past maxCacheSize,
+// see OverflowPolicy. Caches created WithSoftLimit always evict rather than clear.
+//
+// With OverflowReject, writes made WithWriteThrough or WithWriteBehind still reach the store,
+// they just aren't cached. WithDeferredEviction only honours OverflowReject, since it never
+// evicts on the write path.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Cache) {
+		c.overflow = policy
+	}
+}
+
+// fits reports whether setting key to a value of size bytes keeps the cache within maxCacheSize.
+// c.mu must already be locked.
+func (c *Cache) fits(key string, size int64) bool {
+	total := c.totalCacheSize + size
+	if i, found := c.items[key]; found {
+		total -= c.arena.entry(i).size
+	} else {
+		total += int64(len(key))
+	}
+	return total <= c.maxCacheSize
+}
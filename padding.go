@@ -0,0 +1,20 @@
+//go:build !cachenopad
+
+package cache
+
+import "sync/atomic"
+
+// cacheLineSize is the most common cache line size, counting the pairs of lines x86 CPUs
+// prefetch together, and the line size of ARM64 server CPUs.
+const cacheLineSize = 128
+
+// paddedInt64 is an atomic.Int64 on a cache line of its own. Every Get takes the read lock and
+// adds to hits or misses, so without padding, cores reading the cache keep invalidating each
+// other's copy of the lock and the fields around it, like items and arena, that every lookup
+// reads but rarely changes. Building with the cachenopad tag turns it off, to compare with
+// BenchmarkParallel.
+type paddedInt64 struct {
+	_ [cacheLineSize]byte
+	atomic.Int64
+	_ [cacheLineSize - 8]byte
+}
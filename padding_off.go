@@ -0,0 +1,13 @@
+//go:build cachenopad
+
+package cache
+
+import "sync/atomic"
+
+// cacheLineSize is 0 with the cachenopad tag, which turns padding off to measure what it's
+// worth on a given machine, see padding.go.
+const cacheLineSize = 0
+
+type paddedInt64 struct {
+	atomic.Int64
+}
@@ -0,0 +1,38 @@
+package cache
+
+// PauseEviction stops the cache enforcing its size limit and removing expired or idle items
+// until ResumeEviction is called, so a bulk load, like a startup warmer or a migration, can take
+// the cache past its limit without it clearing halfway through. Writes that OverflowReject would
+// reject are accepted too. Expired items are still treated as missing.
+//
+// Pauses nest: eviction resumes once ResumeEviction has been called as many times as
+// PauseEviction. The memory monitors (WithHeapLimit, WithMemoryPressure) and scheduled clears
+// aren't paused, since they protect the process rather than the cache's budget.
+func (c *Cache) PauseEviction() {
+	c.paused.Add(1)
+}
+
+// ResumeEviction undoes a call to PauseEviction. Once every pause is over, the size limit is
+// enforced straight away, clearing or evicting as if the last write had just happened.
+func (c *Cache) ResumeEviction() {
+	n := c.paused.Add(-1)
+	if n < 0 {
+		c.paused.Add(1)
+		c.logf("ResumeEviction called without PauseEviction")
+		return
+	}
+	if n > 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logf("eviction resumed")
+	c.checkCurrentSize()
+}
+
+// evictionPaused reports whether PauseEviction is in effect.
+func (c *Cache) evictionPaused() bool {
+	return c.paused.Load() > 0
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// pressureCheckInterval is how often WithMemoryPressure checks its source.
+const pressureCheckInterval = time.Second
+
+// PressureSource reports how close the process is to running out of memory, as a fraction
+// of whatever its limit is: 0 is no pressure, 1 is at the limit.
+type PressureSource interface {
+	Pressure() float64
+}
+
+// PressureFunc adapts a func to a PressureSource, e.g. one that reads a container's cgroup stats.
+type PressureFunc func() float64
+
+// Pressure implements PressureSource.
+func (f PressureFunc) Pressure() float64 {
+	return f()
+}
+
+// MemoryLimitPressure reports the Go runtime's memory use as a fraction of its soft memory
+// limit, set with debug.SetMemoryLimit or GOMEMLIMIT. It's always 0 if no limit is set.
+func MemoryLimitPressure() PressureSource {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+
+	return PressureFunc(func() float64 {
+		// A negative value reads the limit without changing it.
+		limit := debug.SetMemoryLimit(-1)
+		if limit <= 0 || limit == math.MaxInt64 {
+			return 0
+		}
+
+		metrics.Read(samples)
+		used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+		return float64(used) / float64(limit)
+	})
+}
+
+// WithMemoryPressure also clears the cache when source reports pressure at or above threshold,
+// e.g. 0.9, so the cache gives memory back before the process hits its limit rather than only
+// when its own estimate crosses maxCacheSize. Pass MemoryLimitPressure to use the Go runtime's
+// memory limit.
+//
+// source is checked every second. After a clear, it isn't checked again until the garbage
+// collector has had a chance to free what was cleared.
+func WithMemoryPressure(source PressureSource, threshold float64) Option {
+	return func(c *Cache) {
+		c.pressure = newPressureMonitor(c, source, threshold)
+		go c.pressure.run()
+	}
+}
+
+func newPressureMonitor(c *Cache, source PressureSource, threshold float64) *pressureMonitor {
+	return &pressureMonitor{
+		cache:     c,
+		source:    source,
+		threshold: threshold,
+		samples:   []metrics.Sample{{Name: metricGCCycles}},
+		done:      make(chan struct{}),
+	}
+}
+
+type pressureMonitor struct {
+	cache     *Cache
+	source    PressureSource
+	threshold float64
+
+	// clearedAt is the GC count at the last clear. Pressure isn't checked again until it changes.
+	clearedAt uint64
+	cleared   bool
+
+	samples []metrics.Sample
+	done    chan struct{}
+}
+
+func (m *pressureMonitor) run() {
+	ticker := time.NewTicker(pressureCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *pressureMonitor) check() {
+	metrics.Read(m.samples)
+	cycles := m.samples[0].Value.Uint64()
+
+	if m.cleared && cycles == m.clearedAt {
+		return
+	}
+	m.cleared = false
+
+	pressure := m.source.Pressure()
+	if pressure < m.threshold {
+		return
+	}
+
+	c := m.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.items) == 0 {
+		return
+	}
+
+	c.logf("memory pressure at %.0f%%, over the threshold of %.0f%%. clearing...", pressure*100, m.threshold*100)
+	c.selfClear()
+
+	m.cleared = true
+	m.clearedAt = cycles
+}
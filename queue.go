@@ -0,0 +1,82 @@
+package cache
+
+// queuedPolicy is an eviction policy that keeps items in the order they were added, rather than
+// sampling them, see EvictSieve and EvictS3FIFO. Apart from visit, which is called on reads,
+// while c.mu is only read locked, its methods need c.mu locked.
+type queuedPolicy interface {
+	// add adds a new item, stored in slot i.
+	add(key string, i uint32)
+
+	// visit records that slot i was read or replaced.
+	visit(i uint32)
+
+	remove(i uint32)
+
+	// reset empties the queues, for when the whole cache is cleared.
+	reset()
+
+	// victim returns the item to evict next, leaving it for the caller to remove. The cache
+	// must not be empty.
+	victim(a *arena, now int64) (string, uint32)
+
+	// len returns how many items are queued, which should always be how many are cached.
+	len() int
+}
+
+// slotQueue is a doubly linked list of arena slots, newest at the head, for eviction policies
+// that need to know the order items were added in. Links are kept in slices indexed by slot,
+// like the arena itself, and hold the slot plus 1 so that 0 means there's no next or previous
+// slot. It's only accessed with c.mu locked.
+type slotQueue struct {
+	newer, older []uint32
+	head, tail   uint32
+	len          int
+}
+
+// grow makes room for links to slot i.
+func (q *slotQueue) grow(i uint32) {
+	for uint32(len(q.newer)) <= i {
+		q.newer = append(q.newer, 0)
+		q.older = append(q.older, 0)
+	}
+}
+
+// push adds slot i at the head of the queue.
+func (q *slotQueue) push(i uint32) {
+	q.grow(i)
+	q.newer[i], q.older[i] = 0, q.head
+	if q.head != 0 {
+		q.newer[q.head-1] = i + 1
+	} else {
+		q.tail = i + 1
+	}
+	q.head = i + 1
+	q.len++
+}
+
+// unlink removes slot i from the queue.
+func (q *slotQueue) unlink(i uint32) {
+	newer, older := q.newer[i], q.older[i]
+	if newer != 0 {
+		q.older[newer-1] = older
+	} else {
+		q.head = older
+	}
+	if older != 0 {
+		q.newer[older-1] = newer
+	} else {
+		q.tail = newer
+	}
+	q.newer[i], q.older[i] = 0, 0
+	q.len--
+}
+
+// oldest returns the slot at the tail of the queue, and false if it's empty.
+func (q *slotQueue) oldest() (uint32, bool) {
+	return q.tail - 1, q.tail != 0
+}
+
+// newerThan returns the slot added after slot i, and false if i is the newest.
+func (q *slotQueue) newerThan(i uint32) (uint32, bool) {
+	return q.newer[i] - 1, q.newer[i] != 0
+}
@@ -0,0 +1,91 @@
+package cache_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+var queuedPolicies = []cache.EvictionPolicy{cache.EvictSieve, cache.EvictS3FIFO}
+
+// fillUntilCleared sets keys until the cache clears itself, reading the first few keys
+// repeatedly so WithRetainHot has something to keep.
+func fillUntilCleared(t *testing.T, c *cache.Cache) {
+	t.Helper()
+
+	value := strings.Repeat("x", 50)
+	clears := c.Stats().Clears
+	for i := 0; c.Stats().Clears == clears; i++ {
+		if i == 1000 {
+			t.Fatal("cache never cleared itself")
+		}
+		c.Set("key"+strconv.Itoa(i), value)
+		for hot := range min(i+1, 3) {
+			c.Get("key" + strconv.Itoa(hot))
+		}
+	}
+}
+
+func TestQueuedPoliciesAfterRetainHotClear(t *testing.T) {
+	for _, policy := range queuedPolicies {
+		t.Run(policy.String(), func(t *testing.T) {
+			c := cache.New(2000, cache.WithLogger(nil), cache.WithEvictionPolicy(policy), cache.WithRetainHot(3))
+			defer c.Close()
+
+			fillUntilCleared(t, c)
+			if err := c.Healthy(); err != nil {
+				t.Fatalf("after a retain hot clear: %v", err)
+			}
+
+			if _, found := c.Get("key0"); !found {
+				t.Fatal("hottest key wasn't retained")
+			}
+			c.Delete("key1")
+			c.SetMaxCacheSize(100)
+			if err := c.Healthy(); err != nil {
+				t.Fatalf("after evicting: %v", err)
+			}
+
+			c.SetMaxCacheSize(2000)
+			fillUntilCleared(t, c)
+			if err := c.Healthy(); err != nil {
+				t.Fatalf("after a second clear: %v", err)
+			}
+		})
+	}
+}
+
+func TestS3FIFOKeepsHotKeysThroughScan(t *testing.T) {
+	c := cache.New(5000, cache.WithLogger(nil), cache.WithEvictionPolicy(cache.EvictS3FIFO), cache.WithOverflowPolicy(cache.OverflowEvict))
+	defer c.Close()
+
+	value := strings.Repeat("x", 50)
+	for i := range 10 {
+		c.Set("hot"+strconv.Itoa(i), value)
+	}
+	for range 2 {
+		for i := range 10 {
+			c.Get("hot" + strconv.Itoa(i))
+		}
+	}
+
+	for i := range 1000 {
+		c.Set("scan"+strconv.Itoa(i), value)
+		if i%100 == 0 {
+			for hot := range 10 {
+				c.Get("hot" + strconv.Itoa(hot))
+			}
+		}
+	}
+
+	for i := range 10 {
+		if !c.Has("hot" + strconv.Itoa(i)) {
+			t.Errorf("hot%d was evicted by a scan", i)
+		}
+	}
+	if err := c.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+}
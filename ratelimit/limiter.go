@@ -0,0 +1,125 @@
+// Package ratelimit throttles clients using counters kept in a cache.Cache, so a service can
+// reuse the cache it already has for per-client rate limits:
+//
+//	limiter := ratelimit.New(c, 100, time.Minute) // 100 requests per minute per client
+//	if !limiter.Allow(clientIP) {
+//		http.Error(w, "too many requests", http.StatusTooManyRequests)
+//		return
+//	}
+package ratelimit
+
+import (
+	"strconv"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultKeyPrefix is put in front of every counter's key unless changed with WithKeyPrefix.
+const DefaultKeyPrefix = "ratelimit:"
+
+// Option configures a Limiter.
+type Option func(*Limiter)
+
+// WithKeyPrefix changes the prefix put in front of every counter's key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+// WithClock sets the func used to get the current time, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// Limiter allows up to limit requests per key in any window, using a sliding window counter:
+// each key has a counter per fixed window, made with cache.IncrementWithTTL, and the count for
+// the sliding window ending now is the current window's count plus the previous window's,
+// weighted by how much of it the sliding window still covers. That approximates a true
+// sliding window closely while only storing two counters per key.
+//
+// Requests that are refused don't count towards the limit.
+type Limiter struct {
+	cache  *cache.Cache
+	limit  int64
+	window time.Duration
+	prefix string
+	now    func() time.Time
+}
+
+// New creates a Limiter allowing limit requests per window for each key, counted in c.
+func New(c *cache.Cache, limit int, window time.Duration, opts ...Option) *Limiter {
+	l := &Limiter{
+		cache:  c,
+		limit:  int64(limit),
+		window: window,
+		prefix: DefaultKeyPrefix,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Allow reports whether a request for key can go ahead now, and counts it if so.
+func (l *Limiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n requests for key can go ahead now, and counts them if so.
+//
+// If the cache can't store the count, e.g. because it's been closed, the requests are allowed,
+// so a problem with the cache doesn't take the service down with it.
+func (l *Limiter) AllowN(key string, n int) bool {
+	now := l.now().UnixNano()
+	window := now / int64(l.window)
+	through := float64(now%int64(l.window)) / float64(l.window)
+
+	previous, _ := cache.GetAs[int64](l.cache, l.key(key, window-1))
+	weighted := float64(previous) * (1 - through)
+
+	current, _ := cache.GetAs[int64](l.cache, l.key(key, window))
+	if weighted+float64(current+int64(n)) > float64(l.limit) {
+		return false
+	}
+
+	// The counter has to outlive its window to be the previous one for the next window.
+	current, err := l.cache.IncrementWithTTL(l.key(key, window), int64(n), 2*l.window)
+	if err != nil {
+		return true
+	}
+
+	// Another request may have been counted since current was read.
+	if weighted+float64(current) > float64(l.limit) {
+		l.cache.IncrementWithTTL(l.key(key, window), -int64(n), 2*l.window)
+		return false
+	}
+	return true
+}
+
+// Remaining returns roughly how many more requests key can make now.
+func (l *Limiter) Remaining(key string) int {
+	now := l.now().UnixNano()
+	window := now / int64(l.window)
+	through := float64(now%int64(l.window)) / float64(l.window)
+
+	previous, _ := cache.GetAs[int64](l.cache, l.key(key, window-1))
+	current, _ := cache.GetAs[int64](l.cache, l.key(key, window))
+
+	return max(int(float64(l.limit)-float64(previous)*(1-through)-float64(current)), 0)
+}
+
+// Reset forgets key's requests, e.g. after a successful login.
+func (l *Limiter) Reset(key string) {
+	window := l.now().UnixNano() / int64(l.window)
+	l.cache.Delete(l.key(key, window-1))
+	l.cache.Delete(l.key(key, window))
+}
+
+func (l *Limiter) key(key string, window int64) string {
+	return l.prefix + key + ":" + strconv.FormatInt(window, 10)
+}
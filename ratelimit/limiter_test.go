@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+// newLimiter returns a Limiter allowing limit requests a minute, on a fake clock that starts
+// at the beginning of a window.
+func newLimiter(t *testing.T, limit int) (*Limiter, *clocktest.Clock) {
+	t.Helper()
+
+	clock := clocktest.New(time.Unix(0, 0).Add(1000 * time.Minute))
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock))
+	t.Cleanup(func() { c.Close() })
+	return New(c, limit, time.Minute, WithClock(clock.Now)), clock
+}
+
+// allowed returns how many of n requests for key are allowed.
+func allowed(l *Limiter, key string, n int) int {
+	var allowed int
+	for range n {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestAllow(t *testing.T) {
+	l, _ := newLimiter(t, 10)
+
+	if got := allowed(l, "a", 15); got != 10 {
+		t.Errorf("%d of 15 requests were allowed, want 10", got)
+	}
+	if got := l.Remaining("a"); got != 0 {
+		t.Errorf("Remaining = %d after using up the limit, want 0", got)
+	}
+	if got := l.Remaining("b"); got != 10 {
+		t.Errorf("Remaining = %d for another key, want 10", got)
+	}
+
+	l.Reset("a")
+	if got := allowed(l, "a", 10); got != 10 {
+		t.Errorf("%d of 10 requests were allowed after Reset, want 10", got)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l, clock := newLimiter(t, 10)
+	allowed(l, "key", 10)
+
+	// At the start of the next window, the previous one still counts in full.
+	clock.Advance(time.Minute)
+	if got := l.Remaining("key"); got != 0 {
+		t.Errorf("Remaining = %d at the start of the next window, want 0", got)
+	}
+	if l.Allow("key") {
+		t.Error("request allowed at the start of the next window")
+	}
+
+	// Halfway through it, half of the previous window has slid out.
+	clock.Advance(30 * time.Second)
+	if got := l.Remaining("key"); got != 5 {
+		t.Errorf("Remaining = %d halfway through the next window, want 5", got)
+	}
+	if got := allowed(l, "key", 10); got != 5 {
+		t.Errorf("%d of 10 requests were allowed halfway through the next window, want 5", got)
+	}
+
+	// Two windows on, the first is forgotten and the second is half carried over.
+	clock.Advance(time.Minute)
+	if got := l.Remaining("key"); got != 7 {
+		t.Errorf("Remaining = %d halfway through the window after, want 7", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got := allowed(l, "key", 10); got != 10 {
+		t.Errorf("%d of 10 requests were allowed after two idle windows, want 10", got)
+	}
+}
+
+func TestAllowN(t *testing.T) {
+	l, _ := newLimiter(t, 10)
+
+	if l.AllowN("key", 11) {
+		t.Error("AllowN past the limit was allowed")
+	}
+	// A refused AllowN doesn't count.
+	if !l.AllowN("key", 10) {
+		t.Error("AllowN up to the limit was refused")
+	}
+	if l.AllowN("key", 1) {
+		t.Error("AllowN past the limit was allowed")
+	}
+}
+
+func TestAllowConcurrent(t *testing.T) {
+	l, _ := newLimiter(t, 100)
+
+	var n atomic.Int64
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			n.Add(int64(allowed(l, "key", 10)))
+		})
+	}
+	wg.Wait()
+
+	// Requests refused because of another that's rolled back can leave the limit a little
+	// short, but it's never exceeded.
+	if got := n.Load(); got > 100 || got < 90 {
+		t.Errorf("%d of 500 concurrent requests were allowed, want 100", got)
+	}
+	if got := l.Remaining("key"); got != 100-int(n.Load()) {
+		t.Errorf("Remaining = %d after %d requests were allowed", got, n.Load())
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// ReadMostly is a read-copy-update cache for data that's read constantly and changed rarely,
+// e.g. config or feature flags. Get loads the current map with a single atomic load and never
+// locks, waits or writes to shared memory, so reads scale with cores however hot the keys are.
+// Writers copy the map, change the copy and swap it in, so every write costs time and memory
+// proportional to the number of items, and writers wait for each other.
+//
+// Unlike Cache, it has no size limit, TTLs or stats, since each would need reads to write
+// something. Change many items at once with Update or Replace rather than one Set at a time.
+type ReadMostly struct {
+	mu    sync.Mutex // held by writers
+	items atomic.Pointer[map[string]any]
+}
+
+// NewReadMostly returns a ReadMostly holding a copy of items, which may be nil.
+func NewReadMostly(items map[string]any) *ReadMostly {
+	r := &ReadMostly{}
+	r.Replace(items)
+	return r
+}
+
+// Get retrieves an item. It's wait-free.
+func (r *ReadMostly) Get(key string) (any, bool) {
+	value, found := (*r.items.Load())[key]
+	return value, found
+}
+
+// Snapshot returns the current items. The map is shared with readers and must not be modified.
+func (r *ReadMostly) Snapshot() map[string]any {
+	return *r.items.Load()
+}
+
+// Len returns the number of items.
+func (r *ReadMostly) Len() int {
+	return len(*r.items.Load())
+}
+
+// Set adds an item, replacing any existing item with the same key.
+func (r *ReadMostly) Set(key string, value any) {
+	r.Update(func(items map[string]any) {
+		items[key] = value
+	})
+}
+
+// Delete removes an item.
+func (r *ReadMostly) Delete(key string) {
+	r.Update(func(items map[string]any) {
+		delete(items, key)
+	})
+}
+
+// Update calls fn with a copy of the current items, and swaps in the copy once fn returns,
+// so readers see all of fn's changes at once or none of them. fn must not keep items or call
+// back into r's writers.
+func (r *ReadMostly) Update(fn func(items map[string]any)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	items := maps.Clone(*r.items.Load())
+	fn(items)
+	r.items.Store(&items)
+}
+
+// Replace swaps in a copy of items, dropping every item that isn't in it.
+func (r *ReadMostly) Replace(items map[string]any) {
+	items = maps.Clone(items)
+	if items == nil {
+		items = make(map[string]any)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items.Store(&items)
+}
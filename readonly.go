@@ -0,0 +1,37 @@
+package cache
+
+// ReadOnlyCache is a view of a Cache that can only read it, for handing to components that
+// shouldn't be able to change or clear it. It's a struct rather than an interface so it can't
+// be converted back into the *Cache.
+//
+// Values are shared with the cache as usual, so create the cache WithCopyOnGet if readers
+// mustn't be able to modify the values they get either.
+type ReadOnlyCache struct {
+	c *Cache
+}
+
+// ReadOnly returns a read-only view of the cache.
+func (c *Cache) ReadOnly() ReadOnlyCache {
+	return ReadOnlyCache{c: c}
+}
+
+// Get retrieves an item from the cache, see Cache.Get. If the cache has a loader, a miss
+// still loads the item.
+func (r ReadOnlyCache) Get(key string) (any, bool) {
+	return r.c.Get(key)
+}
+
+// Has reports whether key is in the cache without counting towards hit or miss stats.
+func (r ReadOnlyCache) Has(key string) bool {
+	return r.c.Has(key)
+}
+
+// Keys returns the keys of every item in the cache, in no particular order.
+func (r ReadOnlyCache) Keys() []string {
+	return r.c.Keys()
+}
+
+// Stats returns a snapshot of the cache's current usage.
+func (r ReadOnlyCache) Stats() Stats {
+	return r.c.Stats()
+}
@@ -0,0 +1,75 @@
+package resp
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultDialTimeout is used by Dial.
+const DefaultDialTimeout = 5 * time.Second
+
+// Client is a minimal RESP client for a single connection. Safe for concurrent use,
+// commands are sent one at a time.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *Reader
+	w    *Writer
+}
+
+// Dial connects to the RESP server at addr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a Client using an existing connection.
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn: conn,
+		r:    NewReader(conn),
+		w:    NewWriter(conn),
+	}
+}
+
+// Do sends a command and returns the reply as described by Reader.ReadValue.
+// An error reply is returned as an Error.
+func (c *Client) Do(args ...string) (any, error) {
+	bargs := make([][]byte, len(args))
+	for i, arg := range args {
+		bargs[i] = []byte(arg)
+	}
+	return c.DoBytes(bargs...)
+}
+
+// DoBytes is like Do but takes the arguments as bytes, for binary values.
+func (c *Client) DoBytes(args ...[]byte) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.w.WriteCommand(args...); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	v, err := c.r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if replyErr, ok := v.(Error); ok {
+		return nil, replyErr
+	}
+	return v, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,269 @@
+// Package resp serves a cache.Cache over the Redis serialization protocol (RESP),
+// so redis-cli and existing Redis clients can talk to it.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLen caps the size of a single bulk string so a bad client can't make us allocate
+	// an arbitrary amount of memory. Matches Redis's default proto-max-bulk-len.
+	maxBulkLen = 512 * 1024 * 1024
+
+	// maxUnauthenticatedBulkLen and maxUnauthenticatedArrayLen are the much lower limits for
+	// clients that haven't authenticated yet, and only need to send AUTH. Like Redis's, they
+	// stop anyone who can connect from making the server buffer a 512MB argument.
+	maxUnauthenticatedBulkLen  = 16 * 1024
+	maxUnauthenticatedArrayLen = 10
+
+	// maxLineLen caps inline commands and the header lines of other values, which are read
+	// whole before they're parsed. Matches Redis's inline limit.
+	maxLineLen = 64 * 1024
+
+	// maxDepth caps how deeply arrays can be nested. Commands are never nested, and no reply
+	// the server sends is more than 2 deep, so this only stops a peer from making ReadValue
+	// recurse until the stack overflows.
+	maxDepth = 16
+)
+
+// ErrProtocol is returned when a peer sends something that isn't valid RESP.
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Error is an error reply sent by the other side, e.g. "ERR unknown command".
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Reader reads RESP values from a stream.
+type Reader struct {
+	r        *bufio.Reader
+	maxBulk  int
+	maxArray int // 0 for no limit
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), maxBulk: maxBulkLen}
+}
+
+// restrict lowers r's limits to what a client that hasn't authenticated needs, or puts them
+// back once it has.
+func (r *Reader) restrict(restricted bool) {
+	if restricted {
+		r.maxBulk, r.maxArray = maxUnauthenticatedBulkLen, maxUnauthenticatedArrayLen
+	} else {
+		r.maxBulk, r.maxArray = maxBulkLen, 0
+	}
+}
+
+// ReadCommand reads a single command, either as an array of bulk strings or as an
+// inline command (space separated, as typed into telnet).
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	b, err := r.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] != '*' {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		var args [][]byte
+		for _, field := range strings.Fields(line) {
+			args = append(args, []byte(field))
+		}
+		return args, nil
+	}
+
+	v, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	values, _ := v.([]any)
+	args := make([][]byte, 0, len(values))
+	for _, value := range values {
+		arg, ok := value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected bulk string in command, got %T", ErrProtocol, value)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// ReadValue reads a single RESP value.
+//
+// Simple strings are returned as string, errors as Error, integers as int64,
+// bulk strings as []byte, arrays as []any and null bulk strings or arrays as nil.
+func (r *Reader) ReadValue() (any, error) {
+	return r.readValue(0)
+}
+
+// readValue reads a value nested depth arrays deep.
+func (r *Reader) readValue(depth int) (any, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid integer %q", ErrProtocol, line[1:])
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", ErrProtocol, line[1:])
+		}
+		if n > r.maxBulk {
+			return nil, fmt.Errorf("%w: bulk length %d over the limit of %d", ErrProtocol, n, r.maxBulk)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid array length %q", ErrProtocol, line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if r.maxArray > 0 && n > r.maxArray {
+			return nil, fmt.Errorf("%w: array length %d over the limit of %d", ErrProtocol, n, r.maxArray)
+		}
+		if depth == maxDepth {
+			return nil, fmt.Errorf("%w: arrays nested more than %d deep", ErrProtocol, maxDepth)
+		}
+
+		values := make([]any, 0, min(n, 1024))
+		for range n {
+			v, err := r.readValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown type %q", ErrProtocol, line[0])
+}
+
+// readLine reads up to the next newline, returning an error rather than buffering more than
+// maxLineLen bytes of it.
+func (r *Reader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLen {
+			return "", fmt.Errorf("%w: line longer than %d bytes", ErrProtocol, maxLineLen)
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// Writer writes RESP values to a stream. Call Flush once a full reply has been written.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// WriteSimpleString writes a status reply such as "+OK".
+func (w *Writer) WriteSimpleString(s string) error {
+	_, err := fmt.Fprintf(w.w, "+%s\r\n", s)
+	return err
+}
+
+// WriteError writes an error reply. msg should start with an error code such as "ERR".
+func (w *Writer) WriteError(msg string) error {
+	_, err := fmt.Fprintf(w.w, "-%s\r\n", msg)
+	return err
+}
+
+// WriteInt writes an integer reply.
+func (w *Writer) WriteInt(n int64) error {
+	_, err := fmt.Fprintf(w.w, ":%d\r\n", n)
+	return err
+}
+
+// WriteBulk writes a bulk string reply.
+func (w *Writer) WriteBulk(b []byte) error {
+	if _, err := fmt.Fprintf(w.w, "$%d\r\n", len(b)); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString("\r\n")
+	return err
+}
+
+// WriteNull writes a null bulk string, which is how Redis replies to a missing key.
+func (w *Writer) WriteNull() error {
+	_, err := w.w.WriteString("$-1\r\n")
+	return err
+}
+
+// WriteArrayHeader writes the header for an array of n values. The values follow as separate writes.
+func (w *Writer) WriteArrayHeader(n int) error {
+	_, err := fmt.Fprintf(w.w, "*%d\r\n", n)
+	return err
+}
+
+// WriteCommand writes args as an array of bulk strings, the way clients send commands.
+func (w *Writer) WriteCommand(args ...[]byte) error {
+	if err := w.WriteArrayHeader(len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := w.WriteBulk(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying stream.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,105 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"+OK\r\n", "OK"},
+		{"-ERR bad\r\n", Error("ERR bad")},
+		{":-42\r\n", int64(-42)},
+		{"$5\r\nhello\r\n", []byte("hello")},
+		{"$0\r\n\r\n", []byte{}},
+		{"$-1\r\n", nil},
+		{"*-1\r\n", nil},
+		{"*2\r\n$1\r\na\r\n*1\r\n:1\r\n", []any{[]byte("a"), []any{int64(1)}}},
+	}
+	for _, tt := range tests {
+		got, err := NewReader(strings.NewReader(tt.in)).ReadValue()
+		if err != nil {
+			t.Errorf("ReadValue(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadValue(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadValueInvalid(t *testing.T) {
+	for _, in := range []string{
+		"\r\n",
+		"?1\r\n",
+		":x\r\n",
+		"$x\r\n",
+		"$3\r\nabcde",
+		"*x\r\n",
+		"$536870913\r\n",
+		strings.Repeat("*1\r\n", maxDepth+1) + ":1\r\n",
+		"+" + strings.Repeat("x", maxLineLen) + "\r\n",
+	} {
+		if _, err := NewReader(strings.NewReader(in)).ReadValue(); !errors.Is(err, ErrProtocol) {
+			t.Errorf("ReadValue(%.20q...) returned %v, want a protocol error", in, err)
+		}
+	}
+
+	nested := strings.Repeat("*1\r\n", maxDepth) + ":1\r\n"
+	if _, err := NewReader(strings.NewReader(nested)).ReadValue(); err != nil {
+		t.Errorf("arrays nested %d deep: %v", maxDepth, err)
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteCommand([]byte("SET"), []byte("key"), []byte("a value"))
+	w.Flush()
+	buf.WriteString("GET  key\r\n")
+
+	r := NewReader(&buf)
+	for _, want := range [][]string{{"SET", "key", "a value"}, {"GET", "key"}} {
+		args, err := r.ReadCommand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, arg := range args {
+			got = append(got, string(arg))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadCommand() = %q, want %q", got, want)
+		}
+	}
+
+	if _, err := NewReader(strings.NewReader("*1\r\n:1\r\n")).ReadCommand(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("command with an integer argument returned %v, want a protocol error", err)
+	}
+}
+
+func TestReaderRestrict(t *testing.T) {
+	tooLong := "*1\r\n$16385\r\n" + strings.Repeat("x", 16385) + "\r\n"
+	tooMany := "*11\r\n" + strings.Repeat("$1\r\nx\r\n", 11)
+
+	for _, in := range []string{tooLong, tooMany} {
+		r := NewReader(strings.NewReader(in + in))
+		r.restrict(true)
+		if _, err := r.ReadCommand(); !errors.Is(err, ErrProtocol) {
+			t.Errorf("restricted ReadCommand(%.20q...) returned %v, want a protocol error", in, err)
+		}
+
+		r = NewReader(strings.NewReader(in))
+		r.restrict(true)
+		r.restrict(false)
+		if _, err := r.ReadCommand(); err != nil {
+			t.Errorf("unrestricted ReadCommand(%.20q...): %v", in, err)
+		}
+	}
+}
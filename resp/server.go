@@ -0,0 +1,475 @@
+package resp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close is called.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Server serves a cache over RESP.
+//
+// It supports the subset of Redis commands that map onto the cache: AUTH, PING, ECHO, GET, SET (with EX/PX),
+// DEL, EXISTS, KEYS, SCAN, EXPIRE, PEXPIRE, TTL, PTTL, DBSIZE, INFO, FLUSHALL, FLUSHDB and QUIT.
+type Server struct {
+	cache    *cache.Cache
+	password string
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithRequirePass makes clients AUTH with password before running any other command,
+// like Redis's requirepass setting. Until they do, commands are limited to 10 arguments of
+// at most 16KB each, like Redis, so they can't make the server buffer anything big.
+func WithRequirePass(password string) ServerOption {
+	return func(s *Server) {
+		s.password = password
+	}
+}
+
+// NewServer creates a new Server backed by c.
+func NewServer(c *cache.Cache, opts ...ServerOption) *Server {
+	s := &Server{
+		cache:     c,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called. l is closed when Serve returns.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops all listeners and closes every open connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	var err error
+	for l := range s.listeners {
+		err = errors.Join(err, l.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := NewReader(conn)
+	w := NewWriter(conn)
+
+	authed := s.password == ""
+	r.restrict(!authed)
+
+	for {
+		args, err := r.ReadCommand()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				w.WriteError("ERR " + err.Error())
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("resp: reading from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(string(args[0]))
+		if name == "QUIT" {
+			w.WriteSimpleString("OK")
+			w.Flush()
+			return
+		}
+
+		switch {
+		case name == "AUTH":
+			authed, err = s.auth(w, args[1:], authed)
+			r.restrict(!authed)
+		case !authed:
+			err = w.WriteError("NOAUTH Authentication required.")
+		default:
+			err = s.dispatch(w, name, args[1:])
+		}
+		if err != nil {
+			return
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch runs a single command. Errors returned are write errors, command failures
+// are reported to the client as error replies.
+func (s *Server) dispatch(w *Writer, name string, args [][]byte) error {
+	cmd, found := commands[name]
+	if !found {
+		return w.WriteError(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+	}
+	return cmd.fn(s, w, args)
+}
+
+// auth handles AUTH, which is run outside dispatch since it changes the connection's state.
+// It returns whether the connection is authenticated afterwards.
+func (s *Server) auth(w *Writer, args [][]byte, authed bool) (bool, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return authed, w.WriteError("ERR wrong number of arguments for 'auth' command")
+	}
+	if s.password == "" {
+		return authed, w.WriteError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+
+	// AUTH <username> <password> is accepted for the default user only.
+	password := args[len(args)-1]
+	userOK := len(args) == 1 || string(args[0]) == "default"
+	if !userOK || subtle.ConstantTimeCompare(password, []byte(s.password)) != 1 {
+		return authed, w.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	return true, w.WriteSimpleString("OK")
+}
+
+type command struct {
+	minArgs int
+	maxArgs int // -1 for no limit
+	fn      func(s *Server, w *Writer, args [][]byte) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":     {0, 1, cmdPing},
+		"ECHO":     {1, 1, cmdEcho},
+		"GET":      {1, 1, cmdGet},
+		"SET":      {2, 4, cmdSet},
+		"DEL":      {1, -1, cmdDel},
+		"EXISTS":   {1, -1, cmdExists},
+		"KEYS":     {1, 1, cmdKeys},
+		"SCAN":     {1, 5, cmdScan},
+		"EXPIRE":   {2, 2, cmdExpire(time.Second)},
+		"PEXPIRE":  {2, 2, cmdExpire(time.Millisecond)},
+		"TTL":      {1, 1, cmdTTL(time.Second)},
+		"PTTL":     {1, 1, cmdTTL(time.Millisecond)},
+		"DBSIZE":   {0, 0, cmdDBSize},
+		"INFO":     {0, 1, cmdInfo},
+		"FLUSHALL": {0, 1, cmdFlushAll},
+		"FLUSHDB":  {0, 1, cmdFlushAll},
+
+		// redis-cli sends COMMAND DOCS on startup. An empty reply is enough to keep it happy.
+		"COMMAND": {0, -1, func(s *Server, w *Writer, args [][]byte) error { return w.WriteArrayHeader(0) }},
+	}
+}
+
+func cmdPing(s *Server, w *Writer, args [][]byte) error {
+	if len(args) == 1 {
+		return w.WriteBulk(args[0])
+	}
+	return w.WriteSimpleString("PONG")
+}
+
+func cmdEcho(s *Server, w *Writer, args [][]byte) error {
+	return w.WriteBulk(args[0])
+}
+
+func cmdGet(s *Server, w *Writer, args [][]byte) error {
+	value, found := s.cache.Get(string(args[0]))
+	if !found {
+		return w.WriteNull()
+	}
+	return w.WriteBulk(valueBytes(value))
+}
+
+func cmdSet(s *Server, w *Writer, args [][]byte) error {
+	key, value := string(args[0]), string(args[1])
+
+	var ttl time.Duration
+	if len(args) > 2 {
+		if len(args) != 4 {
+			return w.WriteError("ERR syntax error")
+		}
+
+		var unit time.Duration
+		switch strings.ToUpper(string(args[2])) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			return w.WriteError("ERR syntax error")
+		}
+
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil || n <= 0 {
+			return w.WriteError("ERR invalid expire time in 'set' command")
+		}
+		ttl = time.Duration(n) * unit
+	}
+
+	s.cache.SetWithTTL(key, value, ttl)
+	return w.WriteSimpleString("OK")
+}
+
+func cmdDel(s *Server, w *Writer, args [][]byte) error {
+	var deleted int64
+	for _, arg := range args {
+		key := string(arg)
+		if s.cache.Has(key) {
+			s.cache.Delete(key)
+			deleted++
+		}
+	}
+	return w.WriteInt(deleted)
+}
+
+func cmdExists(s *Server, w *Writer, args [][]byte) error {
+	var found int64
+	for _, arg := range args {
+		if s.cache.Has(string(arg)) {
+			found++
+		}
+	}
+	return w.WriteInt(found)
+}
+
+func cmdKeys(s *Server, w *Writer, args [][]byte) error {
+	return writeKeys(w, s.matchingKeys(string(args[0])))
+}
+
+// cmdScan returns every matching key in one go and a cursor of 0, which clients
+// treat as the end of the scan. COUNT is accepted for compatibility and ignored.
+func cmdScan(s *Server, w *Writer, args [][]byte) error {
+	if string(args[0]) != "0" {
+		if _, err := strconv.ParseUint(string(args[0]), 10, 64); err != nil {
+			return w.WriteError("ERR invalid cursor")
+		}
+		// Any other cursor means a scan that's already finished.
+		if err := w.WriteArrayHeader(2); err != nil {
+			return err
+		}
+		w.WriteBulk([]byte("0"))
+		return w.WriteArrayHeader(0)
+	}
+
+	pattern := "*"
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return w.WriteError("ERR syntax error")
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			if n, err := strconv.Atoi(string(args[i+1])); err != nil || n < 1 {
+				return w.WriteError("ERR value is not an integer or out of range")
+			}
+		default:
+			return w.WriteError("ERR syntax error")
+		}
+	}
+
+	if err := w.WriteArrayHeader(2); err != nil {
+		return err
+	}
+	w.WriteBulk([]byte("0"))
+	return writeKeys(w, s.matchingKeys(pattern))
+}
+
+func (s *Server) matchingKeys(pattern string) []string {
+	var keys []string
+	for _, key := range s.cache.Keys() {
+		if matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeKeys(w *Writer, keys []string) error {
+	if err := w.WriteArrayHeader(len(keys)); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := w.WriteBulk([]byte(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdExpire(unit time.Duration) func(s *Server, w *Writer, args [][]byte) error {
+	return func(s *Server, w *Writer, args [][]byte) error {
+		key := string(args[0])
+
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return w.WriteError("ERR value is not an integer or out of range")
+		}
+
+		// Like Redis, a non-positive timeout deletes the key straight away.
+		if n <= 0 {
+			if !s.cache.Has(key) {
+				return w.WriteInt(0)
+			}
+			s.cache.Delete(key)
+			return w.WriteInt(1)
+		}
+
+		if s.cache.Expire(key, time.Duration(n)*unit) {
+			return w.WriteInt(1)
+		}
+		return w.WriteInt(0)
+	}
+}
+
+func cmdTTL(unit time.Duration) func(s *Server, w *Writer, args [][]byte) error {
+	return func(s *Server, w *Writer, args [][]byte) error {
+		ttl, found := s.cache.TTL(string(args[0]))
+		switch {
+		case !found:
+			return w.WriteInt(-2)
+		case ttl == cache.NoExpiration:
+			return w.WriteInt(-1)
+		}
+		return w.WriteInt(int64((ttl + unit/2) / unit))
+	}
+}
+
+func cmdDBSize(s *Server, w *Writer, args [][]byte) error {
+	return w.WriteInt(int64(s.cache.Stats().Items))
+}
+
+func cmdInfo(s *Server, w *Writer, args [][]byte) error {
+	stats := s.cache.Stats()
+
+	var b strings.Builder
+	b.WriteString("# Stats\r\n")
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(&b, "cache_clears:%d\r\n", stats.Clears)
+	b.WriteString("\r\n# Memory\r\n")
+	fmt.Fprintf(&b, "used_memory:%d\r\n", stats.Size)
+	fmt.Fprintf(&b, "maxmemory:%d\r\n", stats.MaxSize)
+	b.WriteString("\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:keys=%d\r\n", stats.Items)
+
+	return w.WriteBulk([]byte(b.String()))
+}
+
+func cmdFlushAll(s *Server, w *Writer, args [][]byte) error {
+	// ASYNC/SYNC are accepted for compatibility, the cache always clears synchronously.
+	if len(args) == 1 {
+		switch strings.ToUpper(string(args[0])) {
+		case "ASYNC", "SYNC":
+		default:
+			return w.WriteError("ERR syntax error")
+		}
+	}
+
+	s.cache.Clear()
+	return w.WriteSimpleString("OK")
+}
+
+// valueBytes converts a cached value into the bytes sent back to clients.
+// Values set over RESP are strings, anything else set directly on the cache is formatted with fmt.
+func valueBytes(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	return []byte(fmt.Sprint(value))
+}
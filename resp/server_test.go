@@ -0,0 +1,58 @@
+package resp
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// startServer serves c on a local port until the test ends and returns its address.
+func startServer(t *testing.T, c *cache.Cache, opts ...ServerOption) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c, opts...)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	return l.Addr().String()
+}
+
+func TestUnauthenticatedLimits(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+	addr := startServer(t, c, WithRequirePass("secret"))
+
+	big := strings.Repeat("x", 100_000)
+
+	client, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// The server may close the connection before the client has read the error reply.
+	if _, err := client.Do("SET", "key", big); err == nil || errors.As(err, new(Error)) && !strings.Contains(err.Error(), "limit") {
+		t.Errorf("unauthenticated SET of a %d byte value returned %v, want a protocol error", len(big), err)
+	}
+
+	client, err = Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Do("AUTH", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do("SET", "key", big); err != nil {
+		t.Fatalf("authenticated SET of a %d byte value: %v", len(big), err)
+	}
+	if got, _ := c.Get("key"); got != big {
+		t.Errorf("SET stored %d bytes, want %d", len(got.(string)), len(big))
+	}
+}
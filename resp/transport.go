@@ -0,0 +1,230 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Transport is a cache.Transport that sends invalidations over Redis pub/sub.
+//
+// It uses two connections, one for publishing and one for the subscription, and reconnects
+// the subscription in the background if it drops. Invalidations sent while the
+// subscription is down are missed.
+type Transport struct {
+	addr     string
+	channel  string
+	password string
+
+	mu     sync.Mutex
+	pub    *Client
+	sub    net.Conn
+	closed bool
+	done   chan struct{}
+}
+
+// TransportOption configures a Transport.
+type TransportOption func(*Transport)
+
+// WithPassword authenticates with AUTH after connecting.
+func WithPassword(password string) TransportOption {
+	return func(t *Transport) {
+		t.password = password
+	}
+}
+
+// NewTransport creates a Transport publishing and subscribing to channel on the Redis server at addr.
+func NewTransport(addr, channel string, opts ...TransportOption) *Transport {
+	t := &Transport{
+		addr:    addr,
+		channel: channel,
+		done:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Publish implements cache.Transport.
+func (t *Transport) Publish(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return net.ErrClosed
+	}
+
+	// A pooled connection might have been closed by the server since it was last used,
+	// so one failure gets a fresh connection before giving up.
+	var err error
+	for range 2 {
+		if t.pub == nil {
+			if t.pub, err = t.dial(); err != nil {
+				return err
+			}
+		}
+
+		if _, err = t.pub.DoBytes([]byte("PUBLISH"), []byte(t.channel), msg); err == nil {
+			return nil
+		}
+
+		t.pub.Close()
+		t.pub = nil
+	}
+	return err
+}
+
+// Subscribe implements cache.Transport. It returns once the first subscription is confirmed.
+func (t *Transport) Subscribe(handler func(msg []byte)) error {
+	conn, r, err := t.subscribe()
+	if err != nil {
+		return err
+	}
+
+	go t.receive(conn, r, handler)
+	return nil
+}
+
+// Close implements cache.Transport.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+
+	var err error
+	if t.pub != nil {
+		err = t.pub.Close()
+	}
+	if t.sub != nil {
+		err = errors.Join(err, t.sub.Close())
+	}
+	return err
+}
+
+func (t *Transport) dial() (*Client, error) {
+	c, err := Dial(t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.password != "" {
+		if _, err := c.Do("AUTH", t.password); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// subscribe opens a new subscription connection and waits for the server to confirm it.
+func (t *Transport) subscribe() (net.Conn, *Reader, error) {
+	c, err := t.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.w.WriteCommand([]byte("SUBSCRIBE"), []byte(t.channel)); err == nil {
+		err = c.w.Flush()
+	}
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	reply, err := c.r.ReadValue()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	if kind, _, _ := pushMessage(reply); kind != "subscribe" {
+		c.Close()
+		return nil, nil, fmt.Errorf("unexpected reply to SUBSCRIBE: %v", reply)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		c.Close()
+		return nil, nil, net.ErrClosed
+	}
+	t.sub = c.conn
+
+	return c.conn, c.r, nil
+}
+
+// receive delivers messages to handler, resubscribing with backoff whenever the connection drops.
+func (t *Transport) receive(conn net.Conn, r *Reader, handler func(msg []byte)) {
+	delay := minReconnectDelay
+
+	for {
+		err := t.readMessages(r, handler)
+
+		conn.Close()
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+		log.Printf("resp: invalidation subscription to %s lost: %v", t.addr, err)
+
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-time.After(delay):
+			}
+
+			if conn, r, err = t.subscribe(); err == nil {
+				delay = minReconnectDelay
+				break
+			}
+			log.Printf("resp: resubscribing to %s: %v", t.addr, err)
+
+			delay = min(delay*2, maxReconnectDelay)
+		}
+	}
+}
+
+func (t *Transport) readMessages(r *Reader, handler func(msg []byte)) error {
+	for {
+		reply, err := r.ReadValue()
+		if err != nil {
+			return err
+		}
+
+		if kind, channel, payload := pushMessage(reply); kind == "message" && channel == t.channel {
+			handler(payload)
+		}
+	}
+}
+
+// pushMessage splits a pub/sub push such as ["message", channel, payload] into its parts.
+func pushMessage(reply any) (kind, channel string, payload []byte) {
+	values, ok := reply.([]any)
+	if !ok || len(values) != 3 {
+		return "", "", nil
+	}
+
+	kindBytes, _ := values[0].([]byte)
+	channelBytes, _ := values[1].([]byte)
+	payload, _ = values[2].([]byte)
+
+	return string(kindBytes), string(channelBytes), payload
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+)
+
+// maxRetainedFraction bounds how much of maxCacheSize the items kept by WithRetainHot can take
+// up, so keeping them doesn't leave the cache about to clear again.
+const maxRetainedFraction = 0.5
+
+// WithRetainHot keeps the n most read items when the cache clears itself for going over
+// maxCacheSize, instead of starting completely cold. It's a middle ground between clearing and
+// WithSoftLimit's eviction: the cost is one pass over the cache when it clears, and nothing on
+// reads or writes.
+//
+// Items are ranked by hits since they were set, and expired items are never kept. However many
+// items are asked for, the kept items are never more than half of maxCacheSize, and their hits
+// are halved, so items that stop being read are eventually let go.
+func WithRetainHot(n int) Option {
+	return func(c *Cache) {
+		c.retainHot = n
+	}
+}
+
+// WithRetainHotFraction is like WithRetainHot, but keeps fraction of the items in the cache
+// when it clears, e.g. 0.1 for the hottest 10%. If both are set, whichever keeps more applies.
+func WithRetainHotFraction(fraction float64) Option {
+	return func(c *Cache) {
+		c.retainHotFraction = fraction
+	}
+}
+
+// retainsHot reports whether self clears keep some items. c.mu must already be locked.
+func (c *Cache) retainsHot() bool {
+	return c.retainHot > 0 || c.retainHotFraction > 0
+}
+
+// retainedItem is an item kept across a clear.
+type retainedItem struct {
+	key    string
+	stored any
+	e      entry
+}
+
+// hottest returns the items WithRetainHot keeps, hottest first. c.mu must already be locked.
+func (c *Cache) hottest() []retainedItem {
+	n := max(c.retainHot, int(float64(len(c.items))*c.retainHotFraction))
+	if n <= 0 {
+		return nil
+	}
+
+	now := c.now()
+
+	candidates := make([]retainedItem, 0, len(c.items))
+	for key, i := range c.all() {
+		if e := c.arena.entry(i); !e.expired(now) {
+			candidates = append(candidates, retainedItem{key: key, stored: c.arena.value(i), e: *e})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b retainedItem) int {
+		return cmp.Or(cmp.Compare(b.e.hits, a.e.hits), cmp.Compare(b.e.createdAt, a.e.createdAt), cmp.Compare(a.key, b.key))
+	})
+
+	budget := int64(float64(c.maxCacheSize) * maxRetainedFraction)
+
+	var size int64
+	for i, item := range candidates {
+		size += int64(len(item.key)) + item.e.size
+		if i == n || size > budget {
+			return candidates[:i]
+		}
+	}
+	return candidates
+}
+
+// clearRetainingHot is clear for self clears WithRetainHot, and returns how many items it kept.
+// Subscribers are sent EventClear followed by an EventSet for each kept item, so anything
+// mirroring the cache ends up with the same items. c.mu must already be locked.
+func (c *Cache) clearRetainingHot() int {
+	kept := c.hottest()
+
+	keep := make(map[string]bool, len(kept))
+	for _, item := range kept {
+		keep[item.key] = true
+	}
+	c.dropAllExcept(keep)
+	c.reset()
+
+	// Peers aren't told about size-triggered clears since their copies are still valid.
+	c.notifySubscribers(Event{Type: EventClear, Reason: ReasonEvicted})
+
+	for _, item := range kept {
+		item.e.hits = atomic.LoadInt64(&item.e.hits) / 2
+		item.e.expiryIndex = 0
+
+		i := c.insert(item.key, item.stored, item.e)
+		c.totalCacheSize += int64(len(item.key)) + item.e.size
+		c.expiryChanged(item.key, i)
+		c.joinGeneration(item.key, c.arena.entry(i))
+
+		if len(c.subscribers) > 0 {
+			if value, ok := c.output(item.key, item.stored); ok {
+				c.notifySubscribers(setEvent(item.key, value, &item.e))
+			}
+		}
+	}
+	return len(kept)
+}
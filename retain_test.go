@@ -0,0 +1,78 @@
+package cache_test
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+func TestRetainHot(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	c := cache.New(2000, cache.WithLogger(nil), cache.WithClock(clock), cache.WithRetainHot(3))
+	defer c.Close()
+
+	// The most read item of all, but it's expired by the time the cache clears.
+	c.SetWithTTL("expired", "value", time.Minute)
+	for range 1000 {
+		c.Get("expired")
+	}
+	clock.Advance(2 * time.Minute)
+
+	var events []cache.Event
+	defer c.Subscribe(func(ev cache.Event) {
+		if ev.Type != cache.EventSet || len(events) > 0 {
+			events = append(events, ev)
+		}
+	})()
+
+	fillUntilCleared(t, c)
+
+	if len(events) == 0 || events[0].Type != cache.EventClear {
+		t.Fatalf("events = %v, want EventClear first", events)
+	}
+	var kept []string
+	for _, ev := range events[1:] {
+		if ev.Type != cache.EventSet {
+			t.Fatalf("events after the clear = %v, want only EventSets for the kept items", events[1:])
+		}
+		kept = append(kept, ev.Key)
+	}
+	if want := []string{"key0", "key1", "key2"}; !slices.Equal(kept, want) {
+		t.Errorf("kept %q, want %q", kept, want)
+	}
+	if stats := c.Stats(); stats.Items != 3 {
+		t.Errorf("cache has %d items after the clear, want 3", stats.Items)
+	}
+	if err := c.Healthy(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetainHotBudget(t *testing.T) {
+	c := cache.New(2000, cache.WithLogger(nil), cache.WithRetainHot(100))
+	defer c.Close()
+
+	value := strings.Repeat("x", 50)
+	for i := range 30 {
+		key := "key" + strconv.Itoa(i)
+		c.Set(key, value)
+		c.Get(key)
+	}
+	if c.Stats().Clears != 0 {
+		t.Fatal("cache cleared before it was full")
+	}
+	c.Set("big", strings.Repeat("x", 500))
+
+	stats := c.Stats()
+	if stats.Clears != 1 {
+		t.Fatalf("cache cleared %d times, want 1", stats.Clears)
+	}
+	if stats.Items == 0 || stats.Size > 1000 {
+		t.Errorf("kept %d items of %d bytes, want some but no more than half of 2000 bytes", stats.Items, stats.Size)
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+const (
+	// s3fifoSmallShare is the fraction of items, as 1 in this many, that EvictS3FIFO keeps in its
+	// small queue before moving or evicting them.
+	s3fifoSmallShare = 10
+
+	// s3fifoMaxFreq is the most reads EvictS3FIFO counts for an item, so a formerly popular item
+	// only survives a few trips through the main queue once it stops being read.
+	s3fifoMaxFreq = 3
+)
+
+var ghostSeed = maphash.MakeSeed()
+
+// s3fifo is the bookkeeping for EvictS3FIFO: the small and main queues, the ghost queue of keys
+// recently evicted from the small one, and for every slot its key, which queue it's in and how
+// many times it's been read, up to s3fifoMaxFreq. The read counts are updated while c.mu is
+// only read locked, so they're accessed atomically; everything else needs c.mu locked.
+type s3fifo struct {
+	small, main slotQueue
+	ghost       ghostQueue
+
+	keys   []string
+	freq   []uint32
+	inMain []bool
+}
+
+// add puts a new item in the small queue, or straight in the main queue if it was evicted from
+// the small one recently, since it was wanted again soon after.
+func (s *s3fifo) add(key string, i uint32) {
+	for uint32(len(s.keys)) <= i {
+		s.keys = append(s.keys, "")
+		s.freq = append(s.freq, 0)
+		s.inMain = append(s.inMain, false)
+	}
+	s.keys[i] = key
+	s.freq[i] = 0
+
+	s.inMain[i] = s.ghost.take(maphash.String(ghostSeed, key))
+	if s.inMain[i] {
+		s.main.push(i)
+	} else {
+		s.small.push(i)
+	}
+}
+
+func (s *s3fifo) visit(i uint32) {
+	if f := atomic.LoadUint32(&s.freq[i]); f < s3fifoMaxFreq {
+		atomic.CompareAndSwapUint32(&s.freq[i], f, f+1)
+	}
+}
+
+func (s *s3fifo) remove(i uint32) {
+	if s.inMain[i] {
+		s.main.unlink(i)
+	} else {
+		s.small.unlink(i)
+	}
+	s.keys[i] = ""
+}
+
+func (s *s3fifo) reset() {
+	*s = s3fifo{}
+}
+
+func (s *s3fifo) len() int {
+	return s.small.len + s.main.len
+}
+
+// victim evicts from the small queue while it holds more than its share of items, and from the
+// main queue otherwise. Items at the tail of the small queue that were read while in it move
+// to the main queue instead of being evicted, and the rest are remembered in the ghost queue.
+// Items at the tail of the main queue go back to its head, one read fewer, until they run out
+// of reads. Expired items are evicted as soon as they get to the tail of either queue.
+func (s *s3fifo) victim(a *arena, now int64) (string, uint32) {
+	for {
+		if s.small.len > 0 && (s.small.len*s3fifoSmallShare >= s.len() || s.main.len == 0) {
+			i, _ := s.small.oldest()
+			switch {
+			case a.entry(i).expired(now):
+				return s.keys[i], i
+			case atomic.LoadUint32(&s.freq[i]) > 0:
+				s.small.unlink(i)
+				s.main.push(i)
+				s.inMain[i] = true
+				atomic.StoreUint32(&s.freq[i], 0)
+				continue
+			}
+
+			s.ghost.add(maphash.String(ghostSeed, s.keys[i]), s.len())
+			return s.keys[i], i
+		}
+
+		i, _ := s.main.oldest()
+		if f := atomic.LoadUint32(&s.freq[i]); f > 0 && !a.entry(i).expired(now) {
+			atomic.StoreUint32(&s.freq[i], f-1)
+			s.main.unlink(i)
+			s.main.push(i)
+			continue
+		}
+		return s.keys[i], i
+	}
+}
+
+// ghostQueue is a FIFO of hashes of keys, without their values, holding at most as many as the
+// limit it was last added to with. Hashes that are taken out of the middle are only forgotten
+// by the map, and their place in the order is skipped when it comes up.
+type ghostQueue struct {
+	seq   uint64
+	added map[uint64]uint64 // hash to the seq it was added at
+	order []ghostNode
+	start int
+}
+
+type ghostNode struct {
+	hash uint64
+	seq  uint64
+}
+
+// add adds hash, dropping the oldest hashes until there are at most limit.
+func (g *ghostQueue) add(hash uint64, limit int) {
+	if g.added == nil {
+		g.added = make(map[uint64]uint64)
+	}
+	g.seq++
+	g.added[hash] = g.seq
+	g.order = append(g.order, ghostNode{hash: hash, seq: g.seq})
+
+	for len(g.added) > limit && g.start < len(g.order) {
+		n := g.order[g.start]
+		g.start++
+		if g.added[n.hash] == n.seq {
+			delete(g.added, n.hash)
+		}
+	}
+
+	// Reclaim the dropped part of order once it's most of it.
+	if g.start > len(g.order)/2 {
+		g.order = append(g.order[:0], g.order[g.start:]...)
+		g.start = 0
+	}
+}
+
+// take reports whether hash is in the queue, and removes it if it is.
+func (g *ghostQueue) take(hash uint64) bool {
+	if _, ok := g.added[hash]; !ok {
+		return false
+	}
+	delete(g.added, hash)
+	return true
+}
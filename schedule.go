@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when WithClearSchedule clears the cache.
+type Schedule interface {
+	// Next returns the first time after t the cache should be cleared, or the zero time if it
+	// shouldn't be cleared again.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule that clears the cache every d, starting d after it's created.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (d every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+func (d every) String() string {
+	return "@every " + time.Duration(d).String()
+}
+
+// cronSchedule matches times whose fields are set in its bitsets, like cron.
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+func (s *cronSchedule) String() string {
+	return s.spec
+}
+
+// cronDescriptors are the shorthands ParseSchedule accepts instead of five fields.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseSchedule parses a standard five field cron expression, "minute hour day-of-month month
+// day-of-week", e.g. "0 3 * * *" for 3am every day, in local time. Fields can be *, numbers,
+// ranges like 1-5, lists like 1,15, and steps like */15. Months and days of the week can also
+// be names, like jan or mon. Like cron, if both the day of the month and day of the week are
+// restricted, a time matching either is enough.
+//
+// It also accepts "@every <duration>", like "@every 6h", and @yearly, @monthly, @weekly,
+// @daily (or @midnight) and @hourly.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("cache: invalid schedule %q: interval must be a positive duration", spec)
+		}
+		return Every(interval), nil
+	}
+
+	expr := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expr, ok = cronDescriptors[spec]; !ok {
+			return nil, fmt.Errorf("cache: invalid schedule %q: unknown descriptor", spec)
+		}
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cache: invalid schedule %q: want 5 fields, got %d", spec, len(fields))
+	}
+
+	s := &cronSchedule{spec: spec}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+		names    []string
+		name     string
+	}{
+		{&s.minute, 0, 59, nil, "minute"},
+		{&s.hour, 0, 23, nil, "hour"},
+		{&s.dom, 1, 31, nil, "day of month"},
+		{&s.month, 1, 12, monthNames, "month"},
+		{&s.dow, 0, 7, dayNames, "day of week"},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("cache: invalid schedule %q: %s: %w", spec, f.name, err)
+		}
+	}
+
+	// 7 is also Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+	return s, nil
+}
+
+// parseCronField returns a bitset of the values field matches.
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseCronValue(loStr, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseCronValue(hiStr, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			// Month names start at 1, day names at 0.
+			return i + min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q, must be %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+// Next implements Schedule.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+
+	// If nothing matches within a few years, like for "0 0 30 2 *", nothing ever will.
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// clearSchedule is a schedule passed to WithClearSchedule and the namespaces it clears.
+type clearSchedule struct {
+	schedule   Schedule
+	namespaces []string
+}
+
+// WithScheduledClear clears the cache on the schedule spec, parsed with ParseSchedule, e.g.
+// "0 3 * * *" for 3am every day or "@every 24h", for data that has to be refreshed on a
+// schedule regardless of its TTL or the cache's size. If namespaces are given, only they're
+// cleared, as with ClearNamespace.
+//
+// An invalid spec is logged and the schedule ignored. To handle the error instead, use
+// ParseSchedule and pass the result to WithClearSchedule.
+func WithScheduledClear(spec string, namespaces ...string) Option {
+	return func(c *Cache) {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			c.logf("ignoring scheduled clear: %v", err)
+			return
+		}
+		WithClearSchedule(schedule, namespaces...)(c)
+	}
+}
+
+// WithClearSchedule clears the cache, or just namespaces if any are given, whenever schedule says.
+// Scheduled clears are timed with the cache's Clock, and stop when the cache is closed.
+func WithClearSchedule(schedule Schedule, namespaces ...string) Option {
+	return func(c *Cache) {
+		c.schedules = append(c.schedules, clearSchedule{schedule: schedule, namespaces: namespaces})
+	}
+}
+
+// startSchedules starts a goroutine for each schedule. It's called once every option has been
+// applied, so the goroutines don't race with WithClock.
+func (c *Cache) startSchedules() {
+	for _, s := range c.schedules {
+		go c.runSchedule(s)
+	}
+}
+
+func (c *Cache) runSchedule(s clearSchedule) {
+	for {
+		now := time.Unix(0, c.now())
+		next := s.schedule.Next(now)
+		if next.IsZero() {
+			return
+		}
+
+		timer := c.newTimer(next.Sub(now))
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		c.scheduledClear(s.namespaces)
+	}
+}
+
+func (c *Cache) scheduledClear(namespaces []string) {
+	if len(namespaces) > 0 {
+		for _, namespace := range namespaces {
+			c.ClearNamespace(namespace)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clear()
+
+	// Every instance clears on its own schedule, so peers aren't told.
+	c.notifySubscribers(Event{Type: EventClear})
+
+	c.logf("scheduled clear. size reset to 0 bytes.")
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// WithSeedFile loads the items in the seed file at path before New returns, for CLIs and batch
+// jobs that want the same warm start every time. See LoadSeedFile for the format. If the file
+// can't be read, the error is logged and the cache starts empty; to handle it instead, call
+// LoadSeedFile after New.
+func WithSeedFile(path string) Option {
+	return func(c *Cache) {
+		c.seedFile = path
+	}
+}
+
+// LoadSeedFile adds the items in the seed file at path to the cache, with the default TTL, and
+// returns how many it added. The file is a map of keys to values, as a JSON object, or gob
+// encoded if path ends in ".gob". Gob values' types have to be registered with gob.Register,
+// and JSON values are decoded the way encoding/json decodes into an any, e.g. numbers as float64.
+//
+// Items are added in key order, so loading the same file always leaves the cache in the same
+// state. Like loaded values, they aren't written to a store the cache writes through to.
+func (c *Cache) LoadSeedFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("cache: loading seed file: %w", err)
+	}
+	defer f.Close()
+
+	var items map[string]any
+	if filepath.Ext(path) == ".gob" {
+		err = gob.NewDecoder(f).Decode(&items)
+	} else {
+		err = json.NewDecoder(f).Decode(&items)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cache: decoding seed file %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var (
+		n    int
+		errs []error
+	)
+	for _, key := range keys {
+		if err := c.setLocal(key, items[key], c.expiresAt(time.Duration(c.defaultTTL.Load())), true); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// loadSeedFile loads the file from WithSeedFile. Like startSchedules, it's called once every
+// option has been applied, so the seed goes through the cache's serializer, TTL and so on.
+func (c *Cache) loadSeedFile() {
+	if c.seedFile == "" {
+		return
+	}
+
+	n, err := c.LoadSeedFile(c.seedFile)
+	if err != nil {
+		c.logf("error loading seed file: %v", err)
+	}
+	if n > 0 {
+		c.logf("loaded %d items from seed file %s", n, c.seedFile)
+	}
+}
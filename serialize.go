@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Serializer converts values to and from bytes for WithSerializer.
+type Serializer interface {
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte) (any, error)
+}
+
+// WithSerializer stores every value other than []byte and string serialized with s, and
+// deserializes it on every Get. It costs CPU and an allocation per Get, but sizes are exact
+// instead of estimated, callers can never alias cached values, and the garbage collector only
+// sees a byte slice per item instead of whatever graph of pointers the values were made of.
+//
+// Serialized values can also be compressed and encrypted, see WithCompression and WithEncryption.
+// Values s can't marshal aren't cached, Set logs why and SetE returns the error.
+func WithSerializer(s Serializer) Option {
+	return func(c *Cache) {
+		c.serializer = s
+	}
+}
+
+// GobSerializer is a Serializer using encoding/gob. Like any gob encoding of interface values,
+// every concrete type that's cached has to be registered with gob.Register first.
+type GobSerializer struct{}
+
+func (GobSerializer) Marshal(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobSerializer) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
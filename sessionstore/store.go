@@ -0,0 +1,124 @@
+// Package sessionstore keeps web sessions in a cache.Cache. Store implements the Store,
+// CtxStore and IterableStore interfaces of github.com/alexedwards/scs, so it can be used as
+// an SCS session backend without this module depending on SCS:
+//
+//	sessionManager := scs.New()
+//	sessionManager.Store = sessionstore.New(cache.New(64 << 20))
+//
+// Sessions expire with the expiry SCS commits them with. Remember that the cache clears
+// itself when it's full, which logs everyone out, so give it room to spare, or use
+// cache.WithSoftLimit to evict the oldest sessions instead.
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultKeyPrefix is put in front of every session token unless changed with WithKeyPrefix.
+const DefaultKeyPrefix = "session:"
+
+// ErrTooLarge is returned by Commit for sessions bigger than the limit set with WithMaxSessionSize.
+var ErrTooLarge = errors.New("sessionstore: session too large")
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithKeyPrefix changes the prefix put in front of every session token, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithMaxSessionSize makes Commit reject sessions whose encoded data is bigger than n bytes,
+// so a single session can't take over the cache.
+func WithMaxSessionSize(n int) Option {
+	return func(s *Store) {
+		s.maxSize = n
+	}
+}
+
+// Store is an SCS session store backed by a cache.
+type Store struct {
+	cache   *cache.Cache
+	prefix  string
+	maxSize int
+}
+
+// New creates a Store keeping sessions in c.
+func New(c *cache.Cache, opts ...Option) *Store {
+	s := &Store{cache: c, prefix: DefaultKeyPrefix}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Find returns the data of the session with token, and false if there isn't one or it's expired.
+func (s *Store) Find(token string) ([]byte, bool, error) {
+	b, err := s.cache.GetBytesE(s.prefix + token)
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit stores the session with token, replacing any existing one, until expiry.
+func (s *Store) Commit(token string, b []byte, expiry time.Time) error {
+	if s.maxSize > 0 && len(b) > s.maxSize {
+		return fmt.Errorf("%w: %d bytes, max is %d bytes", ErrTooLarge, len(b), s.maxSize)
+	}
+
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return s.Delete(token)
+	}
+	return s.cache.SetWithTTLE(s.prefix+token, b, ttl)
+}
+
+// Delete removes the session with token, if there is one.
+func (s *Store) Delete(token string) error {
+	return s.cache.DeleteE(s.prefix + token)
+}
+
+// All returns the data of every session that hasn't expired, keyed by token.
+func (s *Store) All() (map[string][]byte, error) {
+	sessions := make(map[string][]byte)
+	s.cache.Range(func(item cache.Item) bool {
+		token, ok := strings.CutPrefix(item.Key, s.prefix)
+		if b, isBytes := item.Value.([]byte); ok && isBytes {
+			sessions[token] = b
+		}
+		return true
+	})
+	return sessions, nil
+}
+
+// FindCtx is Find, for SCS's CtxStore interface.
+func (s *Store) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	return s.Find(token)
+}
+
+// CommitCtx is Commit, for SCS's CtxStore interface.
+func (s *Store) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	return s.Commit(token, b, expiry)
+}
+
+// DeleteCtx is Delete, for SCS's CtxStore interface.
+func (s *Store) DeleteCtx(ctx context.Context, token string) error {
+	return s.Delete(token)
+}
+
+// AllCtx is All, for SCS's IterableStore interface.
+func (s *Store) AllCtx(ctx context.Context) (map[string][]byte, error) {
+	return s.All()
+}
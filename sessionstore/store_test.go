@@ -0,0 +1,114 @@
+package sessionstore
+
+import (
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+	"github.com/radovskyb/self-clearing-in-memory-cache/clocktest"
+)
+
+func newStore(t *testing.T, opts ...Option) (*Store, *cache.Cache, *clocktest.Clock) {
+	t.Helper()
+
+	clock := clocktest.New(time.Now())
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithClock(clock))
+	t.Cleanup(func() { c.Close() })
+	return New(c, opts...), c, clock
+}
+
+func TestCommitAndFind(t *testing.T) {
+	s, c, _ := newStore(t)
+
+	if _, found, err := s.Find("token"); found || err != nil {
+		t.Fatalf("Find of a missing session returned %v, %v", found, err)
+	}
+
+	if err := s.Commit("token", []byte("data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if b, found, err := s.Find("token"); !found || err != nil || string(b) != "data" {
+		t.Errorf("Find returned %q, %v, %v", b, found, err)
+	}
+	if !c.Has(DefaultKeyPrefix + "token") {
+		t.Errorf("session isn't stored under %q", DefaultKeyPrefix+"token")
+	}
+
+	if err := s.Commit("token", []byte("new data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if b, _, _ := s.Find("token"); string(b) != "new data" {
+		t.Errorf("Find after committing again returned %q", b)
+	}
+
+	if err := s.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("token"); found {
+		t.Error("deleted session was found")
+	}
+}
+
+func TestCommitExpired(t *testing.T) {
+	s, _, _ := newStore(t)
+
+	// Committing a session that's already expired, like a cookie with MaxAge < 0, deletes it.
+	s.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	if err := s.Commit("token", []byte("data"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("token"); found {
+		t.Error("session committed with a past expiry was found")
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	s, _, clock := newStore(t)
+
+	s.Commit("token", []byte("data"), time.Now().Add(time.Minute))
+	clock.Advance(30 * time.Second)
+	if _, found, _ := s.Find("token"); !found {
+		t.Fatal("session wasn't found before it expired")
+	}
+	clock.Advance(time.Minute)
+	if _, found, _ := s.Find("token"); found {
+		t.Error("session was found after it expired")
+	}
+	if sessions, _ := s.All(); len(sessions) != 0 {
+		t.Errorf("All returned expired sessions: %v", sessions)
+	}
+}
+
+func TestMaxSessionSize(t *testing.T) {
+	s, _, _ := newStore(t, WithMaxSessionSize(4))
+
+	if err := s.Commit("small", []byte("data"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit("big", []byte("data!"), time.Now().Add(time.Hour)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("committing a session past the limit returned %v, want ErrTooLarge", err)
+	}
+	if _, found, _ := s.Find("big"); found {
+		t.Error("session past the limit was stored")
+	}
+}
+
+func TestAll(t *testing.T) {
+	s, c, _ := newStore(t, WithKeyPrefix("s:"))
+
+	s.Commit("a", []byte("1"), time.Now().Add(time.Hour))
+	s.Commit("b", []byte("2"), time.Now().Add(time.Hour))
+	c.Set("other", []byte("not a session"))
+
+	sessions, err := s.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"a": []byte("1"), "b": []byte("2")}; !maps.EqualFunc(sessions, want, func(a, b []byte) bool {
+		return string(a) == string(b)
+	}) {
+		t.Errorf("All returned %q, want %q", sessions, want)
+	}
+}
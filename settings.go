@@ -0,0 +1,105 @@
+package cache
+
+import "time"
+
+// The setters below change the cache's configuration while it's in use, e.g. to tune limits
+// during an incident without losing everything in it. They're safe to call concurrently with
+// any other method, and take effect for every operation that starts after they return.
+
+// SetMaxCacheSize changes the size at which the cache clears, e.g. when a container's memory
+// limit changes. If the cache is already bigger than maxCacheSize, items are evicted until
+// it fits before SetMaxCacheSize returns, rather than clearing everything.
+//
+// If the cache was created WithSoftLimit, the soft limit is scaled by the same ratio. If it
+// was created WithDeferredEviction, the items are evicted in the background instead.
+func (c *Cache) SetMaxCacheSize(maxCacheSize int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logf("max cache size changed from %d to %d bytes", c.maxCacheSize, maxCacheSize)
+
+	if c.evictor != nil && c.maxCacheSize > 0 {
+		c.softLimit = int64(float64(c.softLimit) * float64(maxCacheSize) / float64(c.maxCacheSize))
+	}
+	c.maxCacheSize = maxCacheSize
+
+	if c.totalCacheSize > maxCacheSize && !c.deferEviction {
+		evicted := c.evict(maxCacheSize, 0)
+		c.logf("evicted %d items to fit the new max size. current cache size: %d bytes", evicted, c.totalCacheSize)
+	}
+	c.signalEvictor()
+}
+
+// SetSoftLimit changes the size the cache evicts down to in the background, see WithSoftLimit,
+// and starts evicting straight away if it's already past it. 0 turns it off, and the cache
+// clears when it hits maxCacheSize again, unless it was created WithDeferredEviction or with
+// another overflow policy.
+func (c *Cache) SetSoftLimit(softLimit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logf("soft limit changed from %d to %d bytes", c.softLimit, softLimit)
+
+	c.softLimit = softLimit
+	switch {
+	case c.closed.Load():
+	case softLimit > 0:
+		c.startEvictor()
+	case !c.deferEviction && c.evictor != nil:
+		close(c.evictor)
+		c.evictor = nil
+	}
+	c.signalEvictor()
+}
+
+// SetEvictionPolicy changes which items are evicted first, see WithEvictionPolicy. Switching to
+// EvictSieve or EvictS3FIFO queues the items already in the cache oldest first, as if none of
+// them had been read yet.
+func (c *Cache) SetEvictionPolicy(policy EvictionPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logf("eviction policy changed from %s to %s", c.evictionPolicy, policy)
+
+	c.evictionPolicy = policy
+	c.requeue()
+}
+
+// SetDefaultTTL changes the TTL used by Set and loaders, see WithDefaultTTL.
+// Items already in the cache keep the expiry they were set with.
+func (c *Cache) SetDefaultTTL(ttl time.Duration) {
+	c.defaultTTL.Store(int64(ttl))
+}
+
+// SetStaleWhileRevalidate changes how long expired items keep being served, see WithStaleWhileRevalidate.
+// 0 turns it off.
+func (c *Cache) SetStaleWhileRevalidate(maxStale time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxStale = maxStale
+}
+
+// SetRefreshAhead changes how far through their TTL items are refreshed, see WithRefreshAhead.
+// 0 turns it off.
+func (c *Cache) SetRefreshAhead(fraction float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refreshAhead = fraction
+	if !c.replicates() {
+		c.dropHotKeys()
+	}
+}
+
+// SetNegativeCaching changes how long loader errors are cached, see WithNegativeCaching.
+// 0 turns it off and forgets every error already cached.
+func (c *Cache) SetNegativeCaching(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.negativeTTL = ttl
+	if ttl <= 0 {
+		c.forgetErrors("")
+	}
+}
@@ -0,0 +1,73 @@
+package cache_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestSetEvictionPolicy(t *testing.T) {
+	for _, policy := range queuedPolicies {
+		t.Run(policy.String(), func(t *testing.T) {
+			c := cache.New(1<<20, cache.WithLogger(nil))
+			defer c.Close()
+
+			for i := range 20 {
+				c.Set("key"+strconv.Itoa(i), "value")
+			}
+			c.SetEvictionPolicy(policy)
+			if err := c.Healthy(); err != nil {
+				t.Fatalf("after switching: %v", err)
+			}
+
+			// The oldest keys are the hot ones, so evicting the oldest would drop them first.
+			for i := range 5 {
+				c.Get("key" + strconv.Itoa(i))
+				c.Get("key" + strconv.Itoa(i))
+			}
+			c.SetMaxCacheSize(c.Stats().Size * 6 / 10)
+
+			if stats := c.Stats(); stats.Evictions == 0 || stats.Clears != 0 {
+				t.Fatalf("shrinking didn't evict: %+v", stats)
+			}
+			for i := range 5 {
+				if _, found := c.Get("key" + strconv.Itoa(i)); !found {
+					t.Errorf("hot key%d was evicted", i)
+				}
+			}
+			if err := c.Healthy(); err != nil {
+				t.Fatalf("after evicting: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetSoftLimit(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	defer c.Close()
+
+	for i := range 100 {
+		c.Set("key"+strconv.Itoa(i), "value")
+	}
+	size := c.Stats().Size
+
+	c.SetSoftLimit(size / 2)
+	for deadline := time.Now().Add(5 * time.Second); c.Stats().Size > size/2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache is still %d bytes, want at most %d", c.Stats().Size, size/2)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := c.Stats(); stats.Clears != 0 || stats.Evictions == 0 {
+		t.Errorf("cache cleared instead of evicting down to the soft limit: %+v", stats)
+	}
+
+	c.SetSoftLimit(0)
+	c.SetMaxCacheSize(c.Stats().Size)
+	c.Set("past the limit", "value")
+	if stats := c.Stats(); stats.Clears != 1 {
+		t.Errorf("with the soft limit off, the cache cleared %d times, want 1", stats.Clears)
+	}
+}
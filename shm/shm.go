@@ -0,0 +1,43 @@
+// Package shm is a self-clearing cache for []byte values kept in a shared memory segment,
+// so worker processes on the same host (prefork servers, multiprocess deployments) share one
+// cache instead of each holding their own copy.
+//
+// Every process opens the same file, usually under /dev/shm:
+//
+//	c, err := shm.Open("/dev/shm/myapp-cache", 64<<20)
+//
+// Entries are appended to the segment and overwritten or deleted entries aren't reclaimed,
+// so like the in-memory cache, the whole segment is cleared once it fills up.
+package shm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnsupported is returned by Open on platforms without mmap and flock.
+var ErrUnsupported = errors.New("shm: shared memory isn't supported on this platform")
+
+// ErrClosed is returned by Close if the segment has already been closed. Other methods of a
+// closed Cache miss or do nothing.
+var ErrClosed = errors.New("shm: closed")
+
+// ErrIncompatible is returned by Open when the file exists but isn't a segment created by this package.
+var ErrIncompatible = errors.New("shm: file isn't a compatible cache segment")
+
+// Stats is a snapshot of a segment's usage, shared by every process using it.
+type Stats struct {
+	Items    int64
+	Size     int64
+	Capacity int64
+	Hits     int64
+	Misses   int64
+	Clears   int64
+}
+
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd)
+
+package shm
+
+import "time"
+
+// Cache is a cache for []byte values backed by a shared memory segment.
+// It isn't supported on this platform.
+type Cache struct{}
+
+// Open always returns ErrUnsupported on this platform.
+func Open(path string, size int64) (*Cache, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Cache) Get(key string) ([]byte, bool)                          { return nil, false }
+func (c *Cache) Set(key string, value []byte)                           {}
+func (c *Cache) SetWithTTL(key string, value []byte, ttl time.Duration) {}
+func (c *Cache) Delete(key string)                                      {}
+func (c *Cache) Clear()                                                 {}
+func (c *Cache) Stats() Stats                                           { return Stats{} }
+func (c *Cache) Close() error                                           { return ErrUnsupported }
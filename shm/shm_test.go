@@ -0,0 +1,150 @@
+package shm_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/radovskyb/self-clearing-in-memory-cache/shm"
+)
+
+// open opens a new segment of size bytes in a temporary directory, skipping the test on
+// platforms without shared memory.
+func open(t *testing.T, size int64) (*shm.Cache, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "segment")
+	c, err := shm.Open(path, size)
+	if errors.Is(err, shm.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, path
+}
+
+func TestCache(t *testing.T) {
+	c, _ := open(t, 1<<20)
+
+	c.Set("key", []byte("value"))
+	c.Set("other", []byte("value"))
+	if got, found := c.Get("key"); !found || string(got) != "value" {
+		t.Errorf("Get returned %q, %v", got, found)
+	}
+
+	c.Set("key", []byte("new value"))
+	if got, _ := c.Get("key"); string(got) != "new value" {
+		t.Errorf("Get after replacing returned %q", got)
+	}
+
+	c.Delete("key")
+	if _, found := c.Get("key"); found {
+		t.Error("deleted key was found")
+	}
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.Items != 1 || stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats = %+v, want 1 item, 2 hits and 2 misses", stats)
+	}
+
+	c.Clear()
+	if _, found := c.Get("other"); found {
+		t.Error("key was found after clearing")
+	}
+	if stats := c.Stats(); stats.Items != 0 || stats.Size != 0 || stats.Clears != 1 {
+		t.Errorf("Stats after clearing = %+v", stats)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, _ := open(t, 1<<20)
+
+	c.SetWithTTL("key", []byte("value"), 20*time.Millisecond)
+	if _, found := c.Get("key"); !found {
+		t.Fatal("key wasn't found before it expired")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, found := c.Get("key"); found {
+		t.Error("key was found after it expired")
+	}
+}
+
+func TestCacheShared(t *testing.T) {
+	a, path := open(t, 1<<20)
+
+	// A second Open of the same file stands in for another process.
+	b, err := shm.Open(path, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	a.Set("key", []byte("value"))
+	if got, found := b.Get("key"); !found || string(got) != "value" {
+		t.Errorf("other process got %q, %v", got, found)
+	}
+	b.Delete("key")
+	if _, found := a.Get("key"); found {
+		t.Error("key deleted by the other process was found")
+	}
+}
+
+func TestCacheFull(t *testing.T) {
+	c, _ := open(t, 64<<10)
+	capacity := c.Stats().Capacity
+
+	value := []byte(strings.Repeat("x", 1000))
+	for i := int64(0); i*1000 <= capacity; i++ {
+		c.Set("key", value)
+	}
+	if stats := c.Stats(); stats.Clears != 1 || stats.Items != 1 {
+		t.Errorf("Stats after filling the segment = %+v, want 1 clear and the last item", stats)
+	}
+	if _, found := c.Get("key"); !found {
+		t.Error("the item set when the segment cleared was lost")
+	}
+
+	c.Set("huge", make([]byte, capacity))
+	if _, found := c.Get("huge"); found {
+		t.Error("value bigger than the segment was cached")
+	}
+}
+
+func TestOpenIncompatible(t *testing.T) {
+	open(t, 1<<20) // skips the test where it's unsupported
+
+	junk := filepath.Join(t.TempDir(), "junk")
+	if err := os.WriteFile(junk, make([]byte, 1<<20), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shm.Open(junk, 1<<20); !errors.Is(err, shm.ErrIncompatible) {
+		t.Errorf("opening a file that isn't a segment returned %v, want ErrIncompatible", err)
+	}
+}
+
+func TestCacheClosed(t *testing.T) {
+	c, _ := open(t, 1<<20)
+	c.Set("key", []byte("value"))
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := c.Get("key"); found {
+		t.Error("Get after Close found the key")
+	}
+	c.Set("key", []byte("value"))
+	c.Delete("key")
+	c.Clear()
+	if stats := c.Stats(); stats != (shm.Stats{}) {
+		t.Errorf("Stats after Close = %+v, want zero", stats)
+	}
+	if err := c.Close(); !errors.Is(err, shm.ErrClosed) {
+		t.Errorf("second Close returned %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,389 @@
+//go:build linux || darwin || freebsd
+
+package shm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	magic   = 0x7368_6d63_6163_6865 // "shmcache"
+	version = 1
+
+	headerSize      = 128
+	entryHeaderSize = 24
+	tombstone       = ^uint32(0)
+
+	minBuckets = 1024
+)
+
+// Header field offsets. Every field is 8 byte aligned so the counters can be updated atomically.
+const (
+	offMagic    = 0
+	offVersion  = 8
+	offBuckets  = 16
+	offCapacity = 24
+	offUsed     = 32
+	offItems    = 40
+	offClears   = 48
+	offHits     = 56
+	offMisses   = 64
+)
+
+// Cache is a cache for []byte values backed by a shared memory segment.
+// Safe for concurrent use by goroutines and by other processes with the same file open.
+type Cache struct {
+	f    *os.File
+	data []byte
+	lock fileLock
+
+	buckets   uint64
+	dataStart uint64
+}
+
+// Open opens the segment at path, creating it with size bytes if it doesn't exist yet.
+// If it already exists its original size is used.
+func Open(path string, size int64) (*Cache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := open(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func open(f *os.File, size int64) (*Cache, error) {
+	fd := int(f.Fd())
+
+	// Hold an exclusive lock while checking and initializing so two processes opening
+	// a new segment at the same time don't both initialize it.
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Roughly one bucket per 512 bytes of segment keeps chains short for typical values.
+	buckets := uint64(minBuckets)
+	for buckets*512 < uint64(size) {
+		buckets *= 2
+	}
+
+	fresh := info.Size() == 0
+	if fresh {
+		if uint64(size) < headerSize+buckets*8+entryHeaderSize {
+			return nil, fmt.Errorf("shm: size %d is too small", size)
+		}
+		if err := f.Truncate(size); err != nil {
+			return nil, err
+		}
+	} else {
+		size = info.Size()
+	}
+
+	data, err := syscall.Mmap(fd, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("shm: mapping segment: %w", err)
+	}
+
+	c := &Cache{f: f, data: data}
+	c.lock.fd = fd
+
+	if fresh {
+		c.put(offMagic, magic)
+		c.put(offVersion, version)
+		c.put(offBuckets, buckets)
+		c.put(offCapacity, uint64(size))
+		c.put(offUsed, headerSize+buckets*8)
+	} else if c.get(offMagic) != magic || c.get(offVersion) != version || c.get(offCapacity) != uint64(size) {
+		syscall.Munmap(data)
+		return nil, ErrIncompatible
+	}
+
+	c.buckets = c.get(offBuckets)
+	c.dataStart = headerSize + c.buckets*8
+
+	return c, nil
+}
+
+// Get retrieves a copy of a value from the cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if !c.lock.RLock() {
+		return nil, false
+	}
+	defer c.lock.RUnlock()
+
+	off, found := c.find(key)
+	if !found {
+		c.add(offMisses, 1)
+		return nil, false
+	}
+	c.add(offHits, 1)
+
+	// The segment can change as soon as the lock is released, so the value is copied out.
+	keyLen, valLen := c.lengths(off)
+	start := off + entryHeaderSize + uint64(keyLen)
+	return bytes.Clone(c.data[start : start+uint64(valLen)]), true
+}
+
+// Set adds a value to the cache, replacing any existing value.
+func (c *Cache) Set(key string, value []byte) {
+	c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL adds a value to the cache that expires after ttl. A ttl <= 0 means it never expires.
+func (c *Cache) SetWithTTL(key string, value []byte, ttl time.Duration) {
+	if !c.lock.Lock() {
+		return
+	}
+	defer c.lock.Unlock()
+
+	if _, found := c.find(key); !found {
+		c.add(offItems, 1)
+	}
+	c.append(key, value, uint32(len(value)), expiresAt(ttl))
+}
+
+// Delete removes a value from the cache.
+func (c *Cache) Delete(key string) {
+	if !c.lock.Lock() {
+		return
+	}
+	defer c.lock.Unlock()
+
+	if _, found := c.find(key); found {
+		c.add(offItems, ^uint64(0))
+		c.append(key, nil, tombstone, 0)
+	}
+}
+
+// Clear removes every value from the segment, for every process using it.
+func (c *Cache) Clear() {
+	if !c.lock.Lock() {
+		return
+	}
+	defer c.lock.Unlock()
+
+	c.clear()
+}
+
+// Stats returns a snapshot of the segment's usage, or the zero Stats once c is closed.
+func (c *Cache) Stats() Stats {
+	if !c.lock.RLock() {
+		return Stats{}
+	}
+	defer c.lock.RUnlock()
+
+	return Stats{
+		Items:    int64(c.get(offItems)),
+		Size:     int64(c.get(offUsed) - c.dataStart),
+		Capacity: int64(c.get(offCapacity) - c.dataStart),
+		Hits:     int64(c.get(offHits)),
+		Misses:   int64(c.get(offMisses)),
+		Clears:   int64(c.get(offClears)),
+	}
+}
+
+// Close unmaps the segment. The file is left in place for the other processes. Afterwards,
+// Get misses, the other methods do nothing, and Close returns ErrClosed.
+func (c *Cache) Close() error {
+	// Only the in-process lock is needed to stop our own goroutines using the mapping,
+	// and the flock goes away with the file anyway.
+	c.lock.rw.Lock()
+	defer c.lock.rw.Unlock()
+
+	if c.lock.closed {
+		return ErrClosed
+	}
+	c.lock.closed = true
+
+	err := syscall.Munmap(c.data)
+	c.data = nil
+	if closeErr := c.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// find returns the offset of the newest live entry for key. c.lock must be held.
+func (c *Cache) find(key string) (uint64, bool) {
+	now := time.Now().UnixNano()
+
+	for off := c.get(c.bucket(key)); off != 0; off = c.get(off) {
+		keyLen, valLen := c.lengths(off)
+		if int(keyLen) != len(key) || string(c.data[off+entryHeaderSize:off+entryHeaderSize+uint64(keyLen)]) != key {
+			continue
+		}
+
+		// The newest entry for a key shadows every older one, so stop at the first match.
+		exp := int64(c.get(off + 8))
+		if valLen == tombstone || (exp > 0 && now >= exp) {
+			return 0, false
+		}
+		return off, true
+	}
+	return 0, false
+}
+
+// append writes a new entry for key and links it in front of any older ones.
+// c.lock must be held exclusively.
+//
+// Entry layout: next offset (8), expiresAt (8), key length (4), value length (4), key, value,
+// padded to 8 bytes.
+func (c *Cache) append(key string, value []byte, valLen uint32, expiresAt int64) {
+	need := (entryHeaderSize + uint64(len(key)) + uint64(len(value)) + 7) &^ 7
+
+	capacity := c.get(offCapacity)
+	if need > capacity-c.dataStart {
+		log.Printf("shm: %q is too large for the segment (%d bytes). not caching.", key, need)
+		return
+	}
+
+	if c.get(offUsed)+need > capacity {
+		log.Printf("shm: segment full (%d bytes). clearing...", capacity)
+		c.clear()
+
+		// Clearing dropped every item, including the one being set. Count it again.
+		if valLen != tombstone {
+			c.put(offItems, 1)
+		}
+	}
+
+	off := c.get(offUsed)
+	bucket := c.bucket(key)
+
+	c.put(off, c.get(bucket))
+	c.put(off+8, uint64(expiresAt))
+	binary.LittleEndian.PutUint32(c.data[off+16:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(c.data[off+20:], valLen)
+	copy(c.data[off+entryHeaderSize:], key)
+	copy(c.data[off+entryHeaderSize+uint64(len(key)):], value)
+
+	c.put(bucket, off)
+	c.put(offUsed, off+need)
+}
+
+// clear resets the segment. c.lock must be held exclusively.
+func (c *Cache) clear() {
+	clear(c.data[headerSize:c.dataStart])
+	c.put(offUsed, c.dataStart)
+	c.put(offItems, 0)
+	c.add(offClears, 1)
+}
+
+func (c *Cache) lengths(off uint64) (keyLen, valLen uint32) {
+	return binary.LittleEndian.Uint32(c.data[off+16:]), binary.LittleEndian.Uint32(c.data[off+20:])
+}
+
+// bucket returns the offset of the bucket holding key's chain.
+func (c *Cache) bucket(key string) uint64 {
+	// FNV-1a, inlined to avoid allocating a hasher per lookup.
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return headerSize + (h&(c.buckets-1))*8
+}
+
+// get, put and add access 8 byte words atomically, since hit and miss counters are updated
+// under a shared lock by several processes at once.
+func (c *Cache) get(off uint64) uint64 {
+	return atomic.LoadUint64((*uint64)(unsafe.Pointer(&c.data[off])))
+}
+
+func (c *Cache) put(off, v uint64) {
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&c.data[off])), v)
+}
+
+func (c *Cache) add(off, delta uint64) {
+	atomic.AddUint64((*uint64)(unsafe.Pointer(&c.data[off])), delta)
+}
+
+// fileLock is a readers-writer lock across both goroutines and processes.
+//
+// flock locks belong to the open file, so every goroutine in this process shares one.
+// The process only takes the shared flock for its first reader and releases it
+// after its last, and the RWMutex keeps its own readers and writers apart.
+//
+// RLock and Lock report false, without locking, once the cache has been closed, since the
+// file they'd flock is closed too.
+type fileLock struct {
+	fd      int
+	rw      sync.RWMutex
+	closed  bool // set by Close, under rw
+	mu      sync.Mutex
+	readers int
+}
+
+func (l *fileLock) RLock() bool {
+	l.rw.RLock()
+	if l.closed {
+		l.rw.RUnlock()
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.readers == 0 {
+		flock(l.fd, syscall.LOCK_SH)
+	}
+	l.readers++
+	return true
+}
+
+func (l *fileLock) RUnlock() {
+	l.mu.Lock()
+	l.readers--
+	if l.readers == 0 {
+		flock(l.fd, syscall.LOCK_UN)
+	}
+	l.mu.Unlock()
+
+	l.rw.RUnlock()
+}
+
+func (l *fileLock) Lock() bool {
+	l.rw.Lock()
+	if l.closed {
+		l.rw.Unlock()
+		return false
+	}
+	flock(l.fd, syscall.LOCK_EX)
+	return true
+}
+
+func (l *fileLock) Unlock() {
+	flock(l.fd, syscall.LOCK_UN)
+	l.rw.Unlock()
+}
+
+func flock(fd, how int) {
+	for {
+		err := syscall.Flock(fd, how)
+		if err != syscall.EINTR {
+			if err != nil {
+				log.Printf("shm: flock: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import "sync/atomic"
+
+// sieve is the bookkeeping for EvictSieve: a queue of every item, newest first, a visited bit
+// for each slot, and the hand, the slot eviction carries on from, plus 1 so that 0 means it
+// starts from the oldest item. The visited bits are set while c.mu is only read locked, so
+// they're accessed atomically; everything else needs c.mu locked.
+type sieve struct {
+	queue   slotQueue
+	keys    []string
+	visited []uint32
+	hand    uint32
+}
+
+// add puts a new item at the head of the queue.
+func (s *sieve) add(key string, i uint32) {
+	for uint32(len(s.keys)) <= i {
+		s.keys = append(s.keys, "")
+		s.visited = append(s.visited, 0)
+	}
+	s.keys[i] = key
+	s.visited[i] = 0
+	s.queue.push(i)
+}
+
+// visit marks slot i as read since the hand last passed it. Only setting a bit, and only if it
+// isn't set already, is what keeps reads cheap compared to moving items in an LRU list.
+func (s *sieve) visit(i uint32) {
+	if atomic.LoadUint32(&s.visited[i]) == 0 {
+		atomic.StoreUint32(&s.visited[i], 1)
+	}
+}
+
+// remove takes slot i out of the queue, moving the hand past it if it's there.
+func (s *sieve) remove(i uint32) {
+	if s.hand == i+1 {
+		if newer, ok := s.queue.newerThan(i); ok {
+			s.hand = newer + 1
+		} else {
+			s.hand = 0
+		}
+	}
+	s.queue.unlink(i)
+	s.keys[i] = ""
+}
+
+func (s *sieve) reset() {
+	*s = sieve{}
+}
+
+func (s *sieve) len() int {
+	return s.queue.len
+}
+
+// victim moves the hand from older items towards newer ones, clearing visited bits as it goes,
+// and returns the first item that hasn't been visited, or has expired. The queue must not be
+// empty. The victim is left in the queue for the caller to remove.
+func (s *sieve) victim(a *arena, now int64) (string, uint32) {
+	i, _ := s.queue.oldest()
+	if s.hand != 0 {
+		i = s.hand - 1
+	}
+
+	// Every visited bit is cleared on the first pass, so this ends within two.
+	for atomic.LoadUint32(&s.visited[i]) == 1 && !a.entry(i).expired(now) {
+		atomic.StoreUint32(&s.visited[i], 0)
+
+		var ok bool
+		if i, ok = s.queue.newerThan(i); !ok {
+			i, _ = s.queue.oldest()
+		}
+	}
+
+	if newer, ok := s.queue.newerThan(i); ok {
+		s.hand = newer + 1
+	} else {
+		s.hand = 0
+	}
+	return s.keys[i], i
+}
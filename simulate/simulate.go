@@ -0,0 +1,203 @@
+// Package simulate replays a trace of cache accesses against different cache sizes and
+// eviction strategies and reports the hit rate of each, so capacity and policy decisions can
+// be based on real traffic instead of guesses:
+//
+//	trace, err := simulate.ReadRecordedTrace(f)
+//	if err != nil {
+//		return err
+//	}
+//	sizes := []int64{16 << 20, 32 << 20, 64 << 20, 128 << 20}
+//	results := simulate.Run(trace, sizes, simulate.DefaultStrategies)
+//	simulate.WriteReport(os.Stdout, results)
+//
+// Each simulated cache is a real cache.Cache, but values only take up the size recorded in the
+// trace, not any memory.
+package simulate
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// Op is the kind of an Access.
+type Op = cache.TraceOp
+
+const (
+	// OpGet is a read. If the simulated cache misses and the access has a size, the key is set
+	// with that size, as if the application had loaded it.
+	OpGet    = cache.TraceGet
+	OpSet    = cache.TraceSet
+	OpDelete = cache.TraceDelete
+)
+
+// Access is a single operation in a trace. It's the same as a cache.TraceEvent, so traces
+// recorded in production with cache.WithTraceRecorder can be simulated directly.
+type Access = cache.TraceEvent
+
+// ReadRecordedTrace reads a trace recorded with cache.WithTraceRecorder.
+func ReadRecordedTrace(r io.Reader) ([]Access, error) {
+	tr := cache.NewTraceReader(r)
+
+	var trace []Access
+	for {
+		a, err := tr.Next()
+		if err == io.EOF {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("simulate: reading recorded trace: %w", err)
+		}
+		trace = append(trace, a)
+	}
+}
+
+// ReadTrace reads a trace in a text format, e.g. one generated from access logs, one access per line: the op ("get", "set" or
+// "delete"), the value's size in bytes, and the key, which is the rest of the line.
+//
+//	set 512 users:42
+//	get 512 users:42
+//	delete 0 users:42
+//
+// Blank lines and lines starting with # are skipped.
+func ReadTrace(r io.Reader) ([]Access, error) {
+	var trace []Access
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("simulate: line %d: want op, size and key, got %q", line, text)
+		}
+
+		var a Access
+		switch fields[0] {
+		case "get":
+			a.Op = OpGet
+		case "set":
+			a.Op = OpSet
+		case "delete":
+			a.Op = OpDelete
+		default:
+			return nil, fmt.Errorf("simulate: line %d: unknown op %q", line, fields[0])
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("simulate: line %d: invalid size %q", line, fields[1])
+		}
+		a.Size = size
+		a.Key = fields[2]
+
+		trace = append(trace, a)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("simulate: reading trace: %w", err)
+	}
+	return trace, nil
+}
+
+// Strategy is a named set of options to simulate, e.g. an eviction policy.
+type Strategy struct {
+	Name    string
+	Options []cache.Option
+}
+
+// DefaultStrategies are the ways the cache can deal with being full: clearing (the default),
+// evicting with each EvictionPolicy, keeping the hottest items when clearing, and generations.
+var DefaultStrategies = []Strategy{
+	{Name: "clear"},
+	{Name: "evict-oldest", Options: []cache.Option{cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithEvictionPolicy(cache.EvictOldest)}},
+	{Name: "evict-least-hit", Options: []cache.Option{cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithEvictionPolicy(cache.EvictLeastHit)}},
+	{Name: "sieve", Options: []cache.Option{cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithEvictionPolicy(cache.EvictSieve)}},
+	{Name: "s3-fifo", Options: []cache.Option{cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithEvictionPolicy(cache.EvictS3FIFO)}},
+	{Name: "retain-hot-10%", Options: []cache.Option{cache.WithRetainHotFraction(0.1)}},
+	{Name: "generations", Options: []cache.Option{cache.WithGenerations()}},
+}
+
+// Result is the outcome of replaying a trace against one size and strategy.
+type Result struct {
+	Strategy string
+	MaxSize  int64
+	Stats    cache.Stats
+}
+
+// HitRate returns the fraction of gets that hit.
+func (r Result) HitRate() float64 {
+	return r.Stats.HitRate()
+}
+
+// Run replays trace against a cache of every size with every strategy, concurrently, and
+// returns the results ordered by strategy and then size.
+func Run(trace []Access, sizes []int64, strategies []Strategy) []Result {
+	var (
+		wg      sync.WaitGroup
+		results = make([]Result, 0, len(sizes)*len(strategies))
+		mu      sync.Mutex
+	)
+
+	for _, strategy := range strategies {
+		for _, size := range sizes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				r := Replay(trace, size, strategy.Options...)
+				r.Strategy = strategy.Name
+
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+
+	order := make(map[string]int, len(strategies))
+	for i, s := range strategies {
+		order[s.Name] = i
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Or(cmp.Compare(order[a.Strategy], order[b.Strategy]), cmp.Compare(a.MaxSize, b.MaxSize))
+	})
+	return results
+}
+
+// Replay replays trace against a single cache of maxSize created with opts.
+func Replay(trace []Access, maxSize int64, opts ...cache.Option) Result {
+	opts = append([]cache.Option{cache.WithLogger(log.New(io.Discard, "", 0))}, opts...)
+	c := cache.New(maxSize, opts...)
+	defer c.Close()
+
+	for _, a := range trace {
+		c.Replay(a)
+	}
+
+	return Result{MaxSize: maxSize, Stats: c.Stats()}
+}
+
+// WriteReport writes results to w as a table.
+func WriteReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "strategy\tmax size\thit rate\thits\tmisses\tclears\tevictions")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%d\t%d\t%d\t%d\n", r.Strategy, r.MaxSize, r.HitRate()*100, r.Stats.Hits, r.Stats.Misses, r.Stats.Clears, r.Stats.Evictions)
+	}
+
+	return tw.Flush()
+}
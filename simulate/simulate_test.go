@@ -0,0 +1,136 @@
+package simulate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader(`
+# a comment
+set 512 users:42
+get 512 users:42
+
+delete 0 key with spaces
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Access{
+		{Op: OpSet, Size: 512, Key: "users:42"},
+		{Op: OpGet, Size: 512, Key: "users:42"},
+		{Op: OpDelete, Size: 0, Key: "key with spaces"},
+	}
+	if len(trace) != len(want) {
+		t.Fatalf("read %d accesses, want %d: %+v", len(trace), len(want), trace)
+	}
+	for i := range want {
+		if trace[i].Op != want[i].Op || trace[i].Size != want[i].Size || trace[i].Key != want[i].Key {
+			t.Errorf("access %d is %+v, want %+v", i, trace[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"get users:42", "put 1 key", "get -1 key", "get large key"} {
+		if _, err := ReadTrace(strings.NewReader("get 1 ok\n" + bad)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("reading %q returned %v, want an error on line 2", bad, err)
+		}
+	}
+}
+
+func TestReadRecordedTrace(t *testing.T) {
+	var buf bytes.Buffer
+	rec := cache.NewTraceRecorder(&buf)
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithTraceRecorder(rec))
+	c.Set("a", "value")
+	c.Get("a")
+	c.Delete("a")
+	c.Close()
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	trace, err := ReadRecordedTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []Op
+	for _, a := range trace {
+		if a.Key != "a" {
+			t.Errorf("recorded access to %q, want a", a.Key)
+		}
+		ops = append(ops, a.Op)
+	}
+	if len(ops) != 3 || ops[0] != OpSet || ops[1] != OpGet || ops[2] != OpDelete {
+		t.Errorf("recorded ops %v, want set, get, delete", ops)
+	}
+
+	if _, err := ReadRecordedTrace(strings.NewReader("get 1 key\n")); err == nil {
+		t.Error("reading a text trace as a recorded one succeeded")
+	}
+}
+
+// loop returns a trace that reads the same keys, each of size bytes, in the same order, passes times.
+func loop(keys, passes int, size int64) []Access {
+	var trace []Access
+	for range passes {
+		for i := range keys {
+			trace = append(trace, Access{Op: OpGet, Size: size, Key: string(rune('a' + i))})
+		}
+	}
+	return trace
+}
+
+func TestReplay(t *testing.T) {
+	trace := loop(10, 5, 100)
+
+	r := Replay(trace, 1<<20)
+	if r.Stats.Misses != 10 || r.Stats.Hits != 40 {
+		t.Errorf("a cache the whole trace fits in had %d hits and %d misses, want 40 and 10", r.Stats.Hits, r.Stats.Misses)
+	}
+	if r.MaxSize != 1<<20 {
+		t.Errorf("the result's MaxSize is %d, want %d", r.MaxSize, 1<<20)
+	}
+
+	trace = append(trace, Access{Op: OpDelete, Key: "a"}, Access{Op: OpGet, Key: "a"})
+	if r := Replay(trace, 1<<20); r.Stats.Misses != 11 {
+		t.Errorf("a get after a delete had %d misses in all, want 11", r.Stats.Misses)
+	}
+}
+
+func TestRun(t *testing.T) {
+	trace := loop(10, 5, 100)
+	sizes := []int64{1 << 20, 500}
+	strategies := DefaultStrategies[:2]
+
+	results := Run(trace, sizes, strategies)
+	if len(results) != len(sizes)*len(strategies) {
+		t.Fatalf("Run returned %d results, want %d", len(results), len(sizes)*len(strategies))
+	}
+	// Ordered by strategy, then size.
+	for i, want := range []struct {
+		strategy string
+		size     int64
+	}{{"clear", 500}, {"clear", 1 << 20}, {"evict-oldest", 500}, {"evict-oldest", 1 << 20}} {
+		if results[i].Strategy != want.strategy || results[i].MaxSize != want.size {
+			t.Errorf("result %d is %s at %d, want %s at %d", i, results[i].Strategy, results[i].MaxSize, want.strategy, want.size)
+		}
+	}
+	for i := 0; i < len(results); i += 2 {
+		small, large := results[i], results[i+1]
+		if small.HitRate() >= large.HitRate() {
+			t.Errorf("%s: a cache too small for the trace hit %.2f, no less than one it fits in at %.2f", small.Strategy, small.HitRate(), large.HitRate())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1+len(results) || !strings.HasPrefix(lines[0], "strategy") || !strings.Contains(lines[2], "80.0%") {
+		t.Errorf("the report is:\n%s", buf.String())
+	}
+}
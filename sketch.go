@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// sketchRows is how many rows of counters the frequency sketch has. A key's frequency is the
+	// smallest of its counters, one per row, so the more rows, the less collisions overcount.
+	sketchRows = 4
+
+	// sketchMaxCount is the most a 4-bit counter can hold.
+	sketchMaxCount = 15
+
+	// sketchAgingFactor is how many accesses per counter in a row the sketch counts before
+	// halving every counter, so frequencies reflect recent accesses.
+	sketchAgingFactor = 10
+)
+
+// WithFrequencySketch estimates how often every key is read or written, including keys that
+// aren't cached any more or never were, in a count-min sketch sized for about keys different
+// keys. It takes at most 4 bytes per key, whatever the keys' sizes, and every count is halved once the
+// sketch has counted ten accesses per key, so old popularity fades.
+//
+// The estimates, from Frequency, are also used to decide what to keep when the cache is full
+// and evicts (see WithSoftLimit and OverflowEvict): EvictLeastHit evicts the least frequent
+// of the sampled items, rather than the one read the fewest times since it was last set, and
+// a write of a new key that would need an eviction is dropped, and counted in Stats.NotAdmitted,
+// if the key is less frequent than the item that would be evicted for it. EvictSieve and
+// EvictS3FIFO keep their own order, so with them the sketch only answers Frequency.
+func WithFrequencySketch(keys int) Option {
+	return func(c *Cache) {
+		if keys <= 0 {
+			c.logf("ignoring frequency sketch for %d keys, it must be at least 1", keys)
+			return
+		}
+
+		width := 1 << bits.Len(uint(max(keys, 16)-1))
+		c.sketch = &frequencySketch{
+			rows:       make([]atomic.Uint64, sketchRows*width/16),
+			width:      uint32(width),
+			agingLimit: int64(width * sketchAgingFactor),
+			seed:       maphash.MakeSeed(),
+		}
+	}
+}
+
+// frequencySketch is a count-min sketch of 4-bit counters, 16 to a word, in sketchRows rows of
+// width counters each. It's safe for concurrent use without c.mu.
+type frequencySketch struct {
+	rows       []atomic.Uint64
+	width      uint32
+	agingLimit int64
+	seed       maphash.Seed
+
+	accesses atomic.Int64 // since the counters were last halved
+	aging    sync.Mutex
+}
+
+// empty returns a sketch like s with every counter at 0.
+func (s *frequencySketch) empty() *frequencySketch {
+	return &frequencySketch{
+		rows:       make([]atomic.Uint64, len(s.rows)),
+		width:      s.width,
+		agingLimit: s.agingLimit,
+		seed:       s.seed,
+	}
+}
+
+// counter returns the word and shift of key's counter in row.
+func (s *frequencySketch) counter(h uint64, row uint32) (*atomic.Uint64, uint) {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	i := row*s.width + (h1+row*h2)&(s.width-1)
+	return &s.rows[i/16], uint(i%16) * 4
+}
+
+// increment counts an access of the key with hash h.
+func (s *frequencySketch) increment(h uint64) {
+	for row := range uint32(sketchRows) {
+		word, shift := s.counter(h, row)
+		for {
+			old := word.Load()
+			if (old>>shift)&0xf == sketchMaxCount || word.CompareAndSwap(old, old+1<<shift) {
+				break
+			}
+		}
+	}
+
+	if s.accesses.Add(1) >= s.agingLimit {
+		s.age()
+	}
+}
+
+// estimate returns how many times the key with hash h has been accessed, give or take
+// collisions, which only ever make it higher.
+func (s *frequencySketch) estimate(h uint64) int {
+	n := sketchMaxCount
+	for row := range uint32(sketchRows) {
+		word, shift := s.counter(h, row)
+		n = min(n, int(word.Load()>>shift)&0xf)
+	}
+	return n
+}
+
+// age halves every counter. If several callers get to the limit at once, only one ages it.
+func (s *frequencySketch) age() {
+	if !s.aging.TryLock() {
+		return
+	}
+	defer s.aging.Unlock()
+
+	if s.accesses.Load() < s.agingLimit {
+		return // another caller just aged it
+	}
+	for i := range s.rows {
+		for {
+			old := s.rows[i].Load()
+			// Shifting the whole word halves every counter, once each counter's low bit,
+			// which would otherwise shift into its neighbour, is masked off.
+			if s.rows[i].CompareAndSwap(old, (old>>1)&0x7777777777777777) {
+				break
+			}
+		}
+	}
+	s.accesses.Store(0)
+}
+
+// recordFrequency counts an access of key in the frequency sketch, if there is one.
+func (c *Cache) recordFrequency(key string) {
+	if c.sketch != nil {
+		c.sketch.increment(hashKey(c.sketch.seed, key, c.deterministic()))
+	}
+}
+
+// Frequency returns roughly how many times key has been read or written recently, from 0 to 15,
+// if the cache was created WithFrequencySketch, and 0 otherwise. It counts keys whether they're
+// cached or not, so it can be used for admission decisions outside the cache.
+func (c *Cache) Frequency(key string) int {
+	if c.sketch == nil {
+		return 0
+	}
+	return c.sketch.estimate(hashKey(c.sketch.seed, key, c.deterministic()))
+}
+
+// admitsOverVictim reports whether a write of key, size bytes, should be cached: unless it's a
+// new key that needs an eviction to fit, and it's less frequent than the item that would be
+// evicted. c.mu must already be locked.
+func (c *Cache) admitsOverVictim(key string, size int64) bool {
+	if c.sketch == nil || c.queued != nil || len(c.items) == 0 || c.evictionPaused() {
+		return true
+	}
+	if c.overflow != OverflowEvict && c.evictor == nil {
+		return true // the cache clears rather than evicts
+	}
+	if _, found := c.items[key]; found || c.totalCacheSize+int64(len(key))+size <= c.evictTarget() {
+		return true
+	}
+
+	victim, _ := c.evictionCandidate(c.now())
+	return c.Frequency(key) >= c.Frequency(victim)
+}
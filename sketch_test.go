@@ -0,0 +1,80 @@
+package cache_test
+
+import (
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestFrequency(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithDeterministic(1), cache.WithFrequencySketch(1000))
+	defer c.Close()
+
+	for range 3 {
+		c.Get("warm")
+	}
+	for range 100 {
+		c.Get("hot")
+	}
+	if got := c.Frequency("warm"); got != 3 {
+		t.Errorf("Frequency(warm) = %d, want 3", got)
+	}
+	if got := c.Frequency("hot"); got != 15 {
+		t.Errorf("Frequency(hot) = %d, want the most a counter holds, 15", got)
+	}
+	if got := c.Frequency("cold"); got != 0 {
+		t.Errorf("Frequency(cold) = %d, want 0", got)
+	}
+
+	// The sketch is sized for 1024 keys, so every count is halved after 10 times that many
+	// accesses, counting the 103 so far.
+	for range 10*1024 - 103 {
+		c.Get("other")
+	}
+	if got := c.Frequency("hot"); got != 7 {
+		t.Errorf("after aging, Frequency(hot) = %d, want 7", got)
+	}
+	if got := c.Frequency("warm"); got != 1 {
+		t.Errorf("after aging, Frequency(warm) = %d, want 1", got)
+	}
+
+	without := cache.New(1<<20, cache.WithLogger(nil))
+	defer without.Close()
+	without.Get("hot")
+	if got := without.Frequency("hot"); got != 0 {
+		t.Errorf("Frequency without a sketch = %d, want 0", got)
+	}
+}
+
+func TestFrequencyAdmission(t *testing.T) {
+	size := itemSize(t)
+	c := cache.New(4*size, cache.WithLogger(nil), cache.WithDeterministic(1),
+		cache.WithOverflowPolicy(cache.OverflowEvict), cache.WithFrequencySketch(1000))
+	defer c.Close()
+
+	for _, key := range []string{"key0", "key1", "key2", "key3"} {
+		c.Set(key, "value")
+		for range 5 {
+			c.Get(key)
+		}
+	}
+
+	c.Set("key4", "value")
+	if _, found := c.Get("key4"); found {
+		t.Error("a new key less frequent than the cached ones pushed one out")
+	}
+	if stats := c.Stats(); stats.NotAdmitted != 1 || stats.Evictions != 0 {
+		t.Errorf("NotAdmitted = %d, Evictions = %d, want 1 and 0", stats.NotAdmitted, stats.Evictions)
+	}
+
+	for range 10 {
+		c.Get("key5")
+	}
+	c.Set("key5", "value")
+	if _, found := c.Get("key5"); !found {
+		t.Error("a new key more frequent than the cached ones wasn't cached")
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Items != 4 {
+		t.Errorf("Evictions = %d, Items = %d, want 1 and 4", stats.Evictions, stats.Items)
+	}
+}
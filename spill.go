@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// spillHandleSize is what a spilled value counts for towards the cache's size, for the handle
+// that's kept in memory.
+const spillHandleSize = 64
+
+// WithSpill writes values of at least threshold bytes to temporary files in dir (os.TempDir if
+// empty) instead of keeping them in memory, so a few huge blobs can't take up the whole budget.
+// Spilled values only count for a small handle towards maxCacheSize; Stats reports their real
+// total as SpilledSize.
+//
+// Only []byte and string values, or any value if the cache was created WithSerializer, can be
+// spilled. The threshold applies after compression. Files are deleted as soon as they're
+// created where the platform allows, so they don't outlive the process, and otherwise when the
+// value leaves the cache. A clone shares the original's files, and each is only deleted once
+// neither cache has its value anymore.
+func WithSpill(dir string, threshold int) Option {
+	return func(c *Cache) {
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		c.spillDir = dir
+		c.spillAbove = threshold
+		c.spilled = new(spillCounts)
+	}
+}
+
+// spillCounts tracks what's spilled. Each cache has its own, counting the files it holds, even
+// if they're shared with clones.
+type spillCounts struct {
+	items atomic.Int64
+	bytes atomic.Int64
+}
+
+// spillFile holds a spilled value open, so it can still be read once it's been unlinked.
+type spillFile struct {
+	f    *os.File
+	size int
+	path string // empty if the file was unlinked as soon as it was created
+
+	// refs is how many caches hold the value, more than 1 once it's been cloned. The file is
+	// closed when the last of them drops it.
+	refs atomic.Int32
+}
+
+// spill moves v.data to a file if it's big enough. It's called before the cache is locked.
+func (c *Cache) spill(key string, v *encodedValue) error {
+	if c.spillDir == "" || len(v.data) < c.spillAbove {
+		return nil
+	}
+
+	f, err := os.CreateTemp(c.spillDir, "cache-spill-*")
+	if err != nil {
+		return fmt.Errorf("cache: spilling %q: %w", key, err)
+	}
+	if _, err := f.Write(v.data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("cache: spilling %q: %w", key, err)
+	}
+
+	s := &spillFile{f: f, size: len(v.data), path: f.Name()}
+	s.refs.Store(1)
+	if os.Remove(s.path) == nil {
+		s.path = ""
+	}
+
+	v.data, v.spill = nil, s
+	c.spilled.items.Add(1)
+	c.spilled.bytes.Add(int64(s.size))
+	return nil
+}
+
+func (s *spillFile) read() ([]byte, error) {
+	b := make([]byte, s.size)
+	if _, err := s.f.ReadAt(b, 0); err != nil {
+		return nil, fmt.Errorf("reading spilled value: %w", err)
+	}
+	return b, nil
+}
+
+// dropSpill closes and deletes the file holding stored, if it was spilled.
+func (c *Cache) dropSpill(stored any) {
+	v, ok := stored.(*encodedValue)
+	if !ok || v.spill == nil {
+		return
+	}
+
+	c.spilled.items.Add(-1)
+	c.spilled.bytes.Add(-int64(v.spill.size))
+	if v.spill.refs.Add(-1) > 0 {
+		return
+	}
+	v.spill.f.Close()
+	if v.spill.path != "" {
+		os.Remove(v.spill.path)
+	}
+}
+
+// shareSpills takes a reference to every file holding one of c's values, for a clone that was
+// just given the original's items, so the files stay open until both caches have dropped them.
+func (c *Cache) shareSpills() {
+	c.spilled = new(spillCounts)
+	for _, i := range c.items {
+		if v, ok := c.arena.value(i).(*encodedValue); ok && v.spill != nil {
+			v.spill.refs.Add(1)
+			c.spilled.items.Add(1)
+			c.spilled.bytes.Add(int64(v.spill.size))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cache_test
+
+import (
+	"bytes"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestSpill(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithSpill(t.TempDir(), 1024))
+	defer c.Close()
+
+	big := bytes.Repeat([]byte("x"), 100_000)
+	c.Set("big", big)
+	c.Set("small", []byte("small"))
+
+	if got, found := c.GetBytes("big"); !found || !bytes.Equal(got, big) {
+		t.Fatalf("spilled value read back as %d bytes, %v", len(got), found)
+	}
+	if got, found := c.GetBytes("small"); !found || string(got) != "small" {
+		t.Fatalf("small value read back as %q, %v", got, found)
+	}
+
+	stats := c.Stats()
+	if stats.Spilled != 1 || stats.SpilledSize != int64(len(big)) {
+		t.Errorf("spilled %d items, %d bytes, want 1, %d", stats.Spilled, stats.SpilledSize, len(big))
+	}
+	if stats.Size >= int64(len(big)) {
+		t.Errorf("spilled value counts %d bytes towards the size", stats.Size)
+	}
+
+	c.Delete("big")
+	if stats := c.Stats(); stats.Spilled != 0 || stats.SpilledSize != 0 {
+		t.Errorf("after deleting, spilled %d items, %d bytes, want none", stats.Spilled, stats.SpilledSize)
+	}
+}
+
+func TestSpillSharedWithClone(t *testing.T) {
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithSpill(t.TempDir(), 1024))
+	defer c.Close()
+
+	big := bytes.Repeat([]byte("x"), 100_000)
+	c.Set("deleted", big)
+	c.Set("evicted", big)
+
+	clone := c.Clone()
+	defer clone.Close()
+
+	if stats := clone.Stats(); stats.Spilled != 2 {
+		t.Errorf("clone counts %d spilled items, want 2", stats.Spilled)
+	}
+
+	clone.Delete("deleted")
+	clone.SetMaxCacheSize(1)
+	if _, found := clone.Get("evicted"); found {
+		t.Fatal("clone didn't evict")
+	}
+
+	for _, key := range []string{"deleted", "evicted"} {
+		if got, found := c.GetBytes(key); !found || !bytes.Equal(got, big) {
+			t.Errorf("after the clone dropped %q, the original read it back as %d bytes, %v", key, len(got), found)
+		}
+	}
+	if stats := c.Stats(); stats.Spilled != 2 {
+		t.Errorf("original counts %d spilled items, want 2", stats.Spilled)
+	}
+	if stats := clone.Stats(); stats.Spilled != 0 {
+		t.Errorf("clone counts %d spilled items, want 0", stats.Spilled)
+	}
+
+	c.Clear()
+	if stats := c.Stats(); stats.Spilled != 0 {
+		t.Errorf("after clearing, original counts %d spilled items, want 0", stats.Spilled)
+	}
+}
@@ -0,0 +1,220 @@
+// Package sqlcache caches database/sql query results in a cache.Cache, keyed on the normalized
+// query and its arguments, and drops them when a table they read from is written to.
+//
+//	db := sqlcache.New(sqlDB, cache.New(64<<20, cache.WithDefaultTTL(time.Minute)))
+//	res, err := db.Query(ctx, "SELECT id, name FROM users WHERE team = ?", team)
+//	...
+//	_, err = db.Exec(ctx, "UPDATE users SET name = ? WHERE id = ?", name, id) // drops cached users queries
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// DefaultKeyPrefix is put in front of every key unless changed with WithKeyPrefix.
+const DefaultKeyPrefix = "sqlcache:"
+
+// tablePattern matches the table after FROM, JOIN, INTO or UPDATE, quoted or not.
+var tablePattern = regexp.MustCompile("(?i)\\b(?:from|join|into|update)\\s+(\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[a-z_][\\w.$]*)")
+
+// Result is a query's result, read into memory so it can be cached.
+//
+// Values are whatever the driver returned, usually int64, float64, bool, []byte, string,
+// time.Time or nil. Cached results are shared between callers, so they must not be modified.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// Size implements cache.Sizer.
+func (r *Result) Size() int64 {
+	var n int64
+	for _, col := range r.Columns {
+		n += int64(len(col))
+	}
+	for _, row := range r.Rows {
+		for _, v := range row {
+			switch v := v.(type) {
+			case []byte:
+				n += int64(len(v))
+			case string:
+				n += int64(len(v))
+			default:
+				n += 16
+			}
+		}
+	}
+	return n
+}
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithKeyPrefix changes the prefix put in front of every key, DefaultKeyPrefix by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(d *DB) {
+		d.prefix = prefix
+	}
+}
+
+// DB caches the results of queries run on a *sql.DB.
+//
+// Results are tagged with the tables their query reads from, found after FROM and JOIN.
+// Exec drops the results for the tables its statement writes to, found after INSERT INTO,
+// UPDATE and DELETE FROM, and Invalidate drops them for any tables. Writes made any other
+// way, or by other processes, aren't seen: those results expire with the cache's TTL.
+type DB struct {
+	db     *sql.DB
+	cache  *cache.Cache
+	prefix string
+
+	mu sync.Mutex
+	// generations counts each table's invalidations. It's part of every key, so invalidating
+	// a table leaves its old results unreachable until they expire or the cache clears.
+	generations map[string]uint64
+}
+
+// New creates a DB that caches db's query results in c. Results are cached with c's default TTL.
+func New(db *sql.DB, c *cache.Cache, opts ...Option) *DB {
+	d := &DB{
+		db:          db,
+		cache:       c,
+		prefix:      DefaultKeyPrefix,
+		generations: make(map[string]uint64),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Query returns the result of query with args, from the cache if it's there. Concurrent calls
+// for the same uncached query share a single query to the database.
+func (d *DB) Query(ctx context.Context, query string, args ...any) (*Result, error) {
+	value, err := d.cache.GetOrCompute(ctx, d.key(query, args), func(ctx context.Context, _ string) (any, error) {
+		return d.query(ctx, query, args)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Result), nil
+}
+
+func (d *DB) query(ctx context.Context, query string, args []any) (*Result, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Columns: columns}
+	for rows.Next() {
+		row := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		// Scanning into *any copies []byte values, so they outlive rows.
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, rows.Err()
+}
+
+// Exec runs query on the database and drops the cached results for the tables it writes to.
+// They're dropped even if it fails, since it may have partly succeeded.
+func (d *DB) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := d.db.ExecContext(ctx, query, args...)
+	d.Invalidate(tables(query)...)
+	return res, err
+}
+
+// Invalidate drops the cached results of every query reading from any of tables.
+func (d *DB) Invalidate(tables ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, table := range tables {
+		d.generations[normalizeTable(table)]++
+	}
+}
+
+// key returns the key query's result with args is cached under, which includes the current
+// generation of every table it reads from.
+func (d *DB) key(query string, args []any) string {
+	var b strings.Builder
+	b.WriteString(d.prefix)
+
+	d.mu.Lock()
+	for _, table := range tables(query) {
+		fmt.Fprintf(&b, "%s@%d,", table, d.generations[table])
+	}
+	d.mu.Unlock()
+
+	b.WriteByte(0)
+	b.WriteString(normalize(query))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\x00%T=%v", arg, arg)
+	}
+	return b.String()
+}
+
+// tables returns the tables query reads from or writes to, normalized.
+func tables(query string) []string {
+	var names []string
+	for _, m := range tablePattern.FindAllStringSubmatch(query, -1) {
+		names = append(names, normalizeTable(m[1]))
+	}
+	return names
+}
+
+// normalizeTable lowercases name and strips any quotes, so the same table always has the same tag.
+func normalizeTable(name string) string {
+	return strings.ToLower(strings.Trim(name, "\"`[]"))
+}
+
+// normalize collapses runs of whitespace outside quotes, so the same query formatted
+// differently shares a key.
+func normalize(query string) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// fakeDriver is a database/sql driver whose queries return the query itself and its
+// arguments as a single row, and count how many times they ran.
+type fakeDriver struct {
+	queries atomic.Int64
+	execErr error
+}
+
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) { return fakeConn{d}, nil }
+func (d *fakeDriver) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.queries.Add(1)
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return &fakeRows{row: []driver.Value{query, fmt.Sprint(values...)}}, nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), c.d.execErr
+}
+
+type fakeRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"query", "args"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
+
+func newDB(t *testing.T) (*DB, *fakeDriver) {
+	t.Helper()
+
+	d := &fakeDriver{}
+	sqlDB := sql.OpenDB(d)
+	t.Cleanup(func() { sqlDB.Close() })
+	c := cache.New(1<<20, cache.WithLogger(nil))
+	t.Cleanup(func() { c.Close() })
+	return New(sqlDB, c), d
+}
+
+func TestNormalize(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT  *\n\tFROM users ":          "SELECT * FROM users",
+		"SELECT * FROM t WHERE a = 'x   y'": "SELECT * FROM t WHERE a = 'x   y'",
+		"SELECT \"a  b\"  FROM t":           "SELECT \"a  b\" FROM t",
+	} {
+		if got := normalize(query); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestTables(t *testing.T) {
+	for query, want := range map[string][]string{
+		"SELECT * FROM users": {"users"},
+		"select * from Users u join `teams` t on u.team = t.id": {"users", "teams"},
+		`INSERT INTO "Users" (name) VALUES (?)`:                 {"users"},
+		"UPDATE [users] SET name = ?":                           {"users"},
+		"DELETE FROM public.users WHERE id = ?":                 {"public.users"},
+		"SELECT 1":                                              nil,
+	} {
+		if got := tables(query); !slices.Equal(got, want) {
+			t.Errorf("tables(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestQueryIsCached(t *testing.T) {
+	db, d := newDB(t)
+	ctx := context.Background()
+
+	res, err := db.Query(ctx, "SELECT * FROM users WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "SELECT * FROM users WHERE id = ?" {
+		t.Fatalf("Query returned %+v", res)
+	}
+
+	db.Query(ctx, "SELECT *  FROM users\nWHERE id = ?", 1)
+	if got := d.queries.Load(); got != 1 {
+		t.Errorf("the same query formatted differently ran %d times, want once", got)
+	}
+
+	db.Query(ctx, "SELECT * FROM users WHERE id = ?", 2)
+	db.Query(ctx, "SELECT * FROM users WHERE id = ?", "1")
+	if got := d.queries.Load(); got != 3 {
+		t.Errorf("queries with different arguments ran %d times, want 3", got)
+	}
+}
+
+func TestExecInvalidates(t *testing.T) {
+	db, d := newDB(t)
+	ctx := context.Background()
+
+	queries := []string{
+		"SELECT * FROM users",
+		"SELECT * FROM teams",
+		"SELECT * FROM users JOIN teams ON users.team = teams.id",
+	}
+	run := func() int64 {
+		before := d.queries.Load()
+		for _, query := range queries {
+			if _, err := db.Query(ctx, query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return d.queries.Load() - before
+	}
+
+	run()
+	if got := run(); got != 0 {
+		t.Fatalf("%d cached queries ran again", got)
+	}
+
+	if _, err := db.Exec(ctx, "UPDATE users SET name = ?", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(); got != 2 {
+		t.Errorf("after writing users, %d queries ran again, want 2: the ones reading users", got)
+	}
+
+	// A failed write may still have changed the table.
+	d.execErr = errors.New("failed")
+	if _, err := db.Exec(ctx, "INSERT INTO `Teams` (name) VALUES (?)", "name"); err == nil {
+		t.Fatal("Exec didn't return the driver's error")
+	}
+	if got := run(); got != 2 {
+		t.Errorf("after a failed write to teams, %d queries ran again, want 2: the ones reading teams", got)
+	}
+
+	db.Invalidate("USERS", "teams")
+	if got := run(); got != 3 {
+		t.Errorf("after invalidating both tables, %d queries ran again, want 3", got)
+	}
+}
@@ -0,0 +1,347 @@
+// Package standby streams every change on a primary cache to a standby instance over TCP,
+// so a failover process starts with a warm cache instead of an empty one.
+//
+// On the standby:
+//
+//	r := standby.NewReceiver(c)
+//	go r.ListenAndServe(":7070")
+//
+// On the primary:
+//
+//	s := standby.Stream(c, "standby:7070")
+//	defer s.Close()
+//
+// Values are sent with encoding/gob, so any types other than the basic ones must be
+// registered with gob.Register on both sides.
+package standby
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+const (
+	// eventBuffer is how many changes can be waiting to be sent before the standby is
+	// considered too far behind and gets resynced from a fresh snapshot.
+	eventBuffer = 4096
+
+	dialTimeout       = 5 * time.Second
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+const (
+	// opReset tells the standby a full snapshot follows, so it clears what it had.
+	opReset byte = iota + 1
+	opSet
+	opDelete
+	opClear
+)
+
+// message is a single change sent to the standby.
+type message struct {
+	Op        byte
+	Key       string
+	Value     any
+	ExpiresAt time.Time
+}
+
+// Streamer sends every change on a cache to a standby.
+type Streamer struct {
+	cache *cache.Cache
+	addr  string
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// Stream starts sending a snapshot of c and then every change to it to the Receiver at addr.
+//
+// Whenever the connection drops, or the standby falls too far behind, Stream reconnects
+// and resends a full snapshot.
+func Stream(c *cache.Cache, addr string) *Streamer {
+	s := &Streamer{
+		cache: c,
+		addr:  addr,
+		done:  make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Close stops streaming and waits for the connection to close.
+func (s *Streamer) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Streamer) run() {
+	defer s.wg.Done()
+
+	delay := minReconnectDelay
+	for {
+		connected, err := s.stream()
+		if connected {
+			delay = minReconnectDelay
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			log.Printf("standby: streaming to %s: %v. reconnecting in %s", s.addr, err, delay)
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// stream runs a single connection to the standby until it fails or the Streamer is closed.
+// connected reports whether the snapshot was sent, so the caller can reset its backoff.
+func (s *Streamer) stream() (connected bool, err error) {
+	conn, err := net.DialTimeout("tcp", s.addr, dialTimeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Subscribe before taking the snapshot so nothing in between is missed. Changes that
+	// are already in the snapshot are just applied a second time.
+	events := make(chan cache.Event, eventBuffer)
+	overflow := make(chan struct{})
+	var overflowed bool
+
+	unsubscribe := s.cache.Subscribe(func(ev cache.Event) {
+		if overflowed {
+			return
+		}
+		select {
+		case events <- ev:
+		default:
+			// Subscribers are called with the cache locked, so overflowed doesn't need its own lock.
+			overflowed = true
+			close(overflow)
+		}
+	})
+	defer unsubscribe()
+
+	// Copy the snapshot out first rather than writing to the network inside Range,
+	// which would hold the cache's lock for as long as the standby takes to read it.
+	var snapshot []cache.Item
+	s.cache.Range(func(item cache.Item) bool {
+		snapshot = append(snapshot, item)
+		return true
+	})
+
+	w := bufio.NewWriter(conn)
+	enc := gob.NewEncoder(w)
+
+	if err := enc.Encode(message{Op: opReset}); err != nil {
+		return false, err
+	}
+	for _, item := range snapshot {
+		if err := encode(enc, message{Op: opSet, Key: item.Key, Value: item.Value, ExpiresAt: item.ExpiresAt}); err != nil {
+			return false, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+
+	log.Printf("standby: sent snapshot of %d items to %s", len(snapshot), s.addr)
+
+	// Notice the standby going away even when there aren't any changes to send.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-s.done:
+			return true, nil
+		case <-closed:
+			return true, errors.New("connection closed by standby")
+		case <-overflow:
+			return true, errors.New("standby fell too far behind")
+		case ev := <-events:
+			if err := encode(enc, toMessage(ev)); err != nil {
+				return true, err
+			}
+
+			// Batch up whatever else is already waiting before flushing.
+			if len(events) == 0 {
+				if err := w.Flush(); err != nil {
+					return true, err
+				}
+			}
+		}
+	}
+}
+
+// encode writes msg, skipping values gob can't encode rather than dropping the connection,
+// since resending the snapshot would just hit the same value again.
+func encode(enc *gob.Encoder, msg message) error {
+	err := enc.Encode(msg)
+	if err == nil {
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrShortWrite) {
+		return err
+	}
+
+	log.Printf("standby: skipping %q: %v", msg.Key, err)
+	return nil
+}
+
+func toMessage(ev cache.Event) message {
+	switch ev.Type {
+	case cache.EventSet:
+		return message{Op: opSet, Key: ev.Key, Value: ev.Value, ExpiresAt: ev.ExpiresAt}
+	case cache.EventDelete:
+		return message{Op: opDelete, Key: ev.Key}
+	}
+	return message{Op: opClear}
+}
+
+// Receiver applies changes streamed from a primary to a standby cache.
+type Receiver struct {
+	cache *cache.Cache
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// NewReceiver creates a Receiver applying changes to c.
+func NewReceiver(c *cache.Cache) *Receiver {
+	return &Receiver{
+		cache:     c,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and applies changes from primaries until Close is called.
+func (r *Receiver) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(l)
+}
+
+// Serve accepts connections from primaries on l until Close is called. l is closed when Serve returns.
+func (r *Receiver) Serve(l net.Listener) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	r.listeners[l] = struct{}{}
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.listeners, l)
+		r.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		r.conns[conn] = struct{}{}
+		r.mu.Unlock()
+
+		go r.receive(conn)
+	}
+}
+
+// Close stops all listeners and closes every open connection.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	var err error
+	for l := range r.listeners {
+		err = errors.Join(err, l.Close())
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (r *Receiver) receive(conn net.Conn) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+
+	dec := gob.NewDecoder(bufio.NewReader(conn))
+
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("standby: receiving from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch msg.Op {
+		case opReset:
+			r.cache.Clear()
+		case opSet:
+			var ttl time.Duration
+			if !msg.ExpiresAt.IsZero() {
+				if ttl = time.Until(msg.ExpiresAt); ttl <= 0 {
+					continue
+				}
+			}
+			r.cache.SetWithTTL(msg.Key, msg.Value, ttl)
+		case opDelete:
+			r.cache.Delete(msg.Key)
+		case opClear:
+			r.cache.Clear()
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package standby
+
+import (
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+func TestMain(m *testing.M) {
+	// Connections, snapshots and resyncs are logged.
+	log.SetOutput(io.Discard)
+	m.Run()
+}
+
+// listener counts the connections it accepts, and can hold back reads from the first one
+// so the primary's writes back up.
+type listener struct {
+	net.Listener
+	accepted atomic.Int64
+	gate     chan struct{} // closed to let the first connection be read, or nil
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if l.accepted.Add(1) == 1 && l.gate != nil {
+		return &gatedConn{Conn: conn, gate: l.gate}, nil
+	}
+	return conn, nil
+}
+
+type gatedConn struct {
+	net.Conn
+	gate chan struct{}
+}
+
+func (c *gatedConn) Read(p []byte) (int, error) {
+	<-c.gate
+	return c.Conn.Read(p)
+}
+
+// serve starts a Receiver applying changes to a new cache, returning the cache.
+func serve(t *testing.T, l *listener) *cache.Cache {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Listener = ln
+
+	c := cache.New(1<<28, cache.WithLogger(nil))
+	r := NewReceiver(c)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.Serve(l)
+	}()
+	t.Cleanup(func() {
+		r.Close()
+		wg.Wait()
+		c.Close()
+	})
+	return c
+}
+
+// waitFor waits for cond to be true.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSnapshotAndStream(t *testing.T) {
+	l := &listener{}
+	standby := serve(t, l)
+	standby.Set("stale", "left over from an earlier primary")
+
+	primary := cache.New(1<<20, cache.WithLogger(nil))
+	defer primary.Close()
+	primary.Set("a", "1")
+	primary.SetWithTTL("b", "2", time.Hour)
+	primary.Set("deleted", "3")
+
+	s := Stream(primary, l.Addr().String())
+	defer s.Close()
+
+	waitFor(t, "the snapshot", func() bool { return standby.Has("a") && standby.Has("b") })
+	if standby.Has("stale") {
+		t.Error("the standby kept a key that isn't on the primary after the snapshot")
+	}
+	if ttl, _ := standby.TTL("b"); ttl == cache.NoExpiration || ttl > time.Hour {
+		t.Errorf("an item with a TTL of an hour arrived with a TTL of %s", ttl)
+	}
+
+	primary.Set("c", "4")
+	primary.Delete("deleted")
+	waitFor(t, "the changes", func() bool { return standby.Has("c") && !standby.Has("deleted") })
+	if value, _ := standby.Get("c"); value != "4" {
+		t.Errorf("a key set after the snapshot arrived as %v, want 4", value)
+	}
+
+	primary.Clear()
+	waitFor(t, "the clear", func() bool { return len(standby.Keys()) == 0 })
+
+	if got := l.accepted.Load(); got != 1 {
+		t.Errorf("the primary connected %d times, want 1", got)
+	}
+}
+
+func TestResyncAfterOverflow(t *testing.T) {
+	l := &listener{gate: make(chan struct{})}
+	standby := serve(t, l)
+
+	primary := cache.New(1<<28, cache.WithLogger(nil))
+	defer primary.Close()
+
+	s := Stream(primary, l.Addr().String())
+	defer s.Close()
+	waitFor(t, "the primary to connect", func() bool { return l.accepted.Load() == 1 })
+
+	// The standby isn't reading, so once the connection's buffers are full the primary
+	// can't keep up and more changes pile up than it buffers.
+	const n = 2 * eventBuffer
+	value := strings.Repeat("x", 4<<10)
+	for i := range n {
+		primary.Set(strconv.Itoa(i), value)
+	}
+	close(l.gate)
+
+	waitFor(t, "the primary to reconnect", func() bool { return l.accepted.Load() >= 2 })
+	waitFor(t, "the resync", func() bool { return len(standby.Keys()) == n })
+	for i := range n {
+		if v, _ := standby.Get(strconv.Itoa(i)); v != value {
+			t.Fatalf("key %d is %.10q on the standby after the resync", i, v)
+		}
+	}
+}
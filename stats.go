@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of the cache's usage.
+type Stats struct {
+	Items   int   `json:"items"`
+	Size    int64 `json:"size"`
+	MaxSize int64 `json:"max_size"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+
+	// Clears is the number of times the cache was cleared because it exceeded MaxSize.
+	Clears int64 `json:"clears"`
+
+	// Rotations is the number of times the previous generation was dropped, see WithGenerations.
+	Rotations int64 `json:"rotations,omitempty"`
+
+	// Evictions is the number of items evicted to stay under the soft limit (see WithSoftLimit),
+	// after SetMaxCacheSize shrank the cache, or for being idle (see WithIdleTimeout).
+	Evictions int64 `json:"evictions"`
+
+	// IdleEvictions is how many of Evictions were for being idle.
+	IdleEvictions int64 `json:"idle_evictions,omitempty"`
+
+	// Expirations is the number of expired items removed, either by the janitor (see WithJanitor)
+	// or when they were next looked up.
+	Expirations int64 `json:"expirations,omitempty"`
+
+	// Spilled and SpilledSize are the number and total size of values written to temporary
+	// files (see WithSpill). Size only counts a small handle for each of them.
+	Spilled     int64 `json:"spilled,omitempty"`
+	SpilledSize int64 `json:"spilled_size,omitempty"`
+
+	// NotAdmitted is the number of writes and loads that weren't cached because it was the
+	// first time their key was seen (see WithDoorkeeper), or because it was less frequent than
+	// the item it would have evicted (see WithFrequencySketch).
+	NotAdmitted int64 `json:"not_admitted,omitempty"`
+
+	// AsyncDropped is the number of writes SetAsync dropped because its queue was full.
+	AsyncDropped int64 `json:"async_dropped,omitempty"`
+
+	// LockWaits is the number of times a read or write had to wait for another to release the
+	// cache's lock, and LockWaitTime is how long they waited in total. Most lookups that wait,
+	// wait for a write, so a high LockWaitTime is a sign the cache needs fewer writes or
+	// splitting up.
+	LockWaits    int64         `json:"lock_waits,omitempty"`
+	LockWaitTime time.Duration `json:"lock_wait_time,omitempty"`
+
+	// HotKeys is how many items are copied for reads that don't lock, see WithHotKeyReplication.
+	HotKeys int `json:"hot_keys,omitempty"`
+
+	// Corruptions is the number of values that failed checksum verification (see WithChecksums).
+	Corruptions int64 `json:"corruptions,omitempty"`
+
+	// Groups holds usage for each registered key group, keyed by group name.
+	Groups map[string]GroupStats `json:"groups,omitempty"`
+
+	// TopKeys is the most read and largest items, if the cache was created WithTopKeys.
+	TopKeys *TopKeys `json:"top_keys,omitempty"`
+}
+
+// HitRate returns the fraction of lookups that were hits.
+func (s Stats) HitRate() float64 {
+	return hitRate(s.Hits, s.Misses)
+}
+
+// GroupStats is the usage of a single key group.
+type GroupStats struct {
+	Items  int   `json:"items"`
+	Size   int64 `json:"size"`
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// HitRate returns the fraction of lookups in the group that were hits.
+func (s GroupStats) HitRate() float64 {
+	return hitRate(s.Hits, s.Misses)
+}
+
+func hitRate(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+type group struct {
+	name  string
+	match func(key string) bool
+
+	// hits and misses are updated while c.mu is only read locked, hence the atomics, which are
+	// padded since lookups of different keys in the group update them at the same time.
+	hits   paddedInt64
+	misses paddedInt64
+}
+
+// RegisterGroupPrefix registers a key group containing every key that starts with prefix.
+func (c *Cache) RegisterGroupPrefix(name, prefix string) {
+	c.registerGroup(name, func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// RegisterGroupPattern registers a key group containing every key matching pattern.
+//
+// The pattern syntax is the same as path.Match, e.g. "users:*:profile".
+func (c *Cache) RegisterGroupPattern(name, pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for group %q: %w", pattern, name, err)
+	}
+
+	c.registerGroup(name, func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	})
+	return nil
+}
+
+// registerGroup adds a group, replacing any existing group with the same name.
+func (c *Cache) registerGroup(name string, match func(key string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := &group{name: name, match: match}
+	c.dropHotKeys() // their groups are worked out when they're copied
+
+	for i, existing := range c.groups {
+		if existing.name == name {
+			c.groups[i] = g
+			return
+		}
+	}
+	c.groups = append(c.groups, g)
+}
+
+// recordAccess updates the hit and miss counters for a lookup of key.
+// c.mu must be at least read locked.
+func (c *Cache) recordAccess(key string, hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	// A key can belong to more than one group, so every matching group is counted.
+	for _, g := range c.groups {
+		if !g.match(key) {
+			continue
+		}
+		if hit {
+			g.hits.Add(1)
+		} else {
+			g.misses.Add(1)
+		}
+	}
+
+	if c.keyspace != nil {
+		c.keyspace.record(key, hit)
+	}
+	c.recordFrequency(key)
+}
+
+// Stats returns a snapshot of the cache's current usage.
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := Stats{
+		Items:         len(c.items),
+		Size:          c.totalCacheSize,
+		MaxSize:       c.maxCacheSize,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Clears:        c.clears,
+		Evictions:     c.evictions,
+		IdleEvictions: c.idleEvictions,
+		Rotations:     c.rotations,
+		Expirations:   c.expirations,
+		Corruptions:   c.corruptions.Load(),
+		NotAdmitted:   c.notAdmitted.Load(),
+		AsyncDropped:  c.asyncDropped.Load(),
+		LockWaits:     c.mu.waits.Load(),
+		LockWaitTime:  time.Duration(c.mu.waited.Load()),
+	}
+	if hot := c.hot.Load(); hot != nil {
+		stats.HotKeys = len(hot.items)
+	}
+	if c.spilled != nil {
+		stats.Spilled = c.spilled.items.Load()
+		stats.SpilledSize = c.spilled.bytes.Load()
+	}
+
+	if c.topKeys > 0 {
+		top := c.topKeysLocked(c.topKeys)
+		stats.TopKeys = &top
+	}
+
+	if len(c.groups) == 0 {
+		return stats
+	}
+
+	stats.Groups = make(map[string]GroupStats, len(c.groups))
+	for _, g := range c.groups {
+		gs := GroupStats{
+			Hits:   g.hits.Load(),
+			Misses: g.misses.Load(),
+		}
+		for key, i := range c.items {
+			if g.match(key) {
+				gs.Items++
+				gs.Size += int64(len(key)) + c.arena.entry(i).size
+			}
+		}
+		stats.Groups[g.name] = gs
+	}
+
+	return stats
+}
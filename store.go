@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// storeLockStripes is how many locks writes to the store are spread across.
+const storeLockStripes = 64
+
+// Store is a backing system, such as a database, that the cache writes through to.
+type Store interface {
+	// Get loads the value for key, returning ErrNotFound if it doesn't exist.
+	Get(ctx context.Context, key string) (any, error)
+
+	Set(ctx context.Context, key string, value any) error
+	Delete(ctx context.Context, key string) error
+}
+
+var storeLockSeed = maphash.MakeSeed()
+
+// storeLock returns the lock serializing store writes for key, so two writes to the same
+// key can't reach the store and the cache in different orders.
+func (c *Cache) storeLock(key string) *sync.Mutex {
+	return &c.storeLocks[maphash.String(storeLockSeed, key)%storeLockStripes]
+}
+
+// write adds an item, writing it through to the store first if the cache has one,
+// or queueing it for the store in write-behind mode.
+//
+// If the store write fails, the error is logged and the cached copy is dropped, since
+// the store may or may not have the new value. The next read goes back to the store.
+// The error is also returned, for SetE.
+//
+// ctx is passed to the store, and bounds waiting for the cache's lock when there's no store.
+// Once the store has been written, the cache is always updated to match.
+func (c *Cache) write(ctx context.Context, key string, value any, expiresAt int64) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	c.recordFrequency(key)
+
+	if c.store == nil && c.writeBehind == nil {
+		if !c.admitted(key) {
+			return nil
+		}
+
+		stored, err := c.stored(key, value)
+		if err != nil {
+			c.logf("not caching %q: %v", key, err)
+			return err
+		}
+
+		if err := c.lockContext(ctx); err != nil {
+			c.dropSpill(stored)
+			return err
+		}
+		defer c.mu.Unlock()
+
+		return c.set(key, value, stored, expiresAt, false)
+	}
+
+	lock := c.storeLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if c.writeBehind != nil {
+		c.writeBehind.enqueue(Write{Key: key, Value: value})
+		return c.setLocal(key, value, expiresAt, false)
+	}
+
+	if err := c.store.Set(ctx, key, value); err != nil {
+		c.logf("error writing %q through to store: %v", key, err)
+		c.deleteLocal(key)
+		return fmt.Errorf("cache: writing %q through to store: %w", key, err)
+	}
+
+	if !c.admitted(key) {
+		return nil
+	}
+	return c.setLocal(key, value, expiresAt, false)
+}
+
+// SetLocal adds an item that expires after ttl to the cache only, like SetWithTTL, but never
+// writes it to the store, for values that are already there, such as ones replicated from
+// another instance sharing the store. A ttl <= 0 means the item never expires.
+func (c *Cache) SetLocal(key string, value any, ttl time.Duration) {
+	if c.closed.Load() {
+		return
+	}
+	c.setLocal(key, value, c.expiresAt(ttl), false)
+}
+
+// DeleteLocal removes an item from the cache only, like Delete, but never deletes it from the
+// store, for the same reasons as SetLocal.
+func (c *Cache) DeleteLocal(key string) {
+	if c.closed.Load() {
+		return
+	}
+	c.deleteLocal(key)
+}
+
+// deleteThrough deletes an item from the store and then the cache, or queues the delete
+// in write-behind mode. The cached copy is dropped even if the store fails, for the same
+// reason as write.
+func (c *Cache) deleteThrough(key string) error {
+	lock := c.storeLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if c.writeBehind != nil {
+		c.writeBehind.enqueue(Write{Key: key, Delete: true})
+		c.deleteLocal(key)
+		return nil
+	}
+
+	err := c.store.Delete(context.Background(), key)
+	if err != nil {
+		c.logf("error deleting %q from store: %v", key, err)
+		err = fmt.Errorf("cache: deleting %q from store: %w", key, err)
+	}
+
+	c.deleteLocal(key)
+	return err
+}
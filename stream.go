@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// SetReader reads a value from r straight into chunks and stores it as a *Chunked (see Append),
+// so large bodies, e.g. in an HTTP proxy, are only buffered once. size is the value's length,
+// or -1 to read until EOF. If r ends before size bytes, nothing's cached and
+// io.ErrUnexpectedEOF is returned.
+//
+// Values bigger than maxCacheSize return ErrTooLarge without being read, if size is known, and
+// otherwise as soon as that many bytes have been read. Like Append, the value only lives in the
+// cache and gets the default TTL.
+func (c *Cache) SetReader(key string, r io.Reader, size int64) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+
+	c.mu.RLock()
+	maxCacheSize := c.maxCacheSize
+	c.mu.RUnlock()
+
+	if size > maxCacheSize {
+		return fmt.Errorf("%w: %q is %d bytes, max cache size is %d bytes", ErrTooLarge, key, size, maxCacheSize)
+	}
+
+	v := &Chunked{}
+	for size < 0 || v.size < size {
+		chunk := make([]byte, ChunkSize)
+		if size >= 0 {
+			chunk = chunk[:min(ChunkSize, size-v.size)]
+		}
+
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			v.chunks = append(v.chunks, chunk[:n])
+			v.size += int64(n)
+		}
+
+		if v.size > maxCacheSize {
+			return fmt.Errorf("%w: %q is over %d bytes, max cache size is %d bytes", ErrTooLarge, key, maxCacheSize, maxCacheSize)
+		}
+		if size < 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			break
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("cache: reading %q: %w", key, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.set(key, v, v, c.expiresAt(time.Duration(c.defaultTTL.Load())), false)
+}
+
+// GetReader returns a reader over key's value, which can be a *Chunked, a []byte or a string.
+// Other values are reported as missing. The reader keeps working if the item is replaced or
+// deleted while it's being read.
+func (c *Cache) GetReader(key string) (io.ReadSeeker, bool) {
+	value, found := c.Get(key)
+	if !found {
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case *Chunked:
+		return io.NewSectionReader(v, 0, v.size), true
+	case []byte:
+		return bytes.NewReader(v), true
+	case string:
+		return strings.NewReader(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The E variants below do the same as their plain counterparts, but return an error for the
+// conditions those handle silently, for callers that want to handle them explicitly.
+
+// ErrTooLarge is returned by SetE and SetWithTTLE for items that are bigger than maxCacheSize on
+// their own. Set caches them anyway, which immediately clears or evicts everything.
+var ErrTooLarge = errors.New("cache: item too large")
+
+// ErrWrongType is returned by the typed E getters when the cached value isn't the requested type.
+var ErrWrongType = errors.New("cache: wrong type")
+
+// SetE is like Set, but returns:
+//   - ErrClosed if the cache has been closed.
+//   - ErrTooLarge if the item is bigger than maxCacheSize on its own, without caching it.
+//   - ErrCacheFull if the cache rejected the write, see OverflowReject.
+//   - The store's error if the cache was created WithWriteThrough and writing through failed.
+func (c *Cache) SetE(key string, value any) error {
+	return c.setE(context.Background(), key, value, time.Duration(c.defaultTTL.Load()))
+}
+
+// SetWithTTLE is like SetWithTTL, but returns the same errors as SetE.
+func (c *Cache) SetWithTTLE(key string, value any, ttl time.Duration) error {
+	return c.setE(context.Background(), key, value, ttl)
+}
+
+func (c *Cache) setE(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := c.rlockContext(ctx); err != nil {
+		return err
+	}
+	maxCacheSize := c.maxCacheSize
+	c.mu.RUnlock()
+
+	if size := int64(len(key)) + estimateItemSize(value); size > maxCacheSize {
+		return fmt.Errorf("%w: %q is %d bytes, max cache size is %d bytes", ErrTooLarge, key, size, maxCacheSize)
+	}
+	return c.write(ctx, key, value, c.expiresAt(ttl))
+}
+
+// GetE is like Get, but returns ErrNotFound for misses, the loader's error if the cache was
+// created WithLoader and loading failed, and ErrClosed if the cache has been closed.
+func (c *Cache) GetE(key string) (any, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	if c.loader != nil {
+		return c.GetOrCompute(context.Background(), key, nil)
+	}
+
+	value, found := c.Get(key)
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, key)
+	}
+	return value, nil
+}
+
+// GetAsE is like GetAs, but returns the same errors as GetE, and ErrWrongType if the value isn't a T.
+func GetAsE[T any](c *Cache, key string) (T, error) {
+	var zero T
+
+	value, err := c.GetE(key)
+	if err != nil {
+		return zero, err
+	}
+
+	v, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: %q is %T, not %T", ErrWrongType, key, value, zero)
+	}
+	return v, nil
+}
+
+// GetBytesE is like GetAsE for []byte values.
+func (c *Cache) GetBytesE(key string) ([]byte, error) {
+	return GetAsE[[]byte](c, key)
+}
+
+// GetStringE is like GetAsE for string values.
+func (c *Cache) GetStringE(key string) (string, error) {
+	return GetAsE[string](c, key)
+}
+
+// DeleteE is like Delete, but returns the store's error if the cache was created WithWriteThrough
+// and deleting from the store failed, in which case the item is removed from the cache anyway,
+// and ErrClosed if the cache has been closed.
+func (c *Cache) DeleteE(key string) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	if c.store != nil || c.writeBehind != nil {
+		return c.deleteThrough(key)
+	}
+	c.deleteLocal(key)
+	return nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Tiered chains two caches, a small fast L1, e.g. one holding plain values, in front of a larger
+// L2, e.g. one created WithCompression, WithSerializer or WithSpill.
+//
+// Every write goes to both, so L2 holds everything and L1 holds the items read most recently.
+// A read that misses L1 and hits L2 promotes the item back into L1, keeping the expiry it has in
+// L2, and items that L1 evicts or clears are demoted by simply being left in L2.
+//
+// Neither cache should have a loader, since a miss in L1 would then load instead of falling
+// through to L2. Use GetOrCompute to load items that are in neither.
+type Tiered struct {
+	l1, l2 *Cache
+
+	hits       atomic.Int64
+	misses     atomic.Int64
+	promotions atomic.Int64
+}
+
+// NewTiered returns a Tiered cache with l1 in front of l2.
+func NewTiered(l1, l2 *Cache) *Tiered {
+	return &Tiered{l1: l1, l2: l2}
+}
+
+// Get retrieves an item from L1, or from L2 if it isn't in L1, promoting it to L1.
+func (t *Tiered) Get(key string) (any, bool) {
+	if value, found := t.l1.Get(key); found {
+		t.hits.Add(1)
+		return value, true
+	}
+
+	value, expiresAt, found := t.l2.GetWithExpiration(key)
+	if !found {
+		t.misses.Add(1)
+		return nil, false
+	}
+
+	t.hits.Add(1)
+	t.promotions.Add(1)
+	t.l1.SetWithDeadline(key, value, expiresAt)
+	return value, true
+}
+
+// GetOrCompute is like Get, but if the item is in neither cache, calls compute to load it and
+// adds it to both. Concurrent loads of the same key share a single call to compute.
+func (t *Tiered) GetOrCompute(ctx context.Context, key string, compute LoaderFunc) (any, error) {
+	if value, found := t.Get(key); found {
+		return value, nil
+	}
+
+	value, err := t.l2.GetOrCompute(ctx, key, compute)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, expiresAt, found := t.l2.GetWithExpiration(key); found {
+		t.l1.SetWithDeadline(key, value, expiresAt)
+	}
+	return value, nil
+}
+
+// Set adds an item to both caches, with each cache's default TTL.
+func (t *Tiered) Set(key string, value any) {
+	t.l2.Set(key, value)
+	t.l1.Set(key, value)
+}
+
+// SetWithTTL adds an item to both caches that expires after ttl.
+func (t *Tiered) SetWithTTL(key string, value any, ttl time.Duration) {
+	t.l2.SetWithTTL(key, value, ttl)
+	t.l1.SetWithTTL(key, value, ttl)
+}
+
+// Delete removes an item from both caches.
+func (t *Tiered) Delete(key string) {
+	t.l1.Delete(key)
+	t.l2.Delete(key)
+}
+
+// Clear removes every item from both caches.
+func (t *Tiered) Clear() {
+	t.l1.Clear()
+	t.l2.Clear()
+}
+
+// TieredStats is the usage of a Tiered cache as a whole, along with each cache's own stats.
+type TieredStats struct {
+	// Hits and Misses count reads of the Tiered cache, so a read that misses L1 and hits L2 is
+	// a single hit.
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+
+	// Promotions is the number of items read from L2 and added back to L1.
+	Promotions int64 `json:"promotions"`
+
+	L1 Stats `json:"l1"`
+	L2 Stats `json:"l2"`
+}
+
+// HitRate returns the fraction of reads that hit either cache.
+func (s TieredStats) HitRate() float64 {
+	return hitRate(s.Hits, s.Misses)
+}
+
+// Stats returns a snapshot of the Tiered cache's usage.
+func (t *Tiered) Stats() TieredStats {
+	return TieredStats{
+		Hits:       t.hits.Load(),
+		Misses:     t.misses.Load(),
+		Promotions: t.promotions.Load(),
+		L1:         t.l1.Stats(),
+		L2:         t.l2.Stats(),
+	}
+}
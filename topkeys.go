@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+	"sync/atomic"
+)
+
+// TopKeys is the most read and the largest items in the cache, see Cache.TopKeys.
+type TopKeys struct {
+	// Hottest is ordered by Hits, most first.
+	Hottest []Metadata `json:"hottest"`
+
+	// Largest is ordered by the size of the key and value together, largest first.
+	Largest []Metadata `json:"largest"`
+}
+
+// WithTopKeys includes the k most read and k largest items in every Stats, in Stats.TopKeys,
+// so hot keys and oversized values show up on dashboards before they cause trouble.
+//
+// Finding them means going through every item, which Stats otherwise doesn't need to do, so
+// only enable it when Stats isn't called too often for the cache's size.
+func WithTopKeys(k int) Option {
+	return func(c *Cache) {
+		c.topKeys = k
+	}
+}
+
+// TopKeys returns the metadata of the k most read and k largest unexpired items in the cache.
+// It only keeps k of each while it goes through the items, so it's cheap even for big caches.
+func (c *Cache) TopKeys(k int) TopKeys {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.topKeysLocked(k)
+}
+
+// topKeysLocked is TopKeys for callers that already hold c.mu.
+func (c *Cache) topKeysLocked(k int) TopKeys {
+	if k <= 0 {
+		return TopKeys{}
+	}
+
+	hottest := &topHeap{cmp: func(a, b Metadata) int {
+		return cmp.Or(cmp.Compare(a.Hits, b.Hits), cmp.Compare(b.Key, a.Key))
+	}}
+	largest := &topHeap{cmp: func(a, b Metadata) int {
+		return cmp.Or(cmp.Compare(a.Size+int64(len(a.Key)), b.Size+int64(len(b.Key))), cmp.Compare(b.Key, a.Key))
+	}}
+
+	now := c.now()
+	for key, i := range c.items {
+		e := c.arena.entry(i)
+		if e.expired(now) {
+			continue
+		}
+
+		// Most items don't make either list, so they're ruled out before building their metadata.
+		hits, size := atomic.LoadInt64(&e.hits), e.size+int64(len(key))
+		if hottest.full(k) && hits < hottest.nodes[0].Hits && largest.full(k) && size < largest.nodes[0].Size+int64(len(largest.nodes[0].Key)) {
+			continue
+		}
+
+		m := e.metadata(key)
+		hottest.offer(m, k)
+		largest.offer(m, k)
+	}
+
+	return TopKeys{Hottest: hottest.sorted(), Largest: largest.sorted()}
+}
+
+// topHeap keeps the k greatest Metadata offered to it, by cmp, with the least of them at the root.
+type topHeap struct {
+	nodes []Metadata
+	cmp   func(a, b Metadata) int
+}
+
+func (h *topHeap) full(k int) bool {
+	return len(h.nodes) >= k
+}
+
+// offer adds m if it's one of the k greatest so far.
+func (h *topHeap) offer(m Metadata, k int) {
+	switch {
+	case !h.full(k):
+		heap.Push(h, m)
+	case h.cmp(m, h.nodes[0]) > 0:
+		h.nodes[0] = m
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns the nodes, greatest first.
+func (h *topHeap) sorted() []Metadata {
+	slices.SortFunc(h.nodes, func(a, b Metadata) int {
+		return h.cmp(b, a)
+	})
+	return h.nodes
+}
+
+func (h *topHeap) Len() int           { return len(h.nodes) }
+func (h *topHeap) Less(i, j int) bool { return h.cmp(h.nodes[i], h.nodes[j]) < 0 }
+func (h *topHeap) Swap(i, j int)      { h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i] }
+func (h *topHeap) Push(x any)         { h.nodes = append(h.nodes, x.(Metadata)) }
+
+func (h *topHeap) Pop() any {
+	m := h.nodes[len(h.nodes)-1]
+	h.nodes = h.nodes[:len(h.nodes)-1]
+	return m
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TraceOp is the kind of a TraceEvent.
+type TraceOp uint8
+
+const (
+	// TraceGet is a read. Its size is the size of the value if it was a hit, or 0 for a miss.
+	TraceGet TraceOp = iota + 1
+	TraceSet
+	TraceDelete
+)
+
+func (op TraceOp) String() string {
+	switch op {
+	case TraceGet:
+		return "get"
+	case TraceSet:
+		return "set"
+	case TraceDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// TraceEvent is a single recorded operation. Values aren't recorded, only their sizes.
+type TraceEvent struct {
+	Op  TraceOp
+	Key string
+
+	// Size is the size of the value, not including the key. It's 0 for deletes.
+	Size int64
+}
+
+// traceMagic starts every trace file.
+const traceMagic = "cachetrace1\n"
+
+// maxTraceKeys is how many distinct keys a trace numbers. Keys seen after that are written out in
+// full every time, so recording a huge key space doesn't use unbounded memory.
+const maxTraceKeys = 1 << 20
+
+// Flags in the op byte: the key follows in full, and is numbered (traceNewKey) or not (traceInlineKey).
+// Otherwise the key's number follows.
+const (
+	traceNewKey    = 0x80
+	traceInlineKey = 0x40
+	traceOpMask    = 0x3f
+)
+
+// TraceRecorder writes a compact trace of a cache's gets, sets and deletes, with keys and value
+// sizes but not values, for offline debugging with ReplayTrace or capacity planning with the
+// simulate package. Each key is written in full the first time it's seen and as a small number
+// after that. It's safe for concurrent use.
+type TraceRecorder struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	keys map[string]uint64
+	buf  []byte
+	err  error
+}
+
+// NewTraceRecorder returns a recorder writing to w. Pass it to WithTraceRecorder, and Flush it,
+// or Close the cache, when done.
+func NewTraceRecorder(w io.Writer) *TraceRecorder {
+	t := &TraceRecorder{w: bufio.NewWriter(w), keys: make(map[string]uint64)}
+	_, t.err = t.w.WriteString(traceMagic)
+	return t
+}
+
+// WithTraceRecorder records every Get, Set and Delete with r. Recording stops at the first
+// write error, which Flush returns.
+func WithTraceRecorder(r *TraceRecorder) Option {
+	return func(c *Cache) {
+		c.recorder = r
+	}
+}
+
+// Flush writes any buffered events, and returns the first error writing the trace.
+func (t *TraceRecorder) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+func (t *TraceRecorder) record(op TraceOp, key string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return
+	}
+
+	buf := t.buf[:0]
+	if id, known := t.keys[key]; known {
+		buf = append(buf, byte(op))
+		buf = binary.AppendUvarint(buf, id)
+	} else {
+		flag := byte(traceInlineKey)
+		if len(t.keys) < maxTraceKeys {
+			t.keys[key] = uint64(len(t.keys))
+			flag = traceNewKey
+		}
+		buf = append(buf, byte(op)|flag)
+		buf = binary.AppendUvarint(buf, uint64(len(key)))
+		buf = append(buf, key...)
+	}
+	if op != TraceDelete {
+		buf = binary.AppendUvarint(buf, uint64(size))
+	}
+	t.buf = buf
+
+	_, t.err = t.w.Write(buf)
+}
+
+// TraceReader reads a trace written by a TraceRecorder.
+type TraceReader struct {
+	r    *bufio.Reader
+	keys []string
+	err  error
+}
+
+// NewTraceReader returns a reader for the trace in r.
+func NewTraceReader(r io.Reader) *TraceReader {
+	t := &TraceReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(traceMagic))
+	if _, err := io.ReadFull(t.r, magic); err != nil || string(magic) != traceMagic {
+		t.err = errors.New("cache: not a trace")
+	}
+	return t
+}
+
+// Next returns the next event in the trace, or io.EOF at the end of it.
+func (t *TraceReader) Next() (TraceEvent, error) {
+	if t.err != nil {
+		return TraceEvent{}, t.err
+	}
+
+	ev, err := t.next()
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = errors.New("cache: trace is truncated")
+		}
+		t.err = err
+	}
+	return ev, err
+}
+
+func (t *TraceReader) next() (TraceEvent, error) {
+	b, err := t.r.ReadByte()
+	if err != nil {
+		return TraceEvent{}, err
+	}
+
+	ev := TraceEvent{Op: TraceOp(b & traceOpMask)}
+	if ev.Op.String() == "unknown" {
+		return TraceEvent{}, fmt.Errorf("cache: trace has unknown op %d", ev.Op)
+	}
+
+	if b&(traceNewKey|traceInlineKey) != 0 {
+		n, err := t.uvarint()
+		if err != nil {
+			return TraceEvent{}, err
+		}
+		key := make([]byte, n)
+		if _, err := io.ReadFull(t.r, key); err != nil {
+			return TraceEvent{}, io.ErrUnexpectedEOF
+		}
+		ev.Key = string(key)
+		if b&traceNewKey != 0 {
+			t.keys = append(t.keys, ev.Key)
+		}
+	} else {
+		id, err := t.uvarint()
+		if err != nil {
+			return TraceEvent{}, err
+		}
+		if id >= uint64(len(t.keys)) {
+			return TraceEvent{}, fmt.Errorf("cache: trace refers to unknown key %d", id)
+		}
+		ev.Key = t.keys[id]
+	}
+
+	if ev.Op != TraceDelete {
+		size, err := t.uvarint()
+		if err != nil {
+			return TraceEvent{}, err
+		}
+		ev.Size = int64(size)
+	}
+	return ev, nil
+}
+
+func (t *TraceReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(t.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// Replay applies a recorded event to the cache, with a placeholder value of the recorded size.
+// A get that misses is followed by a set if the event has a size, as if the item had been
+// loaded, since the recorded cache had it.
+func (c *Cache) Replay(ev TraceEvent) {
+	switch ev.Op {
+	case TraceGet:
+		if _, found := c.Get(ev.Key); !found && ev.Size > 0 {
+			c.Set(ev.Key, tracedValue(ev.Size))
+		}
+	case TraceSet:
+		c.Set(ev.Key, tracedValue(ev.Size))
+	case TraceDelete:
+		c.Delete(ev.Key)
+	}
+}
+
+// ReplayTrace replays every event in the trace in r with Replay, e.g. to reproduce a production
+// cache's behavior offline, and returns how many it replayed.
+func (c *Cache) ReplayTrace(r io.Reader) (int, error) {
+	tr := NewTraceReader(r)
+
+	var n int
+	for {
+		ev, err := tr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		c.Replay(ev)
+		n++
+	}
+}
+
+// tracedValue stands in for a replayed value. It only has a size.
+type tracedValue int64
+
+func (v tracedValue) Size() int64 { return int64(v) }
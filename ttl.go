@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// NoExpiration is returned by TTL for items that never expire.
+const NoExpiration time.Duration = -1
+
+// SetWithTTL adds an item to the cache that expires after ttl, replacing any existing item.
+//
+// A ttl <= 0 means the item never expires.
+func (c *Cache) SetWithTTL(key string, value any, ttl time.Duration) {
+	c.write(context.Background(), key, value, c.expiresAt(ttl))
+}
+
+// SetWithDeadline adds an item to the cache that expires at deadline, replacing any existing
+// item, for data that's only valid until a fixed time, like a token's expiry or the market
+// close. Unlike SetWithTTL it isn't affected by WithTTLJitter.
+//
+// A zero deadline means the item never expires, and one that's already passed replaces any
+// existing item with one that's already expired.
+func (c *Cache) SetWithDeadline(key string, value any, deadline time.Time) {
+	var expiresAt int64
+	if !deadline.IsZero() {
+		// At least 1, since 0 means never.
+		expiresAt = max(deadline.UnixNano(), 1)
+	}
+	c.write(context.Background(), key, value, expiresAt)
+}
+
+// Expire updates an existing item to expire after ttl. A ttl <= 0 removes the expiration.
+//
+// Returns false if the item isn't in the cache.
+func (c *Cache) Expire(key string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, e := c.lookup(key)
+	if e == nil || e.expired(c.now()) {
+		return false
+	}
+
+	c.unreplicate(key)
+	e.expiresAt = c.expiresAt(ttl)
+	c.expiryChanged(key, i)
+	value, _ := c.output(key, c.arena.value(i))
+	c.notify(setEvent(key, value, e))
+	return true
+}
+
+// GetWithExpiration is like Get, but also returns when the item expires, e.g. to set an Age or
+// Expires header, or the zero time if it never does. It doesn't load missing items or return
+// stale ones.
+func (c *Cache) GetWithExpiration(key string) (any, time.Time, bool) {
+	c.mu.RLock()
+	value, found, _, expired := c.getLocked(key, false)
+	var expiresAt int64
+	if found {
+		_, e := c.lookup(key)
+		expiresAt = e.expiresAt
+	}
+	c.mu.RUnlock()
+
+	if expired {
+		c.reclaim(key)
+	}
+
+	if found && c.transformsOutput() {
+		value, found = c.output(key, value)
+	}
+	if !found {
+		return nil, time.Time{}, false
+	}
+
+	if expiresAt == 0 {
+		return value, time.Time{}, true
+	}
+	return value, time.Unix(0, expiresAt), true
+}
+
+// TTL returns how long until an item expires, or NoExpiration if it never does.
+//
+// Returns false if the item isn't in the cache.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+
+	_, e := c.lookup(key)
+	if e == nil || e.expired(now) {
+		return 0, false
+	}
+
+	if e.expiresAt == 0 {
+		return NoExpiration, true
+	}
+	return time.Duration(e.expiresAt - now), true
+}
+
+// WithTTLJitter randomly lengthens or shortens every TTL by up to fraction of it, e.g. 0.1 for
+// ±10%, so items written together, like after a clear or while warming up, don't all expire
+// at the same moment and send a burst of loads to the origin. It applies to the default TTL,
+// SetWithTTL and Expire alike.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *Cache) {
+		c.ttlJitter = fraction
+	}
+}
+
+func (c *Cache) expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if c.ttlJitter > 0 {
+		ttl += time.Duration((c.random()*2 - 1) * c.ttlJitter * float64(ttl))
+	}
+	return c.now() + int64(ttl)
+}
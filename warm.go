@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultWarmConcurrency is how many items Warm and WarmFunc load at once unless
+// WithWarmConcurrency says otherwise.
+const DefaultWarmConcurrency = 8
+
+// WarmOption configures Warm and WarmFunc.
+type WarmOption func(*warmConfig)
+
+type warmConfig struct {
+	concurrency int
+	interval    time.Duration // between starting items, 0 for no limit
+}
+
+// WithWarmConcurrency sets how many items are loaded at once.
+func WithWarmConcurrency(n int) WarmOption {
+	return func(cfg *warmConfig) {
+		cfg.concurrency = n
+	}
+}
+
+// WithWarmRate limits warming to perSecond items a second, so warming doesn't overload the
+// origin, or the store the cache writes through to.
+func WithWarmRate(perSecond float64) WarmOption {
+	return func(cfg *warmConfig) {
+		if perSecond > 0 {
+			cfg.interval = time.Duration(float64(time.Second) / perSecond)
+		}
+	}
+}
+
+// Warm adds entries to the cache before it starts taking traffic, with the default TTL. Like
+// loaded values, they're only added to the cache, not written to a store it writes through to.
+//
+// It stops early if ctx is done, returning ctx.Err(). To keep the cache from clearing while a
+// large warm up runs, call PauseEviction first.
+func (c *Cache) Warm(ctx context.Context, entries map[string]any, opts ...WarmOption) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	return c.warm(ctx, keys, opts, func(ctx context.Context, key string) error {
+		return c.setLocal(key, entries[key], c.expiresAt(time.Duration(c.defaultTTL.Load())), true)
+	})
+}
+
+// WarmFunc loads keys with loader, or the loader from WithLoader if it's nil, before the cache
+// starts taking traffic. Keys that are already cached aren't loaded again. Loads go through the
+// same timeouts, retries and negative caching as Get's.
+//
+// Every load is attempted even if some fail, and their errors are returned together, apart
+// from ErrNotFound. It stops early if ctx is done.
+func (c *Cache) WarmFunc(ctx context.Context, keys []string, loader LoaderFunc, opts ...WarmOption) error {
+	if loader == nil {
+		loader = c.loader
+	}
+	if loader == nil {
+		return ErrNoLoader
+	}
+
+	return c.warm(ctx, keys, opts, func(ctx context.Context, key string) error {
+		if _, err := c.load(ctx, key, loader, false); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("cache: warming %q: %w", key, err)
+		}
+		return nil
+	})
+}
+
+// warm calls fn for each key, with the concurrency and rate limit from opts.
+func (c *Cache) warm(ctx context.Context, keys []string, opts []WarmOption, fn func(ctx context.Context, key string) error) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+
+	cfg := warmConfig{concurrency: DefaultWarmConcurrency}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.concurrency = max(cfg.concurrency, 1)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, cfg.concurrency)
+	)
+
+	var wait func() error
+	if cfg.interval > 0 {
+		wait = c.rateLimit(ctx, cfg.interval)
+	}
+
+	start := c.now()
+
+loop:
+	for _, key := range keys {
+		if wait != nil {
+			if err := wait(); err != nil {
+				break
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := fn(ctx, key); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.logf("warmed %d keys in %s (%d errors)", len(keys), time.Duration(c.now()-start), len(errs))
+	return errors.Join(errs...)
+}
+
+// rateLimit returns a function that blocks until interval has passed since it last returned,
+// or ctx is done.
+func (c *Cache) rateLimit(ctx context.Context, interval time.Duration) func() error {
+	var next int64
+	return func() error {
+		now := c.now()
+		if next > now {
+			timer := c.newTimer(time.Duration(next - now))
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+			now = next
+		}
+		next = now + int64(interval)
+		return nil
+	}
+}
@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Write-behind defaults, used for any zero WriteBehindConfig fields.
+const (
+	DefaultWriteBehindBatchSize  = 100
+	DefaultWriteBehindInterval   = time.Second
+	DefaultWriteBehindMaxPending = 10000
+)
+
+// Write is a single change queued for the store in write-behind mode.
+type Write struct {
+	Key    string
+	Value  any
+	Delete bool
+}
+
+// BatchStore can be implemented by stores that can apply several writes at once,
+// which write-behind mode uses instead of calling Set and Delete for each write.
+type BatchStore interface {
+	Store
+	WriteBatch(ctx context.Context, writes []Write) error
+}
+
+// WriteBehindConfig configures WithWriteBehind.
+type WriteBehindConfig struct {
+	// BatchSize is the most writes sent to the store at once.
+	BatchSize int
+
+	// Interval is the longest a write waits before being flushed.
+	Interval time.Duration
+
+	// MaxPending bounds the queue. Once it's full, Set and Delete block until there's room.
+	MaxPending int
+}
+
+// WithWriteBehind makes Set, SetWithTTL and Delete update the cache and return straight away,
+// while a background worker writes the changes to store in batches.
+// Several writes to the same key before it's flushed are collapsed into the latest one.
+//
+// Call Flush before shutting down to make sure every queued write has reached the store.
+//
+// Unless the cache also has a loader, missing keys are read from store, after checking for
+// a queued write that hasn't reached it yet.
+func WithWriteBehind(store Store, cfg WriteBehindConfig) Option {
+	return func(c *Cache) {
+		c.writeBehind = newWriteBehind(store, cfg)
+		c.writeBehind.logf = c.logf
+		if c.loader == nil {
+			c.loader = c.writeBehind.load
+		}
+	}
+}
+
+// Flush blocks until every write queued in write-behind mode has been sent to the store,
+// and returns any errors from writes that failed since the last Flush.
+func (c *Cache) Flush() error {
+	if c.writeBehind == nil {
+		return nil
+	}
+	return c.writeBehind.flush()
+}
+
+type writeBehind struct {
+	store      Store
+	batchSize  int
+	interval   time.Duration
+	maxPending int
+	logf       func(format string, args ...any)
+
+	mu       sync.Mutex
+	changed  *sync.Cond // broadcast whenever a batch finishes
+	pending  map[string]Write
+	order    []string
+	inflight map[string]Write
+	errs     []error
+
+	// waitingSince is when the oldest unwritten change was queued, or the last batch
+	// finished if that's more recent. It's zero when nothing is waiting.
+	waitingSince time.Time
+
+	wake chan struct{}
+	done chan struct{} // closed by the last cache using the queue to close
+
+	// refs is how many caches use the queue, more than 1 once it's been cloned.
+	refs atomic.Int32
+}
+
+func newWriteBehind(store Store, cfg WriteBehindConfig) *writeBehind {
+	wb := &writeBehind{
+		store:      store,
+		batchSize:  cfg.BatchSize,
+		interval:   cfg.Interval,
+		maxPending: cfg.MaxPending,
+		pending:    make(map[string]Write),
+		inflight:   make(map[string]Write),
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	wb.changed = sync.NewCond(&wb.mu)
+	wb.refs.Store(1)
+
+	if wb.batchSize <= 0 {
+		wb.batchSize = DefaultWriteBehindBatchSize
+	}
+	if wb.interval <= 0 {
+		wb.interval = DefaultWriteBehindInterval
+	}
+	if wb.maxPending <= 0 {
+		wb.maxPending = DefaultWriteBehindMaxPending
+	}
+
+	go wb.run()
+
+	return wb
+}
+
+// enqueue queues w, replacing any write to the same key that hasn't been flushed yet.
+func (wb *writeBehind) enqueue(w Write) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if _, queued := wb.pending[w.Key]; !queued {
+		for len(wb.pending) >= wb.maxPending {
+			wb.signal()
+			wb.changed.Wait()
+		}
+		wb.order = append(wb.order, w.Key)
+	}
+	wb.pending[w.Key] = w
+
+	if wb.waitingSince.IsZero() {
+		wb.waitingSince = time.Now()
+	}
+
+	if len(wb.pending) >= wb.batchSize {
+		wb.signal()
+	}
+}
+
+// load reads a missing key, preferring a queued write over what's in the store.
+func (wb *writeBehind) load(ctx context.Context, key string) (any, error) {
+	wb.mu.Lock()
+	w, queued := wb.pending[key]
+	if !queued {
+		w, queued = wb.inflight[key]
+	}
+	wb.mu.Unlock()
+
+	if queued {
+		if w.Delete {
+			return nil, ErrNotFound
+		}
+		return w.Value, nil
+	}
+	return wb.store.Get(ctx, key)
+}
+
+func (wb *writeBehind) flush() error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for len(wb.pending) > 0 || len(wb.inflight) > 0 {
+		wb.signal()
+		wb.changed.Wait()
+	}
+
+	err := errors.Join(wb.errs...)
+	wb.errs = nil
+	return err
+}
+
+// signal wakes the worker without blocking if it's already been woken.
+func (wb *writeBehind) signal() {
+	select {
+	case wb.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (wb *writeBehind) run() {
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-wb.wake:
+		case <-wb.done:
+			return
+		}
+
+		for wb.writeBatch() {
+		}
+	}
+}
+
+// writeBatch sends up to batchSize pending writes to the store and reports whether any were sent.
+func (wb *writeBehind) writeBatch() bool {
+	wb.mu.Lock()
+	n := min(len(wb.order), wb.batchSize)
+	if n == 0 {
+		wb.mu.Unlock()
+		return false
+	}
+
+	batch := make([]Write, 0, n)
+	for _, key := range wb.order[:n] {
+		w := wb.pending[key]
+		delete(wb.pending, key)
+		wb.inflight[key] = w
+		batch = append(batch, w)
+	}
+	wb.order = wb.order[n:]
+	wb.mu.Unlock()
+
+	err := wb.write(batch)
+
+	wb.mu.Lock()
+	for _, w := range batch {
+		delete(wb.inflight, w.Key)
+	}
+	if err != nil {
+		wb.logf("error writing %d queued writes to store: %v", len(batch), err)
+		wb.errs = append(wb.errs, err)
+	}
+	if len(wb.pending) == 0 && len(wb.inflight) == 0 {
+		wb.waitingSince = time.Time{}
+	} else {
+		wb.waitingSince = time.Now()
+	}
+	wb.changed.Broadcast()
+	wb.mu.Unlock()
+
+	return true
+}
+
+func (wb *writeBehind) write(batch []Write) error {
+	ctx := context.Background()
+
+	if bs, ok := wb.store.(BatchStore); ok {
+		return bs.WriteBatch(ctx, batch)
+	}
+
+	var errs []error
+	for _, w := range batch {
+		var err error
+		if w.Delete {
+			err = wb.store.Delete(ctx, w.Key)
+		} else {
+			err = wb.store.Set(ctx, w.Key, w.Value)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
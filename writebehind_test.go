@@ -0,0 +1,77 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/radovskyb/self-clearing-in-memory-cache"
+)
+
+// memStore is a Store backed by a map.
+type memStore struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+func newMemStore() *memStore {
+	return &memStore{values: make(map[string]any)}
+}
+
+func (s *memStore) Get(ctx context.Context, key string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, found := s.values[key]
+	if !found {
+		return nil, cache.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *memStore) Set(ctx context.Context, key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+func TestWriteBehindSharedWithClone(t *testing.T) {
+	store := newMemStore()
+	c := cache.New(1<<20, cache.WithLogger(nil), cache.WithWriteBehind(store, cache.WriteBehindConfig{}))
+	clone := c.Clone()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The clone's writes still reach the store once the original has closed.
+	clone.Set("key", "value")
+	flushed := make(chan error)
+	go func() { flushed <- clone.Flush() }()
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("clone's Flush hung after the original closed")
+	}
+	if value, err := store.Get(context.Background(), "key"); err != nil || value != "value" {
+		t.Errorf("store has %v, %v for the clone's write", value, err)
+	}
+
+	clone.Set("after", "value")
+	if err := clone.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(context.Background(), "after"); err != nil {
+		t.Errorf("clone's Close didn't flush its last write: %v", err)
+	}
+}